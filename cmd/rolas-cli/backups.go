@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// backup backs up the database to the backups directory, or to the
+// given file, or lists the backups in the directory.
+func backup(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	list := flags.Bool("list", false, "list the backups instead of making one")
+	keep := flags.Int("keep", model.DefaultKeptBackups, "number of backups to keep in the backups directory")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	arguments := parseFlags(flags, args)
+	if len(arguments) > 1 || (*list && len(arguments) > 0) {
+		return errors.New("usage: rolas-cli backup [-keep n] [-list] [file]")
+	}
+
+	if len(arguments) == 1 {
+		err := database.Backup(arguments[0])
+		if err == nil && !*asJSON {
+			fmt.Println("library backed up to", arguments[0])
+		}
+		return err
+	}
+	backups := model.NewBackups(*keep)
+	var files []*model.BackupFile
+	var err error
+	if *list {
+		files, err = backups.List()
+	} else {
+		var file *model.BackupFile
+		file, err = backups.Create(database)
+		if file != nil {
+			files = []*model.BackupFile{file}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(files)
+	}
+	rows := [][]string{{"TIME", "SIZE", "PATH"}}
+	for _, file := range files {
+		rows = append(rows, []string{file.Time.Format("2006-01-02 15:04:05"),
+			strconv.FormatInt(file.Size, 10), file.Path})
+	}
+	return writeTable(rows)
+}
+
+// restore replaces the database with a backup, given as a file or as the
+// number of a backup in the list of 'backup -list', 1 being the newest.
+// The database is backed up first.
+func restore(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	keep := flags.Int("keep", model.DefaultKeptBackups, "number of backups to keep in the backups directory")
+	arguments := parseFlags(flags, args)
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli restore [-keep n] <file or backup number>")
+	}
+
+	backups := model.NewBackups(*keep)
+	path := arguments[0]
+	if number, err := strconv.Atoi(path); err == nil {
+		files, err := backups.List()
+		if err != nil {
+			return err
+		}
+		if number < 1 || number > len(files) {
+			return fmt.Errorf("there is no backup number %d", number)
+		}
+		path = files[number-1].Path
+	}
+	err := backups.Restore(database, path)
+	if err != nil {
+		return err
+	}
+	fmt.Println("library restored from", path)
+	return nil
+}
+
+// check prints the problems found in the library, and fails if there
+// are any.
+func check(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the problems as JSON")
+	if len(parseFlags(flags, args)) > 0 {
+		return errors.New("check takes no arguments")
+	}
+
+	problems := database.Check()
+	var err error
+	if *asJSON {
+		err = writeJSON(problems)
+	} else if len(problems) > 0 {
+		rows := [][]string{{"PROBLEM", "TABLE", "ID", "DESCRIPTION"}}
+		for _, problem := range problems {
+			id := ""
+			if problem.ID != 0 {
+				id = strconv.FormatInt(problem.ID, 10)
+			}
+			rows = append(rows, []string{problem.Kind, problem.Table, id, problem.Description})
+		}
+		err = writeTable(rows)
+	} else {
+		fmt.Println("no problems found")
+	}
+	if err == nil && len(problems) > 0 {
+		err = fmt.Errorf("%d problems found", len(problems))
+	}
+	return err
+}
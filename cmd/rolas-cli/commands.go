@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// scan mines the rolas under a directory, the Music directory of the
+// user by default, and adds the new ones to the database.
+func scan(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	root := flags.String("root", "", "directory to mine (default ~/Music)")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	if len(parseFlags(flags, args)) > 0 {
+		return errors.New("scan takes no arguments")
+	}
+
+	miner := model.NewMiner()
+	if *root != "" {
+		miner.SetRoot(*root)
+	}
+	miner.Traverse()
+	go miner.Extract()
+	go miner.Populate(database)
+	added := 0
+	for range miner.TrackList {
+		added++
+	}
+
+	count, duration := database.LibraryDuration()
+	if *asJSON {
+		return writeJSON(map[string]interface{}{
+			"root":     miner.Root(),
+			"added":    added,
+			"rolas":    count,
+			"duration": duration.Seconds(),
+		})
+	}
+	fmt.Printf("%d new rolas in %s, %d rolas in the library, %s\n",
+		added, miner.Root(), count, model.FormatDuration(duration))
+	return nil
+}
+
+// search prints the rolas found with a simple search, or with a search
+// in the language of the parser.
+func search(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the rolas as JSON")
+	arguments := parseFlags(flags, args)
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli search [-json] <text or '*~*' query>")
+	}
+
+	rolas := queryRolas(database, database.Search(arguments[0]))
+	if *asJSON {
+		return writeJSON(rolas)
+	}
+	rows := [][]string{{"ID", "TITLE", "ARTIST", "ALBUM", "YEAR", "DURATION"}}
+	for _, rola := range rolas {
+		rows = append(rows, []string{strconv.FormatInt(rola.ID(), 10), rola.Title(), rola.Artist(),
+			rola.Album(), strconv.Itoa(rola.Year()), model.FormatDuration(rola.Duration())})
+	}
+	return writeTable(rows)
+}
+
+// show prints all the information of a rola.
+func show(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the rola as JSON")
+	arguments := parseFlags(flags, args)
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli show [-json] <id>")
+	}
+	id, err := rolaID(database, arguments[0])
+	if err != nil {
+		return err
+	}
+	return printRola(database.QueryRola(id), *asJSON)
+}
+
+// edit changes the tags of a rola in the database; only the tags given
+// as flags are changed.
+func edit(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the edited rola as JSON")
+	texts := map[string]*string{
+		"title":        flags.String("title", "", "title"),
+		"artist":       flags.String("artist", "", "artist"),
+		"album":        flags.String("album", "", "album"),
+		"genre":        flags.String("genre", "", "genre"),
+		"album-artist": flags.String("album-artist", "", "album artist"),
+		"composer":     flags.String("composer", "", "composer"),
+		"comment":      flags.String("comment", "", "comment"),
+		"lyrics":       flags.String("lyrics", "", "lyrics"),
+	}
+	numbers := map[string]*int{
+		"track": flags.Int("track", 0, "track number"),
+		"year":  flags.Int("year", 0, "year"),
+		"disc":  flags.Int("disc", 0, "disc number"),
+		"bpm":   flags.Int("bpm", 0, "beats per minute"),
+	}
+	arguments := parseFlags(flags, args)
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli edit <id> [-title text] [-year n] ...")
+	}
+	id, err := rolaID(database, arguments[0])
+	if err != nil {
+		return err
+	}
+
+	textSetters := map[string]func(*model.Rola, string){
+		"title":        (*model.Rola).SetTitle,
+		"artist":       (*model.Rola).SetArtist,
+		"album":        (*model.Rola).SetAlbum,
+		"genre":        (*model.Rola).SetGenre,
+		"album-artist": (*model.Rola).SetAlbumArtist,
+		"composer":     (*model.Rola).SetComposer,
+		"comment":      (*model.Rola).SetComment,
+		"lyrics":       (*model.Rola).SetLyrics,
+	}
+	numberSetters := map[string]func(*model.Rola, int){
+		"track": (*model.Rola).SetTrack,
+		"year":  (*model.Rola).SetYear,
+		"disc":  (*model.Rola).SetDisc,
+		"bpm":   (*model.Rola).SetBPM,
+	}
+	rola := database.QueryRola(id)
+	changed := false
+	flags.Visit(func(f *flag.Flag) {
+		if setter, ok := textSetters[f.Name]; ok {
+			setter(rola, *texts[f.Name])
+			changed = true
+		}
+		if setter, ok := numberSetters[f.Name]; ok {
+			setter(rola, *numbers[f.Name])
+			changed = true
+		}
+	})
+	if !changed {
+		return errors.New("nothing to edit, see 'rolas-cli edit -h'")
+	}
+	database.UpdateRola(rola)
+	return printRola(database.QueryRola(id), *asJSON)
+}
+
+// export writes the rolas of the library, or the ones found by a search,
+// as JSON or CSV.   With -all it writes the whole library instead, in
+// the format read by the import command; as CSV it is a directory with
+// a file for every table.
+func export(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "json", "output format, json or csv")
+	all := flags.Bool("all", false, "export the whole library, to be imported back")
+	output := flags.String("o", "", "file to write, or directory for the CSV files of -all (default standard output)")
+	arguments := parseFlags(flags, args)
+	if len(arguments) > 1 || (*all && len(arguments) > 0) {
+		return errors.New("usage: rolas-cli export [-format json|csv] [-o path] [-all | text or '*~*' query]")
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *all {
+		return exportLibrary(database, *format, *output)
+	}
+	text := ""
+	if len(arguments) == 1 {
+		text = arguments[0]
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	rolas := queryRolas(database, database.Search(text))
+	if *format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rolas)
+	}
+	writer := csv.NewWriter(out)
+	writer.Write(csvHeader)
+	for _, rola := range rolas {
+		writer.Write(csvRow(rola))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportLibrary writes the whole library as JSON, to a file or the
+// standard output, or as CSV files in a directory.
+func exportLibrary(database *model.Database, format, output string) error {
+	dump := database.Export()
+	if format == "csv" {
+		if output == "" {
+			return errors.New("the CSV files of -all need a directory, given with -o")
+		}
+		return dump.WriteCSV(output)
+	}
+	if output == "" {
+		return dump.WriteJSON(os.Stdout)
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = dump.WriteJSON(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// importLibrary reads a library written by 'export -all', from a JSON
+// file or a directory of CSV files, and adds it to the database.
+func importLibrary(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	policyName := flags.String("policy", "skip", "what to do with the records already in the library: skip, overwrite or merge")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	arguments := parseFlags(flags, args)
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli import [-policy skip|overwrite|merge] <file.json or directory>")
+	}
+	policy, ok := model.ConflictPolicies[*policyName]
+	if !ok {
+		return fmt.Errorf("unknown policy %q", *policyName)
+	}
+
+	dump, err := readDump(arguments[0])
+	if err != nil {
+		return err
+	}
+	report, err := database.Import(dump, policy)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(map[string]interface{}{
+			"added":   report.Added,
+			"skipped": report.Skipped,
+			"updated": report.Updated,
+		})
+	}
+	rows := [][]string{{"TABLE", "ADDED", "SKIPPED", "UPDATED"}}
+	for _, table := range model.DumpTables {
+		rows = append(rows, []string{table, strconv.Itoa(report.Added[table]),
+			strconv.Itoa(report.Skipped[table]), strconv.Itoa(report.Updated[table])})
+	}
+	return writeTable(rows)
+}
+
+// readDump reads a library written by 'export -all': a directory of CSV
+// files, or a JSON file.
+func readDump(path string) (*model.Dump, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return model.ReadCSVDump(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return model.ReadJSONDump(file)
+}
+
+// stats prints the number of rolas, performers, albums, persons and
+// groups in the library, their total duration, and the rolas of each
+// genre.
+func stats(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the statistics as JSON")
+	if len(parseFlags(flags, args)) > 0 {
+		return errors.New("stats takes no arguments")
+	}
+
+	library := database.LibraryStats()
+	if *asJSON {
+		return writeJSON(map[string]interface{}{
+			"rolas":      library.Rolas,
+			"performers": library.Performers,
+			"albums":     library.Albums,
+			"persons":    library.Persons,
+			"groups":     library.Groups,
+			"duration":   library.Duration.Seconds(),
+			"genres":     library.Genres,
+		})
+	}
+	rows := [][]string{
+		{"Rolas", strconv.Itoa(library.Rolas)},
+		{"Duration", model.FormatDuration(library.Duration)},
+		{"Performers", strconv.Itoa(library.Performers)},
+		{"Persons", strconv.Itoa(library.Persons)},
+		{"Groups", strconv.Itoa(library.Groups)},
+		{"Albums", strconv.Itoa(library.Albums)},
+	}
+	err := writeTable(rows)
+	if err != nil || len(library.Genres) == 0 {
+		return err
+	}
+
+	fmt.Println()
+	return writeGenres(library.Genres)
+}
+
+// credits prints the performers credited in a rola or, with -split,
+// credits again the performers of every rola with the splitting rules.
+func credits(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("credits", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the credits as JSON")
+	split := flags.Bool("split", false, "credit again the performers of every rola")
+	arguments := parseFlags(flags, args)
+	if *split {
+		if len(arguments) > 0 {
+			return errors.New("usage: rolas-cli credits -split")
+		}
+		changed := database.SplitCredits(model.GetCreditSplitter())
+		if *asJSON {
+			return writeJSON(map[string]int{"changed": changed})
+		}
+		fmt.Printf("%d rolas credited again\n", changed)
+		return nil
+	}
+	if len(arguments) != 1 {
+		return errors.New("usage: rolas-cli credits [-json] <id>")
+	}
+	id, err := rolaID(database, arguments[0])
+	if err != nil {
+		return err
+	}
+	list := database.QueryCredits(id)
+	if *asJSON {
+		return writeJSON(list)
+	}
+	rows := [][]string{{"PERFORMER", "CREDIT"}}
+	for _, credit := range list {
+		rows = append(rows, []string{credit.Name, credit.Credit})
+	}
+	return writeTable(rows)
+}
+
+// rolaID parses the ID of a rola and checks that it is in the database.
+func rolaID(database *model.Database, text string) (int64, error) {
+	id, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rola id %q", text)
+	}
+	if database.QueryPath(id) == "" {
+		return 0, fmt.Errorf("no rola with id %d", id)
+	}
+	return id, nil
+}
+
+// queryRolas reads from the database the Rolas with the given IDs.
+func queryRolas(database *model.Database, ids []int64) []*model.Rola {
+	rolas := make([]*model.Rola, 0, len(ids))
+	for _, id := range ids {
+		rolas = append(rolas, database.QueryRola(id))
+	}
+	return rolas
+}
+
+// printRola writes all the fields of a Rola, as a two columns table
+// followed by the lyrics, or as JSON.
+func printRola(rola *model.Rola, asJSON bool) error {
+	if asJSON {
+		return writeJSON(rola)
+	}
+	rows := make([][]string, 0)
+	for _, field := range fields(rola) {
+		rows = append(rows, []string{field[0] + ":", field[1]})
+	}
+	err := writeTable(rows)
+	if err != nil || rola.Lyrics() == "" {
+		return err
+	}
+	fmt.Printf("\n%s\n", rola.Lyrics())
+	return nil
+}
+
+// infer previews the tags inferred from the paths of the given rolas, or
+// of every rola, for the fields still missing; with -apply it saves them.
+func infer(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("infer", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the inferred tags as JSON")
+	apply := flags.Bool("apply", false, "save the inferred tags")
+	arguments := parseFlags(flags, args)
+	ids := make([]int64, 0, len(arguments))
+	for _, argument := range arguments {
+		id, err := rolaID(database, argument)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		ids = database.QueryCustom("SELECT id_rola FROM rolas WHERE hidden = 0 ORDER BY id_rola")
+	}
+
+	inferences := database.InferTags(ids, model.GetFilenameRules())
+	if *apply {
+		for _, inference := range inferences {
+			database.UpdateRola(inference.Rola)
+		}
+	}
+	if *asJSON {
+		return writeJSON(inferences)
+	}
+	rows := [][]string{{"ID", "PATH", "INFERRED"}}
+	for _, inference := range inferences {
+		rows = append(rows, []string{strconv.FormatInt(inference.Rola.ID(), 10), inference.Rola.Path(),
+			inference.String()})
+	}
+	return writeTable(rows)
+}
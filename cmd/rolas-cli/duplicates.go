@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// duplicates prints the groups of rolas taken for copies of the same
+// song, the best copy of each group first.   With -keep and one of
+// -delete, -hide or -move it keeps a rola and deletes, hides or moves
+// the rolas given as arguments; with -unhide it shows the given hidden
+// rolas again, or lists the hidden rolas if none is given.
+func duplicates(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the duplicates as JSON")
+	tolerance := flags.Duration("tolerance", model.DefaultDuplicateTolerance,
+		"largest difference of duration between copies with the same tags")
+	keep := flags.Int64("keep", 0, "id of the rola to keep")
+	remove := flags.Bool("delete", false, "delete the files of the other rolas and remove them")
+	hide := flags.Bool("hide", false, "hide the other rolas")
+	move := flags.String("move", "", "move the files of the other rolas to this directory and hide them")
+	unhide := flags.Bool("unhide", false, "show the given hidden rolas again, or list them")
+	arguments := parseFlags(flags, args)
+
+	if *unhide {
+		if len(arguments) == 0 {
+			rolas := queryRolas(database, database.QueryHidden())
+			if *asJSON {
+				return writeJSON(rolas)
+			}
+			rows := [][]string{{"ID", "TITLE", "ARTIST", "ALBUM", "PATH"}}
+			for _, rola := range rolas {
+				rows = append(rows, []string{strconv.FormatInt(rola.ID(), 10), rola.Title(), rola.Artist(),
+					rola.Album(), rola.Path()})
+			}
+			return writeTable(rows)
+		}
+		for _, argument := range arguments {
+			id, err := rolaID(database, argument)
+			if err != nil {
+				return err
+			}
+			database.SetHidden(id, false)
+		}
+		return nil
+	}
+
+	if *keep != 0 {
+		actions := 0
+		action := model.DuplicateDelete
+		for _, chosen := range []struct {
+			set    bool
+			action model.DuplicateAction
+		}{{*remove, model.DuplicateDelete}, {*hide, model.DuplicateHide}, {*move != "", model.DuplicateMove}} {
+			if chosen.set {
+				actions++
+				action = chosen.action
+			}
+		}
+		if actions != 1 || len(arguments) == 0 {
+			return errors.New("usage: rolas-cli duplicates -keep <id> (-delete | -hide | -move <dir>) <id>...")
+		}
+		if _, err := rolaID(database, strconv.FormatInt(*keep, 10)); err != nil {
+			return err
+		}
+		others := make([]int64, 0, len(arguments))
+		for _, argument := range arguments {
+			id, err := rolaID(database, argument)
+			if err != nil {
+				return err
+			}
+			others = append(others, id)
+		}
+		return database.ResolveDuplicates(*keep, others, action, *move)
+	}
+
+	if len(arguments) > 0 {
+		return errors.New("duplicates takes no arguments without -keep or -unhide")
+	}
+	groups := database.FindDuplicates(*tolerance)
+	if *asJSON {
+		return writeJSON(groups)
+	}
+	rows := [][]string{{"ID", "TITLE", "ARTIST", "FORMAT", "BITRATE", "DURATION", "SIZE", "PATH"}}
+	for i, group := range groups {
+		if i > 0 {
+			rows = append(rows, []string{})
+		}
+		rows = append(rows, []string{"#" + strconv.Itoa(i+1), strings.Join(group.Reasons, ", ")})
+		for _, candidate := range group.Candidates {
+			rows = append(rows, []string{
+				strconv.FormatInt(candidate.ID, 10),
+				candidate.Title,
+				candidate.Artist,
+				candidate.Format,
+				strconv.Itoa(candidate.Bitrate) + " kbps",
+				model.FormatDuration(candidate.Duration),
+				fmt.Sprintf("%.1f MB", float64(candidate.Size)/(1<<20)),
+				candidate.Path,
+			})
+		}
+	}
+	return writeTable(rows)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// fingerprint computes the acoustic fingerprints of the rolas that have
+// none.   With -match it then lists, for each untagged rola, the tagged
+// rola with the same audio whose tags could be copied; with -apply it
+// copies them, to the untagged rolas given as arguments or to all.
+func fingerprint(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	match := flags.Bool("match", false, "propose tags for the untagged rolas")
+	apply := flags.Bool("apply", false, "copy the proposed tags to the untagged rolas")
+	tolerance := flags.Duration("tolerance", model.DefaultDuplicateTolerance,
+		"largest difference of duration between matching rolas")
+	arguments := parseFlags(flags, args)
+	if len(arguments) > 0 && !*apply {
+		return errors.New("usage: rolas-cli fingerprint [-match] [-apply [id...]]")
+	}
+	ids := make(map[int64]bool)
+	for _, argument := range arguments {
+		id, err := rolaID(database, argument)
+		if err != nil {
+			return err
+		}
+		ids[id] = true
+	}
+
+	computed := database.ComputeFingerprints(func(done, total int) {
+		if !*asJSON {
+			fmt.Fprintf(os.Stderr, "\rfingerprinting %d/%d", done, total)
+		}
+	})
+	if !*asJSON && computed > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if !*match && !*apply {
+		if *asJSON {
+			return writeJSON(map[string]int{"computed": computed})
+		}
+		fmt.Printf("%d fingerprints computed\n", computed)
+		return nil
+	}
+
+	proposals := make([]*model.TagProposal, 0)
+	for _, proposal := range database.ProposeTags(*tolerance) {
+		if len(ids) == 0 || ids[proposal.RolaID] {
+			proposals = append(proposals, proposal)
+		}
+	}
+	if *apply {
+		for _, proposal := range proposals {
+			database.CopyTags(proposal.Tags.ID(), proposal.RolaID)
+		}
+	}
+	if *asJSON {
+		return writeJSON(proposals)
+	}
+	rows := [][]string{{"ID", "PATH", "SIMILARITY", "FROM", "TITLE", "ARTIST", "ALBUM"}}
+	for _, proposal := range proposals {
+		rows = append(rows, []string{
+			strconv.FormatInt(proposal.RolaID, 10),
+			proposal.Path,
+			fmt.Sprintf("%.0f%%", 100*proposal.Similarity),
+			strconv.FormatInt(proposal.Tags.ID(), 10),
+			proposal.Tags.Title(),
+			proposal.Tags.Artist(),
+			proposal.Tags.Album(),
+		})
+	}
+	err := writeTable(rows)
+	if err == nil && *apply {
+		fmt.Printf("\n%d rolas tagged\n", len(proposals))
+	}
+	return err
+}
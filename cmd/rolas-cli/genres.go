@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// genres prints the number of rolas of each genre or, with -normalize,
+// normalizes the genres of every rola first, decoding the ID3 genre
+// codes of the rolas added before they were decoded and resolving the
+// aliases.   With -tree it prints the hierarchy of the genres instead.
+func genres(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("genres", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the genres as JSON")
+	normalize := flags.Bool("normalize", false, "normalize the genres of every rola")
+	tree := flags.Bool("tree", false, "show the genres with their subgenres and aliases")
+	if len(parseFlags(flags, args)) > 0 {
+		return errors.New("genres takes no arguments")
+	}
+	if *tree {
+		return writeGenreTree(database, *asJSON)
+	}
+	changed := 0
+	if *normalize {
+		changed = database.NormalizeGenres()
+	}
+	library := database.LibraryStats()
+	if *asJSON {
+		return writeJSON(map[string]interface{}{"changed": changed, "genres": library.Genres})
+	}
+	if *normalize {
+		fmt.Printf("%d rolas normalized\n\n", changed)
+	}
+	return writeGenres(library.Genres)
+}
+
+// alias makes a name an alias of one or more genres, or removes it with
+// -remove, and normalizes the genres of the rolas again.
+func alias(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("alias", flag.ExitOnError)
+	remove := flags.Bool("remove", false, "remove the alias")
+	arguments := parseFlags(flags, args)
+	switch {
+	case *remove && len(arguments) == 1:
+		database.RemoveGenreAlias(arguments[0])
+	case !*remove && len(arguments) >= 2:
+		err := database.SetGenreAlias(arguments[0], arguments[1:])
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("usage: rolas-cli alias <alias> <genre>... or rolas-cli alias -remove <alias>")
+	}
+	fmt.Printf("%d rolas normalized\n", database.NormalizeGenres())
+	return nil
+}
+
+// subgenre makes a genre a subgenre of another, or a top genre if no
+// parent is given.
+func subgenre(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("subgenre", flag.ExitOnError)
+	arguments := parseFlags(flags, args)
+	if len(arguments) < 1 || len(arguments) > 2 {
+		return errors.New("usage: rolas-cli subgenre <genre> [parent]")
+	}
+	parent := ""
+	if len(arguments) == 2 {
+		parent = arguments[1]
+	}
+	return database.SetGenreParent(arguments[0], parent)
+}
+
+// writeGenres writes a table with the number of rolas of each genre,
+// from the most common genre to the least.
+func writeGenres(counts map[string]int) error {
+	genres := make([]string, 0, len(counts))
+	for genre := range counts {
+		genres = append(genres, genre)
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if counts[genres[i]] != counts[genres[j]] {
+			return counts[genres[i]] > counts[genres[j]]
+		}
+		return genres[i] < genres[j]
+	})
+	rows := [][]string{{"GENRE", "ROLAS"}}
+	for _, genre := range genres {
+		rows = append(rows, []string{genre, strconv.Itoa(counts[genre])})
+	}
+	return writeTable(rows)
+}
+
+// writeGenreTree writes the genres of the database, each under the genre
+// it is a subgenre of, with their aliases.
+func writeGenreTree(database *model.Database, asJSON bool) error {
+	genres, aliases := database.QueryGenreTree()
+	if asJSON {
+		records := make([]map[string]interface{}, 0, len(genres))
+		for _, genre := range genres {
+			records = append(records, map[string]interface{}{
+				"id":        genre.ID,
+				"name":      genre.Name,
+				"parent_id": genre.ParentID,
+				"aliases":   aliases[genre.ID],
+			})
+		}
+		return writeJSON(records)
+	}
+	children := make(map[int64][]*model.GenreRecord)
+	for _, genre := range genres {
+		children[genre.ParentID] = append(children[genre.ParentID], genre)
+	}
+	rows := [][]string{{"GENRE", "ALIASES"}}
+	var walk func(parentID int64, depth int)
+	walk = func(parentID int64, depth int) {
+		for _, genre := range children[parentID] {
+			rows = append(rows, []string{strings.Repeat("  ", depth) + genre.Name,
+				strings.Join(aliases[genre.ID], ", ")})
+			walk(genre.ID, depth+1)
+		}
+	}
+	walk(0, 0)
+	return writeTable(rows)
+}
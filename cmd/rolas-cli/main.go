@@ -0,0 +1,146 @@
+// Command rolas-cli manages the library of rolas without a display.   It
+// uses the same database as the graphical application, and its output
+// is either a table or JSON, so it can be used in scripts.
+//
+// Usage:
+//
+//	rolas-cli [-db path] command [flags] [arguments]
+//
+// The commands are:
+//
+//	scan        mine the rolas under a directory and add them to the library
+//	search      search rolas, with a simple search or a '*~*' query
+//	show        show all the information of a rola
+//	edit        edit the tags of a rola in the database
+//	export      write the rolas of the library as JSON or CSV
+//	import      add a library written by 'export -all' to this one
+//	stats       show the number of rolas, performers, albums and genres
+//	backup      back up the database, keeping the newest backups
+//	restore     replace the database with one of its backups
+//	check       look for problems in the database and the library
+//	credits     show the performers credited in a rola, or credit them again
+//	genres      show the rolas of each genre, or normalize the genres
+//	alias       make a name an alias of one or more genres
+//	subgenre    make a genre a subgenre of another
+//	duplicates  find copies of the same rola and keep one of them
+//	fingerprint compute acoustic fingerprints and propose tags for untagged rolas
+//	infer       infer the missing tags of rolas from their paths
+//	organize    move and rename the files of rolas after a template
+//	missing     list the rolas whose files are missing, and relink or remove them
+//	replaygain  measure the loudness of rolas and albums, and write their ReplayGain tags
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// A command is a subcommand of rolas-cli; run receives the arguments
+// after the name of the command.
+type command struct {
+	description string
+	run         func(database *model.Database, args []string) error
+}
+
+var commands = map[string]*command{
+	"scan":        {"mine the rolas under a directory and add them to the library", scan},
+	"search":      {"search rolas, with a simple search or a '*~*' query", search},
+	"show":        {"show all the information of a rola", show},
+	"edit":        {"edit the tags of a rola in the database", edit},
+	"export":      {"write the rolas of the library as JSON or CSV", export},
+	"import":      {"add a library written by 'export -all' to this one", importLibrary},
+	"stats":       {"show the number of rolas, performers, albums and genres", stats},
+	"backup":      {"back up the database, keeping the newest backups", backup},
+	"restore":     {"replace the database with one of its backups", restore},
+	"check":       {"look for problems in the database and the library", check},
+	"credits":     {"show the performers credited in a rola, or credit them again", credits},
+	"genres":      {"show the rolas of each genre, or normalize the genres", genres},
+	"alias":       {"make a name an alias of one or more genres", alias},
+	"subgenre":    {"make a genre a subgenre of another", subgenre},
+	"duplicates":  {"find copies of the same rola and keep one of them", duplicates},
+	"fingerprint": {"compute acoustic fingerprints and propose tags for untagged rolas", fingerprint},
+	"infer":       {"infer the missing tags of rolas from their paths", infer},
+	"organize":    {"move and rename the files of rolas after a template", organize},
+	"missing":     {"list the rolas whose files are missing, and relink or remove them", missing},
+	"replaygain":  {"measure the loudness of rolas and albums, and write their ReplayGain tags", replayGain},
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the command in the arguments of rolas-cli and returns the
+// exit status, once the database is closed.
+func run() int {
+	dbPath := flag.String("db", "", "path of the database (default ~/.cache/rolas/rolas.db)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		return 2
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "rolas-cli: unknown command %q\n", flag.Arg(0))
+		usage()
+		return 2
+	}
+
+	var database *model.Database
+	var exists bool
+	if *dbPath == "" {
+		database, exists = model.NewDatabase()
+	} else {
+		database, exists = model.NewDatabaseAt(*dbPath)
+	}
+	defer database.Database.Close()
+	if !exists {
+		database.CreateDB()
+	}
+	database.MigrateDB()
+	database.LoadDB()
+
+	err := cmd.run(database, flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rolas-cli:", err)
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rolas-cli [-db path] command [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'rolas-cli command -h' for the flags of a command.")
+}
+
+// parseFlags parses the flags of a command, which may come before or
+// after its arguments, and returns the arguments.
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	arguments := make([]string, 0)
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return arguments
+		}
+		arguments = append(arguments, args[0])
+		args = args[1:]
+	}
+}
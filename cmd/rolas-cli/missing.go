@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// missing looks for the files of the rolas and lists the rolas whose
+// files are missing.   With -find it also lists the files under the root
+// that may be theirs; with -relink it relinks each rola to its likeliest
+// file, or the rola given as the first argument to the file given as the
+// second; with -remove it removes the given rolas, or all of them, from
+// the library.
+func missing(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("missing", flag.ExitOnError)
+	root := flags.String("root", "", "directory to search for the missing files (default ~/Music)")
+	find := flags.Bool("find", false, "search the root for the missing files")
+	relink := flags.Bool("relink", false, "relink the rolas to the files found")
+	remove := flags.Bool("remove", false, "remove the rolas from the library")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	arguments := parseFlags(flags, args)
+	if (*relink && *remove) || (len(arguments) > 0 && !*remove && !(*relink && len(arguments) == 2)) {
+		return errors.New("usage: rolas-cli missing [-root DIR] [-find] [-relink [id file]] [-remove [id...]]")
+	}
+	if *root == "" {
+		*root = model.NewMiner().Root()
+	}
+
+	database.CheckMissing()
+	rolas := database.QueryMissing()
+	if *relink && len(arguments) == 2 {
+		id, err := rolaID(database, arguments[0])
+		if err != nil {
+			return err
+		}
+		err = database.Relink(id, arguments[1])
+		if err == nil && !*asJSON {
+			fmt.Println("rola", id, "relinked to", arguments[1])
+		}
+		return err
+	}
+
+	if *remove {
+		ids := make(map[int64]bool)
+		for _, argument := range arguments {
+			id, err := rolaID(database, argument)
+			if err != nil {
+				return err
+			}
+			ids[id] = true
+		}
+		removed := make([]*model.MissingRola, 0)
+		for _, rola := range rolas {
+			if len(ids) == 0 || ids[rola.ID] {
+				database.RemoveRola(rola.ID)
+				removed = append(removed, rola)
+			}
+		}
+		rolas = removed
+	} else if *find || *relink {
+		database.FindRelinks(rolas, []string{*root})
+	}
+
+	relinked := 0
+	if *relink {
+		used := make(map[string]bool)
+		for _, rola := range rolas {
+			if best := rola.Best(); best != nil && !used[best.Path] {
+				used[best.Path] = true
+				err := database.Relink(rola.ID, best.Path)
+				if err != nil {
+					return err
+				}
+				relinked++
+			}
+		}
+	}
+	if *asJSON {
+		return writeJSON(rolas)
+	}
+	rows := [][]string{{"ID", "TITLE", "ARTIST", "PATH", "FILES"}}
+	for _, rola := range rolas {
+		candidates := make([]string, len(rola.Candidates))
+		for i, candidate := range rola.Candidates {
+			candidates[i] = candidate.String()
+		}
+		rows = append(rows, []string{strconv.FormatInt(rola.ID, 10), rola.Title, rola.Artist, rola.Path,
+			strings.Join(candidates, "; ")})
+	}
+	err := writeTable(rows)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *remove:
+		fmt.Printf("\n%d rolas removed\n", len(rolas))
+	case *relink:
+		fmt.Printf("\n%d of %d rolas relinked\n", relinked, len(rolas))
+	default:
+		fmt.Printf("\n%d rolas with missing files\n", len(rolas))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// organize moves and renames the files of the rolas under the root, or
+// of the rolas given as arguments, after a template.   It only shows the
+// moves unless -apply is given; the moves applied are journaled, so that
+// -undo can move the files back, the latest run or the given one in the
+// list of -journals, 1 being the newest.
+func organize(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("organize", flag.ExitOnError)
+	root := flags.String("root", "", "directory to organize (default ~/Music)")
+	template := flags.String("template", model.DefaultOrganizeTemplate, "template of the new paths, relative to the root")
+	apply := flags.Bool("apply", false, "move the files instead of showing the moves")
+	undo := flags.Bool("undo", false, "move back the files moved by the latest or the given run")
+	list := flags.Bool("journals", false, "list the runs that can be undone")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	arguments := parseFlags(flags, args)
+	if (*undo && len(arguments) > 1) || (*list && len(arguments) > 0) {
+		return errors.New("usage: rolas-cli organize [-template T] [-root DIR] [-apply] [id...] | -undo [n] | -journals")
+	}
+	if *root == "" {
+		*root = model.NewMiner().Root()
+	}
+	journals := model.NewJournals()
+
+	if *undo || *list {
+		runs, err := journals.List()
+		if err != nil {
+			return err
+		}
+		if *list {
+			return writeJournals(runs, *asJSON)
+		}
+		number := 1
+		if len(arguments) == 1 {
+			number, err = strconv.Atoi(arguments[0])
+			if err != nil {
+				return fmt.Errorf("invalid run number %q", arguments[0])
+			}
+		}
+		if number < 1 || number > len(runs) {
+			return fmt.Errorf("there is no run number %d", number)
+		}
+		err = database.UndoOrganize(runs[number-1])
+		if err == nil && !*asJSON {
+			fmt.Println("undone the run of", runs[number-1].Time.Format("2006-01-02 15:04:05"))
+		}
+		return err
+	}
+
+	ids := make([]int64, 0, len(arguments))
+	for _, argument := range arguments {
+		id, err := rolaID(database, argument)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	moves, err := database.PlanOrganize(*root, *template, ids)
+	if err != nil {
+		return err
+	}
+	if *apply && len(moves) > 0 {
+		_, err = database.Organize(journals, *root, *template, moves)
+		if err != nil {
+			return err
+		}
+	}
+	if *asJSON {
+		return writeJSON(moves)
+	}
+	rows := [][]string{{"ID", "FROM", "TO"}}
+	for _, move := range moves {
+		rows = append(rows, []string{strconv.FormatInt(move.RolaID, 10),
+			relativePath(*root, move.From), relativePath(*root, move.To)})
+	}
+	err = writeTable(rows)
+	if err == nil {
+		if *apply {
+			fmt.Printf("\n%d rolas moved\n", len(moves))
+		} else {
+			fmt.Printf("\n%d rolas to move; use -apply to move them\n", len(moves))
+		}
+	}
+	return err
+}
+
+// writeJournals writes the runs of the organizer that can be undone.
+func writeJournals(runs []*model.OrganizeJournal, asJSON bool) error {
+	if asJSON {
+		return writeJSON(runs)
+	}
+	rows := [][]string{{"RUN", "TIME", "MOVES", "ROOT", "TEMPLATE"}}
+	for i, run := range runs {
+		rows = append(rows, []string{strconv.Itoa(i + 1), run.Time.Format("2006-01-02 15:04:05"),
+			strconv.Itoa(len(run.Moves)), run.Root, run.Template})
+	}
+	return writeTable(rows)
+}
+
+// relativePath returns a path relative to the root, if it is under it.
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
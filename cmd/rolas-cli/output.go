@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// fields returns the names and values of the fields of a Rola, in the
+// order they are shown by the show command; the file and the offsets
+// are only shown for the tracks of CUE sheets.
+func fields(rola *model.Rola) [][2]string {
+	shown := [][2]string{
+		{"ID", strconv.FormatInt(rola.ID(), 10)},
+		{"Title", rola.Title()},
+		{"Artist", rola.Artist()},
+		{"Album", rola.Album()},
+		{"Genre", rola.Genre()},
+		{"Track", strconv.Itoa(rola.Track())},
+		{"Year", strconv.Itoa(rola.Year())},
+		{"Disc", strconv.Itoa(rola.Disc())},
+		{"Album artist", rola.AlbumArtist()},
+		{"Composer", rola.Composer()},
+		{"BPM", strconv.Itoa(rola.BPM())},
+		{"Comment", rola.Comment()},
+		{"Duration", model.FormatDuration(rola.Duration())},
+		{"Bitrate", strconv.Itoa(rola.Bitrate()) + " kbps"},
+		{"Sample rate", strconv.Itoa(rola.SampleRate()) + " Hz"},
+		{"Channels", strconv.Itoa(rola.Channels())},
+		{"VBR", strconv.FormatBool(rola.VBR())},
+		{"Artwork", rola.Artwork()},
+		{"Path", rola.Path()},
+	}
+	if rola.IsCueTrack() {
+		end := "end of the file"
+		if rola.End() > 0 {
+			end = model.FormatDuration(rola.End())
+		}
+		shown = append(shown, [2]string{"File", rola.File()},
+			[2]string{"Start", model.FormatDuration(rola.Start())}, [2]string{"End", end})
+	}
+	if gain := rola.ReplayGain(); gain.TrackLoudness != 0 {
+		shown = append(shown, [2]string{"Track gain", formatGain(gain.TrackGain(), gain.TrackPeak)})
+		if gain.AlbumLoudness != 0 {
+			shown = append(shown, [2]string{"Album gain", formatGain(gain.AlbumGain(), gain.AlbumPeak)})
+		}
+	}
+	return shown
+}
+
+// csvHeader holds the names of the columns written by the export
+// command in CSV, which are the names of the JSON fields.
+var csvHeader = []string{"id", "title", "artist", "album", "genre", "track", "year",
+	"disc", "album_artist", "composer", "bpm", "comment", "duration", "bitrate",
+	"sample_rate", "channels", "vbr", "artwork", "path", "lyrics"}
+
+// csvRow returns the values of the fields of a Rola, in the order of the
+// csvHeader.
+func csvRow(rola *model.Rola) []string {
+	return []string{
+		strconv.FormatInt(rola.ID(), 10), rola.Title(), rola.Artist(), rola.Album(), rola.Genre(),
+		strconv.Itoa(rola.Track()), strconv.Itoa(rola.Year()), strconv.Itoa(rola.Disc()),
+		rola.AlbumArtist(), rola.Composer(), strconv.Itoa(rola.BPM()), rola.Comment(),
+		strconv.FormatFloat(rola.Duration().Seconds(), 'f', 3, 64), strconv.Itoa(rola.Bitrate()),
+		strconv.Itoa(rola.SampleRate()), strconv.Itoa(rola.Channels()),
+		strconv.FormatBool(rola.VBR()), rola.Artwork(), rola.Path(), rola.Lyrics(),
+	}
+}
+
+// writeJSON writes a value to the standard output as indented JSON.
+func writeJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeTable writes rows of cells to the standard output as a table,
+// with the first row as the header.
+func writeTable(rows [][]string) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(writer, "\t")
+			}
+			fmt.Fprint(writer, cell)
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// replayGain measures the loudness and the peak of the rolas whose
+// loudness is not known, of every rola with -all, or of the given rolas,
+// together with their albums, and lists them.   With -write it also
+// writes the ReplayGain tags into their files.
+func replayGain(database *model.Database, args []string) error {
+	flags := flag.NewFlagSet("replaygain", flag.ExitOnError)
+	all := flags.Bool("all", false, "measure every rola again")
+	write := flags.Bool("write", false, "write the ReplayGain tags into the files")
+	asJSON := flags.Bool("json", false, "write the result as JSON")
+	arguments := parseFlags(flags, args)
+
+	var ids []int64
+	if *all {
+		ids = database.QueryCustom("SELECT id_rola FROM rolas WHERE hidden = 0")
+	}
+	for _, argument := range arguments {
+		id, err := rolaID(database, argument)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	measured := database.ScanReplayGain(ids, func(done, total int) {
+		if !*asJSON {
+			fmt.Fprintf(os.Stderr, "\rmeasuring %d/%d", done, total)
+		}
+	})
+	if !*asJSON && len(measured) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	written := 0
+	if *write {
+		written = database.WriteReplayGainTags(measured)
+	}
+
+	rolas := make([]*model.Rola, len(measured))
+	for i, id := range measured {
+		rolas[i] = database.QueryRola(id)
+	}
+	if *asJSON {
+		return writeJSON(rolas)
+	}
+	rows := [][]string{{"ID", "TITLE", "ALBUM", "TRACK GAIN", "ALBUM GAIN"}}
+	for _, rola := range rolas {
+		gain := rola.ReplayGain()
+		rows = append(rows, []string{strconv.FormatInt(rola.ID(), 10), rola.Title(), rola.Album(),
+			formatGain(gain.TrackGain(), gain.TrackPeak), formatGain(gain.AlbumGain(), gain.AlbumPeak)})
+	}
+	err := writeTable(rows)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%d rolas measured", len(rolas))
+	if *write {
+		fmt.Printf(", %d files tagged", written)
+	}
+	fmt.Println()
+	return nil
+}
+
+// formatGain formats a gain and its peak as they are written in the
+// ReplayGain tags.
+func formatGain(gain, peak float64) string {
+	return fmt.Sprintf("%+.2f dB, peak %.6f", gain, peak)
+}
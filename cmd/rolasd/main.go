@@ -0,0 +1,115 @@
+// Command rolasd serves the library of rolas over a REST API, described
+// in the documentation of the api package, and over the Subsonic API,
+// described in the documentation of the subsonic package.   By default
+// it only listens on the loopback interface.
+//
+// The REST API only scans the directories given with -music, or the
+// Music directory of the user, and the directories under them.
+//
+// The Subsonic API is served under /rest/ only if the users file
+// exists; see subsonic.LoadUsers for its format.   Its music folders
+// are the directories given with -music, or the Music directory of the
+// user.
+//
+// With -mpd it also serves the library over the protocol of the Music
+// Player Daemon, described in the documentation of the mpd package, on
+// the given address; its files are named relative to the first music
+// folder.
+//
+// The database is backed up every -backups, once a day by default, to
+// the backups directory shared with the graphical application.
+//
+// Usage:
+//
+//	rolasd [-addr host:port] [-db path] [-users path] [-music dir]... [-mpd host:port] [-backups interval]
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/api"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/mpd"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/subsonic"
+)
+
+// folders is a flag that may be given many times.
+type folders []string
+
+func (value *folders) String() string {
+	return strings.Join(*value, ",")
+}
+
+func (value *folders) Set(folder string) error {
+	*value = append(*value, folder)
+	return nil
+}
+
+func main() {
+	home, err := user.Current()
+	if err != nil {
+		log.Fatal("could not retrieve the current user:", err)
+	}
+	var music folders
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	dbPath := flag.String("db", "", "path of the database (default ~/.cache/rolas/rolas.db)")
+	usersPath := flag.String("users", home.HomeDir+"/.config/rolas/users.json", "users of the Subsonic API")
+	flag.Var(&music, "music", "music folder of the scans and the Subsonic API, may be repeated (default ~/Music)")
+	mpdAddr := flag.String("mpd", "", "address of the MPD server, disabled if empty")
+	interval := flag.Duration("backups", model.DefaultBackupInterval, "time between two backups of the database, disabled if 0")
+	flag.Parse()
+
+	var database *model.Database
+	var exists bool
+	if *dbPath == "" {
+		database, exists = model.NewDatabase()
+	} else {
+		database, exists = model.NewDatabaseAt(*dbPath)
+	}
+	defer database.Database.Close()
+	if !exists {
+		database.CreateDB()
+	}
+	database.MigrateDB()
+	database.LoadDB()
+
+	if *interval > 0 {
+		model.NewBackups(model.DefaultKeptBackups).Schedule(database, *interval, func(backup *model.BackupFile, err error) {
+			if err != nil {
+				log.Println("could not back up the database:", err)
+				return
+			}
+			log.Println("database backed up to " + backup.Path)
+		})
+	}
+
+	if len(music) == 0 {
+		music = append(music, model.NewMiner().Root())
+	}
+	http.Handle("/api/", api.NewServer(database, music))
+	log.Println("serving the library on http://" + *addr + "/api/")
+
+	users, err := subsonic.LoadUsers(*usersPath)
+	if os.IsNotExist(err) {
+		log.Println("no users file " + *usersPath + ", the Subsonic API is disabled")
+	} else if err != nil {
+		log.Fatal(err)
+	} else {
+		http.Handle("/rest/", subsonic.NewServer(database, users, music))
+		log.Println("serving the Subsonic API on http://" + *addr + "/rest/")
+	}
+
+	if *mpdAddr != "" {
+		server := mpd.NewServer(database, music[0])
+		go func() {
+			log.Fatal(server.ListenAndServe(*mpdAddr))
+		}()
+		log.Println("serving the MPD protocol on " + *mpdAddr)
+	}
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,148 @@
+// Package api exposes the library of the model over a REST API that
+// speaks JSON.   All the routes are under /api:
+//
+//	GET  /api/rolas                 list rolas (filters, sorting, pagination)
+//	GET  /api/rolas/{id}            a rola
+//	PUT  /api/rolas/{id}            update the tags of a rola
+//	GET  /api/rolas/{id}/audio      the audio file (supports ranges)
+//	GET  /api/rolas/{id}/cover      the picture (?size=64|128|250|500)
+//	GET  /api/search?q=...          search, simple or with a '*~*' query
+//	GET  /api/performers            performers
+//	GET  /api/persons               persons
+//	GET  /api/groups                groups, with their members
+//	GET  /api/albums                albums
+//	GET  /api/albums/{id}/cover     the picture of an album
+//	GET  /api/scans                 scans started since the server started
+//	POST /api/scans                 start a scan ({"root": "/path"})
+//	GET  /api/scans/{id}            the progress of a scan
+//
+// The lists of rolas take the parameters title, artist, album, genre
+// and year to filter, sort (a field, with a leading '-' for descending
+// order), offset and limit.
+//
+// A scan is started with a body of type application/json, so that a web
+// page can not start it with a simple cross-origin request, and its root
+// must be one of the music directories of the server or a directory
+// under them; the first music directory is scanned if it has no root.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// A Server handles the requests to the REST API of a database.
+type Server struct {
+	database *model.Database
+	scanner  *scanner
+}
+
+// NewServer returns a Server for the given database, whose scans are
+// limited to the given music directories.
+func NewServer(database *model.Database, music []string) *Server {
+	return &Server{
+		database: database,
+		scanner:  newScanner(database, music),
+	}
+}
+
+// ServeHTTP dispatches a request to the handler of its route.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "rolas":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.listRolas})
+	case path == "search":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.search})
+	case path == "performers":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.listPerformers})
+	case path == "persons":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.listPersons})
+	case path == "groups":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.listGroups})
+	case path == "albums":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.listAlbums})
+	case path == "scans":
+		server.route(w, r, map[string]http.HandlerFunc{
+			"GET":  server.listScans,
+			"POST": server.startScan,
+		})
+	case len(parts) == 2 && parts[0] == "scans":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.getScan(parts[1])})
+	case len(parts) == 2 && parts[0] == "rolas":
+		server.withRola(w, r, parts[1], map[string]rolaHandler{
+			"GET": server.getRola,
+			"PUT": server.updateRola,
+		})
+	case len(parts) == 3 && parts[0] == "rolas" && parts[2] == "audio":
+		server.withRola(w, r, parts[1], map[string]rolaHandler{"GET": server.audio})
+	case len(parts) == 3 && parts[0] == "rolas" && parts[2] == "cover":
+		server.withRola(w, r, parts[1], map[string]rolaHandler{"GET": server.rolaCover})
+	case len(parts) == 3 && parts[0] == "albums" && parts[2] == "cover":
+		server.route(w, r, map[string]http.HandlerFunc{"GET": server.albumCover(parts[1])})
+	default:
+		writeError(w, http.StatusNotFound, "no such resource")
+	}
+}
+
+// route calls the handler for the method of the request, answering
+// with 405 Method Not Allowed if there is none.   HEAD requests are
+// handled as GET requests.
+func (server *Server) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	method := r.Method
+	if method == "HEAD" {
+		method = "GET"
+	}
+	handler, ok := handlers[method]
+	if !ok {
+		allowed := make([]string, 0, len(handlers))
+		for name := range handlers {
+			allowed = append(allowed, name)
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	handler(w, r)
+}
+
+// A rolaHandler handles a request about the Rola with the given ID.
+type rolaHandler func(w http.ResponseWriter, r *http.Request, id int64)
+
+// withRola parses the ID of a Rola and calls the handler for the method
+// of the request, answering with 404 Not Found if the Rola is not in the
+// database.
+func (server *Server) withRola(w http.ResponseWriter, r *http.Request, text string, handlers map[string]rolaHandler) {
+	id, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || server.database.QueryPath(id) == "" {
+		writeError(w, http.StatusNotFound, "no such rola")
+		return
+	}
+	httpHandlers := make(map[string]http.HandlerFunc)
+	for method, handler := range handlers {
+		handler := handler
+		httpHandlers[method] = func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, id)
+		}
+	}
+	server.route(w, r, httpHandlers)
+}
+
+// writeJSON answers a request with a value encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError answers a request with an error, as a JSON object with an
+// "error" field.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseListOptions(t *testing.T) {
+	query, _ := url.ParseQuery("artist=beatles&year=1965&sort=-title&offset=10&limit=20")
+	options, err := parseListOptions(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.Artist != "beatles" || options.Year != 1965 {
+		t.Errorf("unexpected filters %v", options)
+	}
+	if options.Sort != "title" || !options.Descending {
+		t.Errorf("expecting %v, received %v", "-title", options.Sort)
+	}
+	if options.Offset != 10 || options.Limit != 20 {
+		t.Errorf("expecting %v, received %v", "10, 20", options)
+	}
+
+	options, _ = parseListOptions(url.Values{})
+	if options.Limit != defaultLimit || options.Sort != "" {
+		t.Errorf("expecting %v, received %v", defaultLimit, options.Limit)
+	}
+}
+
+func TestParseListOptionsInvalid(t *testing.T) {
+	for _, raw := range []string{"sort=path", "limit=0", "limit=501", "offset=-1", "year=nineteen"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := parseListOptions(query); err == nil {
+			t.Errorf("expecting an error for %v", raw)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	server := &Server{}
+	requests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{"GET", "/api/nothing", http.StatusNotFound},
+		{"GET", "/api/rolas/1/lyrics", http.StatusNotFound},
+		{"DELETE", "/api/rolas", http.StatusMethodNotAllowed},
+		{"PUT", "/api/scans", http.StatusMethodNotAllowed},
+		{"GET", "/api/rolas?limit=1000", http.StatusBadRequest},
+		{"GET", "/api/search", http.StatusBadRequest},
+		{"GET", "/api/scans/1", http.StatusNotFound},
+	}
+	server.scanner = newScanner(nil, nil)
+	for _, request := range requests {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(request.method, request.target, nil))
+		if recorder.Code != request.status {
+			t.Errorf("%v %v: expecting %v, received %v", request.method, request.target, request.status, recorder.Code)
+		}
+	}
+}
+
+func TestServeArtworkInvalidSize(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	serveArtwork(recorder, httptest.NewRequest("GET", "/api/rolas/1/cover?size=300", nil), "hash")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expecting %v, received %v", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestStartScanRejected(t *testing.T) {
+	server := &Server{scanner: newScanner(nil, []string{"/srv/music"})}
+	requests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"", `{"root": "/srv/music"}`, http.StatusUnsupportedMediaType},
+		{"text/plain", `{"root": "/srv/music"}`, http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", "root=/srv/music", http.StatusUnsupportedMediaType},
+		{"application/json", `{"root": "/etc"}`, http.StatusForbidden},
+		{"application/json", `{"root": "/srv/music2"}`, http.StatusForbidden},
+		{"application/json; charset=utf-8", `{"root": "/srv/music/../secret"}`, http.StatusForbidden},
+		{"application/json", `{"root": "relative"}`, http.StatusForbidden},
+		{"application/json", `{"root": 7}`, http.StatusBadRequest},
+	}
+	for _, request := range requests {
+		r := httptest.NewRequest("POST", "/api/scans", strings.NewReader(request.body))
+		if request.contentType != "" {
+			r.Header.Set("Content-Type", request.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, r)
+		if recorder.Code != request.status {
+			t.Errorf("%v %v: expecting %v, received %v", request.contentType, request.body, request.status, recorder.Code)
+		}
+	}
+	if scans := server.scanner.all(); len(scans) != 0 {
+		t.Errorf("expecting %v, received %v", 0, len(scans))
+	}
+}
+
+func TestScannerInMusic(t *testing.T) {
+	scanner := newScanner(nil, []string{"/srv/music/", "/home/user/Music"})
+	roots := map[string]bool{
+		"/srv/music":               true,
+		"/srv/music/new":           true,
+		"/home/user/Music/a/../b":  true,
+		"/srv/musical":             false,
+		"/srv":                     false,
+		"/home/user/Music/../Docs": false,
+	}
+	for root, expected := range roots {
+		if _, ok := scanner.inMusic(root); ok != expected {
+			t.Errorf("%v: expecting %v, received %v", root, expected, ok)
+		}
+	}
+}
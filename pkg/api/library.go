@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+)
+
+// A person is a person of the database as it is written in JSON.
+type person struct {
+	ID        int64  `json:"id"`
+	StageName string `json:"stage_name"`
+	RealName  string `json:"real_name"`
+	Birth     string `json:"birth"`
+	Death     string `json:"death"`
+}
+
+// A group is a group of the database as it is written in JSON, with
+// the stage names of its members.
+type group struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Start   string   `json:"start"`
+	End     string   `json:"end"`
+	Members []string `json:"members"`
+}
+
+func (server *Server) listPerformers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, server.database.AllPerformers())
+}
+
+func (server *Server) listAlbums(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, server.database.AllAlbums())
+}
+
+func (server *Server) listPersons(w http.ResponseWriter, r *http.Request) {
+	persons := make([]*person, 0)
+	for _, id := range server.database.AllPersons() {
+		stageName, realName, birth, death := server.database.QueryPerson(id)
+		persons = append(persons, &person{id, stageName, realName, birth, death})
+	}
+	sort.Slice(persons, func(i, j int) bool {
+		return persons[i].StageName < persons[j].StageName
+	})
+	writeJSON(w, http.StatusOK, persons)
+}
+
+func (server *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	groups := make([]*group, 0)
+	for _, id := range server.database.AllGroups() {
+		name, start, end := server.database.QueryGroup(id)
+		members := make([]string, 0)
+		for member := range server.database.QueryGroupMembers(id) {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		groups = append(groups, &group{id, name, start, end, members})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	writeJSON(w, http.StatusOK, groups)
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// The number of Rolas in a page when the request has no limit, and the
+// largest limit accepted.
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// A page is a page of a list of Rolas, with the number of Rolas in the
+// whole list.
+type page struct {
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Rolas  []*model.Rola `json:"rolas"`
+}
+
+// A rolaPatch holds the fields of a Rola to update; the fields missing
+// from the request are nil and are left as they are.
+type rolaPatch struct {
+	Title       *string `json:"title"`
+	Artist      *string `json:"artist"`
+	Album       *string `json:"album"`
+	Genre       *string `json:"genre"`
+	Track       *int    `json:"track"`
+	Year        *int    `json:"year"`
+	Disc        *int    `json:"disc"`
+	AlbumArtist *string `json:"album_artist"`
+	Composer    *string `json:"composer"`
+	BPM         *int    `json:"bpm"`
+	Comment     *string `json:"comment"`
+	Lyrics      *string `json:"lyrics"`
+}
+
+// listRolas answers with a page of the Rolas that pass the filters of
+// the request.
+func (server *Server) listRolas(w http.ResponseWriter, r *http.Request) {
+	options, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	server.writePage(w, options)
+}
+
+// search answers with a page of the Rolas found by the search in the q
+// parameter, which may be a simple search or a '*~*' query.
+func (server *Server) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if strings.TrimSpace(query.Get("q")) == "" {
+		writeError(w, http.StatusBadRequest, "missing parameter q")
+		return
+	}
+	options, err := parseListOptions(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options.IDs = server.database.Search(query.Get("q"))
+	server.writePage(w, options)
+}
+
+func (server *Server) writePage(w http.ResponseWriter, options *model.ListOptions) {
+	ids, total := server.database.ListRolas(options)
+	rolas := make([]*model.Rola, 0, len(ids))
+	for _, id := range ids {
+		rolas = append(rolas, server.database.QueryRola(id))
+	}
+	writeJSON(w, http.StatusOK, &page{
+		Total:  total,
+		Offset: options.Offset,
+		Limit:  options.Limit,
+		Rolas:  rolas,
+	})
+}
+
+func (server *Server) getRola(w http.ResponseWriter, r *http.Request, id int64) {
+	writeJSON(w, http.StatusOK, server.database.QueryRola(id))
+}
+
+// updateRola changes the fields of a Rola present in the body of the
+// request, and answers with the updated Rola.
+func (server *Server) updateRola(w http.ResponseWriter, r *http.Request, id int64) {
+	patch := &rolaPatch{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(patch)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid rola: "+err.Error())
+		return
+	}
+	for _, number := range []*int{patch.Track, patch.Year, patch.Disc, patch.BPM} {
+		if number != nil && *number < 0 {
+			writeError(w, http.StatusBadRequest, "invalid rola: negative number")
+			return
+		}
+	}
+
+	rola := server.database.QueryRola(id)
+	texts := []struct {
+		value *string
+		set   func(string)
+	}{
+		{patch.Title, rola.SetTitle},
+		{patch.Artist, rola.SetArtist},
+		{patch.Album, rola.SetAlbum},
+		{patch.Genre, rola.SetGenre},
+		{patch.AlbumArtist, rola.SetAlbumArtist},
+		{patch.Composer, rola.SetComposer},
+		{patch.Comment, rola.SetComment},
+		{patch.Lyrics, rola.SetLyrics},
+	}
+	for _, text := range texts {
+		if text.value != nil {
+			text.set(*text.value)
+		}
+	}
+	numbers := []struct {
+		value *int
+		set   func(int)
+	}{
+		{patch.Track, rola.SetTrack},
+		{patch.Year, rola.SetYear},
+		{patch.Disc, rola.SetDisc},
+		{patch.BPM, rola.SetBPM},
+	}
+	for _, number := range numbers {
+		if number.value != nil {
+			number.set(*number.value)
+		}
+	}
+	server.database.UpdateRola(rola)
+	writeJSON(w, http.StatusOK, server.database.QueryRola(id))
+}
+
+// audio serves the audio of a Rola; http.ServeContent answers range
+// requests and conditional requests.   The track of a CUE sheet is served
+// as only its part of the file.
+func (server *Server) audio(w http.ResponseWriter, r *http.Request, id int64) {
+	audio, err := model.OpenAudio(server.database.QueryRola(id))
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "the file of the rola is missing")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer audio.Close()
+	switch strings.ToLower(filepath.Ext(audio.Name)) {
+	case ".mp3":
+		w.Header().Set("Content-Type", "audio/mpeg")
+	case ".flac":
+		w.Header().Set("Content-Type", "audio/flac")
+	}
+	http.ServeContent(w, r, audio.Name, audio.ModTime, audio)
+}
+
+func (server *Server) rolaCover(w http.ResponseWriter, r *http.Request, id int64) {
+	serveArtwork(w, r, server.database.QueryArtwork(id))
+}
+
+func (server *Server) albumCover(text string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(text, 10, 64)
+		if err == nil {
+			for _, album := range server.database.AllAlbums() {
+				if album.ID == id {
+					serveArtwork(w, r, album.Artwork)
+					return
+				}
+			}
+		}
+		writeError(w, http.StatusNotFound, "no such album")
+	}
+}
+
+// serveArtwork serves a picture of the ArtworkCache, either the original
+// or the thumbnail of the size in the size parameter.   Pictures never
+// change for a given hash, so the hash is used as the ETag.
+func serveArtwork(w http.ResponseWriter, r *http.Request, hash string) {
+	cache := model.GetArtworkCache()
+	size := r.URL.Query().Get("size")
+	var data []byte
+	var err error
+	if size == "" {
+		data, err = cache.Original(hash)
+	} else if pixels := artworkSize(size); pixels > 0 {
+		data, err = cache.Thumbnail(hash, pixels)
+	} else {
+		writeError(w, http.StatusBadRequest, "invalid size")
+		return
+	}
+	if err == model.ErrNoArtwork {
+		writeError(w, http.StatusNotFound, "no artwork")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("ETag", `"`+hash+"-"+size+`"`)
+	w.Header().Set("Cache-Control", "max-age=86400")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// parseListOptions reads the filters, sorting and pagination of a list of
+// Rolas from the parameters of a request.
+func parseListOptions(query url.Values) (*model.ListOptions, error) {
+	options := &model.ListOptions{
+		Title:  query.Get("title"),
+		Artist: query.Get("artist"),
+		Album:  query.Get("album"),
+		Genre:  query.Get("genre"),
+		Limit:  defaultLimit,
+	}
+	numbers := []struct {
+		name  string
+		value *int
+	}{
+		{"year", &options.Year},
+		{"offset", &options.Offset},
+		{"limit", &options.Limit},
+	}
+	for _, number := range numbers {
+		text := query.Get(number.name)
+		if text == "" {
+			continue
+		}
+		value, err := strconv.Atoi(text)
+		if err != nil || value < 0 {
+			return nil, errors.New("invalid " + number.name)
+		}
+		*number.value = value
+	}
+	if options.Limit == 0 || options.Limit > maxLimit {
+		return nil, errors.New("limit must be between 1 and " + strconv.Itoa(maxLimit))
+	}
+
+	sort := query.Get("sort")
+	if strings.HasPrefix(sort, "-") {
+		options.Descending = true
+		sort = sort[1:]
+	}
+	if sort != "" {
+		if _, ok := model.SortColumns[sort]; !ok {
+			return nil, errors.New("invalid sort field " + sort)
+		}
+		options.Sort = sort
+	}
+	return options, nil
+}
+
+// artworkSize parses the size of a thumbnail, and returns 0 if it is
+// not one of the ArtworkSizes.
+func artworkSize(text string) int {
+	for _, size := range model.ArtworkSizes {
+		if text == strconv.Itoa(size) {
+			return size
+		}
+	}
+	return 0
+}
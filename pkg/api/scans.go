@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// errScanRunning is returned when a scan is started while another one
+// is running; the miner writes to the database, so scans run one at a
+// time.
+var errScanRunning = errors.New("a scan is already running")
+
+// errOutsideMusic is returned when the root of a scan is not under the
+// music directories of the scanner.
+var errOutsideMusic = errors.New("the root is not under a music directory")
+
+// A scan is a run of the miner over a directory, with its progress:
+// the number of files found, the number of Rolas processed and the
+// number of new Rolas added to the database.
+type scan struct {
+	ID        int        `json:"id"`
+	Root      string     `json:"root"`
+	State     string     `json:"state"`
+	Found     int        `json:"found"`
+	Processed int        `json:"processed"`
+	Added     int        `json:"added"`
+	Started   time.Time  `json:"started"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	miner     *model.Miner
+}
+
+// The states of a scan.
+const (
+	scanTraversing = "traversing"
+	scanMining     = "mining"
+	scanDone       = "done"
+)
+
+// A scanner runs the scans of a database, of the music directories or
+// the directories under them, and keeps their progress.
+type scanner struct {
+	database *model.Database
+	music    []string
+	mutex    sync.Mutex
+	scans    []*scan
+}
+
+func newScanner(database *model.Database, music []string) *scanner {
+	return &scanner{
+		database: database,
+		music:    music,
+		scans:    make([]*scan, 0),
+	}
+}
+
+// inMusic returns the clean absolute path of a root, and whether it is
+// one of the music directories or a directory under them.
+func (scanner *scanner) inMusic(root string) (string, bool) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	for _, dir := range scanner.music {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if root == dir || strings.HasPrefix(root, dir+string(filepath.Separator)) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// start starts a scan of the given root directory, or of the first
+// music directory if it is empty.
+func (scanner *scanner) start(root string) (*scan, error) {
+	if root == "" && len(scanner.music) > 0 {
+		root = scanner.music[0]
+	}
+	root, ok := scanner.inMusic(root)
+	if !ok {
+		return nil, errOutsideMusic
+	}
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+	for _, running := range scanner.scans {
+		if running.State != scanDone {
+			return nil, errScanRunning
+		}
+	}
+
+	miner := model.NewMiner()
+	miner.SetRoot(root)
+	info, err := os.Stat(miner.Root())
+	if err != nil || !info.IsDir() {
+		return nil, errors.New("no such directory " + miner.Root())
+	}
+	current := &scan{
+		ID:      len(scanner.scans) + 1,
+		Root:    miner.Root(),
+		State:   scanTraversing,
+		Started: time.Now(),
+		miner:   miner,
+	}
+	scanner.scans = append(scanner.scans, current)
+	go scanner.run(current)
+	return current.snapshot(), nil
+}
+
+// run runs the miner of a scan, updating the progress of the scan.
+func (scanner *scanner) run(current *scan) {
+	miner := current.miner
+	miner.Traverse()
+	scanner.mutex.Lock()
+	current.Found = miner.Found()
+	current.State = scanMining
+	scanner.mutex.Unlock()
+
+	go miner.Extract()
+	go miner.Populate(scanner.database)
+	for range miner.TrackList {
+		scanner.mutex.Lock()
+		current.Added++
+		scanner.mutex.Unlock()
+	}
+
+	finished := time.Now()
+	scanner.mutex.Lock()
+	current.State = scanDone
+	current.Finished = &finished
+	scanner.mutex.Unlock()
+}
+
+// get returns a copy of the scan with the given ID, or nil if there is
+// none.
+func (scanner *scanner) get(id int) *scan {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+	if id < 1 || id > len(scanner.scans) {
+		return nil
+	}
+	return scanner.scans[id-1].snapshot()
+}
+
+// all returns a copy of all the scans.
+func (scanner *scanner) all() []*scan {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+	result := make([]*scan, 0, len(scanner.scans))
+	for _, current := range scanner.scans {
+		result = append(result, current.snapshot())
+	}
+	return result
+}
+
+// snapshot returns a copy of the scan with the number of Rolas processed
+// so far.   The mutex of the scanner must be held.
+func (current *scan) snapshot() *scan {
+	copied := *current
+	copied.Processed = current.miner.Processed()
+	return &copied
+}
+
+func (server *Server) listScans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, server.scanner.all())
+}
+
+// startScan starts a scan of the root directory in the body of the
+// request, and answers with 202 Accepted and the new scan.   The body
+// must be of type application/json, which browsers do not send across
+// origins without asking first.
+func (server *Server) startScan(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "the scan must be sent as application/json")
+		return
+	}
+	request := struct {
+		Root string `json:"root"`
+	}{}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid scan: "+err.Error())
+		return
+	}
+	started, err := server.scanner.start(request.Root)
+	if err == errScanRunning {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == errOutsideMusic {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/scans/"+strconv.Itoa(started.ID))
+	writeJSON(w, http.StatusAccepted, started)
+}
+
+func (server *Server) getScan(text string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(text)
+		current := server.scanner.get(id)
+		if err != nil || current == nil {
+			writeError(w, http.StatusNotFound, "no such scan")
+			return
+		}
+		writeJSON(w, http.StatusOK, current)
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// scheduleBackups backs up the database once a day while the application
+// runs, telling the result in the status bar.
+func (principal *Principal) scheduleBackups() {
+	principal.backups.Schedule(principal.database, model.DefaultBackupInterval, func(backup *model.BackupFile, err error) {
+		if err != nil {
+			glib.IdleAdd(principal.showStatus, "could not back up the library: "+err.Error())
+			return
+		}
+		glib.IdleAdd(principal.showStatus, "library backed up to "+backup.Path)
+	})
+}
+
+// manageBackups opens the backup manager, which lists the backups of the
+// database, the newest first.   Restoring a backup backs up the database
+// first, so it can be undone.
+func (principal *Principal) manageBackups() {
+	backupPopUp := view.BackupManagerWindow()
+	var backups []*model.BackupFile
+	load := func() {
+		var err error
+		backups, err = principal.backups.List()
+		if err != nil {
+			principal.showStatus("could not list the backups: " + err.Error())
+		}
+		descriptions := make([]string, len(backups))
+		for i, backup := range backups {
+			descriptions[i] = fmt.Sprintf("%s\t%.1f MB", backup.Time.Format("2006-01-02 15:04:05"),
+				float64(backup.Size)/(1<<20))
+		}
+		backupPopUp.SetBackups(descriptions)
+	}
+	load()
+
+	backupPopUp.BackupB.Connect("clicked", func() {
+		backup, err := principal.backups.Create(principal.database)
+		if err != nil {
+			principal.showStatus("could not back up the library: " + err.Error())
+			return
+		}
+		principal.showStatus("library backed up to " + backup.Path)
+		load()
+	})
+
+	backupPopUp.RestoreB.Connect("clicked", func() {
+		index := backupPopUp.Selected()
+		if index < 0 || index >= len(backups) {
+			return
+		}
+		backup := backups[index]
+		question := "Replace the library with the backup of " +
+			backup.Time.Format("2006-01-02 15:04:05") + "?   The library is backed up first."
+		if !view.Confirm(backupPopUp.Win, question) {
+			return
+		}
+		err := principal.backups.Restore(principal.database, backup.Path)
+		if err != nil {
+			principal.showStatus("could not restore the library: " + err.Error())
+			return
+		}
+		principal.treeview.clear()
+		principal.repopulate()
+		principal.showStatus("library restored from " + backup.Path)
+		load()
+	})
+
+	backupPopUp.CheckB.Connect("clicked", func() {
+		principal.checkLibrary()
+	})
+}
+
+// checkLibrary shows the problems found in the library.
+func (principal *Principal) checkLibrary() {
+	problems := principal.database.Check()
+	descriptions := make([]string, len(problems))
+	for i, problem := range problems {
+		descriptions[i] = problem.String()
+	}
+	view.ProblemsWindow(descriptions)
+	principal.showStatus(fmt.Sprintf("%d problems found in the library", len(problems)))
+}
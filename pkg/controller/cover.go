@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+)
+
+// manageArtwork opens the artwork manager for the selected Rola.   The
+// manager lists the pictures embedded in the tag of the Rola, or the
+// picture of its album if there are none.   Every picture shown or
+// replaced is stored in the ArtworkCache, and the changes are written
+// back to the tags only if the user asks for it.
+func (principal *Principal) manageArtwork() {
+	rowValues := principal.rowTextValues()
+	if len(rowValues) == 0 {
+		return
+	}
+	rolaID := principal.rowID()
+	path := principal.database.QueryFile(rolaID)
+	coverPopUp := view.CoverManagerWindow()
+	coverPopUp.Win.SetTitle("Artwork: " + rowValues[0])
+	pictures := principal.loadPictures(coverPopUp, rolaID, path)
+
+	selected := func() *model.Picture {
+		index := coverPopUp.Selected()
+		if index < 0 || index >= len(pictures) {
+			if len(pictures) == 1 {
+				return pictures[0]
+			}
+			return nil
+		}
+		return pictures[index]
+	}
+
+	coverPopUp.ViewB.Connect("clicked", func() {
+		picture := selected()
+		if picture == nil {
+			return
+		}
+		pix, err := view.PixbufFromBytes(picture.Data)
+		if err != nil {
+			principal.showStatus("could not load the picture: " + err.Error())
+			return
+		}
+		view.PictureWindow(picture.TypeName(), pix)
+	})
+
+	coverPopUp.SaveAsB.Connect("clicked", func() {
+		picture := selected()
+		if picture == nil {
+			return
+		}
+		name := strings.ToLower(strings.Replace(picture.TypeName(), " ", "-", -1)) + picture.Extension()
+		file := view.ChooseSaveFile(coverPopUp.Win, "Save picture", name)
+		if file == "" {
+			return
+		}
+		err := ioutil.WriteFile(file, picture.Data, 0644)
+		if err != nil {
+			principal.showStatus("could not save the picture: " + err.Error())
+			return
+		}
+		principal.showStatus("picture saved to " + file)
+	})
+
+	coverPopUp.ReplaceB.Connect("clicked", func() {
+		file := view.ChooseOpenFile(coverPopUp.Win, "Replace picture")
+		if file == "" {
+			return
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			principal.showStatus("could not read the picture: " + err.Error())
+			return
+		}
+		hash, err := model.GetArtworkCache().Store(data)
+		if err != nil {
+			principal.showStatus("could not load the picture: " + err.Error())
+			return
+		}
+		var pictureType byte = model.FrontCover
+		if old := selected(); old != nil {
+			pictureType = old.Type
+		}
+		picture := model.NewPicture(data, pictureType)
+		if coverPopUp.WriteTagsCB.GetActive() {
+			err = model.ReplacePicture(path, picture)
+			if err != nil {
+				principal.showStatus("could not write the tag: " + err.Error())
+				return
+			}
+		}
+		if pictureType == model.FrontCover {
+			principal.database.ReplaceArtwork(rolaID, hash)
+		}
+		replaced := make([]*model.Picture, 0, len(pictures)+1)
+		for _, old := range pictures {
+			if old.Type != pictureType {
+				replaced = append(replaced, old)
+			}
+		}
+		pictures = append(replaced, picture)
+		coverPopUp.Clear()
+		principal.showPictures(coverPopUp, pictures, "")
+		principal.selectionChanged(principal.treeSel)
+		principal.showStatus("picture replaced")
+	})
+
+	coverPopUp.ApplyB.Connect("clicked", func() {
+		picture := selected()
+		if picture == nil {
+			return
+		}
+		hash, err := model.GetArtworkCache().Store(picture.Data)
+		if err != nil {
+			principal.showStatus("could not load the picture: " + err.Error())
+			return
+		}
+		_, albumID := principal.database.QueryRolaForeign(rolaID)
+		principal.database.ReplaceAlbumArtwork(albumID, hash)
+		rolas := principal.database.QueryAlbumRolas(albumID)
+		failed := 0
+		for _, id := range rolas {
+			principal.database.ReplaceArtwork(id, hash)
+			if coverPopUp.WriteTagsCB.GetActive() {
+				front := model.NewPicture(picture.Data, model.FrontCover)
+				if model.ReplacePicture(principal.database.QueryFile(id), front) != nil {
+					failed++
+				}
+			}
+		}
+		principal.selectionChanged(principal.treeSel)
+		if failed > 0 {
+			principal.showStatus(fmt.Sprintf("artwork applied to %d rolas, could not write %d tags", len(rolas), failed))
+			return
+		}
+		principal.showStatus(fmt.Sprintf("artwork applied to %d rolas", len(rolas)))
+	})
+}
+
+// loadPictures reads the pictures embedded in the tag of the file in
+// the given path and adds them to the artwork manager.   If the file
+// has no pictures, the artwork of the Rola in the ArtworkCache is added
+// instead.
+func (principal *Principal) loadPictures(coverPopUp *view.CoverManager, rolaID int64, path string) []*model.Picture {
+	pictures, err := model.ReadPictures(path)
+	if err == nil && len(pictures) > 0 {
+		principal.showPictures(coverPopUp, pictures, "")
+		return pictures
+	}
+	pictures = make([]*model.Picture, 0)
+	data, err := model.GetArtworkCache().Original(principal.database.QueryArtwork(rolaID))
+	if err == nil {
+		pictures = append(pictures, model.NewPicture(data, model.FrontCover))
+		principal.showPictures(coverPopUp, pictures, " (album artwork)")
+	}
+	return pictures
+}
+
+// showPictures adds a list of pictures to the artwork manager, with
+// thumbnails taken from the ArtworkCache.
+func (principal *Principal) showPictures(coverPopUp *view.CoverManager, pictures []*model.Picture, suffix string) {
+	cache := model.GetArtworkCache()
+	for _, picture := range pictures {
+		description := picture.TypeName() + suffix
+		if picture.Description != "" {
+			description += "\n" + picture.Description
+		}
+		pix := view.NoImagePixbuf(128)
+		if hash, err := cache.Store(picture.Data); err == nil {
+			if data, err := cache.Thumbnail(hash, 128); err == nil {
+				if thumbnail, err := view.PixbufFromBytes(data); err == nil {
+					pix = thumbnail
+				}
+			}
+		}
+		coverPopUp.AddPicture(pix, description)
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+)
+
+// findDuplicates opens the duplicate finder, which lists the groups of
+// rolas taken for copies of the same song.   Choosing a group shows its
+// copies, the best one first and selected; the buttons keep the selected
+// copy and delete, hide or move the others, after asking.
+func (principal *Principal) findDuplicates() {
+	finder := view.DuplicateFinderWindow()
+	var groups []*model.DuplicateGroup
+	load := func() {
+		groups = principal.database.FindDuplicates(model.DefaultDuplicateTolerance)
+		descriptions := make([]string, len(groups))
+		for i, group := range groups {
+			first := group.Candidates[0]
+			descriptions[i] = fmt.Sprintf("%s - %s\t%d copies (%s)", first.Artist, first.Title,
+				len(group.Candidates), strings.Join(group.Reasons, ", "))
+		}
+		finder.SetGroups(descriptions)
+		principal.showStatus(fmt.Sprintf("%d groups of duplicates found", len(groups)))
+	}
+	load()
+
+	selected := func() *model.DuplicateGroup {
+		index := finder.SelectedGroup()
+		if index < 0 || index >= len(groups) {
+			return nil
+		}
+		return groups[index]
+	}
+
+	finder.GroupsLB.Connect("row-selected", func() {
+		group := selected()
+		if group == nil {
+			return
+		}
+		descriptions := make([]string, len(group.Candidates))
+		for i, candidate := range group.Candidates {
+			descriptions[i] = fmt.Sprintf("%d kbps\t%s\t%s\t%.1f MB\t%s", candidate.Bitrate, candidate.Format,
+				model.FormatDuration(candidate.Duration), float64(candidate.Size)/(1<<20), candidate.Path)
+		}
+		finder.SetCandidates(descriptions)
+	})
+
+	resolve := func(action model.DuplicateAction, verb string) {
+		group := selected()
+		index := finder.SelectedCandidate()
+		if group == nil || index < 0 || index >= len(group.Candidates) {
+			principal.showStatus("choose a group and the copy to keep first")
+			return
+		}
+		keep := group.Candidates[index]
+		dir := ""
+		if action == model.DuplicateMove {
+			dir = view.ChooseFolder(finder.Win, "Move the other copies to")
+			if dir == "" {
+				return
+			}
+		}
+		question := fmt.Sprintf("Keep %s and %s the other %d copies?", keep.Path, verb, len(group.Candidates)-1)
+		if action == model.DuplicateDelete {
+			question += "   Their files are deleted."
+		}
+		if !view.Confirm(finder.Win, question) {
+			return
+		}
+		others := make([]int64, 0, len(group.Candidates)-1)
+		for _, candidate := range group.Candidates {
+			if candidate != keep {
+				others = append(others, candidate.ID)
+			}
+		}
+		err := principal.database.ResolveDuplicates(keep.ID, others, action, dir)
+		principal.treeview.clear()
+		principal.repopulate()
+		if err != nil {
+			principal.showStatus("could not " + verb + " the copies: " + err.Error())
+		}
+		load()
+	}
+
+	finder.DeleteB.Connect("clicked", func() {
+		resolve(model.DuplicateDelete, "delete")
+	})
+
+	finder.HideB.Connect("clicked", func() {
+		resolve(model.DuplicateHide, "hide")
+	})
+
+	finder.MoveB.Connect("clicked", func() {
+		resolve(model.DuplicateMove, "move")
+	})
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+)
+
+// inferTags opens the tag inferrer, which previews the tags inferred
+// from the paths of the rolas shown in the tree view, so a search
+// chooses the rolas; only the fields still missing are inferred.   The
+// chosen inferences are saved in the database and shown in the tree
+// view.
+func (principal *Principal) inferTags() {
+	inferrer := view.TagInferrerWindow()
+	inferences := principal.database.InferTags(principal.treeview.VisibleIDs(), model.GetFilenameRules())
+	descriptions := make([]string, len(inferences))
+	for i, inference := range inferences {
+		descriptions[i] = inference.Rola.Path() + "\n" + inference.String()
+	}
+	inferrer.SetInferences(descriptions)
+
+	inferrer.NoneB.Connect("clicked", func() {
+		inferrer.ChooseNone()
+	})
+
+	inferrer.ApplyB.Connect("clicked", func() {
+		chosen := inferrer.Chosen()
+		for _, index := range chosen {
+			rola := inferences[index].Rola
+			principal.database.UpdateRola(rola)
+			principal.treeview.updateRow(rola)
+		}
+		principal.showStatus(fmt.Sprintf("tags inferred for %d rolas", len(chosen)))
+		inferrer.Win.Close()
+	})
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+)
+
+// exportLibrary writes the whole library to a file chosen by the user:
+// a name ending in .json is written as JSON, any other name as a
+// directory with a CSV file for every table.
+func (principal *Principal) exportLibrary() {
+	path := view.ChooseSaveFile(principal.mainWindow.Win, "Export library", "rolas.json")
+	if path == "" {
+		return
+	}
+	dump := principal.database.Export()
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		var file *os.File
+		file, err = os.Create(path)
+		if err == nil {
+			err = dump.WriteJSON(file)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	} else {
+		err = dump.WriteCSV(path)
+	}
+	if err != nil {
+		principal.showStatus("could not export the library: " + err.Error())
+		return
+	}
+	principal.showStatus(fmt.Sprintf("%d rolas exported to %s", len(dump.Rolas), path))
+}
+
+// importLibrary adds to the database a library exported as JSON, or as
+// CSV if the user chooses one of its CSV files.   The records already
+// in the library are kept unchanged.
+func (principal *Principal) importLibrary() {
+	path := view.ChooseOpenFile(principal.mainWindow.Win, "Import library")
+	if path == "" {
+		return
+	}
+	var dump *model.Dump
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		dump, err = model.ReadCSVDump(filepath.Dir(path))
+	} else {
+		var file *os.File
+		file, err = os.Open(path)
+		if err == nil {
+			dump, err = model.ReadJSONDump(file)
+			file.Close()
+		}
+	}
+	var report *model.ImportReport
+	if err == nil {
+		report, err = principal.database.Import(dump, model.SkipConflicts)
+	}
+	if err != nil {
+		principal.showStatus("could not import the library: " + err.Error())
+		return
+	}
+	principal.repopulate()
+	principal.showStatus(fmt.Sprintf("%d rolas imported, %d already in the library",
+		report.Added["rolas"], report.Skipped["rolas"]))
+}
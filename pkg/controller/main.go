@@ -2,16 +2,9 @@
 package controller
 
 import (
-	"bytes"
-	"image"
-	// Needed for decoding gif images with image
-	_ "image/gif"
-	"image/jpeg"
-	// Needed for decoding png images with image
-	_ "image/png"
+	"fmt"
 	"log"
 	"os"
-	"os/user"
 	"strconv"
 	"time"
 	"unicode"
@@ -19,29 +12,31 @@ import (
 	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
 	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
 
-	"github.com/dhowden/tag"
-	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
 // Principal is the main window controller. It contains as fields
 // a database from the model package, a MainWindow object from the
-// view package, a tree view, and the tree selection of the former.
+// view package, a tree view, the tree selection of the former, and the
+// backups of the database.
 type Principal struct {
 	database   *model.Database
 	mainWindow *view.MainWindow
 	treeview   *TreeView
 	treeSel    *gtk.TreeSelection
+	backups    *model.Backups
 }
 
 // A SongInfo holds the information of a Rola to show in the bottom
-// of the main window, including the image obtained from the ID3v2 tag.
+// of the main window, including the image obtained from the ID3v2 tag
+// and the lyrics.
 type SongInfo struct {
 	image  *gtk.Image
 	title  string
 	artist string
 	album  string
+	lyrics string
 }
 
 // MainWindow creates and draws the main window for the application.
@@ -64,18 +59,21 @@ func newPrincipal() *Principal {
 	if !exists {
 		database.CreateDB()
 	}
+	database.MigrateDB()
 
 	principal := &Principal{
 		database:   database,
 		mainWindow: mainWindow,
 		treeview:   treeview,
 		treeSel:    sel,
+		backups:    model.NewBackups(model.DefaultKeptBackups),
 	}
 	return principal
 }
 
 func (principal *Principal) initialize() {
 	principal.database.LoadDB()
+	principal.scheduleBackups()
 
 	principal.mainWindow.Buttons["about"].Connect("clicked", func() {
 		view.NewAbout()
@@ -110,6 +108,38 @@ func (principal *Principal) initialize() {
 		principal.editPerformer()
 	})
 
+	principal.mainWindow.Buttons["artwork"].Connect("clicked", func() {
+		principal.manageArtwork()
+	})
+
+	principal.mainWindow.Buttons["export"].Connect("clicked", func() {
+		principal.exportLibrary()
+	})
+
+	principal.mainWindow.Buttons["import"].Connect("clicked", func() {
+		principal.importLibrary()
+	})
+
+	principal.mainWindow.Buttons["backups"].Connect("clicked", func() {
+		principal.manageBackups()
+	})
+
+	principal.mainWindow.Buttons["duplicates"].Connect("clicked", func() {
+		principal.findDuplicates()
+	})
+
+	principal.mainWindow.Buttons["infer"].Connect("clicked", func() {
+		principal.inferTags()
+	})
+
+	principal.mainWindow.Buttons["missing"].Connect("clicked", func() {
+		principal.findMissing()
+	})
+
+	principal.mainWindow.Buttons["replaygain"].Connect("clicked", func() {
+		principal.scanReplayGain()
+	})
+
 	principal.mainWindow.SearchEntry.Connect("activate", func() {
 		text := view.GetTextSearchEntry(principal.mainWindow.SearchEntry)
 		principal.searchAction(text)
@@ -125,9 +155,9 @@ func (principal *Principal) addNewPerformer() {
 		page := performerPopUp.Notebook.GetCurrentPage()
 		switch page {
 		case 0:
-			principal.savePersonContent(performerPopUp.PersonContent)
+			principal.savePersonContent(performerPopUp.PersonContent, 0)
 		case 1:
-			principal.saveGroupContent(performerPopUp.GroupContent)
+			principal.saveGroupContent(performerPopUp.GroupContent, 0)
 		}
 		performerPopUp.Win.Close()
 	})
@@ -137,9 +167,7 @@ func (principal *Principal) populate() {
 	miner := model.NewMiner()
 	miner.Traverse()
 	go miner.Extract()
-	time.Sleep(100 * time.Millisecond)
 	go miner.Populate(principal.database)
-	time.Sleep(100 * time.Millisecond)
 	go principal.populateOnTheFly(miner)
 }
 
@@ -176,44 +204,34 @@ func (principal *Principal) rowID() int64 {
 
 // Handler of "activate" signal of TreeView's selection
 func (principal *Principal) selectionChanged(s *gtk.TreeSelection) {
-	home, err := user.Current()
-	if err != nil {
-		log.Fatal("could not retrieve the current user:", err)
-	}
-	cache := home.HomeDir + "/.cache/rolas"
-
 	items := principal.rowTextValues()
 	if len(items) == 0 {
 		return
 	}
-	file, err := os.Open(items[4])
-	if err != nil {
-		log.Fatal("could not open file:", err)
+	rolaID := principal.rowID()
+	rola := principal.database.QueryRola(rolaID)
+	if _, err := os.Stat(rola.File()); os.IsNotExist(err) {
+		principal.treeview.setAvailable(rolaID, false)
+		principal.showStatus("the file of this rola is missing: " + rola.File())
 	}
-	metadata, err := tag.ReadFrom(file)
+	lyrics := rola.Lyrics()
+	artist := items[1]
+	if age, ok := principal.database.AgeAtRecording(rolaID); ok {
+		artist = fmt.Sprintf("%s (aged %d)", artist, age)
+	}
+	hash := principal.database.QueryArtwork(rolaID)
+	data, err := model.GetArtworkCache().Thumbnail(hash, 250)
 	if err != nil {
-		log.Fatal("error while reading the tags in file: ", items[4]+" ", err)
+		principal.defaultImage(items[0], artist, items[2], lyrics)
+		return
 	}
-	picture := metadata.Picture()
-	if picture == nil {
-		principal.defaultImage(items[0], items[1], items[2])
-	} else {
-		pic := picture.Data
-		file, _ := os.Create(cache + "/image.jpg")
-		imageReader := bytes.NewReader(pic)
-		loadedImage, _, _ := image.Decode(imageReader)
-		if loadedImage != nil {
-			err = jpeg.Encode(file, loadedImage, nil)
-			pix, _ := gdk.PixbufNewFromFileAtScale(cache+"/image.jpg", 250, 250, false)
-			image, _ := gtk.ImageNewFromPixbuf(pix)
-			glib.IdleAdd(principal.attachInfo, &SongInfo{image, items[0], items[1], items[2]})
-			if err != nil {
-				log.Fatal("could not encode the image to jpeg")
-			}
-		} else {
-			principal.defaultImage(items[0], items[1], items[2])
-		}
+	pix, err := view.PixbufFromBytes(data)
+	if err != nil {
+		principal.defaultImage(items[0], artist, items[2], lyrics)
+		return
 	}
+	image, _ := gtk.ImageNewFromPixbuf(pix)
+	glib.IdleAdd(principal.attachInfo, &SongInfo{image, items[0], artist, items[2], lyrics})
 }
 
 func (principal *Principal) editPerformer() {
@@ -226,23 +244,25 @@ func (principal *Principal) editPerformer() {
 
 	performerID := principal.database.ExistsPerformer(rola.Artist())
 	ptype, name := principal.database.QueryPerformerType(performerID)
+	personID, groupID := principal.database.QueryPerformerLinks(performerID)
 	switch ptype {
 	case 0:
 		personPopUp := view.EditPersonWindow()
-		principal.showPersonContent(personPopUp.PersonContent, name)
-		personID := principal.database.ExistsPerson(name)
-		var listBoxRow *gtk.ListBoxRow
-		personGroups := principal.database.QueryPersonGroups(personID)
-		for group := range principal.database.AllGroups() {
-			if !personGroups[group] {
-				personPopUp.NewGroupCBT.AppendText(group)
-			} else {
-				listBoxRow = view.SetupListBoxRowLabel(group)
-				listBoxRow.SetSensitive(false)
-				personPopUp.CurrentGroupLB.Add(listBoxRow)
-				personPopUp.Win.ShowAll()
-			}
+		if personID == 0 {
+			personID = principal.database.ExistsPerson(name)
 		}
+		principal.showPersonContent(personPopUp.PersonContent, personID, name)
+		page := &membershipPage{
+			personID: personID,
+			list:     personPopUp.CurrentGroupLB,
+			combo:    personPopUp.NewGroupCBT,
+			joined:   personPopUp.JoinedE,
+			left:     personPopUp.LeftE,
+			roles:    personPopUp.RolesE,
+			remove:   personPopUp.RemoveB,
+			win:      personPopUp.Win,
+		}
+		principal.editMemberships(page)
 		personPopUp.Notebook.ConnectAfter("switch-page", func() {
 			page := personPopUp.Notebook.GetCurrentPage()
 			switch page {
@@ -253,31 +273,34 @@ func (principal *Principal) editPerformer() {
 			}
 		})
 		personPopUp.SaveB.Connect("clicked", func() {
-			page := personPopUp.Notebook.GetCurrentPage()
-			switch page {
+			switch personPopUp.Notebook.GetCurrentPage() {
 			case 0:
-				principal.savePersonContent(personPopUp.PersonContent)
+				if id := principal.savePersonContent(personPopUp.PersonContent, personID); id > 0 {
+					principal.database.LinkPerformer(performerID, id, 0)
+				}
 			case 1:
-				principal.database.AddPersonToGroup(personID, principal.database.AllGroups()[personPopUp.NewGroupCBT.GetActiveText()])
+				principal.saveMembership(page)
 			}
 			personPopUp.Win.Close()
 		})
 	case 1:
 		groupPopUp := view.EditGroupWindow()
-		principal.showGroupContent(groupPopUp.GroupContent, name)
-		groupID := principal.database.ExistsGroup(name)
-		var listBoxRow *gtk.ListBoxRow
-		groupMembers := principal.database.QueryGroupMembers(groupID)
-		for member := range principal.database.AllPersons() {
-			if !groupMembers[member] {
-				groupPopUp.NewMemberCBT.AppendText(member)
-			} else {
-				listBoxRow = view.SetupListBoxRowLabel(member)
-				listBoxRow.SetSensitive(false)
-				groupPopUp.CurrentMemberLB.Add(listBoxRow)
-				groupPopUp.Win.ShowAll()
-			}
+		if groupID == 0 {
+			groupID = principal.database.ExistsGroup(name)
 		}
+		principal.showGroupContent(groupPopUp.GroupContent, groupID, name)
+		page := &membershipPage{
+			ofGroup: true,
+			groupID: groupID,
+			list:    groupPopUp.CurrentMemberLB,
+			combo:   groupPopUp.NewMemberCBT,
+			joined:  groupPopUp.JoinedE,
+			left:    groupPopUp.LeftE,
+			roles:   groupPopUp.RolesE,
+			remove:  groupPopUp.RemoveB,
+			win:     groupPopUp.Win,
+		}
+		principal.editMemberships(page)
 		groupPopUp.Notebook.ConnectAfter("switch-page", func() {
 			page := groupPopUp.Notebook.GetCurrentPage()
 			switch page {
@@ -287,13 +310,17 @@ func (principal *Principal) editPerformer() {
 				glib.IdleAdd(groupPopUp.SaveB.SetLabel, "Add")
 			}
 		})
+		groupPopUp.LineupB.Connect("clicked", func() {
+			principal.showLineup(groupID)
+		})
 		groupPopUp.SaveB.Connect("clicked", func() {
-			page := groupPopUp.Notebook.GetCurrentPage()
-			switch page {
+			switch groupPopUp.Notebook.GetCurrentPage() {
 			case 0:
-				principal.saveGroupContent(groupPopUp.GroupContent)
+				if id := principal.saveGroupContent(groupPopUp.GroupContent, groupID); id > 0 {
+					principal.database.LinkPerformer(performerID, 0, id)
+				}
 			case 1:
-				principal.database.AddPersonToGroup(principal.database.AllPersons()[groupPopUp.NewMemberCBT.GetActiveText()], groupID)
+				principal.saveMembership(page)
 			}
 			groupPopUp.Win.Close()
 		})
@@ -301,18 +328,18 @@ func (principal *Principal) editPerformer() {
 		performerPopUp := view.EditPerformerWindow()
 		rola := principal.database.QueryRola(rolaID)
 		performerPopUp.PersonContent.StageNameE.SetText(rola.Artist())
-		performerPopUp.PersonContent.StageNameE.SetSensitive(false)
 		performerPopUp.GroupContent.GroupNameE.SetText(rola.Artist())
-		performerPopUp.GroupContent.GroupNameE.SetSensitive(false)
 		performerPopUp.SaveB.Connect("clicked", func() {
 			page := performerPopUp.Notebook.GetCurrentPage()
 			switch page {
 			case 0:
-				principal.database.UpdatePerformerType(performerID, 0)
-				principal.savePersonContent(performerPopUp.PersonContent)
+				if id := principal.savePersonContent(performerPopUp.PersonContent, 0); id > 0 {
+					principal.database.LinkPerformer(performerID, id, 0)
+				}
 			case 1:
-				principal.database.UpdatePerformerType(performerID, 1)
-				principal.saveGroupContent(performerPopUp.GroupContent)
+				if id := principal.saveGroupContent(performerPopUp.GroupContent, 0); id > 0 {
+					principal.database.LinkPerformer(performerID, 0, id)
+				}
 			}
 			performerPopUp.Win.Close()
 		})
@@ -333,27 +360,27 @@ func (principal *Principal) editForeignPerformer() {
 	}
 	foreignPopUp.PersonCBT.Connect("changed", func() {
 		personName = foreignPopUp.PersonCBT.GetActiveText()
-		principal.showPersonContent(foreignPopUp.PersonContent, personName)
 		personID = principal.database.ExistsPerson(personName)
+		principal.showPersonContent(foreignPopUp.PersonContent, personID, personName)
 	})
 	foreignPopUp.GroupCBT.Connect("changed", func() {
 		groupName = foreignPopUp.GroupCBT.GetActiveText()
-		principal.showGroupContent(foreignPopUp.GroupContent, groupName)
 		groupID = principal.database.ExistsGroup(groupName)
+		principal.showGroupContent(foreignPopUp.GroupContent, groupID, groupName)
 	})
 	foreignPopUp.SaveB.Connect("clicked", func() {
 		switch foreignPopUp.Notebook.GetCurrentPage() {
 		case 0:
-			principal.savePersonContent(foreignPopUp.PersonContent)
+			principal.savePersonContent(foreignPopUp.PersonContent, personID)
 		case 1:
-			principal.saveGroupContent(foreignPopUp.GroupContent)
+			principal.saveGroupContent(foreignPopUp.GroupContent, groupID)
 		}
 		foreignPopUp.Win.Close()
 	})
 }
 
 func (principal *Principal) populateFromExistingDB(database *model.Database) {
-	rows, err := database.Database.Query("SELECT performers.name, albums.name, rolas.path, rolas.title, rolas.genre, rolas.id_rola FROM rolas INNER JOIN performers ON performers.id_performer = rolas.id_performer INNER JOIN albums ON albums.id_album = rolas.id_album")
+	rows, err := database.Database.Query("SELECT performers.name, albums.name, rolas.path, rolas.title, rolas.genre, rolas.id_rola, rolas.duration, rolas.bitrate, rolas.sample_rate, rolas.channels, rolas.vbr, rolas.missing, rolas.loudness, rolas.album_loudness FROM rolas INNER JOIN performers ON performers.id_performer = rolas.id_performer INNER JOIN albums ON albums.id_album = rolas.id_album WHERE rolas.hidden = 0")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -365,18 +392,28 @@ func (principal *Principal) populateFromExistingDB(database *model.Database) {
 		var title string
 		var genre string
 		var id int64
-		err = rows.Scan(&performer, &album, &path, &title, &genre, &id)
+		var duration float64
+		var bitrate int
+		var sampleRate int
+		var channels int
+		var vbr bool
+		var missing bool
+		gain := &model.ReplayGain{}
+		err = rows.Scan(&performer, &album, &path, &title, &genre, &id, &duration, &bitrate, &sampleRate, &channels, &vbr, &missing,
+			&gain.TrackLoudness, &gain.AlbumLoudness)
 		if err != nil {
 			log.Fatal(err)
 		}
 		if principal.treeview.Rows[id] == nil {
-			glib.IdleAdd(principal.treeview.addRowStruct, &RowInfo{title, performer, album, genre, path, true, id})
+			glib.IdleAdd(principal.treeview.addRowStruct, &RowInfo{title, performer, album, genre, path, true, id,
+				time.Duration(duration * float64(time.Second)), bitrate, sampleRate, channels, vbr, !missing, gain})
 		}
 	}
 	err = rows.Err()
 	if err != nil {
 		log.Fatal("could not scan row:", err)
 	}
+	glib.IdleAdd(principal.showLibraryStatus)
 }
 
 func (principal *Principal) populateOnTheFly(miner *model.Miner) {
@@ -386,24 +423,33 @@ func (principal *Principal) populateOnTheFly(miner *model.Miner) {
 	}
 	principal.mainWindow.Buttons["populate"].SetSensitive(true)
 	principal.treeSel.SetMode(gtk.SELECTION_SINGLE)
+	glib.IdleAdd(principal.showLibraryStatus)
 }
 
 func (principal *Principal) searchAction(wildcard string) {
 	principal.treeSel.UnselectAll()
 	principal.treeview.AllInvisible()
-	parser := model.GetParser()
-	stmt, queryTerms, ok := parser.Parse(wildcard)
-	if ok {
-		for _, id := range principal.database.QueryCustom(stmt, queryTerms...) {
-			iter := principal.treeview.Rows[id]
-			principal.treeview.ListStore.SetValue(iter, 5, true)
-		}
-	} else {
-		for _, id := range principal.database.QuerySimple(stmt) {
-			iter := principal.treeview.Rows[id]
-			principal.treeview.ListStore.SetValue(iter, 5, true)
-		}
+	ids := principal.database.Search(wildcard)
+	for _, id := range ids {
+		iter := principal.treeview.Rows[id]
+		principal.treeview.ListStore.SetValue(iter, 5, true)
 	}
+	principal.showStatus(fmt.Sprintf("%d rolas found, %s", len(ids),
+		model.FormatDuration(principal.treeview.TotalDuration(ids))))
+}
+
+// showLibraryStatus shows in the status bar the number of Rolas in the
+// library and their total duration.
+func (principal *Principal) showLibraryStatus() {
+	count, duration := principal.database.LibraryDuration()
+	principal.showStatus(fmt.Sprintf("%d rolas in the library, %s", count, model.FormatDuration(duration)))
+}
+
+func (principal *Principal) showStatus(text string) {
+	status := principal.mainWindow.Status
+	context := status.GetContextId("rolas")
+	status.Pop(context)
+	status.Push(context, text)
 }
 
 func (principal *Principal) rowActivated() {
@@ -423,19 +469,19 @@ func (principal *Principal) rowActivated() {
 	})
 }
 
-func (principal *Principal) saveGroupContent(groupContent *view.GroupContent) {
+func (principal *Principal) saveGroupContent(groupContent *view.GroupContent, groupID int64) int64 {
 	newGroupName := view.GetTextEntry(groupContent.GroupNameE)
 	newStart := view.GetTextEntry(groupContent.StartE)
 	newEnd := view.GetTextEntry(groupContent.EndE)
-	principal.saveGroup(newGroupName, newStart, newEnd)
+	return principal.saveGroup(groupID, newGroupName, newStart, newEnd)
 }
 
-func (principal *Principal) savePersonContent(personContent *view.PersonContent) {
+func (principal *Principal) savePersonContent(personContent *view.PersonContent, personID int64) int64 {
 	newStageName := view.GetTextEntry(personContent.StageNameE)
 	newRealName := view.GetTextEntry(personContent.RealNameE)
 	newBirth := view.GetTextEntry(personContent.BirthE)
 	newDeath := view.GetTextEntry(personContent.DeathE)
-	principal.savePerson(newStageName, newRealName, newBirth, newDeath)
+	return principal.savePerson(personID, newStageName, newRealName, newBirth, newDeath)
 }
 
 func (principal *Principal) saveRolaContent(rolaContent *view.RolaContent, rolaID int64, path string) {
@@ -446,39 +492,102 @@ func (principal *Principal) saveRolaContent(rolaContent *view.RolaContent, rolaI
 	rola.SetArtist(view.GetTextEntry(rolaContent.ArtistE))
 	rola.SetAlbum(view.GetTextEntry(rolaContent.AlbumE))
 	rola.SetGenre(view.GetTextEntry(rolaContent.GenreE))
+	rola.SetAlbumArtist(view.GetTextEntry(rolaContent.AlbumArtistE))
+	rola.SetComposer(view.GetTextEntry(rolaContent.ComposerE))
+	rola.SetComment(view.GetTextEntry(rolaContent.CommentE))
+	rola.SetLyrics(view.GetTextView(rolaContent.LyricsTV))
+	oldRola := principal.database.QueryRola(rolaID)
 	if isInt(view.GetTextEntry(rolaContent.TrackE)) {
 		newTrack, _ := strconv.Atoi(view.GetTextEntry(rolaContent.TrackE))
 		rola.SetTrack(newTrack)
 	} else {
-		oldRola := principal.database.QueryRola(rolaID)
 		rola.SetTrack(oldRola.Track())
 	}
 	if isInt(view.GetTextEntry(rolaContent.YearE)) {
 		newYear, _ := strconv.Atoi(view.GetTextEntry(rolaContent.YearE))
 		rola.SetYear(newYear)
 	} else {
-		oldRola := principal.database.QueryRola(rolaID)
 		rola.SetYear(oldRola.Year())
 	}
+	if isInt(view.GetTextEntry(rolaContent.DiscE)) {
+		newDisc, _ := strconv.Atoi(view.GetTextEntry(rolaContent.DiscE))
+		rola.SetDisc(newDisc)
+	} else {
+		rola.SetDisc(oldRola.Disc())
+	}
+	if isInt(view.GetTextEntry(rolaContent.BPME)) {
+		newBPM, _ := strconv.Atoi(view.GetTextEntry(rolaContent.BPME))
+		rola.SetBPM(newBPM)
+	} else {
+		rola.SetBPM(oldRola.BPM())
+	}
 	principal.database.UpdateRola(rola)
 }
 
-func (principal *Principal) saveGroup(groupName, start, end string) {
-	groupID := principal.database.ExistsGroup(groupName)
+// saveGroup saves the fields of the group with the given ID, which may
+// be renamed, or of the group with the given name if the ID is 0, adding
+// it if there is none.   It returns the ID of the group, or 0 if the name
+// belongs to another group.
+func (principal *Principal) saveGroup(groupID int64, groupName, start, end string) int64 {
+	if !principal.checkDates("start date", &start, "end date", &end) {
+		return 0
+	}
+	existing := principal.database.ExistsGroup(groupName)
+	if groupID == 0 {
+		groupID = existing
+	}
+	if existing > 0 && existing != groupID {
+		principal.showStatus("there is already a group named " + groupName)
+		return 0
+	}
 	if groupID > 0 {
 		principal.database.UpdateGroup(groupName, start, end, groupID)
-	} else {
-		principal.database.AddGroup(groupName, start, end)
+		return groupID
 	}
+	return principal.database.AddGroup(groupName, start, end)
 }
 
-func (principal *Principal) savePerson(stageName, realName, birth, death string) {
-	personID := principal.database.ExistsPerson(stageName)
+// savePerson saves the fields of the person with the given ID, who may
+// be renamed, or of the person with the given stage name if the ID is 0,
+// adding the person if there is none.   It returns the ID of the person,
+// or 0 if the stage name belongs to another person.
+func (principal *Principal) savePerson(personID int64, stageName, realName, birth, death string) int64 {
+	if !principal.checkDates("date of birth", &birth, "date of death", &death) {
+		return 0
+	}
+	existing := principal.database.ExistsPerson(stageName)
+	if personID == 0 {
+		personID = existing
+	}
+	if existing > 0 && existing != personID {
+		principal.showStatus("there is already a person named " + stageName)
+		return 0
+	}
 	if personID > 0 {
 		principal.database.UpdatePerson(stageName, realName, birth, death, personID)
-	} else {
-		principal.database.AddPerson(stageName, realName, birth, death)
+		return personID
+	}
+	return principal.database.AddPerson(stageName, realName, birth, death)
+}
+
+// checkDates normalizes the partial dates of the start and the end of
+// something, and tells in the status bar if they are not partial dates
+// or the end is before the start.
+func (principal *Principal) checkDates(startField string, start *string, endField string, end *string) bool {
+	fields := []string{startField, endField}
+	for i, date := range []*string{start, end} {
+		normalized, err := model.NormalizeDate(*date)
+		if err != nil {
+			principal.showStatus(fields[i] + ": " + err.Error())
+			return false
+		}
+		*date = normalized
 	}
+	if *start != "" && *end != "" && *end+"~" < *start {
+		principal.showStatus("the " + endField + " is before the " + startField)
+		return false
+	}
+	return true
 }
 
 func (principal *Principal) rolaContentToRow(content *view.RolaContent, rolaID int64) *model.Rola {
@@ -495,8 +604,13 @@ func (principal *Principal) rolaContentToRow(content *view.RolaContent, rolaID i
 	return rola
 }
 
-func (principal *Principal) showPersonContent(content *view.PersonContent, name string) {
-	personID := principal.database.ExistsPerson(name)
+// showPersonContent shows the fields of the person with the given ID, or
+// only the given name if the ID is 0.
+func (principal *Principal) showPersonContent(content *view.PersonContent, personID int64, name string) {
+	if personID == 0 {
+		glib.IdleAdd(content.StageNameE.SetText, name)
+		return
+	}
 	stageName, realName, birth, death := principal.database.QueryPerson(personID)
 	glib.IdleAdd(content.StageNameE.SetText, stageName)
 	glib.IdleAdd(content.RealNameE.SetText, realName)
@@ -504,8 +618,13 @@ func (principal *Principal) showPersonContent(content *view.PersonContent, name
 	glib.IdleAdd(content.DeathE.SetText, death)
 }
 
-func (principal *Principal) showGroupContent(content *view.GroupContent, name string) {
-	groupID := principal.database.ExistsGroup(name)
+// showGroupContent shows the fields of the group with the given ID, or
+// only the given name if the ID is 0.
+func (principal *Principal) showGroupContent(content *view.GroupContent, groupID int64, name string) {
+	if groupID == 0 {
+		glib.IdleAdd(content.GroupNameE.SetText, name)
+		return
+	}
 	groupName, start, end := principal.database.QueryGroup(groupID)
 	glib.IdleAdd(content.GroupNameE.SetText, groupName)
 	glib.IdleAdd(content.StartE.SetText, start)
@@ -519,17 +638,17 @@ func (principal *Principal) showRolaContent(content *view.RolaContent, rola *mod
 	content.GenreE.SetText(rola.Genre())
 	content.TrackE.SetText(strconv.Itoa(rola.Track()))
 	content.YearE.SetText(strconv.Itoa(rola.Year()))
+	content.DiscE.SetText(strconv.Itoa(rola.Disc()))
+	content.AlbumArtistE.SetText(rola.AlbumArtist())
+	content.ComposerE.SetText(rola.Composer())
+	content.BPME.SetText(strconv.Itoa(rola.BPM()))
+	content.CommentE.SetText(rola.Comment())
+	view.SetTextView(content.LyricsTV, rola.Lyrics())
 }
 
-func (principal *Principal) defaultImage(title, artist, album string) {
-	home, err := user.Current()
-	if err != nil {
-		log.Fatal("could not retrieve the current user:", err)
-	}
-	cache := home.HomeDir + "/.cache/rolas"
-	pix, _ := gdk.PixbufNewFromFileAtScale(cache+"/noimage.png", 250, 250, false)
-	image, _ := gtk.ImageNewFromPixbuf(pix)
-	glib.IdleAdd(principal.attachInfo, &SongInfo{image, title, artist, album})
+func (principal *Principal) defaultImage(title, artist, album, lyrics string) {
+	image, _ := gtk.ImageNewFromPixbuf(view.NoImagePixbuf(250))
+	glib.IdleAdd(principal.attachInfo, &SongInfo{image, title, artist, album, lyrics})
 	glib.IdleAdd(principal.mainWindow.Win.ShowAll)
 }
 
@@ -543,6 +662,7 @@ func (principal *Principal) attachInfo(songInfo *SongInfo) {
 	principal.mainWindow.SongInfo[0].SetText("\n\n\n\t" + songInfo.title + "\n\n\n")
 	principal.mainWindow.SongInfo[1].SetText("\t" + songInfo.artist + "\n\n\n")
 	principal.mainWindow.SongInfo[2].SetText("\t" + songInfo.album)
+	view.SetTextView(principal.mainWindow.Lyrics, songInfo.lyrics)
 	principal.mainWindow.Win.ShowAll()
 }
 
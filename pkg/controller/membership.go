@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// membershipPage holds the widgets of the page of the 'edit person' and
+// 'edit group' windows where the memberships are edited, and the
+// memberships they show: the groups of a person or, if ofGroup is true,
+// the members of a group.   The person or group may not be saved yet,
+// and have an ID of 0.
+type membershipPage struct {
+	ofGroup  bool
+	personID int64
+	groupID  int64
+	list     *gtk.ListBox
+	combo    *gtk.ComboBoxText
+	joined   *gtk.Entry
+	left     *gtk.Entry
+	roles    *gtk.Entry
+	remove   *gtk.ToolButton
+	win      *gtk.Window
+	names    []string
+	members  []*model.Member
+}
+
+// other returns the name of the other side of a membership: the group
+// in the page of a person, and the person in the page of a group.
+func (page *membershipPage) other(member *model.Member) string {
+	if page.ofGroup {
+		return member.StageName
+	}
+	return member.GroupName
+}
+
+// loadMemberships fills the page with the current memberships, and the
+// combo box with every group or person, so that choosing a current one
+// edits the membership.
+func (principal *Principal) loadMemberships(page *membershipPage) {
+	page.members = principal.database.QueryMemberships(page.personID, page.groupID)
+	candidates := principal.database.AllGroups()
+	if page.ofGroup {
+		candidates = principal.database.AllPersons()
+	}
+	page.names = make([]string, 0, len(candidates))
+	for name := range candidates {
+		page.names = append(page.names, name)
+	}
+	sort.Strings(page.names)
+
+	page.combo.RemoveAll()
+	for _, name := range page.names {
+		page.combo.AppendText(name)
+	}
+	for row := page.list.GetRowAtIndex(0); row != nil; row = page.list.GetRowAtIndex(0) {
+		row.Destroy()
+	}
+	for _, member := range page.members {
+		text := page.other(member)
+		if period := member.Period(); period != "" {
+			text += " (" + period + ")"
+		}
+		if member.Roles != "" {
+			text += ": " + member.Roles
+		}
+		page.list.Add(view.SetupListBoxRowLabel(text))
+	}
+	page.remove.SetSensitive(false)
+	page.win.ShowAll()
+}
+
+// selectedMembership returns the membership chosen in the list of the
+// page, or nil if there is none.
+func (page *membershipPage) selectedMembership() *model.Member {
+	row := page.list.GetSelectedRow()
+	if row == nil || row.GetIndex() < 0 || row.GetIndex() >= len(page.members) {
+		return nil
+	}
+	return page.members[row.GetIndex()]
+}
+
+// editMemberships connects the widgets of a membership page: choosing a
+// membership in the list shows it to be edited or removed.
+func (principal *Principal) editMemberships(page *membershipPage) {
+	principal.loadMemberships(page)
+	page.list.Connect("row-selected", func() {
+		member := page.selectedMembership()
+		page.remove.SetSensitive(member != nil)
+		if member == nil {
+			return
+		}
+		page.combo.SetActive(sort.SearchStrings(page.names, page.other(member)))
+		page.joined.SetText(member.Joined)
+		page.left.SetText(member.Left)
+		page.roles.SetText(member.Roles)
+	})
+	page.remove.Connect("clicked", func() {
+		member := page.selectedMembership()
+		if member == nil || !view.Confirm(page.win, "Remove "+member.StageName+" from "+member.GroupName+"?") {
+			return
+		}
+		principal.database.RemoveMembership(member.PersonID, member.GroupID)
+		principal.showStatus(member.StageName + " removed from " + member.GroupName)
+		principal.loadMemberships(page)
+	})
+}
+
+// saveMembership adds the person or group chosen in the combo box of
+// the page to the group or person of the page, or updates the period
+// and roles if it was already a membership.
+func (principal *Principal) saveMembership(page *membershipPage) {
+	membership := &model.Membership{
+		PersonID: page.personID,
+		GroupID:  page.groupID,
+		Joined:   view.GetTextEntry(page.joined),
+		Left:     view.GetTextEntry(page.left),
+		Roles:    view.GetTextEntry(page.roles),
+	}
+	name := page.combo.GetActiveText()
+	if page.ofGroup {
+		membership.PersonID = principal.database.AllPersons()[name]
+	} else {
+		membership.GroupID = principal.database.AllGroups()[name]
+	}
+	if membership.PersonID == 0 || membership.GroupID == 0 {
+		principal.showStatus("save the person or group and choose a membership first")
+		return
+	}
+	if !principal.checkDates("joining date", &membership.Joined, "leaving date", &membership.Left) {
+		return
+	}
+	err := principal.database.SetMembership(membership)
+	if err != nil {
+		principal.showStatus(err.Error())
+	}
+}
+
+// showLineup draws the timeline of the lineups of a group.
+func (principal *Principal) showLineup(groupID int64) {
+	if groupID == 0 {
+		return
+	}
+	name, start, end := principal.database.QueryGroup(groupID)
+	members := principal.database.QueryMemberships(0, groupID)
+	periods := model.Lineup(start, end, members)
+
+	labels := make([]string, len(periods))
+	for j, period := range periods {
+		labels[j] = period.String()
+	}
+	names := make([]string, 0, len(members))
+	active := make([][]bool, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.StageName)
+		row := make([]bool, len(periods))
+		for j, period := range periods {
+			for _, current := range period.Members {
+				row[j] = row[j] || current == member.StageName
+			}
+		}
+		active = append(active, row)
+	}
+	view.LineupWindow(name, labels, names, active)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+)
+
+// findMissing looks for the files of the rolas, greys out the rows of the
+// rolas whose files are missing, and opens the window that lists them
+// with the files under the Music folder that may be theirs.   The buttons
+// look for files in another folder, relink the selected rola to the
+// selected file or every rola to its likeliest file, and remove the
+// selected rola or all of them from the library, after asking.
+func (principal *Principal) findMissing() {
+	ids := principal.database.CheckMissing()
+	lost := make(map[int64]bool)
+	for _, id := range ids {
+		lost[id] = true
+	}
+	for id := range principal.treeview.Rows {
+		principal.treeview.setAvailable(id, !lost[id])
+	}
+
+	window := view.MissingFilesWindow()
+	roots := []string{model.NewMiner().Root()}
+	var missing []*model.MissingRola
+	load := func() {
+		missing = principal.database.QueryMissing()
+		principal.database.FindRelinks(missing, roots)
+		descriptions := make([]string, len(missing))
+		for i, rola := range missing {
+			descriptions[i] = fmt.Sprintf("%s - %s\t%d files\n%s", rola.Artist, rola.Title,
+				len(rola.Candidates), rola.Path)
+		}
+		window.SetRolas(descriptions)
+		principal.showStatus(fmt.Sprintf("%d rolas with missing files", len(missing)))
+	}
+	load()
+
+	selected := func() *model.MissingRola {
+		index := window.SelectedRola()
+		if index < 0 || index >= len(missing) {
+			return nil
+		}
+		return missing[index]
+	}
+
+	window.RolasLB.Connect("row-selected", func() {
+		rola := selected()
+		if rola == nil {
+			return
+		}
+		descriptions := make([]string, len(rola.Candidates))
+		for i, candidate := range rola.Candidates {
+			descriptions[i] = candidate.String()
+		}
+		window.SetCandidates(descriptions)
+	})
+
+	reload := func(err error, verb string) {
+		principal.treeview.clear()
+		principal.repopulate()
+		if err != nil {
+			principal.showStatus("could not " + verb + " the rolas: " + err.Error())
+		}
+		load()
+	}
+
+	window.SearchB.Connect("clicked", func() {
+		dir := view.ChooseFolder(window.Win, "Search the missing files in")
+		if dir == "" {
+			return
+		}
+		roots = append(roots, dir)
+		load()
+	})
+
+	window.RelinkB.Connect("clicked", func() {
+		rola := selected()
+		index := window.SelectedCandidate()
+		if rola == nil || index < 0 || index >= len(rola.Candidates) {
+			principal.showStatus("choose a rola and its file first")
+			return
+		}
+		reload(principal.database.Relink(rola.ID, rola.Candidates[index].Path), "relink")
+	})
+
+	window.RelinkAllB.Connect("clicked", func() {
+		relinks := make(map[int64]string)
+		used := make(map[string]bool)
+		for _, rola := range missing {
+			if best := rola.Best(); best != nil && !used[best.Path] {
+				relinks[rola.ID] = best.Path
+				used[best.Path] = true
+			}
+		}
+		question := fmt.Sprintf("Relink %d of the %d rolas to their likeliest files?", len(relinks), len(missing))
+		if len(relinks) == 0 || !view.Confirm(window.Win, question) {
+			return
+		}
+		var err error
+		for id, path := range relinks {
+			if err = principal.database.Relink(id, path); err != nil {
+				break
+			}
+		}
+		reload(err, "relink")
+	})
+
+	window.RemoveB.Connect("clicked", func() {
+		rola := selected()
+		if rola == nil {
+			principal.showStatus("choose a rola first")
+			return
+		}
+		if !view.Confirm(window.Win, "Remove "+rola.Title+" from the library?") {
+			return
+		}
+		principal.database.RemoveRola(rola.ID)
+		reload(nil, "remove")
+	})
+
+	window.RemoveAllB.Connect("clicked", func() {
+		question := fmt.Sprintf("Remove the %d rolas whose files are missing from the library?", len(missing))
+		if len(missing) == 0 || !view.Confirm(window.Win, question) {
+			return
+		}
+		for _, rola := range missing {
+			principal.database.RemoveRola(rola.ID)
+		}
+		reload(nil, "remove")
+	})
+}
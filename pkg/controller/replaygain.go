@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// scanReplayGain measures, in the background, the loudness of the rolas
+// whose loudness is not known and of their albums, telling the progress
+// in the status bar.   When it is done the gains are shown in the tree
+// view, and the ReplayGain tags are written into the files, after
+// asking.
+func (principal *Principal) scanReplayGain() {
+	button := principal.mainWindow.Buttons["replaygain"]
+	button.SetSensitive(false)
+	go func() {
+		measured := principal.database.ScanReplayGain(nil, func(done, total int) {
+			glib.IdleAdd(principal.showStatus, fmt.Sprintf("measuring the loudness of the rolas, %d/%d", done, total))
+		})
+		glib.IdleAdd(func() {
+			button.SetSensitive(true)
+			for _, id := range measured {
+				principal.treeview.updateGain(principal.database.QueryRola(id))
+			}
+			principal.showStatus(fmt.Sprintf("loudness of %d rolas measured", len(measured)))
+			question := fmt.Sprintf("Write the ReplayGain tags into the files of the %d rolas measured?", len(measured))
+			if len(measured) == 0 || !view.Confirm(principal.mainWindow.Win, question) {
+				return
+			}
+			written := principal.database.WriteReplayGainTags(measured)
+			principal.showStatus(fmt.Sprintf("ReplayGain tags written into %d of %d files", written, len(measured)))
+		})
+	}()
+}
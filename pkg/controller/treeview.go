@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
 	"github.com/Japodrilo/MyP-Proyecto2/pkg/view"
@@ -17,25 +20,37 @@ const (
 	COLUMN_PATH
 	COLUMN_VISIBLE
 	COLUMN_ID
+	COLUMN_DURATION
+	COLUMN_BITRATE
+	COLUMN_SAMPLE_RATE
+	COLUMN_CHANNELS
+	COLUMN_VBR
+	COLUMN_AVAILABLE
+	COLUMN_TRACK_GAIN
+	COLUMN_ALBUM_GAIN
 )
 
 // TreeView represents the tree view in the main window of
 // the application.   It contains the gtk window form the view
-// module, and a dictionary with Rola id's as keys, and the rows
-// of the tree view as entries (*gtk.TreeIter).
+// module, a dictionary with Rola id's as keys, and the rows
+// of the tree view as entries (*gtk.TreeIter), and another one
+// with the durations of the Rolas in the rows.
 type TreeView struct {
 	*view.TreeView
-	Rows map[int64]*gtk.TreeIter
+	Rows      map[int64]*gtk.TreeIter
+	Durations map[int64]time.Duration
 }
 
 // NewTreeView takes as an argument a view.TreeView, and creates
-// a new map to hold the Rola id's and rows of the tree view. It
-// returns a TreeView.
+// new maps to hold the Rola id's, rows and durations of the tree
+// view. It returns a TreeView.
 func NewTreeView(treeView *view.TreeView) *TreeView {
 	Rows := make(map[int64]*gtk.TreeIter)
+	Durations := make(map[int64]time.Duration)
 	return &TreeView{
 		treeView,
 		Rows,
+		Durations,
 	}
 }
 
@@ -43,48 +58,74 @@ func NewTreeView(treeView *view.TreeView) *TreeView {
 // This is mainly used to pass a row's information as an argument to
 // glib.IdleAdd().
 type RowInfo struct {
-	title   string
-	artist  string
-	album   string
-	genre   string
-	path    string
-	visible bool
-	id      int64
+	title      string
+	artist     string
+	album      string
+	genre      string
+	path       string
+	visible    bool
+	id         int64
+	duration   time.Duration
+	bitrate    int
+	sampleRate int
+	channels   int
+	vbr        bool
+	available  bool
+	gain       *model.ReplayGain
+}
+
+// newRowInfo creates the RowInfo corresponding to a visible row
+// holding a Rola whose file was just found.
+func newRowInfo(rola *model.Rola) *RowInfo {
+	return &RowInfo{
+		title:      rola.Title(),
+		artist:     rola.Artist(),
+		album:      rola.Album(),
+		genre:      rola.Genre(),
+		path:       rola.Path(),
+		visible:    true,
+		id:         rola.ID(),
+		duration:   rola.Duration(),
+		bitrate:    rola.Bitrate(),
+		sampleRate: rola.SampleRate(),
+		channels:   rola.Channels(),
+		vbr:        rola.VBR(),
+		available:  true,
+		gain:       rola.ReplayGain(),
+	}
 }
 
 // Unexported method to append a row to the list store for the tree view.
-// TODO: use RowInfo as parameter.
-func (treeview *TreeView) addRow(title, artist, album, genre, path string, visible bool, id int64) {
+func (treeview *TreeView) addRowStruct(rowInfo *RowInfo) {
 	iter := treeview.ListStore.Append()
 
 	err := treeview.ListStore.Set(iter,
-		[]int{COLUMN_TITLE, COLUMN_ARTIST, COLUMN_ALBUM, COLUMN_GENRE, COLUMN_PATH, COLUMN_VISIBLE, COLUMN_ID},
-		[]interface{}{title, artist, album, genre, path, visible, id})
+		[]int{COLUMN_TITLE, COLUMN_ARTIST, COLUMN_ALBUM, COLUMN_GENRE, COLUMN_PATH, COLUMN_VISIBLE, COLUMN_ID,
+			COLUMN_DURATION, COLUMN_BITRATE, COLUMN_SAMPLE_RATE, COLUMN_CHANNELS, COLUMN_VBR, COLUMN_AVAILABLE,
+			COLUMN_TRACK_GAIN, COLUMN_ALBUM_GAIN},
+		[]interface{}{rowInfo.title, rowInfo.artist, rowInfo.album, rowInfo.genre, rowInfo.path, rowInfo.visible, rowInfo.id,
+			model.FormatDuration(rowInfo.duration), formatBitrate(rowInfo.bitrate), formatSampleRate(rowInfo.sampleRate),
+			formatChannels(rowInfo.channels), formatMode(rowInfo.vbr, rowInfo.bitrate), rowInfo.available,
+			formatGain(rowInfo.gain.TrackLoudness), formatGain(rowInfo.gain.AlbumLoudness)})
 
 	if err != nil {
 		log.Fatal("Unable to add row:", err)
 	}
-	treeview.Rows[id] = iter
-}
-
-// Unexported method to append a row to the list store for the tree view.
-func (treeview *TreeView) addRowStruct(rowInfo *RowInfo) {
-	treeview.addRow(rowInfo.title, rowInfo.artist, rowInfo.album, rowInfo.genre, rowInfo.path, rowInfo.visible, rowInfo.id)
+	treeview.Rows[rowInfo.id] = iter
+	treeview.Durations[rowInfo.id] = rowInfo.duration
 }
 
 // Unexported method to append a row to the list store for the
 // tree view directly from a Rola.
 func (treeview *TreeView) addRowFromRola(rola *model.Rola) {
-	iter := treeview.ListStore.Append()
-
-	err := treeview.ListStore.Set(iter,
-		[]int{COLUMN_TITLE, COLUMN_ARTIST, COLUMN_ALBUM, COLUMN_GENRE, COLUMN_PATH, COLUMN_VISIBLE, COLUMN_ID},
-		[]interface{}{rola.Title(), rola.Artist(), rola.Album(), rola.Genre(), rola.Path(), true, rola.ID()})
+	treeview.addRowStruct(newRowInfo(rola))
+}
 
-	if err != nil {
-		log.Fatal("Unable to add row:", err)
-	}
-	treeview.Rows[rola.ID()] = iter
+// Unexported method to remove all the rows of the tree view.
+func (treeview *TreeView) clear() {
+	treeview.ListStore.Clear()
+	treeview.Rows = make(map[int64]*gtk.TreeIter)
+	treeview.Durations = make(map[int64]time.Duration)
 }
 
 // Unexported method to update the performer of a Rola in the
@@ -104,6 +145,50 @@ func (treeview *TreeView) updateRow(rola *model.Rola) {
 	treeview.ListStore.SetValue(iter, 3, rola.Genre())
 }
 
+// setAvailable greys out the row of a Rola whose file is missing, or
+// shows it normally again.
+func (treeview *TreeView) setAvailable(rolaID int64, available bool) {
+	if iter, ok := treeview.Rows[rolaID]; ok {
+		treeview.ListStore.SetValue(iter, COLUMN_AVAILABLE, available)
+	}
+}
+
+// updateGain shows the track and album gains of a Rola in its row.
+func (treeview *TreeView) updateGain(rola *model.Rola) {
+	if iter, ok := treeview.Rows[rola.ID()]; ok {
+		gain := rola.ReplayGain()
+		treeview.ListStore.SetValue(iter, COLUMN_TRACK_GAIN, formatGain(gain.TrackLoudness))
+		treeview.ListStore.SetValue(iter, COLUMN_ALBUM_GAIN, formatGain(gain.AlbumLoudness))
+	}
+}
+
+// TotalDuration returns the sum of the durations of the Rolas whose
+// IDs are taken as an argument.
+func (treeview *TreeView) TotalDuration(ids []int64) time.Duration {
+	var total time.Duration
+	for _, id := range ids {
+		total += treeview.Durations[id]
+	}
+	return total
+}
+
+// VisibleIDs returns the IDs of the Rolas in the visible rows of the
+// tree view, sorted.
+func (treeview *TreeView) VisibleIDs() []int64 {
+	ids := make([]int64, 0, len(treeview.Rows))
+	for id, iter := range treeview.Rows {
+		cell, err := treeview.ListStore.GetValue(iter, COLUMN_VISIBLE)
+		if err != nil {
+			continue
+		}
+		if visible, _ := cell.GoValue(); visible == true {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 // AllVisible makes all the rows of the tree view visible.
 func (treeview *TreeView) AllVisible() {
 	iter, ok := treeview.ListStore.GetIterFirst()
@@ -127,3 +212,48 @@ func (treeview *TreeView) AllInvisible() {
 	}
 	sel.SetMode(gtk.SELECTION_SINGLE)
 }
+
+func formatBitrate(bitrate int) string {
+	if bitrate == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d kbps", bitrate)
+}
+
+func formatSampleRate(sampleRate int) string {
+	if sampleRate == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f kHz", float64(sampleRate)/1000)
+}
+
+// formatGain formats the gain that brings a loudness to the reference of
+// ReplayGain, or nothing if the loudness is not known.
+func formatGain(loudness float64) string {
+	if loudness == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.2f dB", model.ReplayGainReference-loudness)
+}
+
+func formatChannels(channels int) string {
+	switch channels {
+	case 0:
+		return ""
+	case 1:
+		return "Mono"
+	case 2:
+		return "Stereo"
+	}
+	return fmt.Sprintf("%d", channels)
+}
+
+func formatMode(vbr bool, bitrate int) string {
+	switch {
+	case bitrate == 0:
+		return ""
+	case vbr:
+		return "VBR"
+	}
+	return "CBR"
+}
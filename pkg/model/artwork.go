@@ -0,0 +1,259 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	// Needed for decoding gif images with image
+	_ "image/gif"
+	"image/jpeg"
+	// Needed for decoding png images with image
+	_ "image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ArtworkSizes are the sizes, in pixels, of the thumbnails kept by the
+// ArtworkCache for every picture.   A thumbnail fits in a square of its
+// size, keeping the proportions of the original picture.
+var ArtworkSizes = []int{64, 128, 250, 500}
+
+// coverNames are the names, without extension, of the picture files
+// looked for in the album directories when a Rola has no picture in
+// its tag.
+var coverNames = []string{"cover", "folder", "front", "album", "albumart"}
+
+// artworkMemory is the number of thumbnails kept in memory.
+const artworkMemory = 256
+
+// ErrNoArtwork is returned by the ArtworkCache when a picture is not
+// in the cache.
+var ErrNoArtwork = errors.New("artwork not in the cache")
+
+// An ArtworkCache keeps the pictures of the Rolas and albums in the
+// directory "~/.cache/rolas/artwork".   Pictures are addressed by the
+// sha1 hash of their contents, so a picture shared by all the Rolas of
+// an album is stored once; for every picture the cache keeps the
+// original file and a thumbnail for each of the ArtworkSizes.   The
+// most recently used thumbnails are also kept in memory.   It is a
+// singleton, safe for concurrent use.
+type ArtworkCache struct {
+	dir    string
+	mutex  sync.Mutex
+	memory map[string][]byte
+	order  []string
+}
+
+var (
+	instanceA *ArtworkCache
+	onceA     sync.Once
+)
+
+// GetArtworkCache returns the singleton instance of ArtworkCache.
+func GetArtworkCache() *ArtworkCache {
+	onceA.Do(func() {
+		home, err := user.Current()
+		if err != nil {
+			log.Fatal("could not retrieve the current user:", err)
+		}
+		instanceA = &ArtworkCache{
+			dir:    home.HomeDir + "/.cache/rolas/artwork",
+			memory: make(map[string][]byte),
+			order:  make([]string, 0),
+		}
+	})
+	return instanceA
+}
+
+// Store adds a picture to the cache, given the contents of its file,
+// and returns its hash.   The picture is only decoded and resized if
+// it was not already in the cache.
+func (cache *ArtworkCache) Store(data []byte) (string, error) {
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	dir := filepath.Join(cache.dir, hash)
+	if _, err := os.Stat(filepath.Join(dir, "original")); err == nil {
+		return hash, nil
+	}
+
+	picture, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	for _, size := range ArtworkSizes {
+		var buffer bytes.Buffer
+		err = jpeg.Encode(&buffer, thumbnail(picture, size), &jpeg.Options{Quality: 90})
+		if err != nil {
+			return "", err
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(size)+".jpg"), buffer.Bytes(), 0600)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "original"), data, 0600)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// StoreFile adds the picture in a file to the cache and returns its
+// hash.
+func (cache *ArtworkCache) StoreFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return cache.Store(data)
+}
+
+// Thumbnail returns the contents of the jpeg thumbnail of the given
+// size for the picture with the given hash.   The size should be one of
+// the ArtworkSizes.
+func (cache *ArtworkCache) Thumbnail(hash string, size int) ([]byte, error) {
+	return cache.load(hash, strconv.Itoa(size)+".jpg")
+}
+
+// Original returns the contents of the file the picture with the given
+// hash was stored from.
+func (cache *ArtworkCache) Original(hash string) ([]byte, error) {
+	return cache.load(hash, "original")
+}
+
+// ThumbnailPath returns the path of the file of the jpeg thumbnail of
+// the given size for the picture with the given hash, for the programs
+// that take pictures by their location.
+func (cache *ArtworkCache) ThumbnailPath(hash string, size int) (string, error) {
+	if hash == "" {
+		return "", ErrNoArtwork
+	}
+	path := filepath.Join(cache.dir, hash, strconv.Itoa(size)+".jpg")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", ErrNoArtwork
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// load reads a file from the directory of a picture, first looking for
+// it in memory.
+func (cache *ArtworkCache) load(hash, name string) ([]byte, error) {
+	if hash == "" {
+		return nil, ErrNoArtwork
+	}
+	key := hash + "/" + name
+	cache.mutex.Lock()
+	data, ok := cache.memory[key]
+	cache.mutex.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(cache.dir, hash, name))
+	if os.IsNotExist(err) {
+		return nil, ErrNoArtwork
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if _, ok := cache.memory[key]; !ok {
+		if len(cache.order) == artworkMemory {
+			delete(cache.memory, cache.order[0])
+			cache.order = cache.order[1:]
+		}
+		cache.memory[key] = data
+		cache.order = append(cache.order, key)
+	}
+	return data, nil
+}
+
+// FindCover looks in a directory for a picture file named like the
+// usual album covers (cover.jpg, folder.png...) and returns its path,
+// or an empty string if there is none.
+func FindCover(dir string) string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, name := range coverNames {
+		for _, file := range files {
+			extension := strings.ToLower(filepath.Ext(file.Name()))
+			if extension != ".jpg" && extension != ".jpeg" && extension != ".png" {
+				continue
+			}
+			if strings.ToLower(strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))) == name {
+				return filepath.Join(dir, file.Name())
+			}
+		}
+	}
+	return ""
+}
+
+// thumbnail scales a picture to fit in a square of the given size,
+// averaging the pixels of the original that fall in each pixel of the
+// thumbnail.   Pictures smaller than the square are not enlarged.
+func thumbnail(picture image.Image, size int) image.Image {
+	bounds := picture.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return picture
+	}
+	newWidth, newHeight := size, size
+	if width > height {
+		newHeight = height * size / width
+	} else {
+		newWidth = width * size / height
+	}
+	if newWidth == 0 {
+		newWidth = 1
+	}
+	if newHeight == 0 {
+		newHeight = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := picture.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			scaled.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return scaled
+}
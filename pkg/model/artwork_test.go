@@ -0,0 +1,97 @@
+package model
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestArtworkCache(t *testing.T) (*ArtworkCache, string) {
+	dir, err := ioutil.TempDir("", "artwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &ArtworkCache{
+		dir:    dir,
+		memory: make(map[string][]byte),
+		order:  make([]string, 0),
+	}
+	return cache, dir
+}
+
+func testPicture(width, height int) []byte {
+	picture := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			picture.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buffer bytes.Buffer
+	png.Encode(&buffer, picture)
+	return buffer.Bytes()
+}
+
+func TestArtworkStore(t *testing.T) {
+	cache, dir := newTestArtworkCache(t)
+	defer os.RemoveAll(dir)
+
+	data := testPicture(600, 300)
+	hash, err := cache.Store(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, _ := cache.Store(data)
+	if again != hash {
+		t.Errorf("expecting %v, received %v", hash, again)
+	}
+	for _, size := range ArtworkSizes {
+		thumb, err := cache.Thumbnail(hash, size)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config.Width != size || config.Height != size/2 {
+			t.Errorf("expecting %vx%v, received %vx%v", size, size/2, config.Width, config.Height)
+		}
+	}
+	original, err := cache.Original(hash)
+	if err != nil || !bytes.Equal(original, data) {
+		t.Errorf("the original picture was not kept")
+	}
+	if _, err := cache.Thumbnail("missing", 64); err != ErrNoArtwork {
+		t.Errorf("expecting %v, received %v", ErrNoArtwork, err)
+	}
+	path, err := cache.ThumbnailPath(hash, 64)
+	if expected := filepath.Join(dir, hash, "64.jpg"); err != nil || path != expected {
+		t.Errorf("expecting %v, received %v", expected, path)
+	}
+	if _, err := cache.ThumbnailPath("missing", 64); err != ErrNoArtwork {
+		t.Errorf("expecting %v, received %v", ErrNoArtwork, err)
+	}
+}
+
+func TestFindCover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "album")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if FindCover(dir) != "" {
+		t.Errorf("expecting no cover, received %v", FindCover(dir))
+	}
+	ioutil.WriteFile(filepath.Join(dir, "Folder.PNG"), testPicture(10, 10), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("cover"), 0600)
+	expecting := filepath.Join(dir, "Folder.PNG")
+	if FindCover(dir) != expecting {
+		t.Errorf("expecting %v, received %v", expecting, FindCover(dir))
+	}
+}
@@ -0,0 +1,411 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// AudioProperties holds the properties of the audio stream of a file,
+// namely, its duration, its average bitrate in kbps, its sample rate
+// in Hz, the number of channels, and whether the bitrate is variable.
+type AudioProperties struct {
+	Duration   time.Duration
+	Bitrate    int
+	SampleRate int
+	Channels   int
+	VBR        bool
+}
+
+// ErrUnknownFormat is returned by ReadAudioProperties when the stream
+// is neither an MP3 nor a FLAC stream.
+var ErrUnknownFormat = errors.New("unknown audio format")
+
+// ReadAudioProperties reads the properties of the audio stream in the
+// reader.   MP3 streams are measured from the first frame header, and
+// the Xing, Info or VBRI header when present; FLAC streams from the
+// STREAMINFO block.
+func ReadAudioProperties(reader io.ReadSeeker) (*AudioProperties, error) {
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	_, err = reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	start, err := skipID3v2(reader)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(reader, magic)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) == "fLaC" {
+		return readFLACProperties(reader, size)
+	}
+	_, err = reader.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return readMP3Properties(reader, start, size)
+}
+
+// AudioHash returns the sha1 hash of the audio stream in the reader,
+// without the ID3v2 tag before it, the metadata blocks of a FLAC stream
+// or the ID3v1 tag after it, so that two copies of a file with different
+// tags have the same hash.
+func AudioHash(reader io.ReadSeeker) (string, error) {
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	_, err = reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	start, err := skipID3v2(reader)
+	if err != nil {
+		return "", err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(reader, magic)
+	if err != nil {
+		return "", err
+	}
+	if string(magic) == "fLaC" {
+		start, err = skipFLACMetadata(reader)
+		if err != nil {
+			return "", err
+		}
+	}
+	end := size
+	if size-start >= 128 {
+		trailer := make([]byte, 3)
+		_, err = reader.Seek(size-128, io.SeekStart)
+		if err == nil {
+			_, err = io.ReadFull(reader, trailer)
+		}
+		if err != nil {
+			return "", err
+		}
+		if string(trailer) == "TAG" {
+			end -= 128
+		}
+	}
+	_, err = reader.Seek(start, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.New()
+	_, err = io.CopyN(hash, reader, end-start)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// skipFLACMetadata positions the reader, placed after the "fLaC" marker,
+// after the last metadata block, and returns the new position.
+func skipFLACMetadata(reader io.ReadSeeker) (int64, error) {
+	header := make([]byte, 4)
+	for last := false; !last; {
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return 0, err
+		}
+		last = header[0]&0x80 != 0
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		_, err = reader.Seek(length, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return reader.Seek(0, io.SeekCurrent)
+}
+
+// FormatDuration returns a duration as h:mm:ss, or as m:ss if it is
+// shorter than an hour.
+func FormatDuration(duration time.Duration) string {
+	seconds := int64((duration + time.Second/2) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// fractionDuration returns the duration of n/d seconds, such as n samples
+// at a rate of d per second.   The whole seconds are divided first, so
+// that the count of samples or bits of a long file does not overflow.
+func fractionDuration(n, d int64) time.Duration {
+	return time.Duration(n/d)*time.Second + time.Duration(n%d)*time.Second/time.Duration(d)
+}
+
+// skipID3v2 positions the reader after the ID3v2 tag at its current
+// position, if there is any, and returns the new position.
+func skipID3v2(reader io.ReadSeeker) (int64, error) {
+	header := make([]byte, 10)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return 0, err
+	}
+	if string(header[:3]) != "ID3" {
+		return reader.Seek(-10, io.SeekCurrent)
+	}
+	size := int64(syncsafe(header[6:10])) + 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return reader.Seek(size-10, io.SeekCurrent)
+}
+
+// syncsafe decodes a 28 bit integer stored in four bytes with their
+// most significant bit cleared.
+func syncsafe(data []byte) uint32 {
+	return uint32(data[0]&0x7f)<<21 | uint32(data[1]&0x7f)<<14 |
+		uint32(data[2]&0x7f)<<7 | uint32(data[3]&0x7f)
+}
+
+// readFLACProperties reads the STREAMINFO block, which is always the
+// first metadata block of a FLAC stream, and measures the audio frames
+// that follow the metadata blocks to compute the average bitrate.
+func readFLACProperties(reader io.ReadSeeker, size int64) (*AudioProperties, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x7f != 0 {
+		return nil, errors.New("missing STREAMINFO block")
+	}
+	info := make([]byte, 34)
+	_, err = io.ReadFull(reader, info)
+	if err != nil {
+		return nil, err
+	}
+	last := header[0]&0x80 != 0
+	length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+	_, err = reader.Seek(length-34, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	for !last {
+		_, err = io.ReadFull(reader, header)
+		if err != nil {
+			return nil, err
+		}
+		last = header[0]&0x80 != 0
+		length = int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		_, err = reader.Seek(length, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := binary.BigEndian.Uint64(info[10:18])
+	sampleRate := int(packed >> 44)
+	channels := int(packed>>41&0x7) + 1
+	samples := int64(packed & 0xfffffffff)
+	if sampleRate == 0 {
+		return nil, errors.New("invalid STREAMINFO block")
+	}
+	properties := &AudioProperties{
+		Duration:   fractionDuration(samples, int64(sampleRate)),
+		SampleRate: sampleRate,
+		Channels:   channels,
+		VBR:        true,
+	}
+	if samples > 0 {
+		properties.Bitrate = int((size - offset) * 8 * int64(sampleRate) / samples / 1000)
+	}
+	return properties, nil
+}
+
+// mp3Bitrates holds the bitrates in kbps, indexed by MPEG version (1 or
+// 2 and 2.5), layer and bitrate index of a frame header.
+var mp3Bitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// mp3SampleRates holds the sample rates of MPEG 1, 2 and 2.5, indexed
+// by the sample rate index of a frame header.
+var mp3SampleRates = [3][3]int{
+	{44100, 48000, 32000},
+	{22050, 24000, 16000},
+	{11025, 12000, 8000},
+}
+
+// mp3Frame holds the fields of an MPEG audio frame header.
+type mp3Frame struct {
+	version    int
+	layer      int
+	bitrate    int
+	sampleRate int
+	channels   int
+	samples    int
+	length     int
+}
+
+// parseMP3Frame decodes the four bytes of an MPEG audio frame header.
+func parseMP3Frame(header []byte) (*mp3Frame, bool) {
+	if header[0] != 0xff || header[1]&0xe0 != 0xe0 {
+		return nil, false
+	}
+	var version int
+	switch header[1] >> 3 & 0x3 {
+	case 3:
+		version = 1
+	case 2:
+		version = 2
+	case 0:
+		version = 3
+	default:
+		return nil, false
+	}
+	layer := 4 - int(header[1]>>1&0x3)
+	bitrateIndex := int(header[2] >> 4)
+	rateIndex := int(header[2] >> 2 & 0x3)
+	if layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return nil, false
+	}
+	table := 0
+	if version > 1 {
+		table = 1
+	}
+	frame := &mp3Frame{
+		version:    version,
+		layer:      layer,
+		bitrate:    mp3Bitrates[table][layer-1][bitrateIndex],
+		sampleRate: mp3SampleRates[version-1][rateIndex],
+		channels:   2,
+	}
+	if header[3]>>6 == 3 {
+		frame.channels = 1
+	}
+	padding := int(header[2] >> 1 & 0x1)
+	switch {
+	case layer == 1:
+		frame.samples = 384
+		frame.length = (12*frame.bitrate*1000/frame.sampleRate + padding) * 4
+	case layer == 3 && version > 1:
+		frame.samples = 576
+		frame.length = 72*frame.bitrate*1000/frame.sampleRate + padding
+	default:
+		frame.samples = 1152
+		frame.length = 144*frame.bitrate*1000/frame.sampleRate + padding
+	}
+	return frame, true
+}
+
+// readMP3Properties looks for the first frame header of the stream,
+// checking that another frame header follows it, and computes the
+// properties from the Xing or VBRI header in that frame, or from the
+// bitrate of the frame if the stream has a constant bitrate.
+func readMP3Properties(reader io.ReadSeeker, start, size int64) (*AudioProperties, error) {
+	const window = 64 * 1024
+	data := make([]byte, window)
+	n, err := io.ReadFull(reader, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	data = data[:n]
+
+	for i := 0; i+4 <= len(data); i++ {
+		frame, ok := parseMP3Frame(data[i : i+4])
+		if !ok {
+			continue
+		}
+		next := i + frame.length
+		if next+4 <= len(data) {
+			if _, ok := parseMP3Frame(data[next : next+4]); !ok {
+				continue
+			}
+		}
+		audioBytes := size - start - int64(i)
+		tail := make([]byte, 3)
+		if _, err := reader.Seek(-128, io.SeekEnd); err == nil {
+			if _, err := io.ReadFull(reader, tail); err == nil && string(tail) == "TAG" {
+				audioBytes -= 128
+			}
+		}
+		properties := &AudioProperties{
+			Bitrate:    frame.bitrate,
+			SampleRate: frame.sampleRate,
+			Channels:   frame.channels,
+		}
+		frames, bytesCount, vbr, found := vbrHeader(data[i:], frame)
+		if found && frames > 0 {
+			properties.Duration = fractionDuration(frames*int64(frame.samples), int64(frame.sampleRate))
+			properties.VBR = vbr
+			if bytesCount == 0 {
+				bytesCount = audioBytes
+			}
+			if properties.Duration > 0 {
+				properties.Bitrate = int(float64(bytesCount*8) / properties.Duration.Seconds() / 1000)
+			}
+			return properties, nil
+		}
+		properties.Duration = fractionDuration(audioBytes*8, int64(frame.bitrate*1000))
+		return properties, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// vbrHeader looks for a Xing, Info or VBRI header in the frame at the
+// start of data, and returns the number of frames and bytes of the
+// stream it declares, whether the bitrate is variable (an Info header
+// is written by encoders for constant bitrate streams), and whether a
+// header was found at all.
+func vbrHeader(data []byte, frame *mp3Frame) (int64, int64, bool, bool) {
+	offset := 4
+	switch {
+	case frame.version == 1 && frame.channels == 2:
+		offset += 32
+	case frame.version == 1 || frame.channels == 2:
+		offset += 17
+	default:
+		offset += 9
+	}
+	if offset+16 <= len(data) {
+		id := string(data[offset : offset+4])
+		if id == "Xing" || id == "Info" {
+			flags := binary.BigEndian.Uint32(data[offset+4 : offset+8])
+			position := offset + 8
+			var frames, bytesCount int64
+			if flags&0x1 != 0 {
+				frames = int64(binary.BigEndian.Uint32(data[position : position+4]))
+				position += 4
+			}
+			if flags&0x2 != 0 && position+4 <= len(data) {
+				bytesCount = int64(binary.BigEndian.Uint32(data[position : position+4]))
+			}
+			return frames, bytesCount, id == "Xing", true
+		}
+	}
+	if 36+18 <= len(data) && bytes.Equal(data[36:40], []byte("VBRI")) {
+		bytesCount := int64(binary.BigEndian.Uint32(data[46:50]))
+		frames := int64(binary.BigEndian.Uint32(data[50:54]))
+		return frames, bytesCount, true, true
+	}
+	return 0, 0, false, false
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func cbrStream(frames int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	stream := []byte("ID3\x03\x00\x00\x00\x00\x00\x0a")
+	stream = append(stream, make([]byte, 10)...)
+	for i := 0; i < frames; i++ {
+		stream = append(stream, frame...)
+	}
+	return stream
+}
+
+func TestReadAudioPropertiesCBR(t *testing.T) {
+	properties, err := ReadAudioProperties(bytes.NewReader(cbrStream(100)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if properties.Bitrate != 128 {
+		t.Errorf("expecting %v, received %v", 128, properties.Bitrate)
+	}
+	if properties.SampleRate != 44100 {
+		t.Errorf("expecting %v, received %v", 44100, properties.SampleRate)
+	}
+	if properties.Channels != 2 {
+		t.Errorf("expecting %v, received %v", 2, properties.Channels)
+	}
+	if properties.VBR {
+		t.Errorf("expecting %v, received %v", false, properties.VBR)
+	}
+	expecting := 2606250 * time.Microsecond
+	if properties.Duration != expecting {
+		t.Errorf("expecting %v, received %v", expecting, properties.Duration)
+	}
+}
+
+func TestReadAudioPropertiesXing(t *testing.T) {
+	stream := cbrStream(3)
+	xing := stream[20+36:]
+	copy(xing, []byte("Xing"))
+	binary.BigEndian.PutUint32(xing[4:], 3)
+	binary.BigEndian.PutUint32(xing[8:], 1000)
+	binary.BigEndian.PutUint32(xing[12:], 800000)
+	properties, err := ReadAudioProperties(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !properties.VBR {
+		t.Errorf("expecting %v, received %v", true, properties.VBR)
+	}
+	expecting := time.Duration(1000*1152) * time.Second / 44100
+	if properties.Duration != expecting {
+		t.Errorf("expecting %v, received %v", expecting, properties.Duration)
+	}
+	if properties.Bitrate != 245 {
+		t.Errorf("expecting %v, received %v", 245, properties.Bitrate)
+	}
+}
+
+func TestReadAudioPropertiesLong(t *testing.T) {
+	reader := bytes.NewReader(cbrStream(3))
+	reader.Seek(20, io.SeekStart)
+	properties, err := readMP3Properties(reader, 20, 20+2<<30)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expecting := 134217728 * time.Millisecond
+	if properties.Duration != expecting {
+		t.Errorf("expecting %v, received %v", expecting, properties.Duration)
+	}
+
+	stream := cbrStream(3)
+	xing := stream[20+36:]
+	copy(xing, []byte("Xing"))
+	binary.BigEndian.PutUint32(xing[4:], 3)
+	binary.BigEndian.PutUint32(xing[8:], 10000000)
+	binary.BigEndian.PutUint32(xing[12:], 4000000000)
+	properties, err = ReadAudioProperties(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expecting = 261224*time.Second + 489795918*time.Nanosecond
+	if properties.Duration != expecting {
+		t.Errorf("expecting %v, received %v", expecting, properties.Duration)
+	}
+	if properties.Bitrate != 122 {
+		t.Errorf("expecting %v, received %v", 122, properties.Bitrate)
+	}
+}
+
+func TestReadAudioPropertiesFLAC(t *testing.T) {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|441000)
+	stream := []byte("fLaC\x80\x00\x00\x22")
+	stream = append(stream, info...)
+	stream = append(stream, make([]byte, 882000)...)
+	properties, err := ReadAudioProperties(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if properties.Duration != 10*time.Second {
+		t.Errorf("expecting %v, received %v", 10*time.Second, properties.Duration)
+	}
+	if properties.Channels != 2 || properties.SampleRate != 44100 {
+		t.Errorf("unexpected properties %v", properties)
+	}
+	if properties.Bitrate != 705 {
+		t.Errorf("expecting %v, received %v", 705, properties.Bitrate)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	if FormatDuration(65*time.Second) != "1:05" {
+		t.Errorf("expecting %v, received %v", "1:05", FormatDuration(65*time.Second))
+	}
+	if FormatDuration(3725*time.Second) != "1:02:05" {
+		t.Errorf("expecting %v, received %v", "1:02:05", FormatDuration(3725*time.Second))
+	}
+}
+
+func TestAudioHash(t *testing.T) {
+	stream := cbrStream(10)
+	retagged := []byte("ID3\x03\x00\x00\x00\x00\x00\x14")
+	retagged = append(retagged, make([]byte, 20)...)
+	retagged = append(retagged, stream[20:]...)
+	trailer := append([]byte("TAG"), make([]byte, 125)...)
+	retagged = append(retagged, trailer...)
+
+	hash, err := AudioHash(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	received, err := AudioHash(bytes.NewReader(retagged))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != hash {
+		t.Errorf("expecting %v, received %v", hash, received)
+	}
+	received, _ = AudioHash(bytes.NewReader(cbrStream(11)))
+	if received == hash {
+		t.Errorf("expecting a hash other than %v", hash)
+	}
+}
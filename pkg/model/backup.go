@@ -0,0 +1,502 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupDriver is the name of the sqlite driver used to copy databases,
+// which hands the connections it opens to backupConns so the backup API
+// of SQLite can be used on them.
+const backupDriver = "sqlite3_backup"
+
+// backupPages is the number of pages copied in every step of a backup;
+// the database can be written by the application between the steps.
+const backupPages = 256
+
+// The default number of backups kept, and the default time between two
+// scheduled backups.
+const (
+	DefaultKeptBackups    = 7
+	DefaultBackupInterval = 24 * time.Hour
+)
+
+// backupTimeLayout is the layout of the time in the name of a backup.
+const backupTimeLayout = "20060102-150405"
+
+var (
+	backupConns = make(chan *sqlite3.SQLiteConn, 1)
+	backupMutex sync.Mutex
+)
+
+func init() {
+	sql.Register(backupDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			backupConns <- conn
+			return nil
+		},
+	})
+}
+
+// Backup copies the database, while it is being used, to the file with
+// the given path, which is replaced if it exists.
+func (database *Database) Backup(path string) error {
+	temporary := path + ".tmp"
+	os.Remove(temporary)
+	err := copyDatabase(temporary, database.path)
+	if err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// Restore replaces the contents of the database with those of a backup,
+// after checking its integrity, and brings its schema up to date.
+func (database *Database) Restore(path string) error {
+	err := checkBackup(path)
+	if err != nil {
+		return err
+	}
+	err = copyDatabase(database.path, path)
+	if err != nil {
+		return err
+	}
+	database.MigrateDB()
+	return nil
+}
+
+// copyDatabase copies the database in the file src to the file dest,
+// with the online backup API of SQLite.
+func copyDatabase(dest, src string) error {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+
+	srcDB, srcConn, err := openRaw(src)
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+	destDB, destConn, err := openRaw(dest)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	backup, err := destConn.Backup("main", srcConn, "main")
+	if err != nil {
+		return err
+	}
+	for {
+		done, err := backup.Step(backupPages)
+		if err != nil {
+			backup.Finish()
+			return err
+		}
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return backup.Finish()
+}
+
+// openRaw opens the database in the given file with a single connection,
+// and returns the connection of the driver.
+func openRaw(path string) (*sql.DB, *sqlite3.SQLiteConn, error) {
+	db, err := sql.Open(backupDriver, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db.SetMaxOpenConns(1)
+	err = db.Ping()
+	if err != nil {
+		select {
+		case <-backupConns:
+		default:
+		}
+		db.Close()
+		return nil, nil, err
+	}
+	return db, <-backupConns, nil
+}
+
+// checkBackup tells whether the file with the given path is a database
+// of the application that passes the integrity check of SQLite.
+func checkBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var tables int
+	err = db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'rolas'").Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("%s is not a database: %v", path, err)
+	}
+	if tables == 0 {
+		return fmt.Errorf("%s is not a database of rolas", path)
+	}
+	var result string
+	err = db.QueryRow("PRAGMA integrity_check").Scan(&result)
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s is damaged: %s", path, result)
+	}
+	return nil
+}
+
+// A BackupFile is a backup of the database kept by Backups.
+type BackupFile struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// Backups keeps copies of the database in a directory, named after the
+// time they were made, keeping only the newest ones.
+type Backups struct {
+	Dir  string
+	Keep int
+}
+
+// NewBackups returns the Backups kept in the directory "rolas/backups"
+// of the data directory of the user, $XDG_DATA_HOME or ~/.local/share,
+// which, unlike the cache directory of the database, is not meant to be
+// wiped.
+func NewBackups(keep int) *Backups {
+	return &Backups{Dir: dataDir("backups"), Keep: keep}
+}
+
+// dataDir returns the directory "rolas/<name>" of the data directory of
+// the user, or of the temporary directory if the user is unknown.
+func dataDir(name string) string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := user.Current()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "rolas", name)
+		}
+		data = filepath.Join(home.HomeDir, ".local", "share")
+	}
+	return filepath.Join(data, "rolas", name)
+}
+
+// Create makes a new backup of the database, and removes the oldest
+// backups beyond the number of backups to keep.
+func (backups *Backups) Create(database *Database) (*BackupFile, error) {
+	backup, err := backups.create(database)
+	if err != nil {
+		return nil, err
+	}
+	return backup, backups.rotate()
+}
+
+// create makes a new backup of the database.   The names of the backups
+// only have seconds, so a backup made in the same second as another one
+// is named after the next second that is free, instead of replacing it.
+func (backups *Backups) create(database *Database) (*BackupFile, error) {
+	err := os.MkdirAll(backups.Dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	path := filepath.Join(backups.Dir, "rolas-"+now.Format(backupTimeLayout)+".db")
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Second)
+		path = filepath.Join(backups.Dir, "rolas-"+now.Format(backupTimeLayout)+".db")
+	}
+	err = database.Backup(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupFile{Path: path, Time: now, Size: info.Size()}, nil
+}
+
+// List returns the backups in the directory, the newest first.
+func (backups *Backups) List() ([]*BackupFile, error) {
+	files, err := ioutil.ReadDir(backups.Dir)
+	if os.IsNotExist(err) {
+		return []*BackupFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*BackupFile, 0)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, "rolas-") || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "rolas-"), ".db")
+		made, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		list = append(list, &BackupFile{Path: filepath.Join(backups.Dir, name), Time: made, Size: file.Size()})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Time.After(list[j].Time)
+	})
+	return list, nil
+}
+
+// rotate removes the oldest backups beyond the number of backups to
+// keep; a Keep of zero or less keeps them all.
+func (backups *Backups) rotate() error {
+	if backups.Keep <= 0 {
+		return nil
+	}
+	list, err := backups.List()
+	if err != nil {
+		return err
+	}
+	for i := backups.Keep; i < len(list); i++ {
+		err = os.Remove(list[i].Path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the database with the backup in the given file.   The
+// database is backed up first, so the restore can be undone.
+func (backups *Backups) Restore(database *Database, path string) error {
+	err := checkBackup(path)
+	if err != nil {
+		return err
+	}
+	_, err = backups.create(database)
+	if err != nil {
+		return fmt.Errorf("could not back up the database before restoring: %v", err)
+	}
+	err = database.Restore(path)
+	if err != nil {
+		return err
+	}
+	return backups.rotate()
+}
+
+// Schedule backs up the database every interval until the returned
+// function is called, starting right away if the newest backup is older
+// than the interval.   The result of every backup is given to report,
+// which is called from another goroutine.
+func (backups *Backups) Schedule(database *Database, interval time.Duration, report func(*BackupFile, error)) func() {
+	stop := make(chan bool)
+	go func() {
+		wait := time.Duration(0)
+		if list, err := backups.List(); err == nil && len(list) > 0 {
+			if age := time.Since(list[0].Time); age < interval {
+				wait = interval - age
+			}
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+				report(backups.Create(database))
+				timer.Reset(interval)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+// The kinds of the problems found by Check.
+const (
+	IntegrityProblem  = "integrity"
+	ForeignKeyProblem = "foreign key"
+	MissingFile       = "missing file"
+	MissingPerson     = "missing person"
+	MissingGroup      = "missing group"
+	EmptyGroup        = "empty group"
+	InvalidDate       = "invalid date"
+)
+
+// A Problem is an inconsistency found in the library by Check; ID is the
+// id of the row of the table with the problem, if there is one.
+type Problem struct {
+	Kind        string `json:"kind"`
+	Table       string `json:"table,omitempty"`
+	ID          int64  `json:"id,omitempty"`
+	Description string `json:"description"`
+}
+
+func (problem *Problem) String() string {
+	if problem.Table == "" {
+		return problem.Kind + ": " + problem.Description
+	}
+	return fmt.Sprintf("%s: %s %d: %s", problem.Kind, problem.Table, problem.ID, problem.Description)
+}
+
+// Check looks for problems in the library: the integrity and foreign
+// key checks of SQLite, the rolas whose files are missing, the
+// performers that are persons or groups but are not linked to one, the
+// groups without members, and the dates that are not partial dates.
+func (database *Database) Check() []*Problem {
+	problems := make([]*Problem, 0)
+	rows, err := database.Database.Query("PRAGMA integrity_check")
+	if err != nil {
+		log.Fatal("could not check the integrity of the database: ", err)
+	}
+	for rows.Next() {
+		var result string
+		err = rows.Scan(&result)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if result != "ok" {
+			problems = append(problems, &Problem{Kind: IntegrityProblem, Description: result})
+		}
+	}
+	closeRows(rows)
+
+	rows, err = database.Database.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		log.Fatal("could not check the foreign keys of the database: ", err)
+	}
+	for rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var key int
+		err = rows.Scan(&table, &rowID, &parent, &key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		problems = append(problems, &Problem{Kind: ForeignKeyProblem, Table: table, ID: rowID.Int64,
+			Description: "refers to a missing row of " + parent})
+	}
+	closeRows(rows)
+
+	rows, err = database.Database.Query("SELECT id_rola, " +
+		"CASE WHEN container = '' THEN path ELSE container END FROM rolas")
+	if err != nil {
+		log.Fatal("could not query the rolas: ", err)
+	}
+	for rows.Next() {
+		var id int64
+		var path string
+		err = rows.Scan(&id, &path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, &Problem{Kind: MissingFile, Table: "rolas", ID: id,
+				Description: path + " does not exist"})
+		}
+	}
+	closeRows(rows)
+
+	rows, err = database.Database.Query("SELECT id_performer, name, id_type FROM performers " +
+		"WHERE (id_type = 0 AND id_person IS NULL) OR (id_type = 1 AND id_group IS NULL)")
+	if err != nil {
+		log.Fatal("could not query the performers: ", err)
+	}
+	for rows.Next() {
+		var id int64
+		var name string
+		var performerType int
+		err = rows.Scan(&id, &name, &performerType)
+		if err != nil {
+			log.Fatal(err)
+		}
+		problem := &Problem{Kind: MissingPerson, Table: "performers", ID: id,
+			Description: name + " is a person, but is not linked to one"}
+		if performerType == 1 {
+			problem.Kind, problem.Description = MissingGroup, name+" is a group, but is not linked to one"
+		}
+		problems = append(problems, problem)
+	}
+	closeRows(rows)
+
+	rows, err = database.Database.Query("SELECT id_group, name FROM groups " +
+		"WHERE NOT EXISTS (SELECT 1 FROM in_group WHERE in_group.id_group = groups.id_group)")
+	if err != nil {
+		log.Fatal("could not query the groups: ", err)
+	}
+	for rows.Next() {
+		var id int64
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		problems = append(problems, &Problem{Kind: EmptyGroup, Table: "groups", ID: id,
+			Description: name + " has no members"})
+	}
+	closeRows(rows)
+
+	problems = append(problems, database.checkDates("persons", "id_person", "stage_name",
+		"birth_date", "death_date")...)
+	problems = append(problems, database.checkDates("groups", "id_group", "name",
+		"start_date", "end_date")...)
+	return problems
+}
+
+// checkDates returns a problem for every date of the given columns of
+// a table that is neither empty nor a partial date.
+func (database *Database) checkDates(table, id, name string, columns ...string) []*Problem {
+	problems := make([]*Problem, 0)
+	for _, column := range columns {
+		rows, err := database.Database.Query(fmt.Sprintf("SELECT %s, %s, %s FROM %s WHERE %s <> ''",
+			id, name, column, table, column))
+		if err != nil {
+			log.Fatal("could not query the ", table, ": ", err)
+		}
+		for rows.Next() {
+			var rowID int64
+			var rowName, date string
+			err = rows.Scan(&rowID, &rowName, &date)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if normalized, err := NormalizeDate(date); err != nil || normalized != date {
+				problems = append(problems, &Problem{Kind: InvalidDate, Table: table, ID: rowID,
+					Description: fmt.Sprintf("%s has the %s %q, expecting YYYY, YYYY-MM or YYYY-MM-DD",
+						rowName, strings.Replace(column, "_", " ", 1), date)})
+			}
+		}
+		closeRows(rows)
+	}
+	return problems
+}
+
+// closeRows closes the rows of a query, after checking they were read
+// without errors.
+func closeRows(rows *sql.Rows) {
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows.Close()
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBackupsRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{
+		"rolas-20181003-211500.db",
+		"rolas-20181105-080000.db",
+		"rolas-20180901-000000.db",
+		"rolas-20181105-075959.db",
+		"rolas-notatime.db",
+		"notes.txt",
+	}
+	for _, name := range names {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0600)
+	}
+
+	backups := &Backups{Dir: dir, Keep: 2}
+	list, err := backups.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{names[1], names[3], names[0], names[2]}
+	if len(list) != len(expected) {
+		t.Fatalf("expecting %v backups, received %v", len(expected), len(list))
+	}
+	for i, backup := range list {
+		if filepath.Base(backup.Path) != expected[i] {
+			t.Errorf("expecting %v, received %v", expected[i], filepath.Base(backup.Path))
+		}
+		if backup.Size != int64(len(expected[i])) {
+			t.Errorf("expecting %v, received %v", len(expected[i]), backup.Size)
+		}
+	}
+	if list[0].Time.Hour() != 8 || list[0].Time.Day() != 5 {
+		t.Errorf("expecting 2018-11-05 08:00:00, received %v", list[0].Time)
+	}
+
+	err = backups.rotate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, name := range names {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if kept := i != 0 && i != 2; kept != (err == nil) {
+			t.Errorf("expecting %v to be kept: %v, received %v", name, kept, err == nil)
+		}
+	}
+
+	empty := &Backups{Dir: filepath.Join(dir, "missing"), Keep: 2}
+	if list, err := empty.List(); err != nil || len(list) != 0 {
+		t.Errorf("expecting no backups, received %v, %v", list, err)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	database, root, clean := minedTestLibrary(t, 3)
+	defer clean()
+	backups := &Backups{Dir: filepath.Join(root, "backups"), Keep: 5}
+
+	backup, err := backups.Create(database)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := checkBackup(backup.Path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	text := filepath.Join(root, "notes.txt")
+	ioutil.WriteFile(text, []byte("not a database"), 0644)
+	other := filepath.Join(root, "other.db")
+	db, _ := sql.Open("sqlite3", other)
+	db.Exec("CREATE TABLE songs (id INTEGER)")
+	db.Close()
+	for _, path := range []string{text, other, filepath.Join(root, "missing.db")} {
+		if err := backups.Restore(database, path); err == nil {
+			t.Errorf("expecting an error restoring %v", path)
+		}
+	}
+
+	database.RemoveRola(1)
+	err = backups.Restore(database, backup.Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rolas := database.AllRolas(); len(rolas) != 3 || rolas[0].ID() != 1 {
+		t.Errorf("expecting %v, received %v", 3, len(rolas))
+	}
+	if list, _ := backups.List(); len(list) != 2 {
+		t.Errorf("expecting %v, received %v", 2, len(list))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	database, _, clean := minedTestLibrary(t, 3)
+	defer clean()
+	if problems := database.Check(); len(problems) != 0 {
+		t.Errorf("expecting %v, received %v", 0, problems)
+	}
+
+	os.Remove(database.QueryPath(2))
+	conn, err := database.Database.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	statements := []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO rola_genres (id_rola, genre) VALUES (99, 'Rock')",
+		"PRAGMA foreign_keys = ON",
+		"UPDATE performers SET id_type = 0 WHERE id_performer = 1",
+		"INSERT INTO groups (id_group, name, start_date, end_date) VALUES (7, 'Nadie', '1990-13', '')",
+		"INSERT INTO persons (id_person, stage_name, birth_date, death_date) VALUES (8, 'Alguien', '', '2001-1-2')",
+	}
+	for _, statement := range statements {
+		_, err = conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", statement, err)
+		}
+	}
+	conn.Close()
+
+	received := make([]string, 0)
+	for _, problem := range database.Check() {
+		received = append(received, problem.Kind+" "+problem.Table)
+	}
+	sort.Strings(received)
+	expected := []string{
+		EmptyGroup + " groups",
+		ForeignKeyProblem + " rola_genres",
+		InvalidDate + " groups",
+		InvalidDate + " persons",
+		MissingFile + " rolas",
+		MissingPerson + " performers",
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// configFile reads the JSON file with the given name in the
+// configuration directory of rolas ($XDG_CONFIG_HOME/rolas or
+// ~/.config/rolas) into the value taken as argument, leaving the fields
+// missing from the file as they were.   A missing file leaves the value
+// untouched; a file that can not be read is logged, and false is
+// returned so the caller can go back to its defaults.
+func configFile(name string, into interface{}) bool {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := user.Current()
+		if err != nil {
+			log.Fatal("could not retrieve the current user:", err)
+		}
+		dir = filepath.Join(home.HomeDir, ".config")
+	}
+	path := filepath.Join(dir, "rolas", name)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, into)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("could not read the configuration in "+path+":", err)
+		return false
+	}
+	return true
+}
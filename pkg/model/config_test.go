@@ -0,0 +1,44 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.MkdirAll(filepath.Join(dir, "rolas"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "rolas", "good.json"), []byte(`{"name": "rolas"}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "rolas", "bad.json"), []byte(`{"name": `), 0644)
+
+	type config struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	cases := []struct {
+		file     string
+		ok       bool
+		expected config
+	}{
+		{"good.json", true, config{"rolas", 3}},
+		{"missing.json", true, config{"default", 3}},
+		{"bad.json", false, config{"default", 3}},
+	}
+	for _, c := range cases {
+		received := config{"default", 3}
+		if ok := configFile(c.file, &received); ok != c.ok {
+			t.Errorf("%v: expecting %v, received %v", c.file, c.ok, ok)
+		}
+		if c.ok && received != c.expected {
+			t.Errorf("%v: expecting %v, received %v", c.file, c.expected, received)
+		}
+	}
+}
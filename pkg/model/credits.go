@@ -0,0 +1,285 @@
+package model
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// The credits of the performers of a Rola.
+const (
+	CreditMain     = "main"
+	CreditFeatured = "featured"
+	CreditRemixer  = "remixer"
+	CreditComposer = "composer"
+)
+
+// A RolaPerformer is a row of the rola_performers table: a performer
+// credited in a Rola, with its credit and its position among the
+// performers of the Rola.
+type RolaPerformer struct {
+	RolaID      int64  `json:"rola_id" db:"id_rola"`
+	PerformerID int64  `json:"performer_id" db:"id_performer"`
+	Credit      string `json:"credit" db:"credit"`
+	Position    int    `json:"position" db:"position"`
+}
+
+// A PerformerCredit is a performer credited in a Rola, by name, with its
+// credit; the ID of the performer is 0 until it is in the database.
+type PerformerCredit struct {
+	PerformerID int64  `json:"performer_id,omitempty"`
+	Name        string `json:"name"`
+	Credit      string `json:"credit"`
+}
+
+// A CreditSplitter finds the performers credited in the tags of a Rola.
+// The artist is split at the Separators, and what follows one of the
+// Featuring words are featured performers, as in "A feat. B" or
+// "A (ft. B)"; a title like "Song (feat. B)" also credits B as featured,
+// and "Song (C Remix)" credits C as remixer, with the Remix words.   The
+// composers are split at the Separators as well.   The names in Keep
+// are never split, as "Simon & Garfunkel" if " & " is a separator.
+// The words are matched regardless of case.
+type CreditSplitter struct {
+	Separators []string `json:"separators"`
+	Featuring  []string `json:"featuring"`
+	Remix      []string `json:"remix"`
+	Keep       []string `json:"keep"`
+}
+
+// DefaultCreditSplitter returns the splitting rules used when there is
+// no configuration file; the null character separates the values of a
+// multi-valued ID3v2.4 frame.
+func DefaultCreditSplitter() *CreditSplitter {
+	return &CreditSplitter{
+		Separators: []string{"\x00", ";", " / "},
+		Featuring:  []string{"feat.", "feat", "ft.", "featuring"},
+		Remix:      []string{"remix"},
+		Keep:       []string{},
+	}
+}
+
+var (
+	instanceS *CreditSplitter
+	onceS     sync.Once
+)
+
+// GetCreditSplitter returns the singleton instance of CreditSplitter,
+// with the rules in credits.json in the configuration directory of
+// rolas (see configFile), if the file exists; the rules missing from
+// the file are the default ones.
+func GetCreditSplitter() *CreditSplitter {
+	onceS.Do(func() {
+		instanceS = DefaultCreditSplitter()
+		if !configFile("credits.json", instanceS) {
+			instanceS = DefaultCreditSplitter()
+		}
+	})
+	return instanceS
+}
+
+// Split returns the performers credited in the artist, the title and
+// the composer of a Rola, in that order and without repetitions.
+func (splitter *CreditSplitter) Split(artist, title, composer string) []*PerformerCredit {
+	credits := make([]*PerformerCredit, 0)
+	seen := make(map[string]bool)
+	add := func(names []string, credit string) {
+		for _, name := range names {
+			key := strings.ToLower(name) + "\x00" + credit
+			if !seen[key] {
+				seen[key] = true
+				credits = append(credits, &PerformerCredit{Name: name, Credit: credit})
+			}
+		}
+	}
+	main, featured := splitter.cutFeaturing(artist)
+	add(splitter.names(main), CreditMain)
+	add(splitter.names(featured), CreditFeatured)
+	_, featured = splitter.cutFeaturing(title)
+	add(splitter.names(featured), CreditFeatured)
+	add(splitter.remixers(title), CreditRemixer)
+	add(splitter.names(composer), CreditComposer)
+	return credits
+}
+
+// names splits a list of names at the separators, except for the names
+// to keep.
+func (splitter *CreditSplitter) names(text string) []string {
+	pieces := []string{text}
+	for _, separator := range splitter.Separators {
+		split := make([]string, 0, len(pieces))
+		for _, piece := range pieces {
+			if splitter.kept(piece) {
+				split = append(split, piece)
+			} else {
+				split = append(split, strings.Split(piece, separator)...)
+			}
+		}
+		pieces = split
+	}
+	names := make([]string, 0, len(pieces))
+	for _, piece := range pieces {
+		if piece = strings.TrimSpace(piece); piece != "" {
+			names = append(names, piece)
+		}
+	}
+	return names
+}
+
+// kept tells whether a name must not be split.
+func (splitter *CreditSplitter) kept(name string) bool {
+	for _, kept := range splitter.Keep {
+		if strings.EqualFold(strings.TrimSpace(name), kept) {
+			return true
+		}
+	}
+	return false
+}
+
+// cutFeaturing separates a text at its first featuring word, returning
+// what comes before and the featured names after it; a featuring word
+// may open a parenthesis or a bracket, whose end closes the names.
+func (splitter *CreditSplitter) cutFeaturing(text string) (string, string) {
+	if splitter.kept(text) {
+		return text, ""
+	}
+	for _, word := range splitter.Featuring {
+		for _, opening := range []string{" (", " [", " "} {
+			i := indexFold(text, opening+word+" ")
+			if i < 0 {
+				continue
+			}
+			featured := text[i+len(opening)+len(word)+1:]
+			if opening != " " {
+				if end := strings.IndexAny(featured, ")]"); end >= 0 {
+					featured = featured[:end]
+				}
+			}
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(featured)
+		}
+	}
+	return text, ""
+}
+
+// remixers returns the names in the parentheses or brackets of a title
+// that end with a remix word, as in "Song (C Remix)".
+func (splitter *CreditSplitter) remixers(title string) []string {
+	names := make([]string, 0)
+	for {
+		start := strings.IndexAny(title, "([")
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexAny(title[start:], ")]")
+		if end < 0 {
+			return names
+		}
+		inside := strings.TrimSpace(title[start+1 : start+end])
+		title = title[start+end+1:]
+		for _, word := range splitter.Remix {
+			if len(inside) > len(word) && strings.EqualFold(inside[len(inside)-len(word):], word) {
+				names = append(names, splitter.names(strings.TrimSpace(inside[:len(inside)-len(word)]))...)
+				break
+			}
+		}
+	}
+}
+
+// indexFold returns the index of the first instance of substr in s,
+// regardless of case, or -1 if there is none.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// performsCondition returns the condition that a Rola is performed, as
+// its main performer or credited in rola_performers other than as
+// composer, by a performer whose name satisfies the given condition,
+// such as "LIKE ?".   The condition appears twice, so its values must be
+// bound twice.
+func performsCondition(condition string) string {
+	return "(performers.name " + condition + " OR EXISTS (" +
+		"SELECT 1 FROM rola_performers " +
+		"INNER JOIN performers credited ON credited.id_performer = rola_performers.id_performer " +
+		"WHERE rola_performers.id_rola = rolas.id_rola " +
+		"AND rola_performers.credit <> '" + CreditComposer + "' " +
+		"AND credited.name " + condition + "))"
+}
+
+// SetCredits replaces the performers credited in a Rola, adding the
+// performers missing from the database.
+func (database *Database) SetCredits(rolaID int64, credits []*PerformerCredit) {
+	for _, credit := range credits {
+		credit.PerformerID = database.AddPerformerNamed(credit.Name)
+	}
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	_, err = tx.Exec("DELETE FROM rola_performers WHERE id_rola = ?", rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for position, credit := range credits {
+		_, err = tx.Exec("INSERT OR IGNORE INTO rola_performers (id_rola, id_performer, credit, position) "+
+			"VALUES (?, ?, ?, ?)", rolaID, credit.PerformerID, credit.Credit, position)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	tx.Commit()
+}
+
+// QueryCredits returns the performers credited in a Rola, in order.
+func (database *Database) QueryCredits(rolaID int64) []*PerformerCredit {
+	stmtStr := "SELECT performers.id_performer, performers.name, rola_performers.credit " +
+		"FROM rola_performers " +
+		"INNER JOIN performers ON performers.id_performer = rola_performers.id_performer " +
+		"WHERE rola_performers.id_rola = ? " +
+		"ORDER BY rola_performers.position"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, rolaID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	credits := make([]*PerformerCredit, 0)
+	for rows.Next() {
+		credit := &PerformerCredit{}
+		err := rows.Scan(&credit.PerformerID, &credit.Name, &credit.Credit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		credits = append(credits, credit)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return credits
+}
+
+// SplitCredits credits again the performers of every Rola of the
+// database with the given splitting rules, and returns the number of
+// Rolas whose credits changed.
+func (database *Database) SplitCredits(splitter *CreditSplitter) int {
+	changed := 0
+	for _, rolaID := range database.QueryCustom("SELECT id_rola FROM rolas ORDER BY id_rola") {
+		rola := database.QueryRola(rolaID)
+		credits := splitter.Split(rola.Artist(), rola.Title(), rola.Composer())
+		current := database.QueryCredits(rolaID)
+		same := len(current) == len(credits)
+		for i := 0; same && i < len(credits); i++ {
+			same = current[i].Name == credits[i].Name && current[i].Credit == credits[i].Credit
+		}
+		if !same {
+			database.SetCredits(rolaID, credits)
+			changed++
+		}
+	}
+	return changed
+}
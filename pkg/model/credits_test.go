@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestCreditSplitter(t *testing.T) {
+	splitter := DefaultCreditSplitter()
+	splitter.Separators = append(splitter.Separators, " & ")
+	splitter.Keep = []string{"Simon & Garfunkel"}
+	tests := []struct {
+		artist, title, composer string
+		expected                []string
+	}{
+		{"Queen", "Bohemian Rhapsody", "", []string{"Queen main"}},
+		{"Queen & David Bowie", "Under Pressure", "", []string{"Queen main", "David Bowie main"}},
+		{"Simon & Garfunkel", "The Boxer", "Paul Simon", []string{"Simon & Garfunkel main", "Paul Simon composer"}},
+		{"Santana Feat. Rob Thomas", "Smooth", "", []string{"Santana main", "Rob Thomas featured"}},
+		{"Daft Punk (ft. Pharrell Williams; Nile Rodgers)", "Get Lucky", "",
+			[]string{"Daft Punk main", "Pharrell Williams featured", "Nile Rodgers featured"}},
+		{"Gorillaz", "Feel Good Inc. (feat. De La Soul) [Stanton Warriors Remix]", "",
+			[]string{"Gorillaz main", "De La Soul featured", "Stanton Warriors remixer"}},
+		{"A\x00B", "Split", "C / D", []string{"A main", "B main", "C composer", "D composer"}},
+		{"Featurette", "Remix", "", []string{"Featurette main"}},
+	}
+	for _, test := range tests {
+		credits := splitter.Split(test.artist, test.title, test.composer)
+		received := make([]string, len(credits))
+		for i, credit := range credits {
+			received[i] = credit.Name + " " + credit.Credit
+		}
+		if len(received) != len(test.expected) {
+			t.Errorf("expecting %q, received %q", test.expected, received)
+			continue
+		}
+		for i := range received {
+			if received[i] != test.expected[i] {
+				t.Errorf("expecting %q, received %q", test.expected, received)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// cueFramesPerSecond is the number of frames in a second of the times of
+// a CUE sheet, as in the sectors of an audio CD.
+const cueFramesPerSecond = 75
+
+// A CueSheet holds the tracks of a CUE sheet, which divides one or more
+// audio files into tracks, and the fields of the sheet that apply to
+// all of them.
+type CueSheet struct {
+	Performer  string
+	Title      string
+	Songwriter string
+	Genre      string
+	Year       int
+	Tracks     []*CueTrack
+}
+
+// A CueTrack is a track of a CUE sheet: the part of its file between
+// its start and its end, or the end of the file if the end is 0.
+type CueTrack struct {
+	File       string
+	Number     int
+	Performer  string
+	Title      string
+	Songwriter string
+	Start      time.Duration
+	End        time.Duration
+}
+
+// ParseCue reads a CUE sheet.   The start of a track is its INDEX 01,
+// and its end is the start of the next track of the same file.   The
+// sheets that are not valid UTF-8 are read as Latin-1; the commands that
+// are not needed, such as FLAGS and ISRC, are skipped.
+func ParseCue(reader io.Reader) (*CueSheet, error) {
+	sheet := &CueSheet{Tracks: make([]*CueTrack, 0)}
+	var file string
+	var track *CueTrack
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if !utf8.ValidString(text) {
+			text = latin1(text)
+		}
+		fields := cueFields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToUpper(fields[0])
+		argument := ""
+		if len(fields) > 1 {
+			argument = fields[1]
+		}
+		switch command {
+		case "REM":
+			if len(fields) > 2 {
+				switch strings.ToUpper(fields[1]) {
+				case "GENRE":
+					sheet.Genre = fields[2]
+				case "DATE":
+					date := fields[2]
+					if len(date) > 4 {
+						date = date[:4]
+					}
+					if year, err := strconv.Atoi(date); err == nil {
+						sheet.Year = year
+					}
+				}
+			}
+		case "FILE":
+			file, track = argument, nil
+		case "TRACK":
+			number, err := strconv.Atoi(argument)
+			if err != nil || file == "" {
+				return nil, fmt.Errorf("line %d: invalid track %q", line, text)
+			}
+			track = &CueTrack{File: file, Number: number, Start: -1}
+			sheet.Tracks = append(sheet.Tracks, track)
+		case "INDEX":
+			if track == nil || len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: index outside of a track", line)
+			}
+			if argument != "01" && argument != "1" {
+				continue
+			}
+			start, err := parseCueTime(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			track.Start = start
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = argument
+			} else {
+				sheet.Performer = argument
+			}
+		case "TITLE":
+			if track != nil {
+				track.Title = argument
+			} else {
+				sheet.Title = argument
+			}
+		case "SONGWRITER":
+			if track != nil {
+				track.Songwriter = argument
+			} else {
+				sheet.Songwriter = argument
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, track := range sheet.Tracks {
+		if track.Start < 0 {
+			return nil, fmt.Errorf("track %d has no index 01", track.Number)
+		}
+		if i+1 < len(sheet.Tracks) && sheet.Tracks[i+1].File == track.File {
+			track.End = sheet.Tracks[i+1].Start
+		}
+	}
+	return sheet, nil
+}
+
+// cueFields splits a line of a CUE sheet into its command and arguments;
+// an argument between double quotes may have spaces.
+func cueFields(line string) []string {
+	fields := make([]string, 0)
+	line = strings.TrimSpace(line)
+	for line != "" {
+		var field string
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				field, line = line[1:], ""
+			} else {
+				field, line = line[1:end+1], line[end+2:]
+			}
+		} else if end := strings.IndexAny(line, " \t"); end >= 0 {
+			field, line = line[:end], line[end:]
+		} else {
+			field, line = line, ""
+		}
+		fields = append(fields, strings.TrimSpace(field))
+		line = strings.TrimSpace(line)
+	}
+	return fields
+}
+
+// parseCueTime parses a time of a CUE sheet, as mm:ss:ff.
+func parseCueTime(text string) (time.Duration, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid time %q", text)
+		}
+		numbers[i] = number
+	}
+	if numbers[1] >= 60 || numbers[2] >= cueFramesPerSecond {
+		return 0, fmt.Errorf("invalid time %q", text)
+	}
+	frames := (numbers[0]*60+numbers[1])*cueFramesPerSecond + numbers[2]
+	return time.Duration(frames) * time.Second / cueFramesPerSecond, nil
+}
+
+// latin1 decodes a text in Latin-1.
+func latin1(text string) string {
+	runes := make([]rune, len(text))
+	for i := 0; i < len(text); i++ {
+		runes[i] = rune(text[i])
+	}
+	return string(runes)
+}
+
+// readCueSheet reads the CUE sheet in a file, and makes the files of its
+// tracks absolute.   A sheet often names the WAV file it was ripped
+// from, so if the file of a track does not exist, the mp3 or flac file
+// with its name is used instead.
+func readCueSheet(path string) (*CueSheet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sheet, err := ParseCue(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	for _, track := range sheet.Tracks {
+		audio := filepath.Join(dir, filepath.FromSlash(strings.Replace(track.File, `\`, "/", -1)))
+		if _, err := os.Stat(audio); os.IsNotExist(err) {
+			base := strings.TrimSuffix(audio, filepath.Ext(audio))
+			for _, ext := range []string{".flac", ".mp3"} {
+				if _, err := os.Stat(base + ext); err == nil {
+					audio = base + ext
+					break
+				}
+			}
+		}
+		track.File = audio
+	}
+	return sheet, nil
+}
+
+// setTags sets the fields of a Rola from a track of the sheet, leaving
+// the ones the sheet does not have: its title, its performer, or the
+// performer of the sheet, who is also the album artist, its songwriter,
+// the title of the sheet as the album, its number, and the genre and the
+// year of the sheet.
+func (sheet *CueSheet) setTags(rola *Rola, track *CueTrack) {
+	texts := []struct {
+		value string
+		set   func(string)
+	}{
+		{track.Title, rola.SetTitle},
+		{sheet.Performer, rola.SetArtist},
+		{track.Performer, rola.SetArtist},
+		{sheet.Performer, rola.SetAlbumArtist},
+		{sheet.Songwriter, rola.SetComposer},
+		{track.Songwriter, rola.SetComposer},
+		{sheet.Title, rola.SetAlbum},
+		{sheet.Genre, rola.SetGenre},
+	}
+	for _, text := range texts {
+		if strings.TrimSpace(text.value) != "" {
+			text.set(text.value)
+		}
+	}
+	rola.SetTrack(track.Number)
+	if sheet.Year != 0 {
+		rola.SetYear(sheet.Year)
+	}
+}
+
+// cueTrackPath returns the path of the Rola of a track of a CUE sheet:
+// the path of its file followed by # and the number of the track, so
+// that each track has its own path.
+func cueTrackPath(track *CueTrack) string {
+	return fmt.Sprintf("%s#%02d", track.File, track.Number)
+}
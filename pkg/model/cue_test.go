@@ -0,0 +1,122 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCue = "\ufeffREM GENRE \"Progressive Rock\"\n" +
+	"REM DATE 1975/11/21\n" +
+	"PERFORMER \"Queen\"\n" +
+	"TITLE \"A Night at the Opera\"\n" +
+	"FILE \"Queen - A Night at the Opera.flac\" WAVE\n" +
+	"  TRACK 01 AUDIO\n" +
+	"    TITLE \"Death on Two Legs\"\n" +
+	"    INDEX 01 00:00:00\n" +
+	"  TRACK 02 AUDIO\n" +
+	"    TITLE \"Lazing on a Sunday Afternoon\"\n" +
+	"    SONGWRITER \"Freddie Mercury\"\n" +
+	"    INDEX 00 03:42:70\n" +
+	"    INDEX 01 03:43:15\n" +
+	"FILE side2.flac WAVE\n" +
+	"  TRACK 03 AUDIO\n" +
+	"    TITLE \"'39\"\n" +
+	"    PERFORMER \"Brian May\"\n" +
+	"    INDEX 01 00:00:00\n" +
+	"  TRACK 04 AUDIO\n" +
+	"    TITLE \"Caf\xe9\"\n" +
+	"    INDEX 01 03:30:00\n"
+
+func TestParseCue(t *testing.T) {
+	sheet, err := ParseCue(strings.NewReader(testCue))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sheet.Performer != "Queen" || sheet.Title != "A Night at the Opera" ||
+		sheet.Genre != "Progressive Rock" || sheet.Year != 1975 {
+		t.Errorf("expecting %v, received %+v", "the fields of the sheet", sheet)
+	}
+	expected := []*CueTrack{
+		{File: "Queen - A Night at the Opera.flac", Number: 1, Title: "Death on Two Legs",
+			End: 3*time.Minute + 43*time.Second + 200*time.Millisecond},
+		{File: "Queen - A Night at the Opera.flac", Number: 2, Title: "Lazing on a Sunday Afternoon",
+			Songwriter: "Freddie Mercury", Start: 3*time.Minute + 43*time.Second + 200*time.Millisecond},
+		{File: "side2.flac", Number: 3, Title: "'39", Performer: "Brian May",
+			End: 3*time.Minute + 30*time.Second},
+		{File: "side2.flac", Number: 4, Title: "Café", Start: 3*time.Minute + 30*time.Second},
+	}
+	if len(sheet.Tracks) != len(expected) {
+		t.Fatalf("expecting %v tracks, received %v", len(expected), len(sheet.Tracks))
+	}
+	for i, track := range sheet.Tracks {
+		if *track != *expected[i] {
+			t.Errorf("expecting %+v, received %+v", expected[i], track)
+		}
+	}
+
+	rola := NewRola()
+	sheet.setTags(rola, sheet.Tracks[2])
+	if rola.Artist() != "Brian May" || rola.AlbumArtist() != "Queen" || rola.Album() != "A Night at the Opera" ||
+		rola.Track() != 3 || rola.Year() != 1975 || rola.Genre() != "Progressive Rock" {
+		t.Errorf("expecting %v, received %v", "the tags of the track", rola)
+	}
+	rola.SetPath(cueTrackPath(sheet.Tracks[2]))
+	if rola.Path() != "side2.flac#03" {
+		t.Errorf("expecting %v, received %v", "side2.flac#03", rola.Path())
+	}
+
+	broken := []string{
+		"TRACK 01 AUDIO\n",
+		"FILE a.flac WAVE\nTRACK 01 AUDIO\nINDEX 01 00:61:00\n",
+		"FILE a.flac WAVE\nTRACK 01 AUDIO\nTITLE \"No index\"\n",
+		"INDEX 01 00:00:00\n",
+		"FILE a.flac WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nFILE b.flac WAVE\nINDEX 01 00:00:00\n",
+	}
+	for _, text := range broken {
+		if _, err := ParseCue(strings.NewReader(text)); err == nil {
+			t.Errorf("expecting %v, received %v", "an error", err)
+		}
+	}
+}
+
+func TestParseCueFiles(t *testing.T) {
+	text := "FILE \"01.flac\" WAVE\n" +
+		"  TRACK 01 AUDIO\n" +
+		"    TITLE \"One\"\n" +
+		"    PERFORMER \"First\"\n" +
+		"    INDEX 01 00:00:00\n" +
+		"FILE \"02.flac\" WAVE\n" +
+		"  REM COMMENT \"between the file and the track\"\n" +
+		"  PERFORMER \"Everyone\"\n" +
+		"  TRACK 02 AUDIO\n" +
+		"    TITLE \"Two\"\n" +
+		"    INDEX 01 00:00:00\n" +
+		"  TRACK 03 AUDIO\n" +
+		"    TITLE \"Three\"\n" +
+		"    INDEX 01 02:00:00\n" +
+		"FILE \"03.flac\" WAVE\n" +
+		"  TRACK 04 AUDIO\n" +
+		"    INDEX 01 00:00:00\n"
+	sheet, err := ParseCue(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*CueTrack{
+		{File: "01.flac", Number: 1, Title: "One", Performer: "First"},
+		{File: "02.flac", Number: 2, Title: "Two", End: 2 * time.Minute},
+		{File: "02.flac", Number: 3, Title: "Three", Start: 2 * time.Minute},
+		{File: "03.flac", Number: 4},
+	}
+	if len(sheet.Tracks) != len(expected) {
+		t.Fatalf("expecting %v tracks, received %v", len(expected), len(sheet.Tracks))
+	}
+	for i, track := range sheet.Tracks {
+		if *track != *expected[i] {
+			t.Errorf("expecting %+v, received %+v", expected[i], track)
+		}
+	}
+	if sheet.Performer != "Everyone" {
+		t.Errorf("expecting %v, received %v", "Everyone", sheet.Performer)
+	}
+}
@@ -0,0 +1,362 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrOutsideTrack is returned by OpenAudio when the start of the track
+// of a CUE sheet is after the end of its file.
+var ErrOutsideTrack = errors.New("the track starts after the end of its file")
+
+// An AudioReader reads the audio of a Rola: its whole file, or only the
+// frames of the track of a CUE sheet.   Name is the name of the file and
+// ModTime the time it was modified, as http.ServeContent expects them.
+type AudioReader struct {
+	*io.SectionReader
+	Name    string
+	ModTime time.Time
+	file    *os.File
+}
+
+// OpenAudio opens the audio of a Rola.   The track of a CUE sheet gives
+// only the frames of its file between the start and the end of the
+// track, so it can be played as a file of its own: the MP3 frames as
+// they are, and the FLAC frames after a STREAMINFO block with the
+// samples of the track.   The frames are whole, so the audio may begin
+// and end a fraction of a second around the track.
+func OpenAudio(rola *Rola) (*AudioReader, error) {
+	file, err := os.Open(rola.File())
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	audio := &AudioReader{
+		SectionReader: io.NewSectionReader(file, 0, info.Size()),
+		Name:          filepath.Base(rola.File()),
+		ModTime:       info.ModTime(),
+		file:          file,
+	}
+	if rola.IsCueTrack() {
+		sections, err := trackSections(file, info.Size(), rola.Start(), rola.End())
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		audio.SectionReader = io.NewSectionReader(sections, 0, sections.size())
+	}
+	return audio, nil
+}
+
+// Close closes the file of the audio.
+func (audio *AudioReader) Close() error {
+	return audio.file.Close()
+}
+
+// joinedSections reads a list of sections as if they were one.
+type joinedSections []*io.SectionReader
+
+// size returns the number of bytes of all the sections.
+func (sections joinedSections) size() int64 {
+	var size int64
+	for _, section := range sections {
+		size += section.Size()
+	}
+	return size
+}
+
+// ReadAt reads from the sections the bytes at the given offset of their
+// concatenation.
+func (sections joinedSections) ReadAt(p []byte, offset int64) (int, error) {
+	read := 0
+	for _, section := range sections {
+		if offset >= section.Size() {
+			offset -= section.Size()
+			continue
+		}
+		n, err := section.ReadAt(p[read:], offset)
+		read += n
+		if read == len(p) {
+			return read, nil
+		}
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		offset = 0
+	}
+	return read, io.EOF
+}
+
+// trackSections returns the sections of the file of a CUE sheet with
+// the audio between the start and the end of a track, or the end of the
+// file if the end is 0.
+func trackSections(file *os.File, size int64, start, end time.Duration) (joinedSections, error) {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	audioStart, err := skipID3v2(file)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(file, magic)
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) == "fLaC" {
+		return flacTrack(file, size, start, end)
+	}
+	audioEnd := size
+	trailer := make([]byte, 3)
+	if _, err := file.Seek(-128, io.SeekEnd); err == nil {
+		if _, err := io.ReadFull(file, trailer); err == nil && string(trailer) == "TAG" {
+			audioEnd -= 128
+		}
+	}
+	return mp3Track(file, audioStart, audioEnd, start, end)
+}
+
+// sampleAt returns the number of the sample at an offset in a stream
+// with the given sample rate.
+func sampleAt(offset time.Duration, rate int) int64 {
+	return int64(offset) * int64(rate) / int64(time.Second)
+}
+
+// mp3Track returns the section with the MP3 frames of a track, from the
+// frame with its start to the frame before its end.   The frame with a
+// Xing, Info or VBRI header is left out, since it describes the whole
+// file.
+func mp3Track(file *os.File, audioStart, audioEnd int64, start, end time.Duration) (joinedSections, error) {
+	_, err := file.Seek(audioStart, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(io.LimitReader(file, audioEnd-audioStart))
+	position := audioStart
+	var sample, startSample, endSample int64
+	cutStart, cutEnd := int64(-1), audioEnd
+	first := true
+	for {
+		header, err := reader.Peek(4)
+		if err != nil {
+			break
+		}
+		frame, ok := parseMP3Frame(header)
+		if !ok {
+			reader.Discard(1)
+			position++
+			continue
+		}
+		if first {
+			first = false
+			startSample, endSample = sampleAt(start, frame.sampleRate), sampleAt(end, frame.sampleRate)
+			data, _ := reader.Peek(frame.length)
+			if _, _, _, found := vbrHeader(data, frame); found {
+				reader.Discard(frame.length)
+				position += int64(frame.length)
+				continue
+			}
+		}
+		if end > 0 && sample >= endSample {
+			cutEnd = position
+			break
+		}
+		if cutStart < 0 && sample+int64(frame.samples) > startSample {
+			cutStart = position
+		}
+		discarded, _ := reader.Discard(frame.length)
+		position += int64(discarded)
+		sample += int64(frame.samples)
+	}
+	if first {
+		return nil, ErrUnknownFormat
+	}
+	if cutStart < 0 {
+		return nil, ErrOutsideTrack
+	}
+	return joinedSections{io.NewSectionReader(file, cutStart, cutEnd-cutStart)}, nil
+}
+
+// flacTrack returns the sections with the audio of a track in a FLAC
+// stream, the reader placed after its "fLaC" marker: a STREAMINFO block
+// with the number of samples of the track, and the frames from the one
+// with its start to the one before its end.
+func flacTrack(file *os.File, size int64, start, end time.Duration) (joinedSections, error) {
+	metadata, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4+flacStreamInfoLength)
+	_, err = io.ReadFull(file, header)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x7f != 0 {
+		return nil, ErrUnknownFormat
+	}
+	info := header[4:]
+	rate := int(binary.BigEndian.Uint32(info[10:]) >> 12)
+	blockSize := int64(binary.BigEndian.Uint16(info))
+	if rate == 0 {
+		return nil, ErrUnknownFormat
+	}
+	_, err = file.Seek(metadata, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	position, err := skipFLACMetadata(file)
+	if err != nil {
+		return nil, err
+	}
+
+	startSample, endSample := sampleAt(start, rate), sampleAt(end, rate)
+	reader := bufio.NewReader(file)
+	var next, firstSample int64
+	cutStart, cutEnd := int64(-1), size
+	for {
+		data, _ := reader.Peek(flacFrameHeaderLength)
+		if len(data) == 0 {
+			break
+		}
+		if data[0] != 0xff {
+			buffered, _ := reader.Peek(reader.Buffered())
+			skip := bytes.IndexByte(buffered[1:], 0xff) + 1
+			if skip == 0 {
+				skip = len(buffered)
+			}
+			reader.Discard(skip)
+			position += int64(skip)
+			continue
+		}
+		sample, samples, length, ok := parseFLACFrame(data, blockSize)
+		if !ok || sample != next {
+			reader.Discard(1)
+			position++
+			continue
+		}
+		if end > 0 && sample >= endSample {
+			cutEnd = position
+			break
+		}
+		if cutStart < 0 && sample+samples > startSample {
+			cutStart, firstSample = position, sample
+		}
+		next = sample + samples
+		reader.Discard(length)
+		position += int64(length)
+	}
+	if next == 0 {
+		return nil, ErrUnknownFormat
+	}
+	if cutStart < 0 {
+		return nil, ErrOutsideTrack
+	}
+
+	samples := uint64(next - firstSample)
+	header[0] = 0x80
+	info[13] = info[13]&0xf0 | byte(samples>>32&0x0f)
+	binary.BigEndian.PutUint32(info[14:], uint32(samples))
+	copy(info[18:], make([]byte, 16))
+	streamInfo := append([]byte("fLaC"), header...)
+	return joinedSections{
+		io.NewSectionReader(bytes.NewReader(streamInfo), 0, int64(len(streamInfo))),
+		io.NewSectionReader(file, cutStart, cutEnd-cutStart),
+	}, nil
+}
+
+const (
+	// flacStreamInfoLength is the length of the STREAMINFO block.
+	flacStreamInfoLength = 34
+	// flacFrameHeaderLength is the longest length of a frame header.
+	flacFrameHeaderLength = 16
+)
+
+// parseFLACFrame decodes the FLAC frame header at the start of data, and
+// returns the number of its first sample, its number of samples and the
+// length of the header.   The number of a frame of a stream with a fixed
+// block size is turned into the number of its first sample with the
+// given block size.   The header is only valid if its CRC-8 matches.
+func parseFLACFrame(data []byte, blockSize int64) (int64, int64, int, bool) {
+	if len(data) < 6 || data[0] != 0xff || data[1]&0xfe != 0xf8 || data[3]&0x01 != 0 {
+		return 0, 0, 0, false
+	}
+	sizeCode, rateCode := data[2]>>4, data[2]&0x0f
+	if sizeCode == 0 || rateCode == 0x0f || data[3]>>4 > 10 {
+		return 0, 0, 0, false
+	}
+
+	ones := 0
+	for ones < 8 && data[4]&(0x80>>uint(ones)) != 0 {
+		ones++
+	}
+	if ones == 1 || ones > 7 {
+		return 0, 0, 0, false
+	}
+	number := int64(data[4] & (0x7f >> uint(ones)))
+	i := 5
+	for ; i < 4+ones; i++ {
+		if i >= len(data) || data[i]&0xc0 != 0x80 {
+			return 0, 0, 0, false
+		}
+		number = number<<6 | int64(data[i]&0x3f)
+	}
+
+	var samples int64
+	switch {
+	case sizeCode == 1:
+		samples = 192
+	case sizeCode <= 5:
+		samples = 576 << (sizeCode - 2)
+	case sizeCode == 6 && i < len(data):
+		samples = int64(data[i]) + 1
+		i++
+	case sizeCode == 7 && i+1 < len(data):
+		samples = int64(binary.BigEndian.Uint16(data[i:])) + 1
+		i += 2
+	case sizeCode >= 8:
+		samples = 256 << (sizeCode - 8)
+	default:
+		return 0, 0, 0, false
+	}
+	switch rateCode {
+	case 12:
+		i++
+	case 13, 14:
+		i += 2
+	}
+	if i >= len(data) || crc8(data[:i]) != data[i] {
+		return 0, 0, 0, false
+	}
+	if data[1]&0x01 == 0 {
+		number *= blockSize
+	}
+	return number, samples, i + 1, true
+}
+
+// crc8 computes the CRC-8 of the FLAC frame headers, with the polynomial
+// x^8 + x^2 + x + 1.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
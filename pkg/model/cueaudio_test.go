@@ -0,0 +1,95 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func flacFrames(frames int) []byte {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint16(info, 256)
+	binary.BigEndian.PutUint16(info[2:], 256)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|uint64(frames*256))
+	stream := []byte("fLaC\x80\x00\x00\x22")
+	stream = append(stream, info...)
+	for i := 0; i < frames; i++ {
+		header := []byte{0xff, 0xf8, 0x89, 0x18}
+		number := make([]byte, 4)
+		header = append(header, number[:utf8.EncodeRune(number, rune(i))]...)
+		header = append(header, crc8(header))
+		stream = append(stream, header...)
+		stream = append(stream, make([]byte, 20)...)
+	}
+	return stream
+}
+
+func TestOpenAudioMP3(t *testing.T) {
+	stream := cbrStream(100)
+	path, clean := tempAudioFile(t, "album.mp3", stream)
+	defer clean()
+
+	rola := NewRola()
+	rola.SetPath(path + "#02")
+	rola.SetCueTrack(path, time.Second, 2*time.Second)
+	audio, err := OpenAudio(rola)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer audio.Close()
+	contents, _ := ioutil.ReadAll(audio)
+	if !bytes.Equal(contents, stream[20+38*417:20+77*417]) {
+		t.Errorf("expecting %v, received %v", 39*417, len(contents))
+	}
+
+	rola.SetCueTrack(path, time.Second, 0)
+	audio, err = OpenAudio(rola)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer audio.Close()
+	contents, _ = ioutil.ReadAll(audio)
+	if !bytes.Equal(contents, stream[20+38*417:]) {
+		t.Errorf("expecting %v, received %v", 62*417, len(contents))
+	}
+
+	rola.SetCueTrack(path, time.Minute, 0)
+	if _, err := OpenAudio(rola); err != ErrOutsideTrack {
+		t.Errorf("expecting %v, received %v", ErrOutsideTrack, err)
+	}
+}
+
+func TestOpenAudioFLAC(t *testing.T) {
+	stream := flacFrames(400)
+	path, clean := tempAudioFile(t, "album.flac", stream)
+	defer clean()
+
+	rola := NewRola()
+	rola.SetPath(path + "#02")
+	rola.SetCueTrack(path, time.Second, 2*time.Second)
+	audio, err := OpenAudio(rola)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer audio.Close()
+	contents, _ := ioutil.ReadAll(audio)
+	if len(contents) < 42 || !bytes.HasPrefix(contents, []byte("fLaC\x80\x00\x00\x22")) {
+		t.Fatalf("unexpected header %v", contents)
+	}
+	samples := binary.BigEndian.Uint64(contents[8+10:]) & (1<<36 - 1)
+	if samples != (345-172)*256 {
+		t.Errorf("expecting %v, received %v", (345-172)*256, samples)
+	}
+	frame := func(i int) int {
+		if i < 128 {
+			return 42 + i*26
+		}
+		return 42 + 128*26 + (i-128)*27
+	}
+	if !bytes.Equal(contents[42:], stream[frame(172):frame(345)]) {
+		t.Errorf("expecting %v, received %v", frame(345)-frame(172), len(contents)-42)
+	}
+}
@@ -2,23 +2,49 @@
 package model
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gchaincl/dotsql"
 	// SQLite driver
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// schemaMigrations holds, in the order they must be applied, the names
+// of the queries in rolas.sql that take the schema of the database from
+// one version to the next.
+var schemaMigrations = []string{
+	"migrate-extended-tags",
+	"migrate-audio-properties",
+	"migrate-artwork",
+	"migrate-playlists",
+	"migrate-constraints",
+	"migrate-performer-links",
+	"migrate-dates",
+	"migrate-membership-periods",
+	"migrate-rola-performers",
+	"migrate-rola-genres",
+	"migrate-genre-hierarchy",
+	"migrate-duplicates",
+	"migrate-fingerprints",
+	"migrate-missing",
+	"migrate-cue-sheets",
+	"migrate-replaygain",
+}
+
 // A Database is the intermediary between the sql database and
 // the rest of the model and the view.
 type Database struct {
 	Database *sql.DB
 	cache    string
+	path     string
 }
 
 // NewDatabase creates a new Database object, checking whether a .db
@@ -29,56 +55,53 @@ func NewDatabase() (*Database, bool) {
 	if err != nil {
 		log.Fatal("could not retrieve the current user:", err)
 	}
-	cache := home.HomeDir + "/.cache/rolas"
-	os.Mkdir(cache, 0700)
+	return NewDatabaseAt(home.HomeDir + "/.cache/rolas/rolas.db")
+}
+
+// NewDatabaseAt works like NewDatabase, but the database is saved in
+// the file with the given path.   The boolean returned tells whether
+// the file already existed.   The foreign keys of the schema are
+// enforced on every connection.
+func NewDatabaseAt(path string) (*Database, bool) {
+	cache := filepath.Dir(path)
+	os.MkdirAll(cache, 0700)
 
 	fileExists := true
-	if _, err := os.Stat(cache + "/rolas.db"); os.IsNotExist(err) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		fileExists = false
 	}
-	db, err := sql.Open("sqlite3", cache+"/rolas.db")
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=1")
 	if err != nil {
 		log.Fatal("could not open the database: ", err)
 	}
 	DB := &Database{
 		Database: db,
 		cache:    cache,
+		path:     path,
 	}
 	return DB, fileExists
 }
 
 // AddAlbum takes a Rola as a parameter, adds its album to the database
-// and returns the ID number of the album in the database.   If the album
-// was already in the database, this method does nothing and returns the
-// ID of the album in the database.
+// and returns the ID number of the album in the database.   The picture
+// of the Rola becomes the picture of the album.   If the album was already
+// in the database, this method only sets the picture of the album if it
+// had none, and returns the ID of the album in the database.
 func (database *Database) AddAlbum(rola *Rola) int64 {
-	idalbum := database.ExistsAlbum(filepath.Dir(rola.Path()), rola.Album())
-	if idalbum > 0 {
-		return idalbum
-	}
-
 	stmtStr := `INSERT
                 INTO albums (
                   path,
                   name,
-                  year)
-                SELECT ?, ?, ?
-                WHERE NOT EXISTS
-                (SELECT 1 FROM albums WHERE path = ? AND name = ?)`
-
-	tx, stmt := database.PrepareStatement(stmtStr)
-	defer stmt.Close()
-
-	id, err := stmt.Exec(filepath.Dir(rola.Path()), rola.Album(), rola.Year(), filepath.Dir(rola.Path()), rola.Album())
-	if err != nil {
-		log.Fatal(err)
-	}
-	tx.Commit()
-	lastId, err := id.LastInsertId()
-	if err != nil {
-		log.Fatal("could not retrieve the last insert ID:", err)
-	}
-	return lastId
+                  year,
+                  artwork)
+                VALUES (?, ?, ?, ?)
+                ON CONFLICT (path, name) DO UPDATE
+                SET artwork = excluded.artwork
+                WHERE excluded.artwork <> '' AND (albums.artwork IS NULL OR albums.artwork = '')`
+
+	albumPath := filepath.Dir(rola.Path())
+	return database.insertOrSelect(stmtStr, []interface{}{albumPath, rola.Album(), rola.Year(), rola.Artwork()},
+		"SELECT id_album FROM albums WHERE path = ? AND name = ?", albumPath, rola.Album())
 }
 
 // AddGroup takes a Rola as a parameter, adds its performer, which has been
@@ -90,21 +113,11 @@ func (database *Database) AddGroup(groupName, start, end string) int64 {
                  name,
                  start_date,
                  end_date)
-                SELECT ?, ?, ?`
-
-	tx, stmt := database.PrepareStatement(stmtStr)
-	defer stmt.Close()
+                VALUES (?, ?, ?)
+                ON CONFLICT (name) DO NOTHING`
 
-	id, err := stmt.Exec(groupName, start, end)
-	if err != nil {
-		log.Fatal(err)
-	}
-	tx.Commit()
-	lastId, err := id.LastInsertId()
-	if err != nil {
-		log.Fatal("could not retrieve the last insert ID:", err)
-	}
-	return lastId
+	return database.insertOrSelect(stmtStr, []interface{}{groupName, start, end},
+		"SELECT id_group FROM groups WHERE name = ?", groupName)
 }
 
 // AddPerformer takes a Rola as a parameter, adds its performer to the
@@ -113,64 +126,51 @@ func (database *Database) AddGroup(groupName, start, end string) int64 {
 // If the performer is already in the database, this method does nothing
 // and returns the performer ID in the database.
 func (database *Database) AddPerformer(rola *Rola) int64 {
-	idp := database.ExistsPerformer(rola.Artist())
-	if idp > 0 {
-		return idp
-	}
+	return database.AddPerformerNamed(rola.Artist())
+}
 
+// AddPerformerNamed adds a performer with the given name to the database,
+// with type 2, and returns its ID; if there is already a performer with
+// the name, this method does nothing and returns its ID.
+func (database *Database) AddPerformerNamed(name string) int64 {
 	stmtStr := `INSERT
                 INTO performers (
                   id_type,
                   name)
-                SELECT ?, ?
-                WHERE NOT EXISTS
-                (SELECT 1 FROM performers WHERE name = ?)`
+                VALUES (?, ?)
+                ON CONFLICT (name) DO NOTHING`
 
-	tx, stmt := database.PrepareStatement(stmtStr)
-	defer stmt.Close()
-
-	id, err := stmt.Exec(2, strings.TrimSpace(rola.Artist()), rola.Artist())
-	if err != nil {
-		log.Fatal(err)
-	}
-	tx.Commit()
-	lastId, err := id.LastInsertId()
-	if err != nil {
-		log.Fatal("could not retrieve the last insert ID:", err)
-	}
-	return lastId
+	name = strings.TrimSpace(name)
+	return database.insertOrSelect(stmtStr, []interface{}{2, name},
+		"SELECT id_performer FROM performers WHERE name = ?", name)
 }
 
-// AddPerson takes a Rola as a parameter, adds its performer, which has been
-// already verified to be a person, to the database, and returns the ID
-// of the person in the database. If the person is already in the database,
-// this method does nothing and returns the person ID in the database.
-func (database *Database) AddPerson(stageName, realName, birth, death string) {
+// AddPerson takes the fields of a person, adds the person to the
+// database, and returns the ID of the person in the database. If there
+// is already a person with the same stage name, this method does nothing
+// and returns the ID of that person.
+func (database *Database) AddPerson(stageName, realName, birth, death string) int64 {
 	stmtStr := `INSERT INTO persons (
                   stage_name,
                   real_name,
                   birth_date,
                   death_date)
-                SELECT ?, ?, ?, ?`
+                VALUES (?, ?, ?, ?)
+                ON CONFLICT (stage_name) DO NOTHING`
 
-	tx, stmt := database.PrepareStatement(stmtStr)
-	defer stmt.Close()
-
-	_, err := stmt.Exec(stageName, realName, birth, death)
-	if err != nil {
-		log.Fatal(err)
-	}
-	tx.Commit()
+	return database.insertOrSelect(stmtStr, []interface{}{stageName, realName, birth, death},
+		"SELECT id_person FROM persons WHERE stage_name = ?", stageName)
 }
 
 // AddPersonToGroup receives the ID of a person and group in the database,
 // respectively, and adds them to the in_group table, i.e., adds the person
-// to the group.
+// to the group.   Nothing happens if the person was already in the group.
 func (database *Database) AddPersonToGroup(personID, groupID int64) {
 	stmtStr := "INSERT INTO in_group (" +
 		" id_person, " +
 		" id_group) " +
-		"SELECT ?, ?"
+		"VALUES (?, ?) " +
+		"ON CONFLICT DO NOTHING"
 
 	tx, stmt := database.PrepareStatement(stmtStr)
 	defer stmt.Close()
@@ -183,9 +183,10 @@ func (database *Database) AddPersonToGroup(personID, groupID int64) {
 }
 
 // AddRola takes a Rola and the IDs of the performer and album of the Rola
-// as parameters, and attempts to add the Rola to the database.   If it was
-// already in the database, it does nothing and returns -1.   Otherwise it
-// returns the ID asigned to the Rola by the database.
+// as parameters, and attempts to add the Rola to the database.   If a Rola
+// with the same path was already in the database, it does nothing and
+// returns -1.   Otherwise it returns the ID asigned to the Rola by the
+// database.
 func (database *Database) AddRola(rola *Rola, idperformer, idalbum int64) int64 {
 	stmtStr := `INSERT
                 INTO rolas (
@@ -195,19 +196,37 @@ func (database *Database) AddRola(rola *Rola, idperformer, idalbum int64) int64
                   title,
                   track,
                   year,
-                  genre)
-                SELECT ?, ?, ?, ?, ?, ?, ?
-                WHERE NOT EXISTS
-                (SELECT 1 FROM rolas WHERE (title = ?
-                  AND id_performer = ?
-                  AND id_album = ?
-                  AND genre = ?)
-				  OR path = ?)`
+                  genre,
+                  disc,
+                  album_artist,
+                  composer,
+                  bpm,
+                  comment,
+                  lyrics,
+                  duration,
+                  bitrate,
+                  sample_rate,
+                  channels,
+                  vbr,
+                  artwork,
+                  container,
+                  start_offset,
+                  end_offset,
+                  loudness,
+                  peak,
+                  album_loudness,
+                  album_peak)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                ON CONFLICT (path) DO NOTHING`
 
 	tx, stmt := database.PrepareStatement(stmtStr)
 	defer stmt.Close()
 
-	result, err := stmt.Exec(idperformer, idalbum, rola.Path(), rola.Title(), rola.Track(), rola.Year(), rola.Genre(), rola.Title(), idperformer, idalbum, rola.Genre(), rola.Path())
+	result, err := stmt.Exec(idperformer, idalbum, rola.Path(), rola.Title(), rola.Track(), rola.Year(), rola.Genre(),
+		rola.Disc(), rola.AlbumArtist(), rola.Composer(), rola.BPM(), rola.Comment(), rola.Lyrics(),
+		rola.Duration().Seconds(), rola.Bitrate(), rola.SampleRate(), rola.Channels(), rola.VBR(), rola.Artwork(),
+		rola.file, rola.Start().Seconds(), rola.End().Seconds(), rola.replayGain.TrackLoudness,
+		rola.replayGain.TrackPeak, rola.replayGain.AlbumLoudness, rola.replayGain.AlbumPeak)
 	if err != nil {
 		log.Fatal("could not execute insert:", err)
 	}
@@ -278,25 +297,11 @@ func (database *Database) AllPersons() map[string]int64 {
 	return persons
 }
 
-// CreateDB creates the tables specified in the rolas.sql file.
+// CreateDB creates the tables specified in the rolas.sql file.   The
+// tables are created with the original schema, MigrateDB should be
+// called afterwards to bring them up to date.
 func (database *Database) CreateDB() {
-	home, err := user.Current()
-	if err != nil {
-		log.Fatal("could not retrieve the current user:", err)
-	}
-	cache := home.HomeDir + "/.cache/rolas"
-	fileExists := true
-	if _, err := os.Stat(cache + "/rolas.sql"); os.IsNotExist(err) {
-		fileExists = false
-	}
-	if !fileExists {
-		RestoreAsset(cache, "rolas.sql")
-	}
-
-	dot, err := dotsql.LoadFromFile(cache + "/rolas.sql")
-	if err != nil {
-		log.Fatal("could not load rolas.sql: ", err)
-	}
+	dot := database.loadQueries()
 
 	CREATE := "create-"
 	TABLE := "-table"
@@ -315,7 +320,7 @@ func (database *Database) CreateDB() {
 	setup = append(setup, CREATE+"in_group"+TABLE)
 
 	for _, query := range setup {
-		_, err = dot.Exec(database.Database, query)
+		_, err := dot.Exec(database.Database, query)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -430,6 +435,89 @@ func (database *Database) ExistsPerson(stageName string) int64 {
 	return id
 }
 
+// loadQueries restores rolas.sql into the cache directory, replacing any
+// copy left by a previous version of the application, and loads the
+// queries it contains.
+func (database *Database) loadQueries() *dotsql.DotSql {
+	err := RestoreAsset(database.cache, "rolas.sql")
+	if err != nil {
+		log.Fatal("could not restore rolas.sql: ", err)
+	}
+	dot, err := dotsql.LoadFromFile(database.cache + "/rolas.sql")
+	if err != nil {
+		log.Fatal("could not load rolas.sql: ", err)
+	}
+	return dot
+}
+
+// LibraryDuration returns the number of Rolas in the database that are
+// not hidden and the sum of their durations.
+func (database *Database) LibraryDuration() (int, time.Duration) {
+	var count int
+	var seconds float64
+	err := database.Database.QueryRow("SELECT COUNT(*), TOTAL(duration) FROM rolas WHERE hidden = 0").Scan(&count, &seconds)
+	if err != nil {
+		log.Fatal("could not compute the library duration: ", err)
+	}
+	return count, time.Duration(seconds * float64(time.Second))
+}
+
+// LibraryStats holds the number of Rolas, performers, albums, persons
+// and groups in the database, the sum of the durations of the Rolas,
+// and the number of Rolas of each genre; a Rola with several genres is
+// counted in each of them.
+type LibraryStats struct {
+	Rolas      int
+	Performers int
+	Albums     int
+	Persons    int
+	Groups     int
+	Duration   time.Duration
+	Genres     map[string]int
+}
+
+// LibraryStats counts the rows of the tables of the database and the
+// Rolas of each genre.
+func (database *Database) LibraryStats() *LibraryStats {
+	stats := &LibraryStats{Genres: make(map[string]int)}
+	stats.Rolas, stats.Duration = database.LibraryDuration()
+	counts := map[string]*int{
+		"performers": &stats.Performers,
+		"albums":     &stats.Albums,
+		"persons":    &stats.Persons,
+		"groups":     &stats.Groups,
+	}
+	for table, count := range counts {
+		err := database.Database.QueryRow("SELECT COUNT(*) FROM " + table).Scan(count)
+		if err != nil {
+			log.Fatal("could not count the "+table+": ", err)
+		}
+	}
+
+	rows, err := database.Database.Query("SELECT coalesce(rola_genres.genre, rolas.genre), COUNT(*) " +
+		"FROM rolas LEFT JOIN rola_genres ON rola_genres.id_rola = rolas.id_rola " +
+		"WHERE rolas.hidden = 0 " +
+		"GROUP BY coalesce(rola_genres.genre, rolas.genre)")
+	if err != nil {
+		log.Fatal("could not count the genres: ", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var genre sql.NullString
+		var count int
+		err = rows.Scan(&genre, &count)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stats.Genres[genre.String] += count
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return stats
+}
+
 // LoadDB pings the database to verify if the connection is active.
 func (database *Database) LoadDB() {
 	err := database.Database.Ping()
@@ -438,6 +526,75 @@ func (database *Database) LoadDB() {
 	}
 }
 
+// MigrateDB brings the schema of the database up to date, running in
+// order the migrations from rolas.sql that have not been applied yet.
+// The number of migrations already applied is kept in the user_version
+// pragma of the database, each migration runs in its own transaction.
+// The foreign keys are not enforced during the migrations, so they can
+// rebuild the tables.
+func (database *Database) MigrateDB() {
+	dot := database.loadQueries()
+	ctx := context.Background()
+	conn, err := database.Database.Conn(ctx)
+	if err != nil {
+		log.Fatal("could not connect to the database: ", err)
+	}
+	defer conn.Close()
+
+	var version int
+	err = conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		log.Fatal("could not retrieve the schema version: ", err)
+	}
+	if version == len(schemaMigrations) {
+		return
+	}
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	if err != nil {
+		log.Fatal("could not disable the foreign keys: ", err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	for ; version < len(schemaMigrations); version++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			log.Fatal("could not begin transaction: ", err)
+		}
+		_, err = dot.Exec(tx, schemaMigrations[version])
+		if err != nil {
+			log.Fatal("could not apply migration "+schemaMigrations[version]+": ", err)
+		}
+		_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+		if err != nil {
+			log.Fatal("could not update the schema version: ", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			log.Fatal("could not commit migration "+schemaMigrations[version]+": ", err)
+		}
+	}
+}
+
+// insertOrSelect executes an insert that does nothing, or updates the
+// existing row, when it conflicts with a row already in the database,
+// and returns the ID of the row, given by the query in the same
+// transaction.
+func (database *Database) insertOrSelect(insert string, args []interface{}, query string, keys ...interface{}) int64 {
+	tx, stmt := database.PrepareStatement(insert)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var id int64
+	err = tx.QueryRow(query, keys...).Scan(&id)
+	if err != nil {
+		log.Fatal("could not retrieve the inserted ID: ", err)
+	}
+	tx.Commit()
+	return id
+}
+
 // PreparedQuery executes a prepared query and returns the resulting rows,
 // it handles the errors and returns the context and prepared statement
 // for the user to close them.
@@ -489,6 +646,63 @@ func (database *Database) QueryCustom(stmtStr string, terms ...interface{}) []in
 	return result
 }
 
+// QueryArtwork receives a Rola's ID as an argument and returns the hash
+// of its picture in the ArtworkCache; if the Rola has no picture of its
+// own, the picture of its album is returned.   An empty string means
+// that neither the Rola nor its album have a picture.
+func (database *Database) QueryArtwork(rolaID int64) string {
+	stmtStr := "SELECT " +
+		" CASE WHEN rolas.artwork = '' THEN albums.artwork ELSE rolas.artwork END " +
+		"FROM rolas " +
+		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
+		"WHERE " +
+		" rolas.id_rola = ?"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, rolaID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	var hash sql.NullString
+	for rows.Next() {
+		err := rows.Scan(&hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return hash.String
+}
+
+// QueryAlbumRolas receives an album's ID as an argument and returns a
+// slice with the IDs of all the Rolas of the album that are not hidden.
+func (database *Database) QueryAlbumRolas(albumID int64) []int64 {
+	result := make([]int64, 0)
+	stmtStr := "SELECT id_rola FROM rolas WHERE id_album = ? AND hidden = 0"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, albumID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		result = append(result, id)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return result
+}
+
 // QueryGroup receives a group ID and returns its name, start_date and
 // end_date. It is assumed that the group is in the database.
 func (database *Database) QueryGroup(groupID int64) (string, string, string) {
@@ -603,6 +817,24 @@ func (database *Database) QueryPerformerType(id int64) (int, string) {
 	return performerType, name
 }
 
+// QueryPerformerLinks receives a performer's ID as an argument and returns
+// the IDs of the person and the group the performer is linked to, which
+// are 0 if it is not linked to one.
+func (database *Database) QueryPerformerLinks(performerID int64) (int64, int64) {
+	stmtStr := "SELECT id_person, id_group FROM performers WHERE id_performer = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	var person, group sql.NullInt64
+	err := stmt.QueryRow(performerID).Scan(&person, &group)
+	if err != nil && err != sql.ErrNoRows {
+		log.Fatal("could not execute query: ", err)
+	}
+	tx.Commit()
+	return person.Int64, group.Int64
+}
+
 // QueryPerson receives a person's ID as an argument and returns its
 // stage_name, real_name, birth_date and death_date, all as strings.   It
 // is assumed that the person is in the database.
@@ -682,58 +914,149 @@ func (database *Database) QueryPersonGroups(personID int64) map[string]bool {
 	return groups
 }
 
+// rolaQuery selects the ID and the fields of the Rolas, to be read by
+// scanRola; the conditions are appended to it.
+const rolaQuery = "SELECT " +
+	" rolas.id_rola, " +
+	" performers.name, " +
+	" albums.name, " +
+	" rolas.title, " +
+	" rolas.track, " +
+	" rolas.year, " +
+	" rolas.genre, " +
+	" rolas.disc, " +
+	" rolas.album_artist, " +
+	" rolas.composer, " +
+	" rolas.bpm, " +
+	" rolas.comment, " +
+	" rolas.lyrics, " +
+	" rolas.duration, " +
+	" rolas.bitrate, " +
+	" rolas.sample_rate, " +
+	" rolas.channels, " +
+	" rolas.vbr, " +
+	" rolas.path, " +
+	" CASE WHEN rolas.artwork = '' THEN albums.artwork ELSE rolas.artwork END, " +
+	" rolas.container, " +
+	" rolas.start_offset, " +
+	" rolas.end_offset, " +
+	" rolas.loudness, " +
+	" rolas.peak, " +
+	" rolas.album_loudness, " +
+	" rolas.album_peak " +
+	"FROM rolas " +
+	"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+	"INNER JOIN albums ON albums.id_album = rolas.id_album "
+
+// scanRola reads a Rola from the current row of a rolaQuery.
+func scanRola(rows *sql.Rows) *Rola {
+	var duration float64
+	var artwork sql.NullString
+	var start float64
+	var end float64
+	rola := &Rola{}
+	err := rows.Scan(&rola.id, &rola.artist, &rola.album, &rola.title, &rola.track, &rola.year, &rola.genre,
+		&rola.disc, &rola.albumArtist, &rola.composer, &rola.bpm, &rola.comment, &rola.lyrics,
+		&duration, &rola.bitrate, &rola.sampleRate, &rola.channels, &rola.vbr, &rola.path, &artwork,
+		&rola.file, &start, &end, &rola.replayGain.TrackLoudness, &rola.replayGain.TrackPeak,
+		&rola.replayGain.AlbumLoudness, &rola.replayGain.AlbumPeak)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rola.duration = time.Duration(duration * float64(time.Second))
+	rola.artwork = artwork.String
+	rola.start = time.Duration(start * float64(time.Second))
+	rola.end = time.Duration(end * float64(time.Second))
+	return rola
+}
+
 // QueryRola receives a Rola's ID as an argument and returns the correspoding
-// rola, but with an empty path.   It is assumed that the rola is in the
-// database.
+// rola.   The picture of the rola is the picture of its album if it has
+// none of its own (see QueryArtwork).   It is assumed that the rola is in
+// the database.
 func (database *Database) QueryRola(rolaID int64) *Rola {
-	stmtStr := "SELECT " +
-		" performers.name, " +
-		" albums.name, " +
-		" rolas.title, " +
-		" rolas.track, " +
-		" rolas.year, " +
-		" rolas.genre " +
-		"FROM rolas " +
-		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
-		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
-		"WHERE " +
-		" rolas.id_rola = ?"
+	tx, stmt, rows := database.PreparedQuery(rolaQuery+"WHERE rolas.id_rola = ?", rolaID)
+	defer stmt.Close()
+	defer rows.Close()
 
-	tx, stmt := database.PrepareStatement(stmtStr)
+	rola := &Rola{id: rolaID}
+	for rows.Next() {
+		rola = scanRola(rows)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return rola
+}
+
+// AllRolas returns the Rolas that are not hidden, sorted by ID, read
+// with a single query.
+func (database *Database) AllRolas() []*Rola {
+	tx, stmt, rows := database.PreparedQuery(rolaQuery + "WHERE rolas.hidden = 0 ORDER BY rolas.id_rola")
 	defer stmt.Close()
+	defer rows.Close()
 
-	rows, err := stmt.Query(rolaID)
+	result := make([]*Rola, 0)
+	for rows.Next() {
+		result = append(result, scanRola(rows))
+	}
+	err := rows.Err()
 	if err != nil {
-		log.Fatal("could not execute query: ", err)
+		log.Fatal(err)
 	}
+	tx.Commit()
+	return result
+}
+
+// QueryPath takes a Rola's ID as an argument and returns the path of
+// its file.
+func (database *Database) QueryPath(rolaID int64) string {
+	stmtStr := "SELECT path FROM rolas WHERE id_rola = ?"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, rolaID)
+	defer stmt.Close()
 	defer rows.Close()
 
-	var performer string
-	var album string
-	var title string
-	var track int
-	var year int
-	var genre string
+	var path string
 	for rows.Next() {
-		err = rows.Scan(&performer, &album, &title, &track, &year, &genre)
+		err := rows.Scan(&path)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		log.Fatal(err)
 	}
 	tx.Commit()
-	return &Rola{artist: performer,
-		title: title,
-		album: album,
-		track: track,
-		year:  year,
-		genre: genre,
-		path:  "",
-		id:    rolaID,
+	return path
+}
+
+// QueryFile takes a Rola's ID as an argument and returns the path of
+// its audio file, which for a track of a CUE sheet is the file of the
+// whole sheet (see Rola.File).
+func (database *Database) QueryFile(rolaID int64) string {
+	stmtStr := "SELECT CASE WHEN container = '' THEN path ELSE container END FROM rolas WHERE id_rola = ?"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, rolaID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	var path string
+	for rows.Next() {
+		err := rows.Scan(&path)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return path
 }
 
 // QueryRolaForeign takes a Rola's ID as an argument and returns the
@@ -773,8 +1096,9 @@ func (database *Database) QueryRolaForeign(rolaID int64) (int64, int64) {
 }
 
 // QuerySimple receives a string as an argument, and returns a slice with
-// the IDs of all the Rolas containing the string in its performer name,
-// album name, title, or genre.
+// the IDs of all the Rolas containing the string in the name of one of
+// its performers, album name, title, or genre; the hidden Rolas are
+// never found.
 func (database *Database) QuerySimple(wildcard string) []int64 {
 	result := make([]int64, 0)
 	stmtStr := "SELECT " +
@@ -783,14 +1107,14 @@ func (database *Database) QuerySimple(wildcard string) []int64 {
 		" rolas " +
 		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
 		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
-		"WHERE " +
-		" performers.name LIKE ? " +
+		"WHERE rolas.hidden = 0 AND (" +
+		performsCondition("LIKE ?") +
 		" OR albums.name LIKE ? " +
 		" OR rolas.title LIKE ? " +
-		" OR rolas.genre LIKE ?"
+		" OR rolas.genre LIKE ?)"
 
 	wildCard := "%" + strings.TrimSpace(wildcard) + "%"
-	tx, stmt, rows := database.PreparedQuery(stmtStr, wildCard, wildCard, wildCard, wildCard)
+	tx, stmt, rows := database.PreparedQuery(stmtStr, wildCard, wildCard, wildCard, wildCard, wildCard)
 	defer stmt.Close()
 	defer rows.Close()
 
@@ -810,6 +1134,17 @@ func (database *Database) QuerySimple(wildcard string) []int64 {
 	return result
 }
 
+// Search receives the text of a search, either a simple search or a
+// search in the language of the Parser (starting with '*~*'), and
+// returns a slice with the IDs of the Rolas found.
+func (database *Database) Search(text string) []int64 {
+	stmt, queryTerms, ok := GetParser().Parse(text)
+	if ok {
+		return database.QueryCustom(stmt, queryTerms...)
+	}
+	return database.QuerySimple(stmt)
+}
+
 // UpdateGroup receives new values for the fields of a group, together with the
 // group's ID, and updates the information. It is assumed that the group is
 // in the database.
@@ -830,18 +1165,153 @@ func (database *Database) UpdateGroup(name, start, end string, groupID int64) {
 	tx.Commit()
 }
 
-// UpdatePerformerType receives a performer's ID and a performer's type
-// (0, 1, 2), to set the new peformer's type.   It is assumed that the
-// performer is in the database.
-func (database *Database) UpdatePerformerType(performerID int64, performerType int) {
+// ReplaceAlbumArtwork sets the picture of an album, given its ID and
+// the hash of the picture in the ArtworkCache, replacing the picture
+// it had.
+func (database *Database) ReplaceAlbumArtwork(albumID int64, hash string) {
+	stmtStr := "UPDATE albums " +
+		"SET artwork = ? " +
+		"WHERE id_album = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(hash, albumID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// ReplaceArtwork sets the picture of a Rola, given its ID and the hash
+// of the picture in the ArtworkCache, replacing the picture it had.
+func (database *Database) ReplaceArtwork(rolaID int64, hash string) {
+	stmtStr := "UPDATE rolas " +
+		"SET artwork = ? " +
+		"WHERE id_rola = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(hash, rolaID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// setAlbumArtwork sets the picture of an album, given its ID, if the
+// album had no picture.
+func (database *Database) setAlbumArtwork(albumID int64, hash string) {
+	stmtStr := "UPDATE albums " +
+		"SET artwork = ? " +
+		"WHERE id_album = ? AND (artwork IS NULL OR artwork = '')"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(hash, albumID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// UpdateArtwork takes a Rola as an argument and sets the picture of the
+// rola in the database with the same path, if the rola had no picture.
+func (database *Database) UpdateArtwork(rola *Rola) {
+	stmtStr := "UPDATE rolas " +
+		"SET artwork = ? " +
+		"WHERE path = ? AND (artwork IS NULL OR artwork = '')"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(rola.Artwork(), rola.Path())
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// UpdateAudioProperties takes a Rola as an argument and updates the
+// properties of the audio stream of the rola in the database with the
+// same path.   It is used to measure the rolas mined before the audio
+// properties were stored; if the duration changed, the file was replaced,
+// and its audio hash and fingerprint are forgotten.
+func (database *Database) UpdateAudioProperties(rola *Rola) {
+	stmtStr := "UPDATE rolas " +
+		"SET duration = ?, " +
+		"    bitrate = ?, " +
+		"    sample_rate = ?, " +
+		"    channels = ?, " +
+		"    vbr = ?, " +
+		"    audio_hash = CASE WHEN duration = ? THEN audio_hash ELSE '' END, " +
+		"    fingerprint = CASE WHEN duration = ? THEN fingerprint ELSE '' END " +
+		"WHERE path = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(rola.Duration().Seconds(), rola.Bitrate(), rola.SampleRate(), rola.Channels(), rola.VBR(),
+		rola.Duration().Seconds(), rola.Duration().Seconds(), rola.Path())
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// UpdateReplayGain takes a Rola and updates the loudness and the peak
+// of the Rola with the same path, and of its album, in the database;
+// the values not known, whose loudness is 0, are left as they were.
+func (database *Database) UpdateReplayGain(rola *Rola) {
+	stmtStr := "UPDATE rolas " +
+		"SET loudness = CASE WHEN ? = 0 THEN loudness ELSE ? END, " +
+		"    peak = CASE WHEN ? = 0 THEN peak ELSE ? END, " +
+		"    album_loudness = CASE WHEN ? = 0 THEN album_loudness ELSE ? END, " +
+		"    album_peak = CASE WHEN ? = 0 THEN album_peak ELSE ? END " +
+		"WHERE path = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	gain := rola.ReplayGain()
+	_, err := stmt.Exec(gain.TrackLoudness, gain.TrackLoudness, gain.TrackLoudness, gain.TrackPeak,
+		gain.AlbumLoudness, gain.AlbumLoudness, gain.AlbumLoudness, gain.AlbumPeak, rola.Path())
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// LinkPerformer receives a performer's ID and links the performer to
+// the person with the given ID, if it is not 0, or else to the group with
+// the given ID, and sets its type accordingly.   With both IDs 0 the
+// performer is unlinked and its type becomes unknown.   The name of the
+// performer, as credited in the rolas, may differ from the name of the
+// person or group.
+func (database *Database) LinkPerformer(performerID, personID, groupID int64) {
 	stmtStr := "UPDATE performers " +
-		"SET id_type = ? " +
+		"SET id_type = ?, " +
+		"    id_person = ?, " +
+		"    id_group = ? " +
 		"WHERE id_performer = ?"
 
+	var performerType int
+	var person, group interface{}
+	switch {
+	case personID > 0:
+		performerType, person = 0, personID
+	case groupID > 0:
+		performerType, group = 1, groupID
+	default:
+		performerType = 2
+	}
+
 	tx, stmt := database.PrepareStatement(stmtStr)
 	defer stmt.Close()
 
-	_, err := stmt.Exec(performerType, performerID)
+	_, err := stmt.Exec(performerType, person, group, performerID)
 	if err != nil {
 		log.Fatal("could not execute update: ", err)
 	}
@@ -871,20 +1341,31 @@ func (database *Database) UpdatePerson(stageName, realName, birth, death string,
 }
 
 // UpdateRola takes a Rola as an argument and updates all its fields in
-// the database.   It is assumed that the Rola taken as argument has the
-// same ID as the rola we want to update.
+// the database, crediting its performers again; the genres of the Rola
+// are normalized and resolved with the aliases of the genres.   It is
+// assumed that the Rola taken as argument has the same ID as the rola
+// we want to update.
 func (database *Database) UpdateRola(rola *Rola) {
+	genres := database.ResolveGenres(rola.genre)
+	rola.SetGenre(strings.Join(genres, GenreSeparator))
 	stmtStr := "UPDATE rolas " +
 		"SET title = ?, " +
 		"    track = ?, " +
 		"    year = ?, " +
-		"    genre = ? " +
+		"    genre = ?, " +
+		"    disc = ?, " +
+		"    album_artist = ?, " +
+		"    composer = ?, " +
+		"    bpm = ?, " +
+		"    comment = ?, " +
+		"    lyrics = ? " +
 		"WHERE id_rola = ?"
 
 	tx, stmt1 := database.PrepareStatement(stmtStr)
 	defer stmt1.Close()
 
-	_, err := stmt1.Exec(rola.title, rola.track, rola.year, rola.genre, rola.id)
+	_, err := stmt1.Exec(rola.title, rola.track, rola.year, rola.genre,
+		rola.disc, rola.albumArtist, rola.composer, rola.bpm, rola.comment, rola.lyrics, rola.id)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -899,11 +1380,14 @@ func (database *Database) UpdateRola(rola *Rola) {
 		"WHERE id_rola = ?"
 
 	tx, stmt2 := database.PrepareStatement(stmtStr)
-	defer stmt1.Close()
+	defer stmt2.Close()
 
 	_, err = stmt2.Exec(performerID, albumID, rola.id)
 	if err != nil {
 		log.Fatal(err)
 	}
 	tx.Commit()
+
+	database.SetCredits(rola.id, GetCreditSplitter().Split(rola.Artist(), rola.Title(), rola.Composer()))
+	database.SetGenres(rola.id, genres)
 }
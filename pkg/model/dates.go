@@ -0,0 +1,110 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A PartialDate is a date known to the year, to the month or to the day,
+// as the dates of birth and death of the persons and the start and end
+// dates of the groups.   A Month or Day of 0 is unknown.
+type PartialDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// ParsePartialDate parses a partial date in ISO 8601: "1946", "1946-09"
+// or "1946-09-05"; the month and the day may have a single digit.
+func ParsePartialDate(text string) (PartialDate, error) {
+	date := PartialDate{}
+	parts := strings.Split(strings.TrimSpace(text), "-")
+	if len(parts) > 3 || len(parts[0]) != 4 {
+		return date, fmt.Errorf("invalid date %q, expecting YYYY, YYYY-MM or YYYY-MM-DD", text)
+	}
+	fields := []*int{&date.Year, &date.Month, &date.Day}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part == "" || len(part) > 4 || (i > 0 && len(part) > 2) {
+			return date, fmt.Errorf("invalid date %q, expecting YYYY, YYYY-MM or YYYY-MM-DD", text)
+		}
+		*fields[i] = n
+	}
+	if date.Year < 1 || (len(parts) > 1 && (date.Month < 1 || date.Month > 12)) {
+		return date, fmt.Errorf("invalid date %q", text)
+	}
+	if len(parts) == 3 {
+		full := time.Date(date.Year, time.Month(date.Month), date.Day, 0, 0, 0, 0, time.UTC)
+		if date.Day < 1 || full.Day() != date.Day {
+			return date, fmt.Errorf("invalid date %q", text)
+		}
+	}
+	return date, nil
+}
+
+// String returns the date in ISO 8601, with as many fields as are known.
+// The dates are stored in the database in this form, which sorts them.
+func (date PartialDate) String() string {
+	switch {
+	case date.Day > 0:
+		return fmt.Sprintf("%04d-%02d-%02d", date.Year, date.Month, date.Day)
+	case date.Month > 0:
+		return fmt.Sprintf("%04d-%02d", date.Year, date.Month)
+	}
+	return fmt.Sprintf("%04d", date.Year)
+}
+
+// NormalizeDate returns a partial date in the form it is stored in the
+// database, or an error if it is not a partial date.   An empty date,
+// which is unknown, stays empty.
+func NormalizeDate(text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+	date, err := ParsePartialDate(text)
+	if err != nil {
+		return "", err
+	}
+	return date.String(), nil
+}
+
+// errNoAge is returned by Age when the age cannot be known.
+var errNoAge = errors.New("unknown age")
+
+// Age returns the age, in years, in the given year of someone born on
+// the given partial date.   As only the year is known, the age is the
+// one reached during that year.
+func Age(birth string, year int) (int, error) {
+	date, err := ParsePartialDate(birth)
+	if err != nil || year <= 0 || year < date.Year {
+		return 0, errNoAge
+	}
+	return year - date.Year, nil
+}
+
+// AgeAtRecording returns the age of the performer of a Rola in the year
+// of the Rola, if the performer is linked to a person whose date of
+// birth is known and the Rola has a year.
+func (database *Database) AgeAtRecording(rolaID int64) (int, bool) {
+	stmtStr := "SELECT rolas.year, persons.birth_date " +
+		"FROM rolas " +
+		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+		"INNER JOIN persons ON persons.id_person = performers.id_person " +
+		"WHERE rolas.id_rola = ?"
+	var year sql.NullInt64
+	var birth sql.NullString
+	err := database.Database.QueryRow(stmtStr, rolaID).Scan(&year, &birth)
+	if err == sql.ErrNoRows {
+		return 0, false
+	}
+	if err != nil {
+		log.Fatal("could not execute query: ", err)
+	}
+	age, err := Age(birth.String, int(year.Int64))
+	return age, err == nil
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	dates := map[string]string{
+		"1946":       "1946",
+		" 1946-9 ":   "1946-09",
+		"1946-09-05": "1946-09-05",
+		"2000-2-29":  "2000-02-29",
+		"":           "",
+	}
+	for text, expected := range dates {
+		date, err := NormalizeDate(text)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if date != expected {
+			t.Errorf("expecting %v, received %v", expected, date)
+		}
+	}
+	invalid := []string{"46", "1946-13", "1946-00", "1900-02-29", "1946-09-31",
+		"1946-09-05-01", "5/9/1946", "1946-", "0000", "1946-009"}
+	for _, text := range invalid {
+		if date, err := NormalizeDate(text); err == nil {
+			t.Errorf("expecting an error for %q, received %v", text, date)
+		}
+	}
+}
+
+func TestAge(t *testing.T) {
+	if age, err := Age("1946-09-05", 1975); err != nil || age != 29 {
+		t.Errorf("expecting %v, received %v, %v", 29, age, err)
+	}
+	if _, err := Age("", 1975); err == nil {
+		t.Errorf("expecting an error for an unknown birth")
+	}
+	if _, err := Age("1946", 0); err == nil {
+		t.Errorf("expecting an error for an unknown year")
+	}
+}
@@ -0,0 +1,773 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DumpVersion is the version of the format of the Dumps written by this
+// version of the application.
+const DumpVersion = 1
+
+// DumpTables are the names of the tables of a Dump, in the order they
+// are imported; they are the keys of its JSON object, the names of its
+// CSV files and the keys of an ImportReport.
+var DumpTables = []string{"persons", "groups", "memberships", "performers", "albums", "genres", "aliases",
+	"rolas", "credits", "playlists"}
+
+// A Dump holds the whole library, as written by Export and read by
+// Import.   Its JSON form is an object with the version of the format
+// and a list of records for every table of the database:
+//
+//	{
+//	  "version": 1,
+//	  "persons": [{"id", "stage_name", "real_name", "birth_date", "death_date"}],
+//	  "groups": [{"id", "name", "start_date", "end_date"}],
+//	  "memberships": [{"person_id", "group_id", "joined_date", "left_date", "roles"}],
+//	  "performers": [{"id", "name", "type", "person_id", "group_id"}],
+//	  "albums": [{"id", "name", "path", "year", "artwork"}],
+//	  "genres": [{"id", "name", "parent_id"}],
+//	  "aliases": [{"alias", "genre_id"}],
+//	  "rolas": [{"id", "performer_id", "album_id", "path", "title", ...}],
+//	  "credits": [{"rola_id", "performer_id", "credit", "position"}],
+//	  "playlists": [{"id", "name", "comment", "owner", "public", "created", "changed", "rolas"}]
+//	}
+//
+// The IDs only link the records of the Dump to each other: the rolas
+// point to their performer and album, the performers to the person or
+// group they are (0 if none), the memberships to a person and a group,
+// the credits to a rola and a performer credited in it, the genres to
+// the genre they are a subgenre of (0 if none), the aliases to a genre,
+// and the playlists list the IDs of their rolas in order.
+// Times are written in RFC 3339, and durations in seconds.   The genres
+// of a rola are separated by semicolons in its genre.
+type Dump struct {
+	Version     int              `json:"version"`
+	Persons     []*Person        `json:"persons"`
+	Groups      []*Group         `json:"groups"`
+	Memberships []*Membership    `json:"memberships"`
+	Performers  []*Performer     `json:"performers"`
+	Albums      []*Album         `json:"albums"`
+	Genres      []*GenreRecord   `json:"genres"`
+	Aliases     []*GenreAlias    `json:"aliases"`
+	Rolas       []*RolaRecord    `json:"rolas"`
+	Credits     []*RolaPerformer `json:"credits"`
+	Playlists   []*Playlist      `json:"playlists"`
+}
+
+// A Person is a row of the persons table.
+type Person struct {
+	ID        int64  `json:"id" db:"id_person"`
+	StageName string `json:"stage_name" db:"stage_name"`
+	RealName  string `json:"real_name" db:"real_name"`
+	Birth     string `json:"birth_date" db:"birth_date"`
+	Death     string `json:"death_date" db:"death_date"`
+}
+
+// A Group is a row of the groups table.
+type Group struct {
+	ID    int64  `json:"id" db:"id_group"`
+	Name  string `json:"name" db:"name"`
+	Start string `json:"start_date" db:"start_date"`
+	End   string `json:"end_date" db:"end_date"`
+}
+
+// A Membership is a row of the in_group table: a person who is a member
+// of a group, the partial dates when the person joined and left it, and
+// the roles of the person in it, separated by commas.
+type Membership struct {
+	PersonID int64  `json:"person_id" db:"id_person"`
+	GroupID  int64  `json:"group_id" db:"id_group"`
+	Joined   string `json:"joined_date" db:"joined_date"`
+	Left     string `json:"left_date" db:"left_date"`
+	Roles    string `json:"roles" db:"roles"`
+}
+
+// A RolaRecord is a row of the rolas table, which, unlike a Rola, names
+// its performer and album by their IDs and keeps the plays of the Rola
+// and whether it is hidden.   The container, start and end of the track
+// of a CUE sheet are its file and its offsets in seconds; the loudness
+// and the peak of the Rola and of its album are its ReplayGain.
+type RolaRecord struct {
+	ID            int64   `json:"id" db:"id_rola"`
+	PerformerID   int64   `json:"performer_id" db:"id_performer"`
+	AlbumID       int64   `json:"album_id" db:"id_album"`
+	Path          string  `json:"path" db:"path"`
+	Title         string  `json:"title" db:"title"`
+	Track         int     `json:"track" db:"track"`
+	Year          int     `json:"year" db:"year"`
+	Genre         string  `json:"genre" db:"genre"`
+	Disc          int     `json:"disc" db:"disc"`
+	AlbumArtist   string  `json:"album_artist" db:"album_artist"`
+	Composer      string  `json:"composer" db:"composer"`
+	BPM           int     `json:"bpm" db:"bpm"`
+	Comment       string  `json:"comment" db:"comment"`
+	Lyrics        string  `json:"lyrics" db:"lyrics"`
+	Duration      float64 `json:"duration" db:"duration"`
+	Bitrate       int     `json:"bitrate" db:"bitrate"`
+	SampleRate    int     `json:"sample_rate" db:"sample_rate"`
+	Channels      int     `json:"channels" db:"channels"`
+	VBR           bool    `json:"vbr" db:"vbr"`
+	Artwork       string  `json:"artwork" db:"artwork"`
+	PlayCount     int     `json:"play_count" db:"play_count"`
+	LastPlayed    string  `json:"last_played" db:"last_played"`
+	Hidden        bool    `json:"hidden" db:"hidden"`
+	Container     string  `json:"container" db:"container"`
+	Start         float64 `json:"start" db:"start_offset"`
+	End           float64 `json:"end" db:"end_offset"`
+	Loudness      float64 `json:"loudness" db:"loudness"`
+	Peak          float64 `json:"peak" db:"peak"`
+	AlbumLoudness float64 `json:"album_loudness" db:"album_loudness"`
+	AlbumPeak     float64 `json:"album_peak" db:"album_peak"`
+}
+
+// A ConflictPolicy tells Import what to do with an imported record when
+// the database already has a record with the same key: a performer or
+// group with the same name, a person with the same stage name, an album
+// with the same name and directory, a Rola with the same path, or a
+// playlist with the same name and owner.
+type ConflictPolicy int
+
+const (
+	// SkipConflicts keeps the record of the database unchanged.
+	SkipConflicts ConflictPolicy = iota
+	// OverwriteConflicts replaces the fields of the record of the
+	// database with the imported ones.
+	OverwriteConflicts
+	// MergeConflicts fills the empty fields of the record of the
+	// database with the imported ones; the Rolas missing from a
+	// playlist are added at its end.
+	MergeConflicts
+)
+
+// ConflictPolicies maps the names of the policies, as given in the
+// command line, to the policies.
+var ConflictPolicies = map[string]ConflictPolicy{
+	"skip":      SkipConflicts,
+	"overwrite": OverwriteConflicts,
+	"merge":     MergeConflicts,
+}
+
+// An ImportReport counts, for every table of a Dump, the records added
+// to the database and the ones already in it that were skipped or
+// updated.
+type ImportReport struct {
+	Added   map[string]int
+	Skipped map[string]int
+	Updated map[string]int
+}
+
+// Export reads the whole library into a Dump.
+func (database *Database) Export() *Dump {
+	dump := &Dump{Version: DumpVersion}
+	database.selectAll("persons", &dump.Persons)
+	database.selectAll("groups", &dump.Groups)
+	database.selectAll("in_group", &dump.Memberships)
+	database.selectAll("performers", &dump.Performers)
+	database.selectAll("albums", &dump.Albums)
+	database.selectAll("genres", &dump.Genres)
+	database.selectAll("genre_aliases", &dump.Aliases)
+	database.selectAll("rolas", &dump.Rolas)
+	database.selectAll("rola_performers", &dump.Credits)
+	database.selectAll("playlists", &dump.Playlists)
+	for _, playlist := range dump.Playlists {
+		stmtStr := "SELECT id_rola FROM playlist_rolas WHERE id_playlist = ? ORDER BY position"
+		playlist.Rolas = database.QueryCustom(stmtStr, playlist.ID)
+	}
+	return dump
+}
+
+// WriteJSON writes the Dump as indented JSON.
+func (dump *Dump) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dump)
+}
+
+// ReadJSONDump reads a Dump written by WriteJSON, and checks it.
+func ReadJSONDump(r io.Reader) (*Dump, error) {
+	dump := &Dump{}
+	err := json.NewDecoder(r).Decode(dump)
+	if err != nil {
+		return nil, err
+	}
+	return dump, dump.check()
+}
+
+// check verifies that the Dump can be imported: that its version is
+// known, that the IDs of every table are unique and that every
+// reference points to a record of the Dump.
+func (dump *Dump) check() error {
+	if dump.Version < 1 || dump.Version > DumpVersion {
+		return fmt.Errorf("unknown version %d of the dump", dump.Version)
+	}
+	ids := make(map[string]map[int64]bool)
+	for _, table := range DumpTables {
+		ids[table] = make(map[int64]bool)
+	}
+	unique := func(table string, id int64) error {
+		if ids[table][id] {
+			return fmt.Errorf("%s: repeated id %d", table, id)
+		}
+		ids[table][id] = true
+		return nil
+	}
+	refer := func(record, target string, targetID int64) error {
+		if !ids[target][targetID] {
+			return fmt.Errorf("%s refers to the missing %s %d", record, strings.TrimSuffix(target, "s"), targetID)
+		}
+		return nil
+	}
+
+	var err error
+	for _, person := range dump.Persons {
+		if err = unique("persons", person.ID); err != nil {
+			return err
+		}
+	}
+	for _, group := range dump.Groups {
+		if err = unique("groups", group.ID); err != nil {
+			return err
+		}
+	}
+	for _, membership := range dump.Memberships {
+		record := fmt.Sprintf("memberships: person %d in group %d", membership.PersonID, membership.GroupID)
+		err = refer(record, "persons", membership.PersonID)
+		if err == nil {
+			err = refer(record, "groups", membership.GroupID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, performer := range dump.Performers {
+		record := fmt.Sprintf("performers: id %d", performer.ID)
+		err = unique("performers", performer.ID)
+		if err == nil && (performer.Type < 0 || performer.Type > 2) {
+			err = fmt.Errorf("%s has the unknown type %d", record, performer.Type)
+		}
+		if err == nil && performer.PersonID != 0 {
+			err = refer(record, "persons", performer.PersonID)
+		}
+		if err == nil && performer.GroupID != 0 {
+			err = refer(record, "groups", performer.GroupID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, album := range dump.Albums {
+		if err = unique("albums", album.ID); err != nil {
+			return err
+		}
+	}
+	for _, genre := range dump.Genres {
+		if err = unique("genres", genre.ID); err != nil {
+			return err
+		}
+	}
+	parents := make(map[int64]int64)
+	for _, genre := range dump.Genres {
+		if genre.ParentID != 0 {
+			if err = refer(fmt.Sprintf("genres: id %d", genre.ID), "genres", genre.ParentID); err != nil {
+				return err
+			}
+			parents[genre.ID] = genre.ParentID
+		}
+	}
+	for _, genre := range dump.Genres {
+		steps := 0
+		for id := genre.ParentID; id != 0; id = parents[id] {
+			if steps++; id == genre.ID || steps > len(dump.Genres) {
+				return fmt.Errorf("genres: id %d is a subgenre of itself", genre.ID)
+			}
+		}
+	}
+	for _, alias := range dump.Aliases {
+		if err = refer(fmt.Sprintf("aliases: alias %q", alias.Alias), "genres", alias.GenreID); err != nil {
+			return err
+		}
+	}
+	for _, rola := range dump.Rolas {
+		record := fmt.Sprintf("rolas: id %d", rola.ID)
+		err = unique("rolas", rola.ID)
+		if err == nil {
+			err = refer(record, "performers", rola.PerformerID)
+		}
+		if err == nil {
+			err = refer(record, "albums", rola.AlbumID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, credit := range dump.Credits {
+		record := fmt.Sprintf("credits: rola %d", credit.RolaID)
+		err = refer(record, "rolas", credit.RolaID)
+		if err == nil {
+			err = refer(record, "performers", credit.PerformerID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, playlist := range dump.Playlists {
+		err = unique("playlists", playlist.ID)
+		for _, rolaID := range playlist.Rolas {
+			if err == nil {
+				err = refer(fmt.Sprintf("playlists: id %d", playlist.ID), "rolas", rolaID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import adds the records of a Dump to the database, resolving the
+// conflicts with the records already in it with the given policy.   The
+// records are given new IDs, and the references between them are
+// changed accordingly.   The Dump is checked before anything is
+// written, and it is imported in a single transaction.
+func (database *Database) Import(dump *Dump, policy ConflictPolicy) (*ImportReport, error) {
+	err := dump.check()
+	if err != nil {
+		return nil, err
+	}
+	existing := database.Export()
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	importer := &importer{
+		tx:     tx,
+		policy: policy,
+		report: &ImportReport{
+			Added:   make(map[string]int),
+			Skipped: make(map[string]int),
+			Updated: make(map[string]int),
+		},
+	}
+
+	personIndex := make(map[string]*Person)
+	for _, person := range existing.Persons {
+		personIndex[person.StageName] = person
+	}
+	persons := make(map[int64]int64)
+	for _, person := range dump.Persons {
+		current := personIndex[person.StageName]
+		id := importer.put("persons", person, current)
+		persons[person.ID] = id
+		if current == nil {
+			added := *person
+			added.ID = id
+			personIndex[person.StageName] = &added
+		}
+	}
+
+	groupIndex := make(map[string]*Group)
+	for _, group := range existing.Groups {
+		groupIndex[group.Name] = group
+	}
+	groups := make(map[int64]int64)
+	for _, group := range dump.Groups {
+		current := groupIndex[group.Name]
+		id := importer.put("groups", group, current)
+		groups[group.ID] = id
+		if current == nil {
+			added := *group
+			added.ID = id
+			groupIndex[group.Name] = &added
+		}
+	}
+
+	for _, membership := range dump.Memberships {
+		result, err := tx.Exec("INSERT OR IGNORE INTO in_group (id_person, id_group, joined_date, left_date, roles) "+
+			"VALUES (?, ?, ?, ?, ?)", persons[membership.PersonID], groups[membership.GroupID],
+			membership.Joined, membership.Left, membership.Roles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			importer.report.Added["memberships"]++
+		} else {
+			importer.report.Skipped["memberships"]++
+		}
+	}
+
+	performerIndex := make(map[string]*Performer)
+	for _, performer := range existing.Performers {
+		performerIndex[performer.Name] = performer
+	}
+	performers := make(map[int64]int64)
+	for _, performer := range dump.Performers {
+		remapped := *performer
+		remapped.PersonID = persons[performer.PersonID]
+		remapped.GroupID = groups[performer.GroupID]
+		current := performerIndex[performer.Name]
+		remapped.ID = importer.put("performers", &remapped, current)
+		performers[performer.ID] = remapped.ID
+		if current == nil {
+			performerIndex[performer.Name] = &remapped
+		}
+	}
+
+	albumIndex := make(map[string]*Album)
+	for _, album := range existing.Albums {
+		albumIndex[album.Path+"\x00"+album.Name] = album
+	}
+	albums := make(map[int64]int64)
+	for _, album := range dump.Albums {
+		key := album.Path + "\x00" + album.Name
+		current := albumIndex[key]
+		id := importer.put("albums", album, current)
+		albums[album.ID] = id
+		if current == nil {
+			added := *album
+			added.ID = id
+			albumIndex[key] = &added
+		}
+	}
+
+	genreIndex := make(map[string]*GenreRecord)
+	for _, genre := range existing.Genres {
+		genreIndex[strings.ToLower(genre.Name)] = genre
+	}
+	genres := make(map[int64]int64)
+	addedGenres := make(map[int64]bool)
+	for _, genre := range dump.Genres {
+		if current := genreIndex[strings.ToLower(genre.Name)]; current != nil {
+			genres[genre.ID] = current.ID
+			importer.report.Skipped["genres"]++
+			continue
+		}
+		added := *genre
+		added.ParentID = 0
+		added.ID = importer.insert("genres", &added)
+		genres[genre.ID] = added.ID
+		addedGenres[genre.ID] = true
+		genreIndex[strings.ToLower(genre.Name)] = &added
+		importer.report.Added["genres"]++
+	}
+	for _, genre := range dump.Genres {
+		if genre.ParentID != 0 && addedGenres[genre.ID] {
+			_, err = tx.Exec("UPDATE genres SET id_parent = ? WHERE id_genre = ?", genres[genre.ParentID], genres[genre.ID])
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	for _, alias := range dump.Aliases {
+		result, err := tx.Exec("INSERT OR IGNORE INTO genre_aliases (alias, id_genre) VALUES (?, ?)",
+			alias.Alias, genres[alias.GenreID])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			importer.report.Added["aliases"]++
+		} else {
+			importer.report.Skipped["aliases"]++
+		}
+	}
+
+	rolaIndex := make(map[string]*RolaRecord)
+	for _, rola := range existing.Rolas {
+		rolaIndex[rola.Path] = rola
+	}
+	rolas := make(map[int64]int64)
+	for _, rola := range dump.Rolas {
+		remapped := *rola
+		remapped.PerformerID = performers[rola.PerformerID]
+		remapped.AlbumID = albums[rola.AlbumID]
+		current := rolaIndex[rola.Path]
+		remapped.ID = importer.put("rolas", &remapped, current)
+		rolas[rola.ID] = remapped.ID
+		if current == nil {
+			rolaIndex[rola.Path] = &remapped
+		}
+	}
+
+	index := loadGenreIndex(tx)
+	for _, id := range rolas {
+		var genre sql.NullString
+		err = tx.QueryRow("SELECT genre FROM rolas WHERE id_rola = ?", id).Scan(&genre)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resolved := index.Resolve(SplitGenres(genre.String))
+		_, err = tx.Exec("UPDATE rolas SET genre = ? WHERE id_rola = ?", strings.Join(resolved, GenreSeparator), id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		setGenres(tx, id, resolved)
+	}
+
+	for _, credit := range dump.Credits {
+		result, err := tx.Exec("INSERT OR IGNORE INTO rola_performers (id_rola, id_performer, credit, position) "+
+			"VALUES (?, ?, ?, ?)", rolas[credit.RolaID], performers[credit.PerformerID], credit.Credit, credit.Position)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			importer.report.Added["credits"]++
+		} else {
+			importer.report.Skipped["credits"]++
+		}
+	}
+	_, err = tx.Exec("INSERT OR IGNORE INTO rola_performers (id_rola, id_performer, credit, position) " +
+		"SELECT id_rola, id_performer, 'main', 0 FROM rolas " +
+		"WHERE NOT EXISTS (SELECT 1 FROM rola_performers WHERE rola_performers.id_rola = rolas.id_rola)")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	playlistIndex := make(map[string]*Playlist)
+	for _, playlist := range existing.Playlists {
+		playlistIndex[playlist.Owner+"\x00"+playlist.Name] = playlist
+	}
+	for _, playlist := range dump.Playlists {
+		remapped := *playlist
+		remapped.Rolas = make([]int64, 0, len(playlist.Rolas))
+		for _, rolaID := range playlist.Rolas {
+			remapped.Rolas = append(remapped.Rolas, rolas[rolaID])
+		}
+		key := playlist.Owner + "\x00" + playlist.Name
+		current := playlistIndex[key]
+		id := importer.put("playlists", &remapped, current)
+		switch {
+		case current == nil:
+			remapped.ID = id
+			playlistIndex[key] = &remapped
+		case policy == SkipConflicts:
+			continue
+		case policy == MergeConflicts:
+			remapped.Rolas = mergeRolas(current.Rolas, remapped.Rolas)
+		}
+		importer.setPlaylistRolas(id, remapped.Rolas)
+	}
+
+	tx.Commit()
+	return importer.report, nil
+}
+
+// mergeRolas returns the Rolas of a playlist followed by the given ones
+// missing from it.
+func mergeRolas(rolaIDs, added []int64) []int64 {
+	result := append([]int64{}, rolaIDs...)
+	seen := make(map[int64]bool)
+	for _, rolaID := range rolaIDs {
+		seen[rolaID] = true
+	}
+	for _, rolaID := range added {
+		if !seen[rolaID] {
+			seen[rolaID] = true
+			result = append(result, rolaID)
+		}
+	}
+	return result
+}
+
+// An importer writes the records of a Dump in a transaction.
+type importer struct {
+	tx     *sql.Tx
+	policy ConflictPolicy
+	report *ImportReport
+}
+
+// put writes an imported record to a table, given the record of the
+// database with the same key, or nil if there is none, and returns the
+// ID of the record in the database.
+func (importer *importer) put(table string, record, current interface{}) int64 {
+	if reflect.ValueOf(current).IsNil() {
+		importer.report.Added[table]++
+		return importer.insert(table, record)
+	}
+	id := reflect.ValueOf(current).Elem().FieldByName("ID").Int()
+	switch importer.policy {
+	case OverwriteConflicts:
+		importer.update(table, id, record)
+	case MergeConflicts:
+		merged := reflect.New(reflect.TypeOf(current).Elem())
+		merged.Elem().Set(reflect.ValueOf(current).Elem())
+		mergeRecord(merged.Interface(), record)
+		importer.update(table, id, merged.Interface())
+	default:
+		importer.report.Skipped[table]++
+		return id
+	}
+	importer.report.Updated[table]++
+	return id
+}
+
+// insert adds a record to a table, with a new ID, and returns the ID.
+func (importer *importer) insert(table string, record interface{}) int64 {
+	columns, values := recordColumns(record)
+	stmtStr := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)",
+		table, strings.Join(columns[1:], ", "), strings.Repeat(", ?", len(columns)-2))
+	result, err := importer.tx.Exec(stmtStr, values[1:]...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Fatal("could not retrieve the last insert ID:", err)
+	}
+	return id
+}
+
+// update replaces the fields of the record of a table with the given ID
+// with the fields of a record.
+func (importer *importer) update(table string, id int64, record interface{}) {
+	columns, values := recordColumns(record)
+	stmtStr := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?",
+		table, strings.Join(columns[1:], " = ?, "), columns[0])
+	_, err := importer.tx.Exec(stmtStr, append(values[1:], id)...)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+}
+
+func (importer *importer) setPlaylistRolas(playlistID int64, rolaIDs []int64) {
+	_, err := importer.tx.Exec("DELETE FROM playlist_rolas WHERE id_playlist = ?", playlistID)
+	if err != nil {
+		log.Fatal("could not execute delete: ", err)
+	}
+	for position, rolaID := range rolaIDs {
+		_, err = importer.tx.Exec("INSERT INTO playlist_rolas (id_playlist, position, id_rola) VALUES (?, ?, ?)",
+			playlistID, position, rolaID)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// mergeRecord fills the fields of a record that have their zero value
+// with the fields of another record of the same type; the ID and the
+// lists are left alone.
+func mergeRecord(into, from interface{}) {
+	target := reflect.ValueOf(into).Elem()
+	source := reflect.ValueOf(from).Elem()
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		if field.Name == "ID" || field.Type.Kind() == reflect.Slice {
+			continue
+		}
+		zero := reflect.Zero(field.Type).Interface()
+		if reflect.DeepEqual(target.Field(i).Interface(), zero) {
+			target.Field(i).Set(source.Field(i))
+		}
+	}
+}
+
+// recordColumns returns the columns of a record, given by the db tags
+// of its fields, and the values to write in them.   The primary key is
+// the first column, except for memberships, which have none; the other
+// IDs are references, written as NULL when they are 0.
+func recordColumns(record interface{}) ([]string, []interface{}) {
+	value := reflect.ValueOf(record).Elem()
+	columns := make([]string, 0)
+	values := make([]interface{}, 0)
+	for i := 0; i < value.NumField(); i++ {
+		column := value.Type().Field(i).Tag.Get("db")
+		if column == "" || column == "-" {
+			continue
+		}
+		columns = append(columns, column)
+		switch field := value.Field(i).Interface().(type) {
+		case time.Time:
+			if field.IsZero() {
+				values = append(values, "")
+			} else {
+				values = append(values, field.Format(time.RFC3339))
+			}
+		case int64:
+			if field == 0 && len(columns) > 1 && strings.HasPrefix(column, "id_") {
+				values = append(values, nil)
+			} else {
+				values = append(values, field)
+			}
+		case bool:
+			if field {
+				values = append(values, 1)
+			} else {
+				values = append(values, 0)
+			}
+		default:
+			values = append(values, field)
+		}
+	}
+	return columns, values
+}
+
+// selectAll reads all the rows of a table, in the order of its first
+// column, into a slice of pointers to records whose db tags are the
+// columns of the table.
+func (database *Database) selectAll(table string, records interface{}) {
+	slice := reflect.ValueOf(records).Elem()
+	recordType := slice.Type().Elem().Elem()
+	columns, _ := recordColumns(reflect.New(recordType).Interface())
+	stmtStr := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", strings.Join(columns, ", "), table, columns[0])
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+	for rows.Next() {
+		record := reflect.New(recordType)
+		fields := make([]reflect.Value, 0, len(columns))
+		holders := make([]interface{}, 0, len(columns))
+		for i := 0; i < recordType.NumField(); i++ {
+			column := recordType.Field(i).Tag.Get("db")
+			if column == "" || column == "-" {
+				continue
+			}
+			field := record.Elem().Field(i)
+			fields = append(fields, field)
+			switch field.Kind() {
+			case reflect.Int, reflect.Int64, reflect.Bool:
+				holders = append(holders, &sql.NullInt64{})
+			case reflect.Float64:
+				holders = append(holders, &sql.NullFloat64{})
+			default:
+				holders = append(holders, &sql.NullString{})
+			}
+		}
+		err := rows.Scan(holders...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, field := range fields {
+			switch holder := holders[i].(type) {
+			case *sql.NullInt64:
+				if field.Kind() == reflect.Bool {
+					field.SetBool(holder.Int64 != 0)
+				} else {
+					field.SetInt(holder.Int64)
+				}
+			case *sql.NullFloat64:
+				field.SetFloat(holder.Float64)
+			case *sql.NullString:
+				if field.Type() == reflect.TypeOf(time.Time{}) {
+					parsed, _ := time.Parse(time.RFC3339, holder.String)
+					field.Set(reflect.ValueOf(parsed))
+				} else {
+					field.SetString(holder.String)
+				}
+			}
+		}
+		slice.Set(reflect.Append(slice, record))
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDump() *Dump {
+	changed := time.Date(2018, 10, 3, 21, 15, 0, 0, time.UTC)
+	return &Dump{
+		Version:     DumpVersion,
+		Persons:     []*Person{{ID: 2, StageName: "Freddie Mercury", RealName: "Farrokh Bulsara", Birth: "1946-09-05"}},
+		Groups:      []*Group{{ID: 3, Name: "Queen", Start: "1970"}},
+		Memberships: []*Membership{{PersonID: 2, GroupID: 3, Joined: "1970", Left: "1991-11-24", Roles: "vocals, piano"}},
+		Performers: []*Performer{{ID: 4, Name: "Queen", Type: 1, GroupID: 3},
+			{ID: 7, Name: "Freddie Mercury", Type: 0, PersonID: 2}, {ID: 8, Name: "Unknown", Type: 2}},
+		Albums:  []*Album{{ID: 5, Name: "A Night at the Opera", Path: "/music/Queen", Year: 1975}},
+		Genres:  []*GenreRecord{{ID: 1, Name: "Rock"}, {ID: 2, Name: "Progressive Rock", ParentID: 1}},
+		Aliases: []*GenreAlias{{Alias: "Prog Rock", GenreID: 2}},
+		Rolas: []*RolaRecord{
+			{ID: 10, PerformerID: 4, AlbumID: 5, Path: "/music/Queen/11.mp3", Title: "Bohemian Rhapsody",
+				Track: 11, Year: 1975, Genre: "Rock", Comment: "with, commas \"and\" quotes",
+				Lyrics: "Is this the real life?\nIs this just fantasy?", Duration: 354.32, VBR: true, PlayCount: 3},
+			{ID: 11, PerformerID: 7, AlbumID: 5, Path: "/music/Queen/09.mp3", Title: "Love of My Life"},
+		},
+		Credits: []*RolaPerformer{{RolaID: 10, PerformerID: 4, Credit: CreditMain},
+			{RolaID: 11, PerformerID: 7, Credit: CreditMain}, {RolaID: 11, PerformerID: 8, Credit: CreditFeatured, Position: 1}},
+		Playlists: []*Playlist{
+			{ID: 1, Name: "Opera", Owner: "ana", Public: true, Created: changed, Changed: changed, Rolas: []int64{11, 10, 11}},
+		},
+	}
+}
+
+func TestDumpCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dump := testDump()
+	err = dump.WriteCSV(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadCSVDump(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, dump) {
+		t.Errorf("expecting %+v, received %+v", dump, read)
+	}
+
+	os.Remove(filepath.Join(dir, "playlists.csv"))
+	if _, err := ReadCSVDump(dir); err == nil || !strings.Contains(err.Error(), "no playlist with id 1") {
+		t.Errorf("expecting an error for the rolas of a missing playlist, received %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "groups.csv"), []byte("id,name,members\n"), 0644)
+	if _, err := ReadCSVDump(dir); err == nil || !strings.Contains(err.Error(), "unknown column \"members\"") {
+		t.Errorf("expecting an error for an unknown column, received %v", err)
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	dump := testDump()
+	var buffer bytes.Buffer
+	err := dump.WriteJSON(&buffer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadJSONDump(&buffer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, dump) {
+		t.Errorf("expecting %+v, received %+v", dump, read)
+	}
+}
+
+func TestDumpCheck(t *testing.T) {
+	broken := []struct {
+		change   func(*Dump)
+		expected string
+	}{
+		{func(dump *Dump) { dump.Version = DumpVersion + 1 }, "unknown version"},
+		{func(dump *Dump) { dump.Albums = append(dump.Albums, &Album{ID: 5}) }, "albums: repeated id 5"},
+		{func(dump *Dump) { dump.Performers[0].Type = 3 }, "unknown type 3"},
+		{func(dump *Dump) { dump.Rolas[1].AlbumID = 6 }, "rolas: id 11 refers to the missing album 6"},
+		{func(dump *Dump) { dump.Memberships[0].GroupID = 4 }, "refers to the missing group 4"},
+		{func(dump *Dump) { dump.Performers[1].PersonID = 5 }, "performers: id 7 refers to the missing person 5"},
+		{func(dump *Dump) { dump.Credits[2].PerformerID = 9 }, "credits: rola 11 refers to the missing performer 9"},
+		{func(dump *Dump) { dump.Genres[0].ParentID = 2 }, "genres: id 1 is a subgenre of itself"},
+		{func(dump *Dump) { dump.Aliases[0].GenreID = 3 }, "aliases: alias \"Prog Rock\" refers to the missing genre 3"},
+		{func(dump *Dump) { dump.Playlists[0].Rolas[0] = 12 }, "playlists: id 1 refers to the missing rola 12"},
+	}
+	if err := testDump().check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, test := range broken {
+		dump := testDump()
+		test.change(dump)
+		if err := dump.check(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expecting %v, received %v", test.expected, err)
+		}
+	}
+}
+
+func TestMergeRecord(t *testing.T) {
+	current := &Person{ID: 1, StageName: "Freddie Mercury", Birth: "1946"}
+	imported := &Person{ID: 9, StageName: "Freddie", RealName: "Farrokh Bulsara", Birth: "1946-09-05"}
+	mergeRecord(current, imported)
+	expected := &Person{ID: 1, StageName: "Freddie Mercury", RealName: "Farrokh Bulsara", Birth: "1946"}
+	if !reflect.DeepEqual(current, expected) {
+		t.Errorf("expecting %+v, received %+v", expected, current)
+	}
+
+	rolas := mergeRolas([]int64{3, 1}, []int64{1, 2, 4, 2})
+	if expected := []int64{3, 1, 2, 4}; !reflect.DeepEqual(rolas, expected) {
+		t.Errorf("expecting %v, received %v", expected, rolas)
+	}
+}
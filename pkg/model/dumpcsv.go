@@ -0,0 +1,263 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// playlistRolasHeader holds the columns of the CSV file with the Rolas
+// of the playlists.
+var playlistRolasHeader = []string{"playlist_id", "position", "rola_id"}
+
+// WriteCSV writes the Dump to a directory as a CSV file for every table,
+// named after the key of the table in the JSON form of the Dump, whose
+// columns are the fields of the JSON records.   The Rolas of the
+// playlists are written to playlist_rolas.csv, with the columns
+// playlist_id, position and rola_id.
+func (dump *Dump) WriteCSV(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, table := range DumpTables {
+		records := dump.table(table)
+		header := csvColumns(records.Type().Elem().Elem())
+		rows := make([][]string, 0, records.Len())
+		for i := 0; i < records.Len(); i++ {
+			rows = append(rows, csvRecord(records.Index(i).Elem()))
+		}
+		err = writeCSVFile(filepath.Join(dir, table+".csv"), header, rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows := make([][]string, 0)
+	for _, playlist := range dump.Playlists {
+		for position, rolaID := range playlist.Rolas {
+			rows = append(rows, []string{strconv.FormatInt(playlist.ID, 10),
+				strconv.Itoa(position), strconv.FormatInt(rolaID, 10)})
+		}
+	}
+	return writeCSVFile(filepath.Join(dir, "playlist_rolas.csv"), playlistRolasHeader, rows)
+}
+
+// ReadCSVDump reads a Dump written by WriteCSV, and checks it.   A
+// missing file is read as an empty table, and the columns may be in any
+// order; the columns missing from a file are left empty.
+func ReadCSVDump(dir string) (*Dump, error) {
+	dump := &Dump{Version: DumpVersion}
+	found := false
+	for _, table := range DumpTables {
+		records := dump.table(table)
+		recordType := records.Type().Elem().Elem()
+		rows, err := readCSVFile(filepath.Join(dir, table+".csv"), csvColumns(recordType))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for i, row := range rows {
+			record := reflect.New(recordType)
+			err = parseCSVRecord(record.Elem(), row)
+			if err != nil {
+				return nil, fmt.Errorf("%s.csv: record %d: %v", table, i+1, err)
+			}
+			records.Set(reflect.Append(records, record))
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no CSV files of a dump in %s", dir)
+	}
+
+	rows, err := readCSVFile(filepath.Join(dir, "playlist_rolas.csv"), playlistRolasHeader)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	playlists := make(map[int64]*Playlist)
+	for _, playlist := range dump.Playlists {
+		playlists[playlist.ID] = playlist
+	}
+	entries := make(map[int64][][2]int64)
+	for i, row := range rows {
+		numbers := make([]int64, len(row))
+		for j, cell := range row {
+			numbers[j], err = strconv.ParseInt(cell, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("playlist_rolas.csv: record %d: invalid %s %q", i+1, playlistRolasHeader[j], cell)
+			}
+		}
+		if playlists[numbers[0]] == nil {
+			return nil, fmt.Errorf("playlist_rolas.csv: record %d: no playlist with id %d", i+1, numbers[0])
+		}
+		entries[numbers[0]] = append(entries[numbers[0]], [2]int64{numbers[1], numbers[2]})
+	}
+	for id, list := range entries {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i][0] < list[j][0]
+		})
+		for _, entry := range list {
+			playlists[id].Rolas = append(playlists[id].Rolas, entry[1])
+		}
+	}
+	return dump, dump.check()
+}
+
+// table returns the slice of the Dump with the records of the table with
+// the given name.
+func (dump *Dump) table(name string) reflect.Value {
+	value := reflect.ValueOf(dump).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("json") == name {
+			return value.Field(i)
+		}
+	}
+	panic("unknown table " + name)
+}
+
+// csvColumns returns the columns of the CSV files of the records of the
+// given type, which are the JSON names of their fields; the lists are
+// not written.
+func csvColumns(recordType reflect.Type) []string {
+	columns := make([]string, 0)
+	for i := 0; i < recordType.NumField(); i++ {
+		if recordType.Field(i).Type.Kind() != reflect.Slice {
+			columns = append(columns, recordType.Field(i).Tag.Get("json"))
+		}
+	}
+	return columns
+}
+
+// csvRecord returns the values of the fields of a record, in the order
+// of its csvColumns.
+func csvRecord(record reflect.Value) []string {
+	row := make([]string, 0)
+	for i := 0; i < record.NumField(); i++ {
+		switch field := record.Field(i).Interface().(type) {
+		case []int64:
+		case time.Time:
+			if field.IsZero() {
+				row = append(row, "")
+			} else {
+				row = append(row, field.Format(time.RFC3339))
+			}
+		case float64:
+			row = append(row, strconv.FormatFloat(field, 'f', -1, 64))
+		default:
+			row = append(row, fmt.Sprint(field))
+		}
+	}
+	return row
+}
+
+// parseCSVRecord sets the fields of a record from a row read with
+// readCSVFile; an empty cell leaves its field with the zero value.
+func parseCSVRecord(record reflect.Value, row []string) error {
+	column := 0
+	for i := 0; i < record.NumField(); i++ {
+		field := record.Field(i)
+		if field.Kind() == reflect.Slice {
+			continue
+		}
+		cell := row[column]
+		name := record.Type().Field(i).Tag.Get("json")
+		column++
+		if cell == "" {
+			continue
+		}
+		var err error
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(cell, 10, 64)
+			field.SetInt(n)
+		case reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(cell, 64)
+			field.SetFloat(f)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(cell)
+			field.SetBool(b)
+		case reflect.String:
+			field.SetString(cell)
+		default:
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, cell)
+			field.Set(reflect.ValueOf(t))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", name, cell)
+		}
+	}
+	return nil
+}
+
+func writeCSVFile(path string, header []string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	err = writer.Error()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readCSVFile reads the rows of a CSV file with the given columns, and
+// returns their cells in the order of the columns.   The first row of
+// the file names its columns.
+func readCSVFile(path string, columns []string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New(filepath.Base(path) + ": empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string]int)
+	for i, column := range columns {
+		indexes[column] = i
+	}
+	for _, column := range header {
+		if _, ok := indexes[column]; !ok {
+			return nil, fmt.Errorf("%s: unknown column %q", filepath.Base(path), column)
+		}
+	}
+
+	rows := make([][]string, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]string, len(columns))
+		for i, cell := range record {
+			row[indexes[header[i]]] = cell
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,423 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The reasons why Rolas are taken for duplicates.
+const (
+	DuplicateTags    = "same tags and duration"
+	DuplicateAudio   = "same audio"
+	DuplicateSimilar = "similar audio"
+)
+
+// DefaultDuplicateTolerance is the default difference of duration
+// between two Rolas with the same tags that are taken for duplicates.
+const DefaultDuplicateTolerance = 2 * time.Second
+
+// A DuplicateCandidate is a Rola of a group of duplicates, with what
+// tells its copies apart: the format, bitrate and size of the file, and
+// its path.
+type DuplicateCandidate struct {
+	ID          int64         `json:"id"`
+	Title       string        `json:"title"`
+	Artist      string        `json:"artist"`
+	Album       string        `json:"album"`
+	Path        string        `json:"path"`
+	Format      string        `json:"format"`
+	Duration    time.Duration `json:"duration"`
+	Bitrate     int           `json:"bitrate"`
+	SampleRate  int           `json:"sample_rate"`
+	Size        int64         `json:"size"`
+	hash        string
+	fingerprint []uint32
+	key         string
+	container   string
+}
+
+// A DuplicateGroup is a set of Rolas taken for copies of the same song,
+// the best one first, and the reasons why they are taken for copies.
+type DuplicateGroup struct {
+	Reasons    []string              `json:"reasons"`
+	Candidates []*DuplicateCandidate `json:"candidates"`
+}
+
+// duplicateKey returns the key under which the tags of a Rola are
+// compared: its artist and title in lowercase, with only their letters
+// and digits.
+func duplicateKey(artist, title string) string {
+	fold := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, text)
+	}
+	return fold(artist) + "\x00" + fold(title)
+}
+
+// groupDuplicates groups the candidates with the same key whose
+// durations differ by at most the tolerance, one after the other when
+// sorted by duration, the candidates with the same audio hash, and the
+// candidates whose durations differ by at most the tolerance and whose
+// fingerprints are similar, whatever their tags and formats.   The
+// candidates of a group are sorted from the best to the worst: by
+// bitrate, then by sample rate, then by path.
+func groupDuplicates(candidates []*DuplicateCandidate, tolerance time.Duration) []*DuplicateGroup {
+	parent := make(map[*DuplicateCandidate]*DuplicateCandidate)
+	var find func(c *DuplicateCandidate) *DuplicateCandidate
+	find = func(c *DuplicateCandidate) *DuplicateCandidate {
+		if parent[c] == nil || parent[c] == c {
+			return c
+		}
+		parent[c] = find(parent[c])
+		return parent[c]
+	}
+	reasons := make(map[*DuplicateCandidate]map[string]bool)
+	union := func(a, b *DuplicateCandidate, reason string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+			if reasons[ra] == nil {
+				reasons[ra] = make(map[string]bool)
+			}
+			for r := range reasons[rb] {
+				reasons[ra][r] = true
+			}
+		}
+		if reasons[ra] == nil {
+			reasons[ra] = make(map[string]bool)
+		}
+		reasons[ra][reason] = true
+	}
+
+	sorted := make([]*DuplicateCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].key != sorted[j].key {
+			return sorted[i].key < sorted[j].key
+		}
+		return sorted[i].Duration < sorted[j].Duration
+	})
+	for i := 1; i < len(sorted); i++ {
+		previous, current := sorted[i-1], sorted[i]
+		if current.key == previous.key && current.Duration-previous.Duration <= tolerance {
+			union(previous, current, DuplicateTags)
+		}
+	}
+	byHash := make(map[string]*DuplicateCandidate)
+	for _, candidate := range candidates {
+		if candidate.hash == "" {
+			continue
+		}
+		if first, ok := byHash[candidate.hash]; ok {
+			union(first, candidate, DuplicateAudio)
+		} else {
+			byHash[candidate.hash] = candidate
+		}
+	}
+
+	byDuration := make([]*DuplicateCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.fingerprint != nil {
+			byDuration = append(byDuration, candidate)
+		}
+	}
+	sort.SliceStable(byDuration, func(i, j int) bool {
+		return byDuration[i].Duration < byDuration[j].Duration
+	})
+	for i, candidate := range byDuration {
+		for _, other := range byDuration[i+1:] {
+			if other.Duration-candidate.Duration > tolerance {
+				break
+			}
+			if find(candidate) != find(other) &&
+				FingerprintSimilarity(candidate.fingerprint, other.fingerprint) >= FingerprintThreshold {
+				union(candidate, other, DuplicateSimilar)
+			}
+		}
+	}
+
+	members := make(map[*DuplicateCandidate][]*DuplicateCandidate)
+	roots := make([]*DuplicateCandidate, 0)
+	for _, candidate := range candidates {
+		root := find(candidate)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], candidate)
+	}
+	groups := make([]*DuplicateGroup, 0)
+	for _, root := range roots {
+		group := members[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].Bitrate != group[j].Bitrate {
+				return group[i].Bitrate > group[j].Bitrate
+			}
+			if group[i].SampleRate != group[j].SampleRate {
+				return group[i].SampleRate > group[j].SampleRate
+			}
+			return group[i].Path < group[j].Path
+		})
+		why := make([]string, 0, 3)
+		for _, reason := range []string{DuplicateAudio, DuplicateSimilar, DuplicateTags} {
+			if reasons[root][reason] {
+				why = append(why, reason)
+			}
+		}
+		groups = append(groups, &DuplicateGroup{Reasons: why, Candidates: group})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Candidates[0].key < groups[j].Candidates[0].key
+	})
+	return groups
+}
+
+// FindDuplicates returns the groups of Rolas, not hidden, that are taken
+// for copies of the same song: the ones with the same artist and title,
+// regardless of case and punctuation, whose durations differ by at most
+// the tolerance, the ones whose files have the same audio, and the ones
+// whose fingerprints are similar, if they were computed.   The audio of
+// a file is hashed only if another Rola has the same duration, and the
+// hash is kept in the database; the tracks of CUE sheets, which share
+// their files, are never hashed.
+func (database *Database) FindDuplicates(tolerance time.Duration) []*DuplicateGroup {
+	stmtStr := "SELECT rolas.id_rola, rolas.title, performers.name, albums.name, rolas.path, " +
+		"rolas.duration, rolas.bitrate, rolas.sample_rate, rolas.audio_hash, rolas.fingerprint, rolas.container " +
+		"FROM rolas " +
+		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
+		"WHERE rolas.hidden = 0 " +
+		"ORDER BY rolas.id_rola"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	candidates := make([]*DuplicateCandidate, 0)
+	durations := make(map[float64]int)
+	seconds := make(map[*DuplicateCandidate]float64)
+	for rows.Next() {
+		candidate := &DuplicateCandidate{}
+		var duration float64
+		var fingerprint string
+		err := rows.Scan(&candidate.ID, &candidate.Title, &candidate.Artist, &candidate.Album, &candidate.Path,
+			&duration, &candidate.Bitrate, &candidate.SampleRate, &candidate.hash, &fingerprint, &candidate.container)
+		if err != nil {
+			log.Fatal(err)
+		}
+		candidate.fingerprint = decodeFingerprint(fingerprint)
+		candidate.Duration = time.Duration(duration * float64(time.Second))
+		candidate.key = duplicateKey(candidate.Artist, candidate.Title)
+		if candidate.container != "" {
+			candidate.Format = strings.ToUpper(strings.TrimPrefix(filepath.Ext(candidate.container), "."))
+		} else {
+			candidate.Format = strings.ToUpper(strings.TrimPrefix(filepath.Ext(candidate.Path), "."))
+		}
+		candidates = append(candidates, candidate)
+		if duration > 0 && candidate.container == "" {
+			durations[duration]++
+			seconds[candidate] = duration
+		}
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows.Close()
+	stmt.Close()
+	tx.Commit()
+
+	for _, candidate := range candidates {
+		if candidate.hash == "" && durations[seconds[candidate]] > 1 {
+			candidate.hash = database.hashAudio(candidate.ID, candidate.Path)
+		}
+	}
+	groups := groupDuplicates(candidates, tolerance)
+	for _, group := range groups {
+		for _, candidate := range group.Candidates {
+			if info, err := os.Stat(candidate.Path); err == nil && candidate.container == "" {
+				candidate.Size = info.Size()
+			}
+		}
+	}
+	return groups
+}
+
+// hashAudio computes the hash of the audio of the file of a Rola and
+// keeps it in the database; it returns an empty hash if the file can
+// not be read.
+func (database *Database) hashAudio(rolaID int64, path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	hash, err := AudioHash(file)
+	if err != nil {
+		return ""
+	}
+	_, err = database.Database.Exec("UPDATE rolas SET audio_hash = ? WHERE id_rola = ?", hash, rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return hash
+}
+
+// A DuplicateAction is what is done with the copies of a song that are
+// not kept.
+type DuplicateAction int
+
+// The actions on the copies that are not kept: their files are deleted
+// and they are removed from the library, they are hidden, or their files
+// are moved to a directory and they are hidden.
+const (
+	DuplicateDelete DuplicateAction = iota
+	DuplicateHide
+	DuplicateMove
+)
+
+// ResolveDuplicates keeps one Rola of a group of duplicates and deletes,
+// hides or moves the others to the given directory.   The plays of the
+// others are added to the kept Rola, and it takes their place in the
+// playlists, once their files were deleted or moved.   The others are
+// handled one by one, and the first error stops the rest.
+func (database *Database) ResolveDuplicates(keep int64, others []int64, action DuplicateAction, dir string) error {
+	for _, other := range others {
+		if other == keep {
+			continue
+		}
+		path := database.QueryPath(other)
+		if action != DuplicateHide && database.QueryFile(other) != path {
+			return fmt.Errorf("rola %d is a track of a cue sheet, so it can only be hidden", other)
+		}
+
+		switch action {
+		case DuplicateDelete:
+			err := os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		case DuplicateMove:
+			moved, err := moveFile(path, dir)
+			if err != nil {
+				return err
+			}
+			err = database.updatePaths([]*OrganizeMove{{RolaID: other, From: path, To: moved}})
+			if err != nil {
+				renameFile(moved, path)
+				return err
+			}
+		}
+
+		database.mergePlays(keep, other)
+		if action == DuplicateDelete {
+			database.RemoveRola(other)
+		} else {
+			database.SetHidden(other, true)
+		}
+	}
+	return nil
+}
+
+// mergePlays adds the plays of a Rola to another one, which takes its
+// place in the playlists.
+func (database *Database) mergePlays(keep, other int64) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	_, err = tx.Exec("UPDATE playlist_rolas SET id_rola = ? WHERE id_rola = ?", keep, other)
+	if err == nil {
+		_, err = tx.Exec("UPDATE rolas SET "+
+			"play_count = play_count + (SELECT play_count FROM rolas WHERE id_rola = ?), "+
+			"last_played = max(last_played, (SELECT last_played FROM rolas WHERE id_rola = ?)) "+
+			"WHERE id_rola = ?", other, other, keep)
+	}
+	if err == nil {
+		_, err = tx.Exec("UPDATE rolas SET play_count = 0, last_played = '' WHERE id_rola = ?", other)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+}
+
+// RemoveRola removes a Rola from the database, with its credits, its
+// genres and its entries in the playlists; its file is left alone.
+func (database *Database) RemoveRola(rolaID int64) {
+	_, err := database.Database.Exec("DELETE FROM rolas WHERE id_rola = ?", rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SetHidden hides a Rola, so that it is neither listed nor found in the
+// library, or shows it again.
+func (database *Database) SetHidden(rolaID int64, hidden bool) {
+	_, err := database.Database.Exec("UPDATE rolas SET hidden = ? WHERE id_rola = ?", hidden, rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// QueryHidden returns the IDs of the hidden Rolas.
+func (database *Database) QueryHidden() []int64 {
+	return database.QueryCustom("SELECT id_rola FROM rolas WHERE hidden = 1 ORDER BY id_rola")
+}
+
+// moveFile moves a file to a directory, which is created if it does not
+// exist, and returns its new path.   A number is added to the name of
+// the file if the directory has a file with its name.
+func moveFile(path, dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	target := filepath.Join(dir, base+ext)
+	for i := 2; ; i++ {
+		if _, err = os.Stat(target); os.IsNotExist(err) {
+			break
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+	return target, renameFile(path, target)
+}
+
+// renameFile renames a file, or copies it and removes it if it can not
+// be renamed, as across file systems.
+func renameFile(from, to string) error {
+	if os.Rename(from, to) == nil {
+		return nil
+	}
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	dest, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dest, source)
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to)
+		return err
+	}
+	source.Close()
+	return os.Remove(from)
+}
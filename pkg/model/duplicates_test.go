@@ -0,0 +1,134 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDuplicateKey(t *testing.T) {
+	cases := []struct {
+		artist, title string
+		otherArtist   string
+		otherTitle    string
+		expected      bool
+	}{
+		{"The Beatles", "Let It Be", "the beatles", "Let it be!", true},
+		{"AC/DC", "T.N.T.", "ACDC", "TNT", true},
+		{"Café Tacvba", "Eres", "Cafe Tacvba", "Eres", false},
+		{"Queen", "Bohemian Rhapsody", "Queen", "Bohemian Rhapsody (Live)", false},
+	}
+	for _, c := range cases {
+		received := duplicateKey(c.artist, c.title) == duplicateKey(c.otherArtist, c.otherTitle)
+		if received != c.expected {
+			t.Errorf("expecting %v, received %v", c.expected, received)
+		}
+	}
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	candidate := func(id int64, title string, seconds float64, bitrate int, hash string) *DuplicateCandidate {
+		return &DuplicateCandidate{
+			ID:       id,
+			Title:    title,
+			Artist:   "Artist",
+			Path:     title,
+			Duration: time.Duration(seconds * float64(time.Second)),
+			Bitrate:  bitrate,
+			hash:     hash,
+			key:      duplicateKey("Artist", title),
+		}
+	}
+	candidates := []*DuplicateCandidate{
+		candidate(1, "Song", 200, 128, ""),
+		candidate(2, "song", 201.5, 320, ""),
+		candidate(3, "Song", 203, 192, ""),
+		candidate(4, "Song", 240, 256, ""),
+		candidate(5, "Other", 180, 128, "abc"),
+		candidate(6, "Renamed", 180, 256, "abc"),
+		candidate(7, "Alone", 180, 128, "def"),
+		candidate(8, "First", 300, 128, ""),
+		candidate(9, "Second", 300.5, 320, ""),
+	}
+	fingerprint := make([]uint32, 100)
+	for i := range fingerprint {
+		fingerprint[i] = uint32(i) * 2654435761
+	}
+	candidates[7].fingerprint = fingerprint
+	candidates[8].fingerprint = fingerprint
+	groups := groupDuplicates(candidates, 2*time.Second)
+	if len(groups) != 3 {
+		t.Fatalf("expecting %v, received %v", 3, len(groups))
+	}
+
+	ids := func(group *DuplicateGroup) []int64 {
+		ids := make([]int64, 0, len(group.Candidates))
+		for _, candidate := range group.Candidates {
+			ids = append(ids, candidate.ID)
+		}
+		return ids
+	}
+	expected := [][]int64{{6, 5}, {9, 8}, {2, 3, 1}}
+	reasons := []string{DuplicateAudio, DuplicateSimilar, DuplicateTags}
+	for i, group := range groups {
+		received := ids(group)
+		if len(received) != len(expected[i]) {
+			t.Errorf("expecting %v, received %v", expected[i], received)
+			continue
+		}
+		for j := range received {
+			if received[j] != expected[i][j] {
+				t.Errorf("expecting %v, received %v", expected[i], received)
+				break
+			}
+		}
+		if len(group.Reasons) != 1 || group.Reasons[0] != reasons[i] {
+			t.Errorf("expecting %v, received %v", reasons[i], group.Reasons)
+		}
+	}
+}
+
+func TestResolveDuplicatesMove(t *testing.T) {
+	database, miner, clean := newTestLibrary(t, 3)
+	defer clean()
+
+	go miner.Extract()
+	go miner.Populate(database)
+	for range miner.TrackList {
+	}
+	database.AddPlay(2, time.Now())
+	plays := func(id int64) int {
+		var count int
+		err := database.Database.QueryRow("SELECT play_count FROM rolas WHERE id_rola = ?", id).Scan(&count)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return count
+	}
+
+	path := database.QueryPath(2)
+	err := database.ResolveDuplicates(1, []int64{2}, DuplicateMove, filepath.Join(path, "duplicates"))
+	if err == nil {
+		t.Errorf("expecting an error for a directory inside a file")
+	}
+	if plays(1) != 0 || plays(2) != 1 || database.QueryPath(2) != path {
+		t.Errorf("expecting %v, received %v", "no changes", []int{plays(1), plays(2)})
+	}
+
+	dir := filepath.Join(filepath.Dir(path), "duplicates")
+	err = database.ResolveDuplicates(1, []int64{2}, DuplicateMove, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	moved := filepath.Join(dir, filepath.Base(path))
+	if database.QueryPath(2) != moved || len(database.QueryHidden()) != 1 {
+		t.Errorf("expecting %v, received %v", moved, database.QueryPath(2))
+	}
+	if _, err := os.Stat(moved); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if plays(1) != 1 || plays(2) != 0 {
+		t.Errorf("expecting %v, received %v", []int{1, 0}, []int{plays(1), plays(2)})
+	}
+}
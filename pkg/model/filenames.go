@@ -0,0 +1,226 @@
+package model
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The fields of a Rola that can be inferred from its path.
+const (
+	FieldArtist      = "artist"
+	FieldAlbum       = "album"
+	FieldTitle       = "title"
+	FieldTrack       = "track"
+	FieldYear        = "year"
+	FieldDisc        = "disc"
+	FieldGenre       = "genre"
+	FieldAlbumArtist = "albumartist"
+	FieldComposer    = "composer"
+)
+
+// numericFields are the fields whose values are numbers.
+var numericFields = map[string]bool{FieldTrack: true, FieldYear: true, FieldDisc: true}
+
+// filenameField matches a field of a pattern, such as %artist%; %ignore%
+// matches any text that is not kept.
+var filenameField = regexp.MustCompile(`%([a-z]+)%`)
+
+// FilenameRules holds the patterns used to infer the tags of a Rola from
+// its path, as "%artist%/%album% (%year%)/%track% - %title%".   A pattern
+// is matched against as many of the last directories of the path as it
+// has slashes, and the name of the file without its extension; the
+// first pattern that matches is used.   The fields are artist, album,
+// title, track, year, disc, genre, albumartist and composer, and
+// %ignore% matches any text.
+type FilenameRules struct {
+	Patterns []string `json:"patterns"`
+}
+
+// DefaultFilenameRules returns the patterns used when there is no
+// configuration file.
+func DefaultFilenameRules() *FilenameRules {
+	return &FilenameRules{
+		Patterns: []string{
+			"%artist%/%album% (%year%)/%track% - %title%",
+			"%artist%/%album%/%track% - %title%",
+			"%artist%/%album% (%year%)/%track% %title%",
+			"%artist%/%album%/%track% %title%",
+			"%track% - %artist% - %title%",
+			"%artist% - %title%",
+			"%track% - %title%",
+			"%artist%/%album%/%title%",
+		},
+	}
+}
+
+var (
+	instanceF *FilenameRules
+	onceF     sync.Once
+)
+
+// GetFilenameRules returns the singleton instance of FilenameRules, with
+// the patterns in filenames.json in the configuration directory of rolas
+// (see configFile), if the file exists.
+func GetFilenameRules() *FilenameRules {
+	onceF.Do(func() {
+		instanceF = DefaultFilenameRules()
+		if !configFile("filenames.json", instanceF) {
+			instanceF = DefaultFilenameRules()
+		}
+	})
+	return instanceF
+}
+
+// compilePattern turns a pattern into a regular expression with a group
+// for each field, and returns the fields in the order of the groups.
+// The text fields take as little as they can, and never a slash; the
+// numeric fields take only digits.
+func compilePattern(pattern string) (*regexp.Regexp, []string) {
+	expression := "^"
+	fields := make([]string, 0)
+	last := 0
+	for _, match := range filenameField.FindAllStringSubmatchIndex(pattern, -1) {
+		expression += regexp.QuoteMeta(pattern[last:match[0]])
+		field := pattern[match[2]:match[3]]
+		switch {
+		case field == "ignore":
+			expression += "[^/]*?"
+		case numericFields[field]:
+			expression += `0*(\d+)`
+			fields = append(fields, field)
+		default:
+			expression += "([^/]+?)"
+			fields = append(fields, field)
+		}
+		last = match[1]
+	}
+	expression += regexp.QuoteMeta(pattern[last:]) + "$"
+	return regexp.MustCompile(expression), fields
+}
+
+// Infer returns the fields found in a path by the first pattern that
+// matches it, or nil if none does.   Underscores in the path are taken
+// for spaces, and the values are trimmed.
+func (rules *FilenameRules) Infer(path string) map[string]string {
+	path = filepath.ToSlash(strings.TrimSuffix(path, filepath.Ext(path)))
+	path = strings.Replace(path, "_", " ", -1)
+	parts := strings.Split(path, "/")
+	for _, pattern := range rules.Patterns {
+		depth := strings.Count(pattern, "/") + 1
+		if depth > len(parts) {
+			continue
+		}
+		expression, fields := compilePattern(pattern)
+		match := expression.FindStringSubmatch(strings.Join(parts[len(parts)-depth:], "/"))
+		if match == nil {
+			continue
+		}
+		inferred := make(map[string]string)
+		for i, field := range fields {
+			value := strings.TrimSpace(match[i+1])
+			if previous, ok := inferred[field]; ok && !strings.EqualFold(previous, value) {
+				inferred = nil
+				break
+			}
+			inferred[field] = value
+		}
+		if inferred != nil {
+			return inferred
+		}
+	}
+	return nil
+}
+
+// Fill sets the missing fields of a Rola with the fields inferred from
+// its path, and returns the names of the fields it set.   A field is
+// missing if it still has the value given by NewRola: "Unknown" for the
+// artist, album, title and genre, 0 for the track and the disc, the
+// default year for the year, and empty for the album artist and the
+// composer.
+func (rules *FilenameRules) Fill(rola *Rola) []string {
+	inferred := rules.Infer(rola.Path())
+	defaults := NewRola()
+	filled := make([]string, 0)
+	texts := []struct {
+		field   string
+		current string
+		initial string
+		set     func(string)
+	}{
+		{FieldArtist, rola.Artist(), defaults.Artist(), rola.SetArtist},
+		{FieldAlbum, rola.Album(), defaults.Album(), rola.SetAlbum},
+		{FieldTitle, rola.Title(), defaults.Title(), rola.SetTitle},
+		{FieldGenre, rola.Genre(), defaults.Genre(), rola.SetGenre},
+		{FieldAlbumArtist, rola.AlbumArtist(), defaults.AlbumArtist(), rola.SetAlbumArtist},
+		{FieldComposer, rola.Composer(), defaults.Composer(), rola.SetComposer},
+	}
+	for _, text := range texts {
+		if value := inferred[text.field]; value != "" && text.current == text.initial {
+			text.set(value)
+			filled = append(filled, text.field)
+		}
+	}
+	numbers := []struct {
+		field   string
+		current int
+		initial int
+		set     func(int)
+	}{
+		{FieldTrack, rola.Track(), defaults.Track(), rola.SetTrack},
+		{FieldYear, rola.Year(), defaults.Year(), rola.SetYear},
+		{FieldDisc, rola.Disc(), defaults.Disc(), rola.SetDisc},
+	}
+	for _, number := range numbers {
+		value, err := strconv.Atoi(inferred[number.field])
+		if err == nil && value > 0 && number.current == number.initial {
+			number.set(value)
+			filled = append(filled, number.field)
+		}
+	}
+	return filled
+}
+
+// A TagInference is a Rola with the fields inferred from its path set,
+// and the names of those fields.
+type TagInference struct {
+	Rola   *Rola    `json:"rola"`
+	Fields []string `json:"fields"`
+}
+
+// InferTags infers the missing fields of the Rolas with the given IDs
+// from their paths, without saving them, and returns the Rolas for which
+// some field was inferred; UpdateRola saves them.
+func (database *Database) InferTags(ids []int64, rules *FilenameRules) []*TagInference {
+	inferences := make([]*TagInference, 0)
+	for _, id := range ids {
+		rola := database.QueryRola(id)
+		if fields := rules.Fill(rola); len(fields) > 0 {
+			inferences = append(inferences, &TagInference{Rola: rola, Fields: fields})
+		}
+	}
+	return inferences
+}
+
+// String describes the inferred fields, as "artist: Queen, year: 1975".
+func (inference *TagInference) String() string {
+	rola := inference.Rola
+	values := map[string]string{
+		FieldArtist:      rola.Artist(),
+		FieldAlbum:       rola.Album(),
+		FieldTitle:       rola.Title(),
+		FieldGenre:       rola.Genre(),
+		FieldAlbumArtist: rola.AlbumArtist(),
+		FieldComposer:    rola.Composer(),
+		FieldTrack:       strconv.Itoa(rola.Track()),
+		FieldYear:        strconv.Itoa(rola.Year()),
+		FieldDisc:        strconv.Itoa(rola.Disc()),
+	}
+	descriptions := make([]string, len(inference.Fields))
+	for i, field := range inference.Fields {
+		descriptions[i] = field + ": " + values[field]
+	}
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestFilenameInfer(t *testing.T) {
+	rules := DefaultFilenameRules()
+	cases := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{"/music/Queen/A Night at the Opera (1975)/11 - Bohemian Rhapsody.mp3",
+			map[string]string{"artist": "Queen", "album": "A Night at the Opera", "year": "1975",
+				"track": "11", "title": "Bohemian Rhapsody"}},
+		{"/music/Caifanes/El Diablito/03_Antes_de_que_nos_olviden.flac",
+			map[string]string{"artist": "Caifanes", "album": "El Diablito", "track": "3",
+				"title": "Antes de que nos olviden"}},
+		{"/music/Singles/Camelia Jordana - Non Non Non.mp3",
+			map[string]string{"artist": "Camelia Jordana", "title": "Non Non Non"}},
+		{"song.mp3", nil},
+	}
+	for _, c := range cases {
+		received := rules.Infer(c.path)
+		if len(received) != len(c.expected) {
+			t.Errorf("expecting %v, received %v", c.expected, received)
+			continue
+		}
+		for field, value := range c.expected {
+			if received[field] != value {
+				t.Errorf("expecting %v, received %v", value, received[field])
+			}
+		}
+	}
+
+	custom := &FilenameRules{Patterns: []string{"%genre%/%artist%/%disc%-%track% %ignore% - %title%"}}
+	received := custom.Infer("Rock/Queen/2-05 live - Love of My Life.mp3")
+	if received["disc"] != "2" || received["track"] != "5" || received["title"] != "Love of My Life" ||
+		received["genre"] != "Rock" {
+		t.Errorf("expecting %v, received %v", "Rock, 2, 5 and Love of My Life", received)
+	}
+}
+
+func TestFilenameFill(t *testing.T) {
+	rola := NewRola()
+	rola.SetPath("/music/Queen/A Night at the Opera (1975)/11 - Bohemian Rhapsody.mp3")
+	rola.SetTitle("Bohemian Rhapsody (Remastered)")
+	rola.SetTrack(7)
+	filled := DefaultFilenameRules().Fill(rola)
+
+	expected := []string{"artist", "album", "year"}
+	if len(filled) != len(expected) {
+		t.Fatalf("expecting %v, received %v", expected, filled)
+	}
+	for i := range expected {
+		if filled[i] != expected[i] {
+			t.Errorf("expecting %v, received %v", expected[i], filled[i])
+		}
+	}
+	if rola.Title() != "Bohemian Rhapsody (Remastered)" || rola.Track() != 7 {
+		t.Errorf("expecting %v, received %v", "the tags kept", rola.Title())
+	}
+	if rola.Artist() != "Queen" || rola.Year() != 1975 {
+		t.Errorf("expecting %v, received %v %v", "Queen 1975", rola.Artist(), rola.Year())
+	}
+}
@@ -0,0 +1,354 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"os"
+	"sort"
+	"time"
+)
+
+// The parameters of the fingerprints: the audio is decoded up to
+// fingerprintSeconds, resampled to fingerprintRate, and cut into frames
+// of fingerprintFrame samples, one every fingerprintHop samples.   The
+// chroma of each frame takes the frequencies from fingerprintMinFreq to
+// fingerprintMaxFreq.
+const (
+	fingerprintSeconds = 120
+	fingerprintRate    = 11025
+	fingerprintFrame   = 4096
+	fingerprintHop     = fingerprintFrame / 3
+	fingerprintMinFreq = 28.0
+	fingerprintMaxFreq = 3520.0
+)
+
+// fingerprintMaxOffset is the largest shift, in frames, tried when two
+// fingerprints are aligned; about five seconds.
+const fingerprintMaxOffset = 40
+
+// FingerprintThreshold is the smallest similarity between the
+// fingerprints of two Rolas taken for the same audio.
+const FingerprintThreshold = 0.8
+
+// AudioFingerprint returns the acoustic fingerprint of the first two
+// minutes of the MP3 or FLAC stream in the reader.   It is computed from
+// the decoded audio, in the manner of Chromaprint, so unlike the hash of
+// the stream it is about the same for two encodings of the same audio,
+// in different formats or bitrates.
+func AudioFingerprint(reader io.ReadSeeker) ([]uint32, error) {
+	samples, rate, err := DecodeAudio(reader, fingerprintSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return fingerprintPCM(samples, rate), nil
+}
+
+// fingerprintPCM computes the fingerprint of mono samples: one 32 bit
+// word per frame, computed from the chroma of the frame smoothed with
+// the frames around it.   The first 12 bits tell whether each pitch
+// class is stronger than the next one, the next 12 whether it is
+// stronger than its fourth, and the last 8 are the strongest and the
+// second strongest pitch classes.
+func fingerprintPCM(samples []float64, rate int) []uint32 {
+	samples = resample(samples, rate, fingerprintRate)
+	chroma := make([][12]float64, 0)
+	for start := 0; start+fingerprintFrame <= len(samples); start += fingerprintHop {
+		chroma = append(chroma, frameChroma(samples[start:start+fingerprintFrame]))
+	}
+	if len(chroma) < 3 {
+		return []uint32{}
+	}
+	fingerprint := make([]uint32, 0, len(chroma)-2)
+	for n := 1; n+1 < len(chroma); n++ {
+		var current [12]float64
+		for b := range current {
+			current[b] = chroma[n-1][b] + 2*chroma[n][b] + chroma[n+1][b]
+		}
+		var word uint32
+		for b := 0; b < 12; b++ {
+			if current[b] > current[(b+1)%12] {
+				word |= 1 << uint(b)
+			}
+			if current[b] > current[(b+5)%12] {
+				word |= 1 << uint(12+b)
+			}
+		}
+		first, second := 0, 1
+		if current[second] > current[first] {
+			first, second = second, first
+		}
+		for b := 2; b < 12; b++ {
+			if current[b] > current[first] {
+				first, second = b, first
+			} else if current[b] > current[second] {
+				second = b
+			}
+		}
+		word |= uint32(first)<<24 | uint32(second)<<28
+		fingerprint = append(fingerprint, word)
+	}
+	return fingerprint
+}
+
+// frameChroma returns the energy of each pitch class in a frame, with
+// the frame windowed by a Hann window, normalized to a unit vector.
+func frameChroma(frame []float64) [12]float64 {
+	values := make([]complex128, len(frame))
+	for i, sample := range frame {
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(frame)-1))
+		values[i] = complex(sample*window, 0)
+	}
+	fft(values)
+
+	var chroma [12]float64
+	resolution := float64(fingerprintRate) / float64(len(frame))
+	for k := int(fingerprintMinFreq/resolution) + 1; k < len(values)/2; k++ {
+		freq := float64(k) * resolution
+		if freq > fingerprintMaxFreq {
+			break
+		}
+		note := int(math.Floor(12*math.Log2(freq/440)+69.5)) % 12
+		chroma[note] += math.Pow(cmplx.Abs(values[k]), 2)
+	}
+	norm := 0.0
+	for _, energy := range chroma {
+		norm += energy * energy
+	}
+	norm = math.Sqrt(norm)
+	if norm > 1e-9 {
+		for i := range chroma {
+			chroma[i] /= norm
+		}
+	}
+	return chroma
+}
+
+// fft computes in place the discrete Fourier transform of values, whose
+// length must be a power of two.
+func fft(values []complex128) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := values[start+k], w*values[start+k+size/2]
+				values[start+k] = even + odd
+				values[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// FingerprintSimilarity returns the fraction of equal bits of two
+// fingerprints, shifted one against the other by the offset, up to
+// about five seconds, that makes them most alike; 1 means the same
+// audio, and about 0.5 unrelated audio.
+func FingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for offset := -fingerprintMaxOffset; offset <= fingerprintMaxOffset; offset++ {
+		equal, total := 0, 0
+		for i := range a {
+			j := i + offset
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			equal += 32 - bits.OnesCount32(a[i]^b[j])
+			total += 32
+		}
+		if total >= 32*fingerprintMaxOffset && float64(equal)/float64(total) > best {
+			best = float64(equal) / float64(total)
+		}
+	}
+	return best
+}
+
+// encodeFingerprint encodes a fingerprint to be kept in the database.
+func encodeFingerprint(fingerprint []uint32) string {
+	data := make([]byte, 4*len(fingerprint))
+	for i, word := range fingerprint {
+		binary.LittleEndian.PutUint32(data[4*i:], word)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeFingerprint decodes a fingerprint kept in the database; it
+// returns nil if there is none.
+func decodeFingerprint(text string) []uint32 {
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(data) < 4 {
+		return nil
+	}
+	fingerprint := make([]uint32, len(data)/4)
+	for i := range fingerprint {
+		fingerprint[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return fingerprint
+}
+
+// ComputeFingerprints computes the fingerprints of the Rolas, not hidden
+// nor tracks of CUE sheets, that have none yet, and keeps them in the
+// database; report, if not nil, is called after each Rola with the
+// number of Rolas done and their total.   The files that can not be
+// decoded are logged and skipped.   It returns the number of
+// fingerprints computed.
+func (database *Database) ComputeFingerprints(report func(done, total int)) int {
+	ids := database.QueryCustom("SELECT id_rola FROM rolas " +
+		"WHERE fingerprint = '' AND hidden = 0 AND container = '' ORDER BY id_rola")
+	computed := 0
+	for i, id := range ids {
+		path := database.QueryPath(id)
+		file, err := os.Open(path)
+		if err == nil {
+			var fingerprint []uint32
+			fingerprint, err = AudioFingerprint(file)
+			file.Close()
+			if err == nil && len(fingerprint) > 0 {
+				database.setFingerprint(id, fingerprint)
+				computed++
+			}
+		}
+		if err != nil {
+			log.Println("could not fingerprint "+path+":", err)
+		}
+		if report != nil {
+			report(i+1, len(ids))
+		}
+	}
+	return computed
+}
+
+// setFingerprint keeps the fingerprint of a Rola in the database.
+func (database *Database) setFingerprint(rolaID int64, fingerprint []uint32) {
+	_, err := database.Database.Exec("UPDATE rolas SET fingerprint = ? WHERE id_rola = ?",
+		encodeFingerprint(fingerprint), rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// A fingerprinted is a Rola with its fingerprint, as compared with
+// others.
+type fingerprinted struct {
+	id          int64
+	untagged    bool
+	duration    time.Duration
+	fingerprint []uint32
+}
+
+// queryFingerprinted returns the Rolas, not hidden, that have a
+// fingerprint, sorted by duration; a Rola is untagged if its title or
+// its performer is still "Unknown".
+func (database *Database) queryFingerprinted() []*fingerprinted {
+	stmtStr := "SELECT rolas.id_rola, rolas.title = 'Unknown' OR performers.name = 'Unknown', " +
+		"rolas.duration, rolas.fingerprint " +
+		"FROM rolas " +
+		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+		"WHERE rolas.hidden = 0 AND rolas.fingerprint <> '' " +
+		"ORDER BY rolas.duration"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	rolas := make([]*fingerprinted, 0)
+	for rows.Next() {
+		rola := &fingerprinted{}
+		var duration float64
+		var fingerprint string
+		err := rows.Scan(&rola.id, &rola.untagged, &duration, &fingerprint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rola.duration = time.Duration(duration * float64(time.Second))
+		rola.fingerprint = decodeFingerprint(fingerprint)
+		rolas = append(rolas, rola)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return rolas
+}
+
+// A TagProposal proposes to copy the tags of a Rola to an untagged Rola
+// with the same audio.
+type TagProposal struct {
+	RolaID     int64   `json:"rola_id"`
+	Path       string  `json:"path"`
+	Similarity float64 `json:"similarity"`
+	Tags       *Rola   `json:"tags"`
+}
+
+// ProposeTags matches the fingerprints of the untagged Rolas, whose
+// title or performer is "Unknown", against the fingerprints of the
+// tagged Rolas whose durations differ by at most the tolerance, and
+// proposes to copy the tags of the most similar one, if they are similar
+// enough.   Only the Rolas with a fingerprint are matched.
+func (database *Database) ProposeTags(tolerance time.Duration) []*TagProposal {
+	rolas := database.queryFingerprinted()
+	proposals := make([]*TagProposal, 0)
+	for i, rola := range rolas {
+		if !rola.untagged {
+			continue
+		}
+		var best *fingerprinted
+		bestSimilarity := 0.0
+		first := sort.Search(len(rolas), func(j int) bool {
+			return rolas[j].duration >= rola.duration-tolerance
+		})
+		for j := first; j < len(rolas) && rolas[j].duration <= rola.duration+tolerance; j++ {
+			if j == i || rolas[j].untagged {
+				continue
+			}
+			similarity := FingerprintSimilarity(rola.fingerprint, rolas[j].fingerprint)
+			if similarity >= FingerprintThreshold && similarity > bestSimilarity {
+				best, bestSimilarity = rolas[j], similarity
+			}
+		}
+		if best != nil {
+			proposals = append(proposals, &TagProposal{
+				RolaID:     rola.id,
+				Path:       database.QueryPath(rola.id),
+				Similarity: bestSimilarity,
+				Tags:       database.QueryRola(best.id),
+			})
+		}
+	}
+	return proposals
+}
+
+// CopyTags copies the tags of a Rola to another one, in the database:
+// its title, performer, album, track, year, genres, disc, album artist
+// and composer.
+func (database *Database) CopyTags(from, to int64) {
+	source := database.QueryRola(from)
+	rola := database.QueryRola(to)
+	rola.SetTitle(source.Title())
+	rola.SetArtist(source.Artist())
+	rola.SetAlbum(source.Album())
+	rola.SetTrack(source.Track())
+	rola.SetYear(source.Year())
+	rola.SetGenre(source.Genre())
+	rola.SetDisc(source.Disc())
+	rola.SetAlbumArtist(source.AlbumArtist())
+	rola.SetComposer(source.Composer())
+	database.UpdateRola(rola)
+}
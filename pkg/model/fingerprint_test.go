@@ -0,0 +1,53 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// melody synthesizes a sequence of notes, given as MIDI numbers, each
+// lasting half a second, with their fifths.
+func melody(notes []int, rate int, gain float64, noise float64, seed int64) []float64 {
+	random := rand.New(rand.NewSource(seed))
+	samples := make([]float64, 0, len(notes)*rate/2)
+	for i := 0; i < len(notes)*rate/2; i++ {
+		note := notes[i/(rate/2)]
+		t := float64(i) / float64(rate)
+		freq := 440 * math.Pow(2, float64(note-69)/12)
+		sample := math.Sin(2*math.Pi*freq*t) + 0.5*math.Sin(2*math.Pi*freq*1.5*t)
+		samples = append(samples, gain*sample/1.5+noise*(2*random.Float64()-1))
+	}
+	return samples
+}
+
+func TestFingerprintSimilarity(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	notes := make([]int, 60)
+	others := make([]int, 60)
+	for i := range notes {
+		notes[i] = 48 + random.Intn(24)
+		others[i] = 48 + random.Intn(24)
+	}
+	original := fingerprintPCM(melody(notes, 44100, 0.8, 0, 1), 44100)
+	encoded := fingerprintPCM(melody(notes, 22050, 0.5, 0.05, 2)[1000:], 22050)
+	different := fingerprintPCM(melody(others, 44100, 0.8, 0, 3), 44100)
+
+	if similarity := FingerprintSimilarity(original, encoded); similarity < FingerprintThreshold {
+		t.Errorf("expecting at least %v, received %v", FingerprintThreshold, similarity)
+	}
+	if similarity := FingerprintSimilarity(original, different); similarity >= FingerprintThreshold {
+		t.Errorf("expecting less than %v, received %v", FingerprintThreshold, similarity)
+	}
+
+	decoded := decodeFingerprint(encodeFingerprint(original))
+	if len(decoded) != len(original) {
+		t.Fatalf("expecting %v, received %v", len(original), len(decoded))
+	}
+	for i := range decoded {
+		if decoded[i] != original[i] {
+			t.Errorf("expecting %v, received %v", original[i], decoded[i])
+			break
+		}
+	}
+}
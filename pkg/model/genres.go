@@ -1,18 +1,35 @@
 package model
 
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 // Genre is a simple translator for ID3v1 genre codes, it contains
-// a single dictionary with the codes as keys and the corresponding
-// genres as values (both are strings).  It is a singleton.
+// a dictionary with the codes, from 0 to 191 with the extensions of
+// Winamp, as keys and the corresponding genres as values (both are
+// strings), and another one with the genres in lowercase as keys, to
+// write them as in the list.  It is a singleton.
 type Genre struct {
 	genres map[string]string
+	names  map[string]string
 }
 
-var instance *Genre
+// GenreSeparator separates the genres of a Rola, which may have several.
+const GenreSeparator = "; "
+
+var (
+	instance *Genre
+	once     sync.Once
+)
 
-// GetGenre returns the singleton instance of Genre. The implementation
-// of the singleton is not thread-safe due to conflicts with gtk.
+// GetGenre returns the singleton instance of Genre, safe for concurrent
+// use.
 func GetGenre() *Genre {
-	if instance == nil {
+	once.Do(func() {
 		genres := make(map[string]string)
 		genres["0"] = "Blues"
 		genres["1"] = "Classic Rock"
@@ -94,17 +111,281 @@ func GetGenre() *Genre {
 		genres["77"] = "Musical"
 		genres["78"] = "Rock & Roll"
 		genres["79"] = "Hard Rock"
-		instance = &Genre{genres}
-	}
+		genres["80"] = "Folk"
+		genres["81"] = "Folk-Rock"
+		genres["82"] = "National Folk"
+		genres["83"] = "Swing"
+		genres["84"] = "Fast Fusion"
+		genres["85"] = "Bebop"
+		genres["86"] = "Latin"
+		genres["87"] = "Revival"
+		genres["88"] = "Celtic"
+		genres["89"] = "Bluegrass"
+		genres["90"] = "Avantgarde"
+		genres["91"] = "Gothic Rock"
+		genres["92"] = "Progressive Rock"
+		genres["93"] = "Psychedelic Rock"
+		genres["94"] = "Symphonic Rock"
+		genres["95"] = "Slow Rock"
+		genres["96"] = "Big Band"
+		genres["97"] = "Chorus"
+		genres["98"] = "Easy Listening"
+		genres["99"] = "Acoustic"
+		genres["100"] = "Humour"
+		genres["101"] = "Speech"
+		genres["102"] = "Chanson"
+		genres["103"] = "Opera"
+		genres["104"] = "Chamber Music"
+		genres["105"] = "Sonata"
+		genres["106"] = "Symphony"
+		genres["107"] = "Booty Bass"
+		genres["108"] = "Primus"
+		genres["109"] = "Porn Groove"
+		genres["110"] = "Satire"
+		genres["111"] = "Slow Jam"
+		genres["112"] = "Club"
+		genres["113"] = "Tango"
+		genres["114"] = "Samba"
+		genres["115"] = "Folklore"
+		genres["116"] = "Ballad"
+		genres["117"] = "Power Ballad"
+		genres["118"] = "Rhythmic Soul"
+		genres["119"] = "Freestyle"
+		genres["120"] = "Duet"
+		genres["121"] = "Punk Rock"
+		genres["122"] = "Drum Solo"
+		genres["123"] = "A Cappella"
+		genres["124"] = "Euro-House"
+		genres["125"] = "Dance Hall"
+		genres["126"] = "Goa"
+		genres["127"] = "Drum & Bass"
+		genres["128"] = "Club-House"
+		genres["129"] = "Hardcore Techno"
+		genres["130"] = "Terror"
+		genres["131"] = "Indie"
+		genres["132"] = "BritPop"
+		genres["133"] = "Afro-Punk"
+		genres["134"] = "Polsk Punk"
+		genres["135"] = "Beat"
+		genres["136"] = "Christian Gangsta Rap"
+		genres["137"] = "Heavy Metal"
+		genres["138"] = "Black Metal"
+		genres["139"] = "Crossover"
+		genres["140"] = "Contemporary Christian"
+		genres["141"] = "Christian Rock"
+		genres["142"] = "Merengue"
+		genres["143"] = "Salsa"
+		genres["144"] = "Thrash Metal"
+		genres["145"] = "Anime"
+		genres["146"] = "JPop"
+		genres["147"] = "Synthpop"
+		genres["148"] = "Abstract"
+		genres["149"] = "Art Rock"
+		genres["150"] = "Baroque"
+		genres["151"] = "Bhangra"
+		genres["152"] = "Big Beat"
+		genres["153"] = "Breakbeat"
+		genres["154"] = "Chillout"
+		genres["155"] = "Downtempo"
+		genres["156"] = "Dub"
+		genres["157"] = "EBM"
+		genres["158"] = "Eclectic"
+		genres["159"] = "Electro"
+		genres["160"] = "Electroclash"
+		genres["161"] = "Emo"
+		genres["162"] = "Experimental"
+		genres["163"] = "Garage"
+		genres["164"] = "Global"
+		genres["165"] = "IDM"
+		genres["166"] = "Illbient"
+		genres["167"] = "Industro-Goth"
+		genres["168"] = "Jam Band"
+		genres["169"] = "Krautrock"
+		genres["170"] = "Leftfield"
+		genres["171"] = "Lounge"
+		genres["172"] = "Math Rock"
+		genres["173"] = "New Romantic"
+		genres["174"] = "Nu-Breakz"
+		genres["175"] = "Post-Punk"
+		genres["176"] = "Post-Rock"
+		genres["177"] = "Psytrance"
+		genres["178"] = "Shoegaze"
+		genres["179"] = "Space Rock"
+		genres["180"] = "Trop Rock"
+		genres["181"] = "World Music"
+		genres["182"] = "Neoclassical"
+		genres["183"] = "Audiobook"
+		genres["184"] = "Audio Theatre"
+		genres["185"] = "Neue Deutsche Welle"
+		genres["186"] = "Podcast"
+		genres["187"] = "Indie Rock"
+		genres["188"] = "G-Funk"
+		genres["189"] = "Dubstep"
+		genres["190"] = "Garage Rock"
+		genres["191"] = "Psybient"
+		names := make(map[string]string)
+		for _, name := range genres {
+			names[strings.ToLower(name)] = name
+		}
+		instance = &Genre{genres, names}
+	})
 	return instance
 }
 
-// Get receives a string corresponding to an ID3v1 genre code
-// and returns the corresponding genre.
+// Get receives the genre of a tag and returns the genres it stands
+// for, as Normalize, separated by the GenreSeparator.
 func (genre *Genre) Get(code string) string {
-	name := genre.genres[code]
-	if name == "" {
-		return code
+	return strings.Join(genre.Normalize(code), GenreSeparator)
+}
+
+// Normalize decodes the genre of an ID3v1, ID3v2.3 or ID3v2.4 tag and
+// returns the genres it stands for, without repetitions: the ID3v1 codes,
+// bare as "17" or in parentheses as "(17)" or "(4)(9)", are replaced by
+// their genres, "RX" and "CR" by "Remix" and "Cover", and the text after
+// the codes, which refines them as in "(4)Eurodisco", is a genre as
+// well; "((" stands for a literal parenthesis.   The values of an ID3v2.4
+// tag are separated by null characters, and the genres of a Rola by
+// semicolons.   A genre of the list is written as in the list.
+func (genre *Genre) Normalize(text string) []string {
+	genres := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if canonical, ok := genre.names[strings.ToLower(name)]; ok {
+			name = canonical
+		}
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			genres = append(genres, name)
+		}
+	}
+	for _, value := range strings.FieldsFunc(text, func(r rune) bool { return r == 0 || r == ';' }) {
+		value = strings.TrimSpace(value)
+		for strings.HasPrefix(value, "(") && !strings.HasPrefix(value, "((") {
+			end := strings.Index(value, ")")
+			if end < 0 {
+				break
+			}
+			add(genre.decode(value[1:end]))
+			value = value[end+1:]
+		}
+		if strings.HasPrefix(value, "((") {
+			value = value[1:]
+		}
+		add(genre.decode(value))
+	}
+	return genres
+}
+
+// decode returns the genre of an ID3v1 code, or of "RX" or "CR", or the
+// given text if it is not a code.
+func (genre *Genre) decode(code string) string {
+	code = strings.TrimSpace(code)
+	switch code {
+	case "RX":
+		return "Remix"
+	case "CR":
+		return "Cover"
+	}
+	if n, err := strconv.Atoi(code); err == nil {
+		if name, ok := genre.genres[strconv.Itoa(n)]; ok {
+			return name
+		}
+	}
+	return code
+}
+
+// SplitGenres returns the genres of a Rola, separated by semicolons in
+// its genre, normalized.
+func SplitGenres(text string) []string {
+	return GetGenre().Normalize(text)
+}
+
+// genresCondition returns the condition that a Rola has a genre that
+// satisfies the given condition, such as "LIKE ?", in its genre or in
+// rola_genres.   The condition appears twice, so its values must be
+// bound twice.
+func genresCondition(condition string) string {
+	return "(rolas.genre " + condition + " OR EXISTS (" +
+		"SELECT 1 FROM rola_genres " +
+		"WHERE rola_genres.id_rola = rolas.id_rola " +
+		"AND rola_genres.genre " + condition + "))"
+}
+
+// SetGenres replaces the genres of a Rola in rola_genres.
+func (database *Database) SetGenres(rolaID int64, genres []string) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	setGenres(tx, rolaID, genres)
+	tx.Commit()
+}
+
+// setGenres replaces the genres of a Rola in rola_genres within a
+// transaction, adding the genres missing from the genres table.
+func setGenres(tx *sql.Tx, rolaID int64, genres []string) {
+	_, err := tx.Exec("DELETE FROM rola_genres WHERE id_rola = ?", rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for position, genre := range genres {
+		_, err = tx.Exec("INSERT OR IGNORE INTO genres (name) VALUES (?)", genre)
+		if err == nil {
+			_, err = tx.Exec("INSERT OR IGNORE INTO rola_genres (id_rola, genre, position) VALUES (?, ?, ?)",
+				rolaID, genre, position)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// QueryGenres returns the genres of a Rola, in order.
+func (database *Database) QueryGenres(rolaID int64) []string {
+	stmtStr := "SELECT genre FROM rola_genres WHERE id_rola = ? ORDER BY position"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, rolaID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	genres := make([]string, 0)
+	for rows.Next() {
+		var genre string
+		err := rows.Scan(&genre)
+		if err != nil {
+			log.Fatal(err)
+		}
+		genres = append(genres, genre)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return genres
+}
+
+// NormalizeGenres normalizes the genre of every Rola of the database,
+// as the tags read before the genres were normalized, resolves it with
+// the aliases and the canonical names of the genres, and returns the
+// number of Rolas whose genres changed.
+func (database *Database) NormalizeGenres() int {
+	changed := 0
+	index := database.GenreIndex()
+	for _, rolaID := range database.QueryCustom("SELECT id_rola FROM rolas ORDER BY id_rola") {
+		rola := database.QueryRola(rolaID)
+		genres := index.Resolve(SplitGenres(rola.Genre()))
+		normalized := strings.Join(genres, GenreSeparator)
+		if normalized == rola.Genre() && strings.Join(database.QueryGenres(rolaID), GenreSeparator) == normalized {
+			continue
+		}
+		_, err := database.Database.Exec("UPDATE rolas SET genre = ? WHERE id_rola = ?", normalized, rolaID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		database.SetGenres(rolaID, genres)
+		changed++
 	}
-	return name
+	return changed
 }
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenreNormalize(t *testing.T) {
+	genre := GetGenre()
+	cases := map[string]string{
+		"17":                  "Rock",
+		"(17)":                "Rock",
+		"(17)Rock":            "Rock",
+		"(4)(9)":              "Disco; Metal",
+		"(4)Eurodisco":        "Disco; Eurodisco",
+		"RX":                  "Remix",
+		"(CR)":                "Cover",
+		"((Punk) Rock":        "(Punk) Rock",
+		"(191)":               "Psybient",
+		"(192)":               "192",
+		"Rock\x00Pop":         "Rock; Pop",
+		"jazz; Jazz ;; blues": "Jazz; Blues",
+		"Synthwave":           "Synthwave",
+		"":                    "",
+	}
+	for text, expecting := range cases {
+		received := strings.Join(genre.Normalize(text), GenreSeparator)
+		if received != expecting {
+			t.Errorf("expecting %v, received %v", expecting, received)
+		}
+	}
+	if genre.Get("(13)") != "Pop" {
+		t.Errorf("expecting %v, received %v", "Pop", genre.Get("(13)"))
+	}
+}
@@ -0,0 +1,279 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// A GenreRecord is a row of the genres table: a canonical genre, and the
+// genre it is a subgenre of, or 0 if it has none.
+type GenreRecord struct {
+	ID       int64  `json:"id" db:"id_genre"`
+	Name     string `json:"name" db:"name"`
+	ParentID int64  `json:"parent_id" db:"id_parent"`
+}
+
+// A GenreAlias is a row of the genre_aliases table: a name that stands
+// for a canonical genre.   An alias may stand for several genres, as
+// "Rap/Hip-Hop" for "Rap" and "Hip-Hop".
+type GenreAlias struct {
+	Alias   string `json:"alias" db:"alias"`
+	GenreID int64  `json:"genre_id" db:"id_genre"`
+}
+
+// genreKey returns the key under which a genre is looked up: the genre
+// in lowercase, without blanks, hyphens or underscores, so that
+// "Hip Hop", "Hip-Hop" and "hiphop" are the same genre.
+func genreKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// A GenreIndex maps the keys of the genres of the database to their
+// canonical names, and the keys of the aliases to the genres they stand
+// for.
+type GenreIndex struct {
+	names   map[string]string
+	aliases map[string][]string
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// GenreIndex reads the genres and the aliases of the database into a
+// GenreIndex.
+func (database *Database) GenreIndex() *GenreIndex {
+	return loadGenreIndex(database.Database)
+}
+
+// loadGenreIndex reads a GenreIndex, within a transaction or not.
+func loadGenreIndex(db queryer) *GenreIndex {
+	index := &GenreIndex{
+		names:   make(map[string]string),
+		aliases: make(map[string][]string),
+	}
+	rows, err := db.Query("SELECT name FROM genres ORDER BY id_genre")
+	if err != nil {
+		log.Fatal("could not execute query: ", err)
+	}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, ok := index.names[genreKey(name)]; !ok {
+			index.names[genreKey(name)] = name
+		}
+	}
+	rows.Close()
+
+	rows, err = db.Query("SELECT genre_aliases.alias, genres.name FROM genre_aliases " +
+		"INNER JOIN genres ON genres.id_genre = genre_aliases.id_genre " +
+		"ORDER BY genre_aliases.rowid")
+	if err != nil {
+		log.Fatal("could not execute query: ", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, name string
+		err = rows.Scan(&alias, &name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		index.aliases[genreKey(alias)] = append(index.aliases[genreKey(alias)], name)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return index
+}
+
+// Resolve replaces each genre by the genres its alias stands for, or by
+// its canonical name, without repetitions.   The genres not in the index
+// are kept as they are, and added to the index, so that the next ones
+// with the same key are written alike.
+func (index *GenreIndex) Resolve(genres []string) []string {
+	resolved := make([]string, 0, len(genres))
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[genreKey(name)] {
+			seen[genreKey(name)] = true
+			resolved = append(resolved, name)
+		}
+	}
+	for _, genre := range genres {
+		key := genreKey(genre)
+		if targets, ok := index.aliases[key]; ok {
+			for _, target := range targets {
+				add(target)
+			}
+			continue
+		}
+		if name, ok := index.names[key]; ok {
+			add(name)
+			continue
+		}
+		index.names[key] = genre
+		add(genre)
+	}
+	return resolved
+}
+
+// ResolveGenres returns the genres of a Rola, separated by semicolons in
+// its genre, normalized and resolved with the aliases and the canonical
+// names of the database.
+func (database *Database) ResolveGenres(text string) []string {
+	return database.GenreIndex().Resolve(SplitGenres(text))
+}
+
+// AddGenre adds a canonical genre to the database, if it is not in it
+// already, and returns its ID.
+func (database *Database) AddGenre(name string) int64 {
+	return database.insertOrSelect("INSERT OR IGNORE INTO genres (name) VALUES (?)",
+		[]interface{}{name}, "SELECT id_genre FROM genres WHERE name = ?", name)
+}
+
+// genreID returns the ID of a canonical genre, or 0 if it is not in the
+// database.
+func (database *Database) genreID(name string) int64 {
+	var id int64
+	err := database.Database.QueryRow("SELECT id_genre FROM genres WHERE name = ?", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0
+	}
+	if err != nil {
+		log.Fatal("could not execute query: ", err)
+	}
+	return id
+}
+
+// SetGenreAlias makes an alias stand for the given genres, which are
+// added to the database if they are not in it; the genres the alias
+// stood for before are replaced.   If the alias was a genre of its own,
+// it stops being one, and its subgenres become subgenres of the first
+// of the given genres; so an alias of "Hip Hop" for "Hip-Hop" renames
+// the genre.   The Rolas keep their genres until they are
+// normalized again.
+func (database *Database) SetGenreAlias(alias string, genres []string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" || len(genres) == 0 {
+		return errors.New("an alias needs a name and at least one genre")
+	}
+	ids := make([]int64, 0, len(genres))
+	for _, genre := range genres {
+		if strings.EqualFold(strings.TrimSpace(genre), alias) {
+			return fmt.Errorf("%q can not be an alias of itself", alias)
+		}
+		ids = append(ids, database.AddGenre(strings.TrimSpace(genre)))
+	}
+
+	former := database.genreID(alias)
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	if former != 0 {
+		_, err = tx.Exec("UPDATE genres SET id_parent = ? WHERE id_parent = ?", ids[0], former)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM genres WHERE id_genre = ?", former)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	_, err = tx.Exec("DELETE FROM genre_aliases WHERE alias = ?", alias)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, id := range ids {
+		_, err = tx.Exec("INSERT OR IGNORE INTO genre_aliases (alias, id_genre) VALUES (?, ?)", alias, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+// RemoveGenreAlias removes an alias.   Nothing happens if there is no
+// such alias.
+func (database *Database) RemoveGenreAlias(alias string) {
+	_, err := database.Database.Exec("DELETE FROM genre_aliases WHERE alias = ?", strings.TrimSpace(alias))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SetGenreParent makes a genre a subgenre of another, as "Death Metal"
+// of "Metal"; both are added to the database if they are not in it.   An
+// empty parent makes the genre a top genre.   A genre can not be a
+// subgenre of itself or of one of its subgenres.
+func (database *Database) SetGenreParent(genre, parent string) error {
+	id := database.AddGenre(strings.TrimSpace(genre))
+	var parentID interface{}
+	if strings.TrimSpace(parent) != "" {
+		ancestor := database.AddGenre(strings.TrimSpace(parent))
+		parentID = ancestor
+		for ancestor != 0 {
+			if ancestor == id {
+				return fmt.Errorf("%q can not be a subgenre of %q, which is one of its subgenres", genre, parent)
+			}
+			var next sql.NullInt64
+			err := database.Database.QueryRow("SELECT id_parent FROM genres WHERE id_genre = ?", ancestor).Scan(&next)
+			if err != nil {
+				log.Fatal("could not execute query: ", err)
+			}
+			ancestor = next.Int64
+		}
+	}
+	_, err := database.Database.Exec("UPDATE genres SET id_parent = ? WHERE id_genre = ?", parentID, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return nil
+}
+
+// QueryGenreTree returns the genres of the database, sorted by name, and
+// the aliases of each genre.
+func (database *Database) QueryGenreTree() ([]*GenreRecord, map[int64][]string) {
+	genres := make([]*GenreRecord, 0)
+	database.selectAll("genres", &genres)
+	sort.Slice(genres, func(i, j int) bool {
+		return strings.ToLower(genres[i].Name) < strings.ToLower(genres[j].Name)
+	})
+
+	records := make([]*GenreAlias, 0)
+	database.selectAll("genre_aliases", &records)
+	aliases := make(map[int64][]string)
+	for _, record := range records {
+		aliases[record.GenreID] = append(aliases[record.GenreID], record.Alias)
+	}
+	return genres, aliases
+}
+
+// subgenresCondition returns the condition that a Rola has a genre that
+// satisfies the given condition, such as "= ?", or a subgenre of one,
+// at any depth.   The values of the condition are bound once.
+func subgenresCondition(condition string) string {
+	return "EXISTS (" +
+		"WITH RECURSIVE tree (id_genre) AS (" +
+		"SELECT id_genre FROM genres WHERE name " + condition + " " +
+		"UNION SELECT genres.id_genre FROM genres INNER JOIN tree ON genres.id_parent = tree.id_genre) " +
+		"SELECT 1 FROM rola_genres " +
+		"INNER JOIN genres ON genres.name = rola_genres.genre " +
+		"WHERE rola_genres.id_rola = rolas.id_rola " +
+		"AND genres.id_genre IN tree)"
+}
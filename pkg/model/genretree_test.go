@@ -0,0 +1,30 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenreIndexResolve(t *testing.T) {
+	index := &GenreIndex{
+		names:   map[string]string{"hiphop": "Hip-Hop", "rock": "Rock"},
+		aliases: map[string][]string{"rap/hiphop": {"Rap", "Hip-Hop"}, "rocknroll": {"Rock & Roll"}},
+	}
+	cases := []struct {
+		genres    []string
+		expecting string
+	}{
+		{[]string{"Hip Hop"}, "Hip-Hop"},
+		{[]string{"hiphop", "HIP-HOP"}, "Hip-Hop"},
+		{[]string{"Rap/Hip-Hop"}, "Rap; Hip-Hop"},
+		{[]string{"Rock n Roll", "rock"}, "Rock & Roll; Rock"},
+		{[]string{"Rock-n-Roll"}, "Rock & Roll"},
+		{[]string{"Rock'n'Roll"}, "Rock'n'Roll"},
+	}
+	for _, test := range cases {
+		received := strings.Join(index.Resolve(test.genres), GenreSeparator)
+		if received != test.expecting {
+			t.Errorf("expecting %v, received %v", test.expecting, received)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// id3Padding is the number of bytes of padding left after the frames
+// when an ID3v2 tag is written, so small changes can be done later.
+const id3Padding = 1024
+
+// ErrUnsupportedTag is returned when a tag can not be written back to
+// a file, either because of its format or its version.
+var ErrUnsupportedTag = errors.New("unsupported tag")
+
+// An id3Frame is a frame of an ID3v2 tag, with its identifier, its
+// flags and its raw contents, as they are stored in the file.
+type id3Frame struct {
+	id    string
+	flags [2]byte
+	data  []byte
+}
+
+// An id3Tag holds the frames of the ID3v2 tag at the start of a file,
+// the major version of the tag, and the number of bytes the whole tag
+// takes in the file (0 if the file has no tag).
+type id3Tag struct {
+	version byte
+	frames  []*id3Frame
+	size    int64
+}
+
+// readID3v2 reads the ID3v2 tag at the start of a file.   A file without
+// a tag gives an empty version 2.3 tag.
+func readID3v2(reader io.ReadSeeker) (*id3Tag, error) {
+	_, err := reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 10)
+	_, err = io.ReadFull(reader, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (err == nil && string(header[:3]) != "ID3") {
+		return &id3Tag{version: 3, frames: make([]*id3Frame, 0)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &id3Tag{
+		version: header[3],
+		frames:  make([]*id3Frame, 0),
+		size:    int64(syncsafe(header[6:10])) + 10,
+	}
+	flags := header[5]
+	if flags&0x10 != 0 {
+		tag.size += 10
+	}
+	body := make([]byte, syncsafe(header[6:10]))
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return nil, err
+	}
+	if flags&0x80 != 0 && tag.version < 4 {
+		body = deunsynchronise(body)
+	}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		extended := int(syncsafe(body))
+		if tag.version == 3 {
+			extended = 4 + int(binary.BigEndian.Uint32(body))
+		}
+		if extended > len(body) {
+			extended = len(body)
+		}
+		body = body[extended:]
+	}
+
+	for len(body) > 0 && body[0] != 0 {
+		var frame *id3Frame
+		var size int
+		switch tag.version {
+		case 2:
+			if len(body) < 6 {
+				return tag, nil
+			}
+			frame = &id3Frame{id: string(body[:3])}
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+			body = body[6:]
+		case 3, 4:
+			if len(body) < 10 {
+				return tag, nil
+			}
+			frame = &id3Frame{id: string(body[:4])}
+			if tag.version == 4 {
+				size = int(syncsafe(body[4:8]))
+			} else {
+				size = int(binary.BigEndian.Uint32(body[4:8]))
+			}
+			copy(frame.flags[:], body[8:10])
+			body = body[10:]
+		default:
+			return nil, ErrUnsupportedTag
+		}
+		if size > len(body) {
+			return tag, nil
+		}
+		frame.data = body[:size]
+		body = body[size:]
+		tag.frames = append(tag.frames, frame)
+	}
+	return tag, nil
+}
+
+// contents returns the contents of a frame, undoing the compression and
+// unsynchronisation indicated by its flags.
+func (frame *id3Frame) contents(version byte) ([]byte, error) {
+	data := frame.data
+	format := frame.flags[1]
+	compressed := false
+	switch version {
+	case 3:
+		if format&0x40 != 0 {
+			return nil, ErrUnsupportedTag
+		}
+		if format&0x80 != 0 && len(data) >= 4 {
+			data = data[4:]
+			compressed = true
+		}
+		if format&0x20 != 0 && len(data) >= 1 {
+			data = data[1:]
+		}
+	case 4:
+		if format&0x04 != 0 {
+			return nil, ErrUnsupportedTag
+		}
+		if format&0x40 != 0 && len(data) >= 1 {
+			data = data[1:]
+		}
+		if format&0x01 != 0 && len(data) >= 4 {
+			data = data[4:]
+		}
+		if format&0x02 != 0 {
+			data = deunsynchronise(data)
+		}
+		compressed = format&0x08 != 0
+	}
+	if compressed {
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	return data, nil
+}
+
+// writeID3v2 replaces the ID3v2 tag at the start of the file in the
+// given path by the tag taken as argument.   The new file is written
+// next to the original one and then renamed, so the original file is
+// left untouched if anything fails.
+func writeID3v2(path string, tag *id3Tag) error {
+	if tag.version != 3 && tag.version != 4 {
+		return ErrUnsupportedTag
+	}
+	var body bytes.Buffer
+	for _, frame := range tag.frames {
+		header := make([]byte, 10)
+		copy(header, frame.id)
+		if tag.version == 4 {
+			putSyncsafe(header[4:8], uint32(len(frame.data)))
+		} else {
+			binary.BigEndian.PutUint32(header[4:8], uint32(len(frame.data)))
+		}
+		copy(header[8:], frame.flags[:])
+		body.Write(header)
+		body.Write(frame.data)
+	}
+	body.Write(make([]byte, id3Padding))
+
+	header := []byte{'I', 'D', '3', tag.version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(header[6:10], uint32(body.Len()))
+	return replaceHead(path, tag.size, append(header, body.Bytes()...))
+}
+
+// replaceHead replaces the first n bytes of the file in the given path
+// by the head taken as argument, writing a temporary file in the same
+// directory and renaming it over the original.
+func replaceHead(path string, n int64, head []byte) error {
+	original, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer original.Close()
+	info, err := original.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = original.Seek(n, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(path), ".rolas-")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(head)
+	if err == nil {
+		_, err = io.Copy(temp, original)
+	}
+	if err == nil {
+		err = temp.Chmod(info.Mode())
+	}
+	closeErr := temp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// putSyncsafe encodes a 28 bit integer in four bytes with their most
+// significant bit cleared.
+func putSyncsafe(data []byte, n uint32) {
+	data[0] = byte(n >> 21 & 0x7f)
+	data[1] = byte(n >> 14 & 0x7f)
+	data[2] = byte(n >> 7 & 0x7f)
+	data[3] = byte(n & 0x7f)
+}
+
+// deunsynchronise removes the zero bytes inserted after every 0xff byte
+// by the unsynchronisation scheme of ID3v2.
+func deunsynchronise(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		result = append(result, data[i])
+		if data[i] == 0xff && i+1 < len(data) && data[i+1] == 0 {
+			i++
+		}
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package model
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// An Album is an album of the database, with its name, the directory
+// of its files, its year and the hash of its picture in the
+// ArtworkCache.   The db tags of the fields are the columns of the
+// albums table.
+type Album struct {
+	ID      int64  `json:"id" db:"id_album"`
+	Name    string `json:"name" db:"name"`
+	Path    string `json:"path" db:"path"`
+	Year    int    `json:"year" db:"year"`
+	Artwork string `json:"artwork" db:"artwork"`
+}
+
+// A Performer is a performer of the database, with its name, as credited
+// in its Rolas, its type (0 for a person, 1 for a group and 2 if unknown)
+// and the ID of the person or group it is, 0 if it is not linked to one.
+type Performer struct {
+	ID       int64  `json:"id" db:"id_performer"`
+	Name     string `json:"name" db:"name"`
+	Type     int    `json:"type" db:"id_type"`
+	PersonID int64  `json:"person_id" db:"id_person"`
+	GroupID  int64  `json:"group_id" db:"id_group"`
+}
+
+// A Credit is the part of a performer in an album: the number of Rolas
+// of the album the performer performs and their duration.
+type Credit struct {
+	AlbumID     int64
+	PerformerID int64
+	Rolas       int
+	Duration    time.Duration
+}
+
+// ListOptions are the options to list the Rolas of the database with
+// ListRolas.   The text fields filter the Rolas containing the text
+// (case insensitive), a Year of 0 does not filter by year, and a nil
+// IDs slice does not restrict the Rolas to the ones with the given
+// IDs.   Sort is one of the keys of SortColumns, and the Rolas are
+// sorted by ID if it is empty.   A Limit of 0 returns all the Rolas
+// from Offset on.
+type ListOptions struct {
+	IDs        []int64
+	Title      string
+	Artist     string
+	Album      string
+	Genre      string
+	Year       int
+	Sort       string
+	Descending bool
+	Offset     int
+	Limit      int
+}
+
+// SortColumns maps the fields the Rolas can be sorted by to the columns
+// of the database.
+var SortColumns = map[string]string{
+	"id":       "rolas.id_rola",
+	"title":    "rolas.title COLLATE NOCASE",
+	"artist":   "performers.name COLLATE NOCASE",
+	"album":    "albums.name COLLATE NOCASE",
+	"genre":    "rolas.genre COLLATE NOCASE",
+	"track":    "rolas.track",
+	"year":     "rolas.year",
+	"disc":     "rolas.disc",
+	"composer": "rolas.composer COLLATE NOCASE",
+	"bpm":      "rolas.bpm",
+	"duration": "rolas.duration",
+	"bitrate":  "rolas.bitrate",
+}
+
+// AllAlbums returns all the albums of the database, sorted by name.
+func (database *Database) AllAlbums() []*Album {
+	result := make([]*Album, 0)
+	stmtStr := "SELECT id_album, name, path, year, artwork FROM albums ORDER BY name COLLATE NOCASE"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	for rows.Next() {
+		album := &Album{}
+		var name, path, artwork sql.NullString
+		var year sql.NullInt64
+		err := rows.Scan(&album.ID, &name, &path, &year, &artwork)
+		if err != nil {
+			log.Fatal(err)
+		}
+		album.Name, album.Path, album.Year, album.Artwork = name.String, path.String, int(year.Int64), artwork.String
+		result = append(result, album)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return result
+}
+
+// AllPerformers returns all the performers of the database, sorted by
+// name.
+func (database *Database) AllPerformers() []*Performer {
+	result := make([]*Performer, 0)
+	stmtStr := "SELECT id_performer, name, id_type, id_person, id_group FROM performers ORDER BY name COLLATE NOCASE"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	for rows.Next() {
+		performer := &Performer{}
+		var name sql.NullString
+		var ptype, person, group sql.NullInt64
+		err := rows.Scan(&performer.ID, &name, &ptype, &person, &group)
+		if err != nil {
+			log.Fatal(err)
+		}
+		performer.Name, performer.Type = name.String, int(ptype.Int64)
+		performer.PersonID, performer.GroupID = person.Int64, group.Int64
+		result = append(result, performer)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return result
+}
+
+// AllCredits returns the credits of all the performers in all the
+// albums of the database; a Rola counts for its main performer and for
+// every performer credited in it, except as composer.   The hidden Rolas
+// do not count.
+func (database *Database) AllCredits() []*Credit {
+	result := make([]*Credit, 0)
+	stmtStr := "SELECT id_album, id_performer, COUNT(*), TOTAL(duration) " +
+		"FROM (" +
+		" SELECT id_rola, id_album, id_performer, duration FROM rolas WHERE hidden = 0 " +
+		" UNION " +
+		" SELECT rolas.id_rola, rolas.id_album, rola_performers.id_performer, rolas.duration " +
+		" FROM rola_performers " +
+		" INNER JOIN rolas ON rolas.id_rola = rola_performers.id_rola " +
+		" WHERE rola_performers.credit <> '" + CreditComposer + "' AND rolas.hidden = 0) " +
+		"GROUP BY id_album, id_performer"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	for rows.Next() {
+		credit := &Credit{}
+		var seconds float64
+		err := rows.Scan(&credit.AlbumID, &credit.PerformerID, &credit.Rolas, &seconds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		credit.Duration = time.Duration(seconds * float64(time.Second))
+		result = append(result, credit)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return result
+}
+
+// ListRolas returns the IDs of the Rolas that are not hidden and pass
+// the filters of the options, sorted and paginated as the options say,
+// together with the number of Rolas that pass the filters before the
+// pagination.
+func (database *Database) ListRolas(options *ListOptions) ([]int64, int) {
+	stmtStr := "SELECT rolas.id_rola " +
+		"FROM rolas " +
+		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
+		"WHERE rolas.hidden = 0"
+	args := make([]interface{}, 0)
+	filters := []struct {
+		condition string
+		text      string
+	}{
+		{"rolas.title LIKE ?", options.Title},
+		{performsCondition("LIKE ?"), options.Artist},
+		{"albums.name LIKE ?", options.Album},
+		{genresCondition("LIKE ?"), options.Genre},
+	}
+	for _, filter := range filters {
+		if filter.text != "" {
+			stmtStr += " AND " + filter.condition
+			for i := strings.Count(filter.condition, "?"); i > 0; i-- {
+				args = append(args, "%"+strings.TrimSpace(filter.text)+"%")
+			}
+		}
+	}
+	if options.Year != 0 {
+		stmtStr += " AND rolas.year = ?"
+		args = append(args, options.Year)
+	}
+	column, ok := SortColumns[options.Sort]
+	if !ok {
+		column = SortColumns["id"]
+	}
+	direction := " ASC"
+	if options.Descending {
+		direction = " DESC"
+	}
+	stmtStr += " ORDER BY " + column + direction + ", rolas.id_rola" + direction
+
+	var allowed map[int64]bool
+	if options.IDs != nil {
+		allowed = make(map[int64]bool)
+		for _, id := range options.IDs {
+			allowed[id] = true
+		}
+	}
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, args...)
+	defer stmt.Close()
+	defer rows.Close()
+
+	result := make([]int64, 0)
+	total := 0
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if allowed != nil && !allowed[id] {
+			continue
+		}
+		if total >= options.Offset && (options.Limit == 0 || len(result) < options.Limit) {
+			result = append(result, id)
+		}
+		total++
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return result, total
+}
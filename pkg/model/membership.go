@@ -0,0 +1,236 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// A Member is a Membership together with the stage name of the person
+// and the name of the group.
+type Member struct {
+	Membership
+	StageName string
+	GroupName string
+}
+
+// Period returns the period of a membership, as "1975–1978", "1975–"
+// for a member who has not left, or "" if neither date is known.
+func (membership *Membership) Period() string {
+	if membership.Joined == "" && membership.Left == "" {
+		return ""
+	}
+	return membership.Joined + "–" + membership.Left
+}
+
+// NormalizeRoles returns the roles of a member, separated by commas,
+// without blanks around them and without repetitions.
+func NormalizeRoles(text string) string {
+	roles := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, role := range strings.Split(text, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" || seen[strings.ToLower(role)] {
+			continue
+		}
+		seen[strings.ToLower(role)] = true
+		roles = append(roles, role)
+	}
+	return strings.Join(roles, ", ")
+}
+
+// SetMembership adds a person to a group, or updates the period and
+// the roles of the person in the group if the person was already in it.
+// The dates of the membership are normalized; it is not set if they are
+// not partial dates, or if the person left before joining.
+func (database *Database) SetMembership(membership *Membership) error {
+	for _, date := range []*string{&membership.Joined, &membership.Left} {
+		normalized, err := NormalizeDate(*date)
+		if err != nil {
+			return err
+		}
+		*date = normalized
+	}
+	if membership.Joined != "" && membership.Left != "" && membership.Left+"~" < membership.Joined {
+		return fmt.Errorf("the leaving date %s is before the joining date %s", membership.Left, membership.Joined)
+	}
+	stmtStr := "INSERT INTO in_group (" +
+		" id_person, " +
+		" id_group, " +
+		" joined_date, " +
+		" left_date, " +
+		" roles) " +
+		"VALUES (?, ?, ?, ?, ?) " +
+		"ON CONFLICT (id_person, id_group) DO UPDATE SET " +
+		" joined_date = excluded.joined_date, " +
+		" left_date = excluded.left_date, " +
+		" roles = excluded.roles"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(membership.PersonID, membership.GroupID, membership.Joined,
+		membership.Left, NormalizeRoles(membership.Roles))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return nil
+}
+
+// RemoveMembership removes a person from a group.   Nothing happens if
+// the person was not in the group.
+func (database *Database) RemoveMembership(personID, groupID int64) {
+	stmtStr := "DELETE FROM in_group WHERE id_person = ? AND id_group = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(personID, groupID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+}
+
+// QueryMemberships returns the memberships of a person, or of the
+// members of a group, if the ID of the person is 0, in the order they
+// joined; the members whose joining date is unknown come first.
+func (database *Database) QueryMemberships(personID, groupID int64) []*Member {
+	stmtStr := "SELECT " +
+		" in_group.id_person, " +
+		" in_group.id_group, " +
+		" in_group.joined_date, " +
+		" in_group.left_date, " +
+		" in_group.roles, " +
+		" persons.stage_name, " +
+		" groups.name " +
+		"FROM " +
+		" in_group " +
+		"INNER JOIN persons ON persons.id_person = in_group.id_person " +
+		"INNER JOIN groups ON groups.id_group = in_group.id_group " +
+		"WHERE " +
+		" in_group.id_person = ? OR in_group.id_group = ? " +
+		"ORDER BY in_group.joined_date, persons.stage_name, groups.name"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	rows, err := stmt.Query(personID, groupID)
+	if err != nil {
+		log.Fatal("could not execute query: ", err)
+	}
+	defer rows.Close()
+
+	members := make([]*Member, 0)
+	for rows.Next() {
+		member := &Member{}
+		err = rows.Scan(&member.PersonID, &member.GroupID, &member.Joined, &member.Left,
+			&member.Roles, &member.StageName, &member.GroupName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		members = append(members, member)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return members
+}
+
+// A LineupPeriod is a span of years, From and To included, in which the
+// members of a group did not change.   A To of 0 means the lineup is
+// still the current one.
+type LineupPeriod struct {
+	From    int
+	To      int
+	Members []string
+}
+
+func (period *LineupPeriod) String() string {
+	switch {
+	case period.From == 0:
+		return "unknown"
+	case period.To == 0:
+		return fmt.Sprintf("%d–", period.From)
+	case period.From == period.To:
+		return fmt.Sprintf("%d", period.From)
+	}
+	return fmt.Sprintf("%d–%d", period.From, period.To)
+}
+
+// yearOf returns the year of a partial date, or 0 if it is unknown.
+func yearOf(date string) int {
+	parsed, err := ParsePartialDate(date)
+	if err != nil {
+		return 0
+	}
+	return parsed.Year
+}
+
+// Lineup returns the lineups of a group over the years, from the start
+// of the group, or the first known joining date, to the end of the group
+// or the year after the last known leaving date.   The members whose joining date is
+// unknown are taken as members from the start, and a member is in the
+// lineups of the years of joining and of leaving.   If no year is known,
+// there is a single period, with From 0 and every member.
+func Lineup(start, end string, members []*Member) []*LineupPeriod {
+	first, last := yearOf(start), yearOf(end)
+	current := last == 0
+	for _, member := range members {
+		joined, left := yearOf(member.Joined), yearOf(member.Left)
+		if joined > 0 && (first == 0 || joined < first) {
+			first = joined
+		}
+		if current {
+			if left > 0 && left+1 > last {
+				last = left + 1
+			}
+			if joined > last {
+				last = joined
+			}
+		}
+	}
+	if first == 0 {
+		names := make([]string, 0, len(members))
+		for _, member := range members {
+			names = append(names, member.StageName)
+		}
+		return []*LineupPeriod{{Members: names}}
+	}
+	if last < first {
+		last = first
+	}
+
+	periods := make([]*LineupPeriod, 0)
+	for year := first; year <= last; year++ {
+		names := make([]string, 0)
+		for _, member := range members {
+			joined, left := yearOf(member.Joined), yearOf(member.Left)
+			if joined <= year && (left == 0 || year <= left) {
+				names = append(names, member.StageName)
+			}
+		}
+		sort.Strings(names)
+		if n := len(periods); n > 0 && strings.Join(periods[n-1].Members, "\x00") == strings.Join(names, "\x00") {
+			periods[n-1].To = year
+			continue
+		}
+		periods = append(periods, &LineupPeriod{From: year, To: year, Members: names})
+	}
+	if n := len(periods); n > 1 && len(periods[n-1].Members) == 0 {
+		periods = periods[:n-1]
+	}
+	if current {
+		for _, member := range members {
+			if member.Left == "" {
+				periods[len(periods)-1].To = 0
+				break
+			}
+		}
+	}
+	return periods
+}
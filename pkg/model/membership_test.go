@@ -0,0 +1,122 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRoles(t *testing.T) {
+	roles := NormalizeRoles(" vocals,guitar , ,Vocals, producer ")
+	if roles != "vocals, guitar, producer" {
+		t.Errorf("expecting %v, received %v", "vocals, guitar, producer", roles)
+	}
+}
+
+func TestLineup(t *testing.T) {
+	member := func(name, joined, left string) *Member {
+		return &Member{Membership: Membership{Joined: joined, Left: left}, StageName: name}
+	}
+	members := []*Member{
+		member("Johnny Rotten", "", "1978-01-14"),
+		member("Steve Jones", "1975", ""),
+		member("Glen Matlock", "1975", "1977-02"),
+		member("Sid Vicious", "1977-02", "1978"),
+	}
+	periods := Lineup("1975", "1978", members)
+	expected := []string{
+		"1975–1976: Glen Matlock, Johnny Rotten, Steve Jones",
+		"1977: Glen Matlock, Johnny Rotten, Sid Vicious, Steve Jones",
+		"1978: Johnny Rotten, Sid Vicious, Steve Jones",
+	}
+	if len(periods) != len(expected) {
+		t.Fatalf("expecting %v periods, received %v", len(expected), len(periods))
+	}
+	for i, period := range periods {
+		received := period.String() + ": " + strings.Join(period.Members, ", ")
+		if received != expected[i] {
+			t.Errorf("expecting %v, received %v", expected[i], received)
+		}
+	}
+
+	periods = Lineup("1970", "", []*Member{member("Brian May", "1970", ""), member("John Deacon", "1971", "1997")})
+	if len(periods) != 3 || periods[1].String() != "1971–1997" || periods[2].String() != "1998–" {
+		t.Errorf("expecting a current lineup from 1998, received %v", periods)
+	}
+
+	periods = Lineup("", "", []*Member{member("Nobody", "", "")})
+	if len(periods) != 1 || periods[0].String() != "unknown" || len(periods[0].Members) != 1 {
+		t.Errorf("expecting a single unknown period, received %v", periods)
+	}
+}
+
+func TestSetMembership(t *testing.T) {
+	database, _, clean := newTestLibrary(t, 0)
+	defer clean()
+	group := database.AddGroup("Sex Pistols", "1975", "1978")
+	rotten := database.AddPerson("Johnny Rotten", "John Lydon", "1956-01-31", "")
+	matlock := database.AddPerson("Glen Matlock", "Glen Matlock", "1956-08-27", "")
+	members := func() []string {
+		result := make([]string, 0)
+		for _, member := range database.QueryMemberships(0, group) {
+			result = append(result, member.StageName+" "+member.GroupName+" "+member.Period()+" "+member.Roles)
+		}
+		return result
+	}
+
+	err := database.SetMembership(&Membership{PersonID: matlock, GroupID: group, Joined: "1975-8", Left: "1977-2",
+		Roles: "bass,  vocals,Bass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = database.SetMembership(&Membership{PersonID: rotten, GroupID: group, Roles: "vocals"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"Johnny Rotten Sex Pistols  vocals",
+		"Glen Matlock Sex Pistols 1975-08–1977-02 bass, vocals",
+	}
+	if received := members(); strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+
+	err = database.SetMembership(&Membership{PersonID: rotten, GroupID: group, Joined: "1975", Left: "1978-01-14",
+		Roles: "vocals, lyrics"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{
+		"Johnny Rotten Sex Pistols 1975–1978-01-14 vocals, lyrics",
+		"Glen Matlock Sex Pistols 1975-08–1977-02 bass, vocals",
+	}
+	if received := members(); strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+	if memberships := database.QueryMemberships(rotten, 0); len(memberships) != 1 {
+		t.Errorf("expecting %v, received %v", 1, len(memberships))
+	}
+
+	for _, period := range [][]string{{"1977", "1976-12-31"}, {"1977-03", "1977-02"}, {"1977-13", ""}} {
+		err = database.SetMembership(&Membership{PersonID: matlock, GroupID: group, Joined: period[0], Left: period[1]})
+		if err == nil {
+			t.Errorf("expecting an error for %v", period)
+		}
+	}
+	if received := members(); strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+	err = database.SetMembership(&Membership{PersonID: matlock, GroupID: group, Joined: "1977-02", Left: "1977"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if received := members(); received[1] != "Glen Matlock Sex Pistols 1977-02–1977 " {
+		t.Errorf("expecting %v, received %v", "Glen Matlock Sex Pistols 1977-02–1977 ", received[1])
+	}
+
+	database.RemoveMembership(matlock, group)
+	database.RemoveMembership(matlock, group)
+	expected = expected[:1]
+	if received := members(); strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+}
@@ -5,108 +5,352 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/dhowden/tag"
 )
 
-// A Miner searches for mp3 files in the /home/user/Music directory
-// along the file tree, gathers their information, and puts it in a
-// Rola object, which is then loaded into a channel for external use.
+// A Miner searches for mp3 and flac files in the /home/user/Music
+// directory (or any other root directory) along the file tree, gathers
+// their information, and puts it in a Rola object, which is then loaded
+// into a channel for external use.   The files divided into tracks by a
+// CUE sheet give a Rola for each track instead.
 type Miner struct {
+	processed int64
+	root      string
 	paths     []string
+	sheets    []*CueSheet
+	covers    map[string]string
 	ore       chan *Rola
 	TrackList chan *Rola
 }
 
-// NewMiner returns a new Miner with an empty paths slice.
+// NewMiner returns a new Miner with an empty paths slice, rooted at the
+// Music directory of the current user.   The channels of the miner are
+// created here, so Extract, Populate and the readers of TrackList can
+// be started in any order.
 func NewMiner() *Miner {
+	home, err := user.Current()
+	if err != nil {
+		log.Fatal("could not retrieve the current user:", err)
+	}
 	return &Miner{
-		paths: make([]string, 0),
+		root:      home.HomeDir + "/Music",
+		paths:     make([]string, 0),
+		sheets:    make([]*CueSheet, 0),
+		covers:    make(map[string]string),
+		ore:       make(chan *Rola),
+		TrackList: make(chan *Rola),
 	}
 }
 
-// Traverse walks the file tree looking for mp3 files and saving their
-// paths into the paths slice.
-func (miner *Miner) Traverse() {
-	home, err := user.Current()
-	if err != nil {
-		log.Fatal("could not retrieve the current user:", err)
+// Found returns the number of files found by Traverse, counting each
+// track of a CUE sheet as a file.
+func (miner *Miner) Found() int {
+	found := len(miner.paths)
+	for _, sheet := range miner.sheets {
+		found += len(sheet.Tracks)
 	}
-	homePath := home.HomeDir
-	start := homePath + "/Music"
+	return found
+}
+
+// Processed returns the number of Rolas added to the database, or
+// updated, by Populate so far.   It is safe to call it while Populate
+// runs.
+func (miner *Miner) Processed() int {
+	return int(atomic.LoadInt64(&miner.processed))
+}
+
+// Root returns the directory where the miner looks for files.
+func (miner *Miner) Root() string {
+	return miner.root
+}
+
+// SetRoot sets the directory where the miner looks for files.
+func (miner *Miner) SetRoot(root string) {
+	miner.root = root
+}
+
+// Ore returns the channel where the Rolas are put by Extract.
+func (miner *Miner) Ore() chan *Rola {
+	return miner.ore
+}
 
-	err = filepath.Walk(start, func(path string, info os.FileInfo, err error) error {
+// Traverse walks the file tree under the root looking for mp3 and flac
+// files and saving their paths into the paths slice.   Directories that
+// can not be read are skipped.   The CUE sheets found are read, and the
+// files they divide into tracks are left out of the paths slice; the
+// sheets that can not be read are skipped.
+func (miner *Miner) Traverse() {
+	cues := make([]string, 0)
+	err := filepath.Walk(miner.root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Fatal("failure accessing the path: ", err)
-			return err
+			log.Println("failure accessing the path:", err)
+			return nil
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".mp3") {
+		switch {
+		case info.IsDir():
+		case isAudioFile(info.Name()):
 			miner.paths = append(miner.paths, path)
+		case strings.ToLower(filepath.Ext(info.Name())) == ".cue":
+			cues = append(cues, path)
 		}
 		return nil
 	})
 	if err != nil {
 		log.Fatal("error walking the path: ", err)
 	}
+
+	divided := make(map[string]bool)
+	for _, cue := range cues {
+		sheet, err := readCueSheet(cue)
+		if err != nil {
+			log.Println("could not read the cue sheet:", err)
+			continue
+		}
+		tracks := make([]*CueTrack, 0, len(sheet.Tracks))
+		for _, track := range sheet.Tracks {
+			if _, err := os.Stat(track.File); err != nil || !isAudioFile(track.File) {
+				log.Println("could not find the file of track", track.Number, "of "+cue)
+				continue
+			}
+			divided[track.File] = true
+			tracks = append(tracks, track)
+		}
+		sheet.Tracks = tracks
+		miner.sheets = append(miner.sheets, sheet)
+	}
+	paths := make([]string, 0, len(miner.paths))
+	for _, path := range miner.paths {
+		if !divided[path] {
+			paths = append(paths, path)
+		}
+	}
+	miner.paths = paths
 }
 
 // Extract traverses the paths slice, opens each of the files whose
-// paths are in the slice, reads the ID3v2 tag, saves the information
-// into a new Rola, and puts it in the ore channel of the miner.
+// paths are in the slice, reads the ID3v2 tag and the properties of the
+// audio stream, saves the information into a new Rola, and puts it in the
+// ore channel of the miner.   Files that can not be read are skipped;
+// files without tags are kept with the default values of NewRola, so
+// their fingerprints can propose tags for them.   The fields missing
+// from the tag are inferred from the path with the FilenameRules.   The
+// picture in the tag, or the cover file in the directory of the file if
+// the tag has none, is added to the ArtworkCache.   The tracks of the CUE
+// sheets come last (see extractCueTracks).
 func (miner *Miner) Extract() {
-	miner.ore = make(chan *Rola)
-	genreConverter := GetGenre()
+	rules := GetFilenameRules()
 	for _, path := range miner.paths {
 		file, err := os.Open(path)
 		if err != nil {
-			log.Fatal("could not open file: ", path, err)
+			log.Println("could not open file "+path+":", err)
+			continue
 		}
 		metadata, err := tag.ReadFrom(file)
-		if err != nil {
-			log.Fatal("could not read the tag:", err)
+		if err != nil && err != tag.ErrNoTagsFound {
+			log.Println("could not read the tag of "+path+":", err)
+			file.Close()
+			continue
 		}
 
 		rola := NewRola()
-		if metadata.Artist() != "" {
-			rola.SetArtist(metadata.Artist())
+		rola.SetPath(path)
+		if metadata != nil {
+			miner.setTags(rola, metadata, filepath.Dir(path))
 		}
-		if metadata.Title() != "" {
-			rola.SetTitle(metadata.Title())
+		rules.Fill(rola)
+		properties, err := ReadAudioProperties(file)
+		if err != nil {
+			log.Println("could not read the audio properties of "+path+":", err)
+		} else {
+			rola.SetAudioProperties(properties)
 		}
-		if metadata.Album() != "" {
-			rola.SetAlbum(metadata.Album())
+		file.Close()
+		miner.ore <- rola
+	}
+	for _, sheet := range miner.sheets {
+		miner.extractCueTracks(sheet)
+	}
+	close(miner.ore)
+}
+
+// extractCueTracks puts in the ore channel a Rola for each track of a
+// CUE sheet.   The tags and the audio properties of its file are read
+// once; the fields of the sheet and the track take the place of the
+// tags, and the duration is the one of the track.   The ReplayGain of
+// the file is the one of its album, not of its tracks.
+func (miner *Miner) extractCueTracks(sheet *CueSheet) {
+	var metadata tag.Metadata
+	var properties *AudioProperties
+	read := ""
+	opened := false
+	for _, track := range sheet.Tracks {
+		if track.File != read {
+			read, metadata, properties = track.File, nil, nil
+			file, err := os.Open(track.File)
+			opened = err == nil
+			if err != nil {
+				log.Println("could not open file "+track.File+":", err)
+				continue
+			}
+			metadata, err = tag.ReadFrom(file)
+			if err != nil && err != tag.ErrNoTagsFound {
+				log.Println("could not read the tag of "+track.File+":", err)
+			}
+			properties, err = ReadAudioProperties(file)
+			if err != nil {
+				log.Println("could not read the audio properties of "+track.File+":", err)
+			}
+			file.Close()
 		}
-		track, _ := metadata.Track()
-		if track != 0 {
-			rola.SetTrack(track)
+		if !opened {
+			continue
 		}
-		if metadata.Year() != 0 {
-			rola.SetYear(metadata.Year())
+
+		rola := NewRola()
+		rola.SetPath(cueTrackPath(track))
+		if metadata != nil {
+			miner.setTags(rola, metadata, filepath.Dir(track.File))
+			gain := rola.ReplayGain()
+			rola.SetReplayGain(&ReplayGain{AlbumLoudness: gain.AlbumLoudness, AlbumPeak: gain.AlbumPeak})
 		}
-		if metadata.Genre() != "" {
-			rola.SetGenre(genreConverter.Get(metadata.Genre()))
+		sheet.setTags(rola, track)
+		if properties != nil {
+			trackProperties := *properties
+			if track.End > 0 && track.End < properties.Duration {
+				trackProperties.Duration = track.End
+			}
+			trackProperties.Duration -= track.Start
+			if trackProperties.Duration < 0 {
+				trackProperties.Duration = 0
+			}
+			rola.SetAudioProperties(&trackProperties)
 		}
-		rola.SetPath(path)
+		rola.SetCueTrack(track.File, track.Start, track.End)
 		miner.ore <- rola
 	}
-	close(miner.ore)
 }
 
 // Populate takes the Rolas in the ore channel of the miner,
-// adds them to the database, and if it was a new Rola, it is
-// put in the TrackList channel.
+// adds them to the database, and if it was a new Rola, its
+// performers are credited with the CreditSplitter, its genres,
+// resolved with the aliases of the genres, are added, and it is
+// put in the TrackList channel.   The audio properties of the
+// Rolas already in the database are updated, and so is their
+// ReplayGain if their tags have one.
 // TODO: Maybe this method should be in the controller package.
 func (miner *Miner) Populate(database *Database) {
-	miner.TrackList = make(chan *Rola)
+	splitter := GetCreditSplitter()
+	index := database.GenreIndex()
 	for rola := range miner.ore {
+		genres := index.Resolve(SplitGenres(rola.Genre()))
+		rola.SetGenre(strings.Join(genres, GenreSeparator))
 		idperformer := database.AddPerformer(rola)
 		idalbum := database.AddAlbum(rola)
 		id := database.AddRola(rola, idperformer, idalbum)
 		if id > 0 {
 			rola.SetID(id)
+			database.SetCredits(id, splitter.Split(rola.Artist(), rola.Title(), rola.Composer()))
+			database.SetGenres(id, genres)
 			miner.TrackList <- rola
+		} else {
+			database.UpdateAudioProperties(rola)
+			database.UpdateArtwork(rola)
+			database.UpdateReplayGain(rola)
 		}
+		atomic.AddInt64(&miner.processed, 1)
 	}
 	close(miner.TrackList)
 }
+
+// setTags sets the fields of a Rola from the tags of its file, leaving
+// the default values for the tags that are empty, and its ReplayGain
+// from the ReplayGain or R128 tags.
+func (miner *Miner) setTags(rola *Rola, metadata tag.Metadata, dir string) {
+	genreConverter := GetGenre()
+	if metadata.Artist() != "" {
+		rola.SetArtist(metadata.Artist())
+	}
+	if metadata.Title() != "" {
+		rola.SetTitle(metadata.Title())
+	}
+	if metadata.Album() != "" {
+		rola.SetAlbum(metadata.Album())
+	}
+	track, _ := metadata.Track()
+	if track != 0 {
+		rola.SetTrack(track)
+	}
+	if metadata.Year() != 0 {
+		rola.SetYear(metadata.Year())
+	}
+	if metadata.Genre() != "" {
+		rola.SetGenre(genreConverter.Get(metadata.Genre()))
+	}
+	disc, _ := metadata.Disc()
+	if disc != 0 {
+		rola.SetDisc(disc)
+	}
+	rola.SetAlbumArtist(metadata.AlbumArtist())
+	rola.SetComposer(metadata.Composer())
+	rola.SetBPM(bpm(metadata))
+	rola.SetComment(metadata.Comment())
+	rola.SetLyrics(metadata.Lyrics())
+	rola.SetArtwork(miner.artwork(metadata, dir))
+	rola.SetReplayGain(replayGainTags(metadata))
+}
+
+// artwork stores the picture in the tag in the ArtworkCache and returns
+// its hash.   If the tag has no picture, the cover file of the directory
+// is used instead; the cover files are stored only once per directory.
+func (miner *Miner) artwork(metadata tag.Metadata, dir string) string {
+	cache := GetArtworkCache()
+	if picture := metadata.Picture(); picture != nil {
+		hash, err := cache.Store(picture.Data)
+		if err == nil {
+			return hash
+		}
+		log.Println("could not store the picture of a rola in "+dir+":", err)
+	}
+	hash, ok := miner.covers[dir]
+	if ok {
+		return hash
+	}
+	if cover := FindCover(dir); cover != "" {
+		var err error
+		hash, err = cache.StoreFile(cover)
+		if err != nil {
+			log.Println("could not store the cover "+cover+":", err)
+		}
+	}
+	miner.covers[dir] = hash
+	return hash
+}
+
+// isAudioFile returns true if the file name has the extension of one
+// of the formats read by the Miner.
+func isAudioFile(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	return extension == ".mp3" || extension == ".flac"
+}
+
+// bpm looks for the beats per minute among the raw frames of the tag,
+// as they are not exposed by tag.Metadata.   It returns 0 if the tag
+// has no such frame.
+func bpm(metadata tag.Metadata) int {
+	for _, frame := range []string{"TBPM", "TBP", "tmpo", "BPM"} {
+		switch value := metadata.Raw()[frame].(type) {
+		case int:
+			return value
+		case string:
+			beats, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil {
+				return int(beats + 0.5)
+			}
+		}
+	}
+	return 0
+}
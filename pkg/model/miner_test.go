@@ -1,6 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -50,3 +55,94 @@ func TestExtract2(t *testing.T) {
 		}
 	}
 }
+
+// newTestLibrary writes the given number of untagged mp3 files into a
+// temporary directory, and returns a new database in it and a miner
+// that already traversed it, with a function to remove the directory.
+func newTestLibrary(t *testing.T, files int) (*Database, *Miner, func()) {
+	dir, err := ioutil.TempDir("", "rolas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i <= files; i++ {
+		name := fmt.Sprintf("%02d - Artist %d - Title %d.mp3", i, i%3, i)
+		err := ioutil.WriteFile(filepath.Join(dir, name), cbrStream(10), 0644)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	database, _ := NewDatabaseAt(filepath.Join(dir, "cache", "rolas.db"))
+	database.CreateDB()
+	database.MigrateDB()
+
+	miner := NewMiner()
+	miner.SetRoot(dir)
+	miner.Traverse()
+	return database, miner, func() {
+		database.Database.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// minedTestLibrary works like newTestLibrary, but the files are already
+// mined into the database.
+func minedTestLibrary(t *testing.T, files int) (*Database, string, func()) {
+	database, miner, clean := newTestLibrary(t, files)
+	go miner.Extract()
+	go miner.Populate(database)
+	for range miner.TrackList {
+	}
+	return database, miner.Root(), clean
+}
+
+func TestPopulateWhileSearching(t *testing.T) {
+	database, miner, clean := newTestLibrary(t, 20)
+	defer clean()
+
+	go miner.Extract()
+	go miner.Populate(database)
+	done := make(chan bool)
+	searches := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			for searching := true; searching; {
+				select {
+				case <-done:
+					searching = false
+				default:
+					database.Search("*~* *AR*~artist")
+					database.Search("title")
+				}
+			}
+			searches <- true
+		}()
+	}
+	for range miner.TrackList {
+	}
+	close(done)
+	<-searches
+	<-searches
+	if found := len(database.Search("title")); found != 20 {
+		t.Errorf("expecting %v, received %v", 20, found)
+	}
+}
+
+func TestAllRolas(t *testing.T) {
+	database, miner, clean := newTestLibrary(t, 5)
+	defer clean()
+
+	go miner.Extract()
+	go miner.Populate(database)
+	for range miner.TrackList {
+	}
+	database.SetHidden(3, true)
+	rolas := database.AllRolas()
+	if len(rolas) != 4 {
+		t.Fatalf("expecting %v, received %v", 4, len(rolas))
+	}
+	for _, rola := range rolas {
+		if expected := database.QueryRola(rola.ID()); !reflect.DeepEqual(rola, expected) {
+			t.Errorf("expecting %v, received %v", expected, rola)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+// The reasons a file is taken for the missing file of a Rola.
+const (
+	RelinkAudio = "same audio"
+	RelinkSize  = "same size"
+	RelinkTags  = "same tags"
+	RelinkName  = "same name"
+)
+
+// relinkWeights rank the reasons, so that the candidates with the
+// strongest reasons come first.
+var relinkWeights = map[string]int{RelinkAudio: 8, RelinkSize: 4, RelinkTags: 2, RelinkName: 1}
+
+// A RelinkCandidate is a file that may be the missing file of a Rola,
+// with the reasons to take it for it.
+type RelinkCandidate struct {
+	Path    string   `json:"path"`
+	Reasons []string `json:"reasons"`
+	score   int
+}
+
+// A MissingRola is a Rola whose file is missing, with the files that may
+// be its file, the likeliest first.
+type MissingRola struct {
+	ID         int64              `json:"id"`
+	Title      string             `json:"title"`
+	Artist     string             `json:"artist"`
+	Album      string             `json:"album"`
+	Path       string             `json:"path"`
+	Candidates []*RelinkCandidate `json:"candidates"`
+	size       int64
+	hash       string
+	container  string
+}
+
+// CheckMissing looks for the files of the Rolas that are not hidden, and
+// marks the Rolas whose files are missing, unmarking the ones whose files
+// are back; the size of each file found is kept, to recognize it if it
+// is moved.   The file of a track of a CUE sheet is the file of the
+// sheet.   It returns the IDs of the Rolas whose files are missing.
+func (database *Database) CheckMissing() []int64 {
+	rows, err := database.Database.Query("SELECT id_rola, CASE WHEN container = '' THEN path ELSE container END " +
+		"FROM rolas WHERE hidden = 0 ORDER BY id_rola")
+	if err != nil {
+		log.Fatal(err)
+	}
+	paths := make(map[int64]string)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		var path string
+		err = rows.Scan(&id, &path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		paths[id] = path
+		ids = append(ids, id)
+	}
+	closeRows(rows)
+
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	found, err := tx.Prepare("UPDATE rolas SET missing = 0, " +
+		"size = CASE WHEN container = '' THEN ? ELSE 0 END WHERE id_rola = ?")
+	if err != nil {
+		log.Fatal(err)
+	}
+	lost, err := tx.Prepare("UPDATE rolas SET missing = 1 WHERE id_rola = ?")
+	if err != nil {
+		log.Fatal(err)
+	}
+	missing := make([]int64, 0)
+	for _, id := range ids {
+		info, err := os.Stat(paths[id])
+		switch {
+		case err == nil:
+			_, err = found.Exec(info.Size(), id)
+		case os.IsNotExist(err):
+			missing = append(missing, id)
+			_, err = lost.Exec(id)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	found.Close()
+	lost.Close()
+	err = tx.Commit()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return missing
+}
+
+// QueryMissing returns the Rolas, not hidden, marked as missing by the
+// last CheckMissing, without candidates.
+func (database *Database) QueryMissing() []*MissingRola {
+	stmtStr := "SELECT rolas.id_rola, rolas.title, performers.name, albums.name, rolas.path, " +
+		"rolas.size, rolas.audio_hash, rolas.container " +
+		"FROM rolas " +
+		"INNER JOIN performers ON performers.id_performer = rolas.id_performer " +
+		"INNER JOIN albums ON albums.id_album = rolas.id_album " +
+		"WHERE rolas.missing = 1 AND rolas.hidden = 0 " +
+		"ORDER BY rolas.path"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr)
+	defer stmt.Close()
+	defer rows.Close()
+
+	missing := make([]*MissingRola, 0)
+	for rows.Next() {
+		rola := &MissingRola{Candidates: []*RelinkCandidate{}}
+		err := rows.Scan(&rola.ID, &rola.Title, &rola.Artist, &rola.Album, &rola.Path, &rola.size, &rola.hash,
+			&rola.container)
+		if err != nil {
+			log.Fatal(err)
+		}
+		missing = append(missing, rola)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	return missing
+}
+
+// relinkFile is an audio file under a root that is not in the library;
+// its tags and the hash of its audio are only read if they are needed.
+type relinkFile struct {
+	path     string
+	size     int64
+	key      string
+	hash     string
+	hashed   bool
+	tagsRead bool
+}
+
+// tagKey returns the key of the artist and the title of the file, as
+// made by duplicateKey, or an empty key if they can not be read.
+func (file *relinkFile) tagKey() string {
+	if !file.tagsRead {
+		file.tagsRead = true
+		if reader, err := os.Open(file.path); err == nil {
+			if metadata, err := tag.ReadFrom(reader); err == nil && metadata.Title() != "" {
+				file.key = duplicateKey(metadata.Artist(), metadata.Title())
+			}
+			reader.Close()
+		}
+	}
+	return file.key
+}
+
+// audioHash returns the hash of the audio of the file, or an empty hash
+// if it can not be read.
+func (file *relinkFile) audioHash() string {
+	if !file.hashed {
+		file.hashed = true
+		if reader, err := os.Open(file.path); err == nil {
+			file.hash, _ = AudioHash(reader)
+			reader.Close()
+		}
+	}
+	return file.hash
+}
+
+// FindRelinks looks under the roots for the audio files that are not in
+// the library and may be the missing files of the Rolas, and sets them
+// as their candidates.   A file is a candidate if it has the size the
+// file of the Rola had, the same artist and title in its tags, or the
+// same name; the audio of a candidate is hashed, and compared with the
+// audio of the Rola, if it was hashed.   The tracks of CUE sheets have no
+// candidates, as they are found again by mining their sheets.
+func (database *Database) FindRelinks(missing []*MissingRola, roots []string) {
+	if len(missing) == 0 {
+		return
+	}
+	known := make(map[string]bool)
+	rows, err := database.Database.Query("SELECT path FROM rolas")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var path string
+		err = rows.Scan(&path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		known[path] = true
+	}
+	closeRows(rows)
+
+	files := make([]*relinkFile, 0)
+	for _, root := range roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !isAudioFile(info.Name()) || known[path] {
+				return nil
+			}
+			known[path] = true
+			files = append(files, &relinkFile{path: path, size: info.Size()})
+			return nil
+		})
+	}
+	matchRelinks(missing, files)
+}
+
+// matchRelinks sets the files that may be the missing file of each Rola
+// as its candidates, the likeliest first.
+func matchRelinks(missing []*MissingRola, files []*relinkFile) {
+	for _, rola := range missing {
+		rola.Candidates = []*RelinkCandidate{}
+		if rola.container != "" {
+			continue
+		}
+		key := duplicateKey(rola.Artist, rola.Title)
+		if rola.Title == NewRola().Title() {
+			key = ""
+		}
+		for _, file := range files {
+			reasons := make([]string, 0)
+			if rola.size > 0 && file.size == rola.size {
+				reasons = append(reasons, RelinkSize)
+			}
+			if key != "" && file.tagKey() == key {
+				reasons = append(reasons, RelinkTags)
+			}
+			if strings.EqualFold(filepath.Base(file.path), filepath.Base(rola.Path)) {
+				reasons = append(reasons, RelinkName)
+			}
+			if len(reasons) == 0 {
+				continue
+			}
+			if rola.hash != "" && file.audioHash() == rola.hash {
+				reasons = append([]string{RelinkAudio}, reasons...)
+			}
+			candidate := &RelinkCandidate{Path: file.path, Reasons: reasons}
+			for _, reason := range reasons {
+				candidate.score += relinkWeights[reason]
+			}
+			rola.Candidates = append(rola.Candidates, candidate)
+		}
+		sort.SliceStable(rola.Candidates, func(i, j int) bool {
+			return rola.Candidates[i].score > rola.Candidates[j].score
+		})
+	}
+}
+
+// Best returns the candidate to relink a missing Rola to without asking:
+// the first candidate, if no other has reasons as strong as its own.
+func (rola *MissingRola) Best() *RelinkCandidate {
+	if len(rola.Candidates) == 0 {
+		return nil
+	}
+	if len(rola.Candidates) > 1 && rola.Candidates[1].score == rola.Candidates[0].score {
+		return nil
+	}
+	return rola.Candidates[0]
+}
+
+// Relink makes a file the file of a Rola whose file is missing, moving
+// the Rola to the album with its name in the directory of the file as
+// Organize does, and unmarks the Rola.   The file must exist and not be
+// the file of another Rola.
+func (database *Database) Relink(rolaID int64, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	old := database.QueryPath(rolaID)
+	if old == "" {
+		return fmt.Errorf("there is no rola with id %d", rolaID)
+	}
+	if database.QueryFile(rolaID) != old {
+		return fmt.Errorf("rola %d is a track of a cue sheet; mine the sheet again", rolaID)
+	}
+	if other := database.QueryCustom("SELECT id_rola FROM rolas WHERE path = ?", path); len(other) > 0 {
+		if other[0] == rolaID {
+			return errors.New("the rola is already linked to " + path)
+		}
+		return fmt.Errorf("%s is the file of the rola with id %d", path, other[0])
+	}
+	err = database.updatePaths([]*OrganizeMove{{RolaID: rolaID, From: old, To: path}})
+	if err != nil {
+		return err
+	}
+	_, err = database.Database.Exec("UPDATE rolas SET missing = 0, size = ? WHERE id_rola = ?", info.Size(), rolaID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return nil
+}
+
+// String describes a candidate, as "/music/a.mp3 (same size, same tags)".
+func (candidate *RelinkCandidate) String() string {
+	return candidate.Path + " (" + strings.Join(candidate.Reasons, ", ") + ")"
+}
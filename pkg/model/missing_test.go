@@ -0,0 +1,141 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatchRelinks(t *testing.T) {
+	files := []*relinkFile{
+		{path: "/new/Queen/Bohemian.mp3", size: 1000, tagsRead: true, hashed: true,
+			key: duplicateKey("Queen", "Bohemian Rhapsody"), hash: "abc"},
+		{path: "/new/other/01 - Bohemian Rhapsody.mp3", size: 2000, tagsRead: true, hashed: true,
+			key: duplicateKey("Queen", "Bohemian Rhapsody!"), hash: "def"},
+		{path: "/new/copies/01 - bohemian rhapsody.MP3", size: 3000, tagsRead: true, hashed: true},
+		{path: "/new/unrelated.mp3", size: 4000, tagsRead: true, hashed: true,
+			key: duplicateKey("Caifanes", "La célula que explota")},
+	}
+	rola := &MissingRola{ID: 1, Title: "Bohemian Rhapsody", Artist: "Queen",
+		Path: "/music/01 - Bohemian Rhapsody.mp3", size: 1000, hash: "abc"}
+	unknown := &MissingRola{ID: 2, Title: NewRola().Title(), Artist: NewRola().Artist(),
+		Path: "/music/track.mp3", size: 5000}
+	matchRelinks([]*MissingRola{rola, unknown}, files)
+
+	expected := []string{files[0].path, files[1].path, files[2].path}
+	if len(rola.Candidates) != len(expected) {
+		t.Fatalf("expecting %v, received %v", expected, rola.Candidates)
+	}
+	for i, candidate := range rola.Candidates {
+		if candidate.Path != expected[i] {
+			t.Errorf("expecting %v, received %v", expected[i], candidate.Path)
+		}
+	}
+	reasons := []string{RelinkAudio, RelinkSize, RelinkTags}
+	for i, reason := range reasons {
+		if rola.Candidates[0].Reasons[i] != reason {
+			t.Errorf("expecting %v, received %v", reason, rola.Candidates[0].Reasons[i])
+		}
+	}
+	if best := rola.Best(); best != rola.Candidates[0] {
+		t.Errorf("expecting %v, received %v", rola.Candidates[0], best)
+	}
+
+	if len(unknown.Candidates) != 0 || unknown.Best() != nil {
+		t.Errorf("expecting %v, received %v", "no candidates", unknown.Candidates)
+	}
+
+	rola.size, rola.hash = 0, ""
+	matchRelinks([]*MissingRola{rola}, files)
+	if len(rola.Candidates) != 3 || rola.Best() == nil || rola.Best().Path != files[1].path {
+		t.Errorf("expecting %v first, received %v", files[1].path, rola.Candidates)
+	}
+
+	files[0].key, files[1].key = "", ""
+	matchRelinks([]*MissingRola{rola}, files)
+	if len(rola.Candidates) != 2 || rola.Best() != nil {
+		t.Errorf("expecting %v, received %v", "no best candidate", rola.Candidates)
+	}
+}
+
+func TestRelink(t *testing.T) {
+	database, root, clean := minedTestLibrary(t, 3)
+	defer clean()
+	plays := func(id int64) int {
+		var count int
+		err := database.Database.QueryRow("SELECT play_count FROM rolas WHERE id_rola = ?", id).Scan(&count)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return count
+	}
+	database.AddPlay(1, time.Now())
+	database.SetCredits(1, []*PerformerCredit{{Name: "Artist 1", Credit: CreditMain},
+		{Name: "Someone", Credit: CreditFeatured}})
+	credits := database.QueryCredits(1)
+
+	old := database.QueryPath(1)
+	moved := filepath.Join(root, "moved", filepath.Base(old))
+	os.Mkdir(filepath.Dir(moved), 0755)
+	if err := os.Rename(old, moved); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Remove(database.QueryPath(2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if missing := database.CheckMissing(); !reflect.DeepEqual(missing, []int64{1, 2}) {
+		t.Fatalf("expecting %v, received %v", []int64{1, 2}, missing)
+	}
+
+	rolas := database.QueryMissing()
+	database.FindRelinks(rolas, []string{root})
+	if len(rolas) != 2 || rolas[0].ID != 1 || rolas[0].Best() == nil || rolas[0].Best().Path != moved {
+		t.Fatalf("expecting %v for rola 1, received %v", moved, rolas)
+	}
+	if err := database.Relink(1, database.QueryPath(3)); err == nil {
+		t.Errorf("expecting an error relinking a rola to the file of another")
+	}
+	if err := database.Relink(1, filepath.Dir(moved)); err == nil {
+		t.Errorf("expecting an error relinking a rola to a directory")
+	}
+	if err := database.Relink(1, rolas[0].Best().Path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rola := database.QueryRola(1)
+	if rola.ID() != 1 || rola.Path() != moved || rola.Album() != filepath.Base(root) {
+		t.Errorf("expecting %v, received %v", moved, rola.Path())
+	}
+	var album string
+	err := database.Database.QueryRow("SELECT albums.path FROM albums INNER JOIN rolas " +
+		"ON rolas.id_album = albums.id_album WHERE rolas.id_rola = 1").Scan(&album)
+	if err != nil || album != filepath.Dir(moved) {
+		t.Errorf("expecting %v, received %v", filepath.Dir(moved), album)
+	}
+	if plays(1) != 1 {
+		t.Errorf("expecting %v, received %v", 1, plays(1))
+	}
+	if received := database.QueryCredits(1); !reflect.DeepEqual(received, credits) {
+		t.Errorf("expecting %v, received %v", credits, received)
+	}
+	if err := database.Relink(1, moved); err == nil {
+		t.Errorf("expecting an error relinking a rola to its own file")
+	}
+
+	rolas = database.QueryMissing()
+	database.FindRelinks(rolas, []string{root})
+	if len(rolas) != 1 || rolas[0].ID != 2 || len(rolas[0].Candidates) != 0 {
+		t.Fatalf("expecting %v without candidates, received %v", 2, rolas)
+	}
+	database.RemoveRola(rolas[0].ID)
+	if missing := database.CheckMissing(); len(missing) != 0 {
+		t.Errorf("expecting %v, received %v", 0, missing)
+	}
+	if path := database.QueryPath(2); path != "" {
+		t.Errorf("expecting %v, received %v", "", path)
+	}
+	if count := len(database.AllRolas()); count != 2 {
+		t.Errorf("expecting %v, received %v", 2, count)
+	}
+}
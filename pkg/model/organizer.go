@@ -0,0 +1,468 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultOrganizeTemplate is the template used to organize the library
+// when none is given.
+const DefaultOrganizeTemplate = "%albumartist%/%album% (%year%)/%track% - %title%"
+
+// maxNameLength is the largest length, in bytes, of a file or directory
+// name written by the organizer.
+const maxNameLength = 200
+
+// An OrganizeMove moves the file of a Rola from one path to another.
+type OrganizeMove struct {
+	RolaID int64  `json:"rola_id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// An OrganizeJournal records the moves made by one run of the organizer,
+// so that they can be undone.   It is written before the files are moved.
+type OrganizeJournal struct {
+	Time     time.Time       `json:"time"`
+	Root     string          `json:"root"`
+	Template string          `json:"template"`
+	Moves    []*OrganizeMove `json:"moves"`
+	Path     string          `json:"-"`
+}
+
+// Journals keeps the journals of the organizer in a directory, named
+// after the time the files were organized.
+type Journals struct {
+	Dir string
+}
+
+// NewJournals returns the Journals kept in the directory
+// "rolas/journals" of the data directory of the user.
+func NewJournals() *Journals {
+	return &Journals{Dir: dataDir("journals")}
+}
+
+// List returns the journals, the newest first.
+func (journals *Journals) List() ([]*OrganizeJournal, error) {
+	files, err := ioutil.ReadDir(journals.Dir)
+	if os.IsNotExist(err) {
+		return []*OrganizeJournal{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*OrganizeJournal, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(journals.Dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		journal := &OrganizeJournal{Path: path}
+		err = json.Unmarshal(data, journal)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a journal: %v", path, err)
+		}
+		list = append(list, journal)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+	return list, nil
+}
+
+// write writes a journal in the directory of the journals.
+func (journals *Journals) write(journal *OrganizeJournal) error {
+	err := os.MkdirAll(journals.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	journal.Path = filepath.Join(journals.Dir, "organize-"+journal.Time.Format("20060102-150405.000")+".json")
+	return ioutil.WriteFile(journal.Path, data, 0644)
+}
+
+// sanitizeName makes a text fit for a file or directory name: the
+// characters not allowed in a name on some system, and the control
+// characters, are replaced by underscores, the blanks and dots at its
+// ends are removed, and it is cut to maxNameLength bytes.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	for len(name) > maxNameLength {
+		name = strings.TrimRightFunc(name[:maxNameLength], func(r rune) bool {
+			return r == unicode.ReplacementChar
+		})
+		name = strings.Trim(name, " .")
+	}
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// checkTemplate tells whether a template only has known fields, and
+// does not lead out of the root.
+func checkTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return errors.New("the template is empty")
+	}
+	for _, match := range filenameField.FindAllStringSubmatch(template, -1) {
+		if _, ok := templateValues(NewRola())[match[1]]; !ok {
+			return fmt.Errorf("unknown field %s in the template", match[0])
+		}
+	}
+	if filepath.IsAbs(template) {
+		return errors.New("the template must be relative to the root")
+	}
+	return nil
+}
+
+// templateValues returns the values of the fields of a Rola that can be
+// used in a template.   The album artist is the artist if the Rola has
+// none, the track has two digits, and the numbers that are 0 are empty.
+func templateValues(rola *Rola) map[string]string {
+	number := func(n int, format string) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf(format, n)
+	}
+	albumArtist := rola.AlbumArtist()
+	if albumArtist == "" {
+		albumArtist = rola.Artist()
+	}
+	return map[string]string{
+		FieldArtist:      rola.Artist(),
+		FieldAlbum:       rola.Album(),
+		FieldTitle:       rola.Title(),
+		FieldGenre:       rola.Genre(),
+		FieldAlbumArtist: albumArtist,
+		FieldComposer:    rola.Composer(),
+		FieldTrack:       number(rola.Track(), "%02d"),
+		FieldYear:        number(rola.Year(), "%d"),
+		FieldDisc:        number(rola.Disc(), "%d"),
+	}
+}
+
+// organizedPath returns the path, relative to the root and without
+// extension, of a Rola organized with a template.   Each directory and
+// the name of the file are sanitized, and left without the empty
+// parentheses or brackets of the empty fields.
+func organizedPath(rola *Rola, template string) string {
+	values := templateValues(rola)
+	parts := strings.Split(filepath.ToSlash(template), "/")
+	for i, part := range parts {
+		part = filenameField.ReplaceAllStringFunc(part, func(field string) string {
+			return strings.Replace(values[strings.Trim(field, "%")], "/", "_", -1)
+		})
+		for _, empty := range []string{"()", "[]"} {
+			part = strings.Replace(part, empty, "", -1)
+		}
+		part = strings.Join(strings.Fields(part), " ")
+		part = strings.Trim(part, " -_")
+		if part == ".." {
+			part = "_"
+		}
+		parts[i] = sanitizeName(part)
+	}
+	return filepath.Join(parts...)
+}
+
+// PlanOrganize returns the moves that organize the files of the Rolas
+// with the given IDs, or of every Rola under the root if none is given,
+// under the root with a template such as DefaultOrganizeTemplate.   The
+// extension of a file is kept, in lowercase.   A number is added to a
+// path taken by another file or Rola, or by another move; the Rolas
+// already in place, and the tracks of CUE sheets, are left out.   Nothing
+// is moved.
+func (database *Database) PlanOrganize(root, template string, ids []int64) ([]*OrganizeMove, error) {
+	err := checkTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool)
+	rows, err := database.Database.Query("SELECT path FROM rolas")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var path string
+		err = rows.Scan(&path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		taken[path] = true
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		// LIKE ignores the case, so the prefix is compared with instr.
+		ids = database.QueryCustom("SELECT id_rola FROM rolas WHERE instr(path, ?) = 1 "+
+			"AND container = '' ORDER BY path", root+string(filepath.Separator))
+	}
+
+	moves := make([]*OrganizeMove, 0)
+	for _, id := range ids {
+		rola := database.QueryRola(id)
+		if rola.IsCueTrack() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(rola.Path()))
+		base := filepath.Join(root, organizedPath(rola, template))
+		target := base + ext
+		for i := 2; target != rola.Path(); i++ {
+			if _, err := os.Stat(target); !taken[target] && os.IsNotExist(err) {
+				break
+			}
+			target = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		if target == rola.Path() {
+			continue
+		}
+		taken[target] = true
+		moves = append(moves, &OrganizeMove{RolaID: id, From: rola.Path(), To: target})
+	}
+	return moves, nil
+}
+
+// Organize moves the files as the moves say, after writing them to a
+// journal, and updates the paths of the Rolas and their albums in the
+// database in one transaction.   If a file can not be moved, or the
+// database can not be updated, the files already moved are moved back,
+// the journal is removed and nothing changes.   The directories left
+// empty under the root are removed.
+func (database *Database) Organize(journals *Journals, root, template string, moves []*OrganizeMove) (*OrganizeJournal, error) {
+	journal := &OrganizeJournal{Time: time.Now(), Root: root, Template: template, Moves: moves}
+	err := journals.write(journal)
+	if err != nil {
+		return nil, err
+	}
+	err = database.relocate(moves, root)
+	if err != nil {
+		os.Remove(journal.Path)
+		return nil, err
+	}
+	return journal, nil
+}
+
+// UndoOrganize moves back the files moved by a run of the organizer, as
+// recorded in its journal, and removes the journal.   A file is moved
+// back only if it is still where it was moved to, and nothing is where
+// it was.
+func (database *Database) UndoOrganize(journal *OrganizeJournal) error {
+	moves := make([]*OrganizeMove, 0, len(journal.Moves))
+	for i := len(journal.Moves) - 1; i >= 0; i-- {
+		move := journal.Moves[i]
+		_, errTo := os.Stat(move.To)
+		_, errFrom := os.Stat(move.From)
+		if errTo == nil && os.IsNotExist(errFrom) && database.QueryPath(move.RolaID) == move.To {
+			moves = append(moves, &OrganizeMove{RolaID: move.RolaID, From: move.To, To: move.From})
+		}
+	}
+	err := database.relocate(moves, journal.Root)
+	if err != nil {
+		return err
+	}
+	return os.Remove(journal.Path)
+}
+
+// relocate moves the files, and then updates the database; if anything
+// fails, the moved files are moved back.
+func (database *Database) relocate(moves []*OrganizeMove, root string) error {
+	done := make([]*OrganizeMove, 0, len(moves))
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			renameFile(done[i].To, done[i].From)
+		}
+	}
+	for _, move := range moves {
+		err := os.MkdirAll(filepath.Dir(move.To), 0755)
+		if err == nil {
+			if _, statErr := os.Stat(move.To); statErr == nil {
+				err = fmt.Errorf("%s already exists", move.To)
+			}
+		}
+		if err == nil {
+			err = renameFile(move.From, move.To)
+		}
+		if err != nil {
+			undo()
+			return err
+		}
+		done = append(done, move)
+	}
+
+	err := database.updatePaths(moves)
+	if err != nil {
+		undo()
+		return err
+	}
+	for _, move := range moves {
+		removeEmptyDirs(filepath.Dir(move.From), root)
+	}
+	return nil
+}
+
+// updatePaths updates the paths of the moved Rolas in one transaction.
+// An album lives in the directory of its Rolas, so the Rolas of an album
+// that end up in another directory are moved to the album with the same
+// name in that directory, which is made with the year and the artwork of
+// the album if it does not exist; the albums left without Rolas are
+// removed.   The albums are relocated from the oldest, so that the
+// albums merged in a directory keep the oldest ID, and undoing a move
+// gives back the albums it took.
+func (database *Database) updatePaths(moves []*OrganizeMove) error {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		return err
+	}
+	seen := make(map[int64]bool)
+	albums := make([]int64, 0)
+	for _, move := range moves {
+		var albumID int64
+		err = tx.QueryRow("SELECT id_album FROM rolas WHERE id_rola = ?", move.RolaID).Scan(&albumID)
+		if err == nil {
+			_, err = tx.Exec("UPDATE rolas SET path = ? WHERE id_rola = ?", move.To, move.RolaID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !seen[albumID] {
+			seen[albumID] = true
+			albums = append(albums, albumID)
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i] < albums[j] })
+	for _, albumID := range albums {
+		err = relocateAlbum(tx, albumID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// relocateAlbum moves an album to the directory of its Rolas, splitting
+// it if they are in several directories.   The album keeps its ID if it
+// keeps some of its Rolas.
+func relocateAlbum(tx *sql.Tx, albumID int64) error {
+	var path, name string
+	err := tx.QueryRow("SELECT coalesce(path, ''), coalesce(name, '') FROM albums WHERE id_album = ?",
+		albumID).Scan(&path, &name)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT id_rola, path FROM rolas WHERE id_album = ?", albumID)
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string][]int64)
+	for rows.Next() {
+		var id int64
+		var rolaPath string
+		err = rows.Scan(&id, &rolaPath)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		dirs[filepath.Dir(rolaPath)] = append(dirs[filepath.Dir(rolaPath)], id)
+	}
+	rows.Close()
+	if len(dirs) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+	keep := names[0]
+	if _, ok := dirs[path]; ok {
+		keep = path
+	}
+
+	for _, dir := range names {
+		target, err := albumAt(tx, dir, name)
+		if err != nil {
+			return err
+		}
+		if target == 0 && dir == keep {
+			_, err = tx.Exec("UPDATE albums SET path = ? WHERE id_album = ?", dir, albumID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if target == 0 {
+			result, err := tx.Exec("INSERT INTO albums (path, name, year, artwork) "+
+				"SELECT ?, name, year, artwork FROM albums WHERE id_album = ?", dir, albumID)
+			if err == nil {
+				target, err = result.LastInsertId()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if target == albumID {
+			continue
+		}
+		for _, id := range dirs[dir] {
+			_, err = tx.Exec("UPDATE rolas SET id_album = ? WHERE id_rola = ?", target, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = tx.Exec("DELETE FROM albums WHERE id_album = ? "+
+		"AND NOT EXISTS (SELECT 1 FROM rolas WHERE id_album = ?)", albumID, albumID)
+	return err
+}
+
+// albumAt returns the ID of the album with the given name in a
+// directory, which may be the album being relocated, or 0 if there is
+// none.
+func albumAt(tx *sql.Tx, dir, name string) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id_album FROM albums WHERE path = ? AND name = ?", dir, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// removeEmptyDirs removes a directory if it is empty, and then its
+// parents, up to the root, which is never removed.
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil || len(files) > 0 || os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrganizedPath(t *testing.T) {
+	rola := NewRola()
+	rola.SetArtist("AC/DC")
+	rola.SetAlbum("Back in Black")
+	rola.SetTitle("Hells Bells?")
+	rola.SetTrack(1)
+	rola.SetYear(1980)
+	received := organizedPath(rola, DefaultOrganizeTemplate)
+	expected := filepath.Join("AC_DC", "Back in Black (1980)", "01 - Hells Bells_")
+	if received != expected {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+
+	rola.SetYear(0)
+	rola.SetTrack(0)
+	rola.SetAlbumArtist("Various Artists")
+	received = organizedPath(rola, "%albumartist%/%album% [%disc%] (%year%)/%track% - %title%")
+	expected = filepath.Join("Various Artists", "Back in Black", "Hells Bells_")
+	if received != expected {
+		t.Errorf("expecting %v, received %v", expected, received)
+	}
+
+	rola.SetAlbum("..")
+	received = organizedPath(rola, "%album%/%title%")
+	if received != filepath.Join("_", "Hells Bells_") {
+		t.Errorf("expecting %v, received %v", "_/Hells Bells_", received)
+	}
+
+	if err := checkTemplate("%artist%/%name%"); err == nil {
+		t.Errorf("expecting %v, received %v", "an error", err)
+	}
+	if err := checkTemplate("/%artist%/%title%"); err == nil {
+		t.Errorf("expecting %v, received %v", "an error", err)
+	}
+	if err := checkTemplate(DefaultOrganizeTemplate); err != nil {
+		t.Errorf("expecting %v, received %v", nil, err)
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"What's Going On?", "What's Going On_"},
+		{`a<b>c:d"e|f*g\h`, "a_b_c_d_e_f_g_h"},
+		{"  trailing dots... ", "trailing dots"},
+		{"tab\there", "tab_here"},
+		{"...", "_"},
+		{"", "_"},
+	}
+	for _, c := range cases {
+		if received := sanitizeName(c.name); received != c.expected {
+			t.Errorf("expecting %v, received %v", c.expected, received)
+		}
+	}
+	long := sanitizeName("a" + strings.Repeat("ñ", maxNameLength))
+	if len(long) > maxNameLength || strings.ContainsRune(long, '�') {
+		t.Errorf("expecting %v, received %v", "a name cut at a character", len(long))
+	}
+}
+
+// libraryRows returns the paths and the albums of the Rolas, and the
+// paths and the names of the albums, of a database.
+func libraryRows(t *testing.T, database *Database) []string {
+	rows, err := database.Database.Query("SELECT 'rola', id_rola, path, id_album FROM rolas " +
+		"UNION ALL SELECT 'album', id_album, path, name FROM albums ORDER BY 1, 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rows.Close()
+	result := make([]string, 0)
+	for rows.Next() {
+		var kind, path, value string
+		var id int64
+		err = rows.Scan(&kind, &id, &path, &value)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result = append(result, strings.Join([]string{kind, path, value}, " "))
+	}
+	return result
+}
+
+func TestOrganize(t *testing.T) {
+	database, root, clean := minedTestLibrary(t, 3)
+	defer clean()
+	journals := &Journals{Dir: filepath.Join(root, "cache", "journals")}
+	original := libraryRows(t, database)
+	template := "%track%/%title%"
+
+	moves, err := database.PlanOrganize(strings.ToUpper(root), template, nil)
+	if err != nil || len(moves) != 0 {
+		t.Errorf("expecting %v, received %v", 0, len(moves))
+	}
+	moves, err = database.PlanOrganize(root, template, nil)
+	if err != nil || len(moves) != 3 {
+		t.Fatalf("expecting %v, received %v", 3, len(moves))
+	}
+	expected := filepath.Join(root, "02", "Artist 2 - Title 2.mp3")
+	if moves[1].To != expected {
+		t.Errorf("expecting %v, received %v", expected, moves[1].To)
+	}
+
+	blocked := moves[2].To
+	os.MkdirAll(filepath.Dir(blocked), 0755)
+	ioutil.WriteFile(blocked, []byte{}, 0644)
+	_, err = database.Organize(journals, root, template, moves)
+	if err == nil {
+		t.Errorf("expecting an error for a file in the way")
+	}
+	for _, move := range moves[:2] {
+		if _, err := os.Stat(move.From); err != nil {
+			t.Errorf("expecting %v, received %v", move.From, err)
+		}
+	}
+	if rows := libraryRows(t, database); !reflect.DeepEqual(rows, original) {
+		t.Errorf("expecting %v, received %v", original, rows)
+	}
+	if list, _ := journals.List(); len(list) != 0 {
+		t.Errorf("expecting %v, received %v", 0, len(list))
+	}
+	os.RemoveAll(filepath.Dir(blocked))
+
+	journal, err := database.Organize(journals, root, template, moves)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, move := range moves {
+		if _, err := os.Stat(move.To); err != nil {
+			t.Errorf("expecting %v, received %v", move.To, err)
+		}
+		if path := database.QueryPath(move.RolaID); path != move.To {
+			t.Errorf("expecting %v, received %v", move.To, path)
+		}
+	}
+	var albums int
+	database.Database.QueryRow("SELECT count(*) FROM albums WHERE path = ?", filepath.Dir(expected)).Scan(&albums)
+	if albums != 1 {
+		t.Errorf("expecting %v, received %v", 1, albums)
+	}
+
+	err = database.UndoOrganize(journal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, move := range moves {
+		if _, err := os.Stat(filepath.Dir(move.To)); !os.IsNotExist(err) {
+			t.Errorf("expecting %v to be removed", filepath.Dir(move.To))
+		}
+	}
+	if rows := libraryRows(t, database); !reflect.DeepEqual(rows, original) {
+		t.Errorf("expecting %v, received %v", original, rows)
+	}
+	if _, err := os.Stat(journal.Path); !os.IsNotExist(err) {
+		t.Errorf("expecting %v to be removed", journal.Path)
+	}
+}
@@ -1,7 +1,9 @@
 package model
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 )
 
 // Parser for the search bar of the main application window.
@@ -11,31 +13,51 @@ import (
 //in the field between asterisks, followed by '=', '~', '<', or
 // '>' for an exact search, a wildcard search, or for certain
 // ranges (for numeric fields), respectively, e.g., '*AR*~punk'
-// searches for all artists containing 'punk' in their name.
+// searches for all artists containing 'punk' in their name; a Rola is
+// found under its main artist and under every performer credited in it,
+// and under each of its genres with '*GE*'; '*GS*' finds it under each
+// of its genres and the genres they are subgenres of, so that
+// '*GS*=Metal' finds the Rolas of Death Metal as well.
+// Album artist, composer and comment are searched with '*AA*',
+// '*CO*' and '*CM*', respectively; the duration (in seconds) with
+// '*DU*', the bitrate (in kbps) with '*BR*', the sample rate with
+// '*SA*', the number of channels with '*CH*', and '*VB*=1' finds
+// the Rolas with variable bitrate.
 // There are negated versions of the four operators, '!=', etc.
+// The dates of the performers are searched with '*BI*' and '*DE*'
+// for the birth and death of a person, and '*ST*' and '*EN*' for
+// the start and end of a group; they take partial dates, so that
+// '*BI*<1950' finds the persons born before 1950 and '*ST*=1977-05'
+// the groups started in May 1977.   '*AC*=1977' finds the groups
+// active in 1977, and '*AG*' searches the age of the performer
+// when the Rola was recorded.
 // The parser joins the atomic formulas to get a formula in
-// disjunctive normal form.
+// disjunctive normal form; the hidden Rolas are never found.
 type Parser struct {
 	stmt string
 }
 
-var instanceP *Parser
+var (
+	instanceP *Parser
+	onceP     sync.Once
+)
 
-// GetParser returns the singleton instance of Parser. The
-// singleton implementation is not thread safe due to conflicts
-// with gtk.
+// GetParser returns the singleton instance of Parser, safe for
+// concurrent use.
 func GetParser() *Parser {
-	if instanceP == nil {
+	onceP.Do(func() {
 		instanceP = &Parser{
 			`SELECT
            rolas.id_rola
          FROM
-           rolas
+           (SELECT * FROM rolas WHERE hidden = 0) AS rolas
          INNER JOIN performers ON performers.id_performer = rolas.id_performer
          INNER JOIN albums ON albums.id_album = rolas.id_album
+         LEFT JOIN persons ON persons.id_person = performers.id_person
+         LEFT JOIN groups ON groups.id_group = performers.id_group
          WHERE `,
 		}
-	}
+	})
 	return instanceP
 }
 
@@ -52,7 +74,7 @@ func (parser *Parser) Parse(entry string) (string, []interface{}, bool) {
 	andLayer := make([]string, 0)
 	for _, term := range tempLayer {
 		term = strings.TrimSpace(term)
-		if isParseable(term) {
+		if isParseable(strings.Split(term, "&&")[0]) {
 			orLayer = append(orLayer, term)
 		}
 	}
@@ -74,271 +96,218 @@ func (parser *Parser) Parse(entry string) (string, []interface{}, bool) {
 		return "", queryTerms, false
 	}
 	for i, term := range andLayer {
-		switch {
-		case term == "||":
+		if term == "||" {
 			if hasNext(i, andLayer) {
 				statement = statement + ") OR ( "
 			}
+			continue
+		}
+		condition, values := atom(term)
+		if hasNext(i, andLayer) {
+			statement = statement + condition + " AND "
+		} else {
+			statement = statement + condition + " "
+		}
+		queryTerms = append(queryTerms, values...)
+	}
+	statement = parser.stmt + statement + ")"
+	return statement, queryTerms, true
+}
 
-		case strings.HasPrefix(term, "*TI*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.title LIKE ? AND "
-			} else {
-				statement = statement + "rolas.title LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*TI*~"))))
-
-		case strings.HasPrefix(term, "*TI*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.title = ? AND "
-			} else {
-				statement = statement + "rolas.title = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TI*=")))
-
-		case strings.HasPrefix(term, "*TI*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.title LIKE ? AND "
-			} else {
-				statement = statement + "NOT rolas.title LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*TI*!~"))))
-
-		case strings.HasPrefix(term, "*TI*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.title = ? AND "
-			} else {
-				statement = statement + "NOT rolas.title = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TI*!=")))
-
-		case strings.HasPrefix(term, "*AR*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "performers.name LIKE ? AND "
-			} else {
-				statement = statement + "performers.name LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*AR*~"))))
-
-		case strings.HasPrefix(term, "*AR*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "performers.name = ? AND "
-			} else {
-				statement = statement + "performers.name = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*AR*=")))
-
-		case strings.HasPrefix(term, "*AR*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT performers.name LIKE ? AND "
-			} else {
-				statement = statement + "NOT performers.name LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*AR*!~"))))
-
-		case strings.HasPrefix(term, "*AR*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT performers.name = ? AND "
-			} else {
-				statement = statement + "NOT performers.name = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*AR*!=")))
-
-		case strings.HasPrefix(term, "*AL*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "albums.name LIKE ? AND "
-			} else {
-				statement = statement + "albums.name LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*AL*~"))))
-
-		case strings.HasPrefix(term, "*AL*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "albums.name = ? AND "
-			} else {
-				statement = statement + "albums.name = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*AL*=")))
-
-		case strings.HasPrefix(term, "*AL*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT albums.name LIKE ? AND "
-			} else {
-				statement = statement + "NOT albums.name LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*AL*!~"))))
-
-		case strings.HasPrefix(term, "*AL*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT albums.name = ? AND "
-			} else {
-				statement = statement + "NOT albums.name = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*AL*!=")))
-
-		case strings.HasPrefix(term, "*TR*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track LIKE ? AND "
-			} else {
-				statement = statement + "rolas.track LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*TR*~"))))
-
-		case strings.HasPrefix(term, "*TR*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track = ? AND "
-			} else {
-				statement = statement + "rolas.track = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*=")))
-
-		case strings.HasPrefix(term, "*TR*<"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track < ? AND "
-			} else {
-				statement = statement + "rolas.track < ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*<")))
-
-		case strings.HasPrefix(term, "*TR*>"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track > ? AND "
-			} else {
-				statement = statement + "rolas.track > ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*>")))
-
-		case strings.HasPrefix(term, "*TR*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.track LIKE ? AND "
-			} else {
-				statement = statement + "NOT rolas.track LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*TR*!~"))))
-
-		case strings.HasPrefix(term, "*TR*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.track = ? AND "
-			} else {
-				statement = statement + "NOT rolas.track = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*!=")))
-
-		case strings.HasPrefix(term, "*TR*!<"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track >= ? AND "
-			} else {
-				statement = statement + "rolas.track >= ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*!<")))
-
-		case strings.HasPrefix(term, "*TR*!>"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.track <= ? AND "
-			} else {
-				statement = statement + "rolas.track <= ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*TR*!>")))
-
-		case strings.HasPrefix(term, "*GE*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.genre LIKE ? AND "
-			} else {
-				statement = statement + "rolas.genre LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*GE*~"))))
-
-		case strings.HasPrefix(term, "*GE*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.genre = ? AND "
-			} else {
-				statement = statement + "rolas.genre = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*GE*=")))
+// textFrames maps the frames of the text fields of a Rola to the
+// columns where they are searched.
+var textFrames = map[string]string{
+	"*TI*": "rolas.title",
+	"*AR*": "performers.name",
+	"*AL*": "albums.name",
+	"*GE*": "rolas.genre",
+	"*AA*": "rolas.album_artist",
+	"*CO*": "rolas.composer",
+	"*CM*": "rolas.comment",
+	"*LY*": "rolas.lyrics",
+}
 
-		case strings.HasPrefix(term, "*GE*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.genre LIKE ? AND "
-			} else {
-				statement = statement + "NOT rolas.genre LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*GE*!~"))))
+// numericFrames maps the frames of the numeric fields of a Rola to the
+// columns where they are searched.
+var numericFrames = map[string]string{
+	"*TR*": "rolas.track",
+	"*YE*": "rolas.year",
+	"*DI*": "rolas.disc",
+	"*BP*": "rolas.bpm",
+	"*DU*": "rolas.duration",
+	"*BR*": "rolas.bitrate",
+	"*SA*": "rolas.sample_rate",
+	"*CH*": "rolas.channels",
+	"*VB*": "rolas.vbr",
+	"*AG*": "CAST(CASE WHEN persons.birth_date <> '' AND rolas.year > 0 " +
+		"THEN rolas.year - CAST(substr(persons.birth_date, 1, 4) AS INTEGER) END AS INTEGER)",
+}
 
-		case strings.HasPrefix(term, "*GE*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.genre = ? AND "
-			} else {
-				statement = statement + "NOT rolas.genre = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*GE*!=")))
+// dateFrames maps the frames of the dates of the performers to the
+// columns where they are searched; the dates are stored as partial
+// dates in ISO 8601, which sort as text.
+var dateFrames = map[string]string{
+	"*BI*": "persons.birth_date",
+	"*DE*": "persons.death_date",
+	"*ST*": "groups.start_date",
+	"*EN*": "groups.end_date",
+}
 
-		case strings.HasPrefix(term, "*YE*~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.YEAR LIKE ? AND "
-			} else {
-				statement = statement + "rolas.YEAR LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*YE*~"))))
+// activeFrame is the frame of the groups active in a partial date.
+const activeFrame = "*AC*"
+
+// relationFrames maps the frames of the fields a Rola may have several
+// values of to the functions returning their conditions: a Rola is found
+// under every performer credited in it, under each of its genres, and
+// under the genres they are subgenres of.
+var relationFrames = map[string]func(string) string{
+	"*AR*": performsCondition,
+	"*GE*": genresCondition,
+	"*GS*": subgenresCondition,
+}
 
-		case strings.HasPrefix(term, "*YE*="):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.year = ? AND "
-			} else {
-				statement = statement + "rolas.year = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*=")))
+// textOperators are the operators accepted by the text frames, and
+// numericOperators the ones accepted by the numeric frames.   The
+// negated operators come first, so that '!~' is not taken for '~'.
+var (
+	textOperators    = []string{"!~", "!=", "~", "="}
+	numericOperators = []string{"!~", "!=", "!<", "!>", "~", "=", "<", ">"}
+	activeOperators  = []string{"!=", "="}
+)
 
-		case strings.HasPrefix(term, "*YE*<"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.year < ? AND "
-			} else {
-				statement = statement + "rolas.year < ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*<")))
+// conditions maps each operator to the sqlite condition it stands for,
+// the column goes in place of the %s.
+var conditions = map[string]string{
+	"~":  "%s LIKE ?",
+	"=":  "%s = ?",
+	"<":  "%s < ?",
+	">":  "%s > ?",
+	"!~": "NOT %s LIKE ?",
+	"!=": "NOT %s = ?",
+	"!<": "%s >= ?",
+	"!>": "%s <= ?",
+}
 
-		case strings.HasPrefix(term, "*YE*>"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.year > ? AND "
-			} else {
-				statement = statement + "rolas.year > ? "
+// split separates a parseable term in its frame, its operator and the
+// searched value.
+func split(entry string) (string, string, string, bool) {
+	entry = strings.TrimSpace(entry)
+	if len(entry) < 4 {
+		return "", "", "", false
+	}
+	frame := entry[:4]
+	operators := textOperators
+	_, isDate := dateFrames[frame]
+	switch {
+	case frame == activeFrame:
+		operators = activeOperators
+	case isDate:
+		operators = numericOperators
+	default:
+		_, isText := textFrames[frame]
+		_, isRelation := relationFrames[frame]
+		if !isText && !isRelation {
+			if _, ok := numericFrames[frame]; !ok {
+				return "", "", "", false
+			}
+			operators = numericOperators
+		}
+	}
+	for _, operator := range operators {
+		if !strings.HasPrefix(entry[4:], operator) {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(entry[4:], operator))
+		if (isDate && !strings.HasSuffix(operator, "~")) || frame == activeFrame {
+			date, err := NormalizeDate(value)
+			if err != nil || date == "" {
+				return "", "", "", false
 			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*>")))
+			value = date
+		}
+		return frame, operator, value, true
+	}
+	return "", "", "", false
+}
 
-		case strings.HasPrefix(term, "*YE*!~"):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.YEAR LIKE ? AND "
-			} else {
-				statement = statement + "NOT rolas.YEAR LIKE ? "
-			}
-			queryTerms = append(queryTerms, wildcard(strings.TrimSpace(strings.TrimPrefix(term, "*YE*!~"))))
+// atom translates a parseable term into its sqlite condition and the
+// values to be bound to it.
+func atom(term string) (string, []interface{}) {
+	frame, operator, value, _ := split(term)
+	if frame == activeFrame {
+		return activeAtom(operator, value)
+	}
+	if relation, ok := relationFrames[frame]; ok {
+		return relationAtom(relation, operator, value)
+	}
+	if column, ok := dateFrames[frame]; ok && !strings.HasSuffix(operator, "~") {
+		return dateAtom(column, operator, value)
+	}
+	column, ok := textFrames[frame]
+	if !ok {
+		column, ok = numericFrames[frame]
+	}
+	if !ok {
+		column = dateFrames[frame]
+	}
+	if strings.HasSuffix(operator, "~") {
+		return fmt.Sprintf(conditions[operator], column), []interface{}{wildcard(value)}
+	}
+	return fmt.Sprintf(conditions[operator], column), []interface{}{value}
+}
 
-		case strings.HasPrefix(term, "*YE*!="):
-			if hasNext(i, andLayer) {
-				statement = statement + "NOT rolas.year = ? AND "
-			} else {
-				statement = statement + "NOT rolas.year = ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*!=")))
+// dateAtom translates the term of a date frame.   A partial date covers
+// every date from itself up to itself followed by '~', which sorts after
+// the digits and '-', so that '1977' covers '1977-05-21'; the unknown
+// dates, which are empty, never match.
+func dateAtom(column, operator, date string) (string, []interface{}) {
+	end := date + "~"
+	switch operator {
+	case "<":
+		return fmt.Sprintf("(%s <> '' AND %s < ?)", column, column), []interface{}{date}
+	case ">":
+		return fmt.Sprintf("%s > ?", column), []interface{}{end}
+	case "!<":
+		return fmt.Sprintf("%s >= ?", column), []interface{}{date}
+	case "!>":
+		return fmt.Sprintf("(%s <> '' AND %s < ?)", column, column), []interface{}{end}
+	case "!=":
+		return fmt.Sprintf("(%s <> '' AND NOT (%s >= ? AND %s < ?))", column, column, column),
+			[]interface{}{date, end}
+	}
+	return fmt.Sprintf("(%s >= ? AND %s < ?)", column, column), []interface{}{date, end}
+}
 
-		case strings.HasPrefix(term, "*YE*!<"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.year >= ? AND "
-			} else {
-				statement = statement + "rolas.year >= ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*!<")))
+// relationAtom translates the term of a field a Rola may have several
+// values of, with the function returning its condition, binding the
+// value to each of its '?'; a negated term finds the Rolas where no
+// value satisfies the positive one.
+func relationAtom(relation func(string) string, operator, value string) (string, []interface{}) {
+	positive := strings.TrimPrefix(operator, "!")
+	if positive == "~" {
+		value = wildcard(value)
+	}
+	condition := relation(strings.TrimSpace(fmt.Sprintf(conditions[positive], "")))
+	if positive != operator {
+		condition = "NOT " + condition
+	}
+	values := make([]interface{}, strings.Count(condition, "?"))
+	for i := range values {
+		values[i] = value
+	}
+	return condition, values
+}
 
-		case strings.HasPrefix(term, "*YE*!>"):
-			if hasNext(i, andLayer) {
-				statement = statement + "rolas.year <= ? AND "
-			} else {
-				statement = statement + "rolas.year <= ? "
-			}
-			queryTerms = append(queryTerms, strings.TrimSpace(strings.TrimPrefix(term, "*YE*!>")))
-		}
+// activeAtom translates the term of the groups active in a partial
+// date: started before its end and not ended before its start.   The
+// groups without a start date are never taken as active.
+func activeAtom(operator, date string) (string, []interface{}) {
+	condition := "(groups.start_date <> '' AND groups.start_date < ? AND " +
+		"(coalesce(groups.end_date, '') = '' OR groups.end_date >= ?))"
+	if operator == "!=" {
+		condition = "NOT " + condition
 	}
-	statement = parser.stmt + statement + ")"
-	return statement, queryTerms, true
+	return condition, []interface{}{date + "~", date}
 }
 
 func wildcard(entry string) string {
@@ -353,24 +322,6 @@ func hasNext(i int, slice []string) bool {
 }
 
 func isParseable(entry string) bool {
-	entry = strings.TrimSpace(entry)
-	frames := []string{"*TI*", "*AR*", "*AL*", "*GE*"}
-	operators := []string{"~", "=", "!~", "!="}
-	for _, frame := range frames {
-		for _, operator := range operators {
-			if strings.HasPrefix(entry, frame+operator) {
-				return true
-			}
-		}
-	}
-	frames = []string{"*TR*", "*YE*"}
-	operators = []string{"~", "=", "<", ">", "!~", "!=", "!<", "!>"}
-	for _, frame := range frames {
-		for _, operator := range operators {
-			if strings.HasPrefix(entry, frame+operator) {
-				return true
-			}
-		}
-	}
-	return false
+	_, _, _, ok := split(entry)
+	return ok
 }
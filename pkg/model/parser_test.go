@@ -0,0 +1,130 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSimple(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("beatles")
+	if ok {
+		t.Errorf("expecting %v, received %v", false, ok)
+	}
+	if stmt != "beatles" {
+		t.Errorf("expecting %v, received %v", "beatles", stmt)
+	}
+	if len(terms) != 0 {
+		t.Errorf("expecting %v, received %v", 0, len(terms))
+	}
+}
+
+func TestParseCustom(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("*~* *TI*!~me && *AR*= The Beatles && *YE*<1968 || *TR*!> 3")
+	if !ok {
+		t.Errorf("expecting %v, received %v", true, ok)
+	}
+	expecting := "( NOT rolas.title LIKE ? AND " + performsCondition("= ?") + " AND rolas.year < ? ) OR ( rolas.track <= ? )"
+	if !strings.HasSuffix(stmt, expecting) {
+		t.Errorf("expecting %v, received %v", expecting, stmt)
+	}
+	values := []string{"%me%", "The Beatles", "The Beatles", "1968", "3"}
+	if len(terms) != len(values) {
+		t.Fatalf("expecting %v, received %v", len(values), len(terms))
+	}
+	for i, value := range values {
+		if terms[i] != value {
+			t.Errorf("expecting %v, received %v", value, terms[i])
+		}
+	}
+}
+
+func TestParseExtendedTags(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("*~* *CO*~Lennon && *DI*=2 && *BP*!<120 && *LY*~love")
+	if !ok {
+		t.Errorf("expecting %v, received %v", true, ok)
+	}
+	expecting := "( rolas.composer LIKE ? AND rolas.disc = ? AND rolas.bpm >= ? AND rolas.lyrics LIKE ? )"
+	if !strings.HasSuffix(stmt, expecting) {
+		t.Errorf("expecting %v, received %v", expecting, stmt)
+	}
+	if len(terms) != 4 || terms[0] != "%Lennon%" || terms[3] != "%love%" {
+		t.Errorf("unexpected terms %v", terms)
+	}
+}
+
+func TestParseGenres(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("*~* *GE*=Rock && *GE*!~pop")
+	if !ok {
+		t.Errorf("expecting %v, received %v", true, ok)
+	}
+	expecting := "( " + genresCondition("= ?") + " AND NOT " + genresCondition("LIKE ?") + " )"
+	if !strings.HasSuffix(stmt, expecting) {
+		t.Errorf("expecting %v, received %v", expecting, stmt)
+	}
+	values := []string{"Rock", "Rock", "%pop%", "%pop%"}
+	if len(terms) != len(values) {
+		t.Fatalf("expecting %v, received %v", len(values), len(terms))
+	}
+	for i, value := range values {
+		if terms[i] != value {
+			t.Errorf("expecting %v, received %v", value, terms[i])
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	parser := GetParser()
+	_, _, ok := parser.Parse("*~* *XX*=nothing || *TI*<5")
+	if ok {
+		t.Errorf("expecting %v, received %v", false, ok)
+	}
+}
+
+func TestParseAudioProperties(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("*~* *DU*>600 && *VB*=1")
+	if !ok {
+		t.Errorf("expecting %v, received %v", true, ok)
+	}
+	expecting := "( rolas.duration > ? AND rolas.vbr = ? )"
+	if !strings.HasSuffix(stmt, expecting) {
+		t.Errorf("expecting %v, received %v", expecting, stmt)
+	}
+	if len(terms) != 2 || terms[0] != "600" {
+		t.Errorf("unexpected terms %v", terms)
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	parser := GetParser()
+	stmt, terms, ok := parser.Parse("*~* *BI*<1950 && *ST*=1977-5 || *AC*=1977")
+	if !ok {
+		t.Errorf("expecting %v, received %v", true, ok)
+	}
+	expecting := "( (persons.birth_date <> '' AND persons.birth_date < ?) AND " +
+		"(groups.start_date >= ? AND groups.start_date < ?) ) OR ( " +
+		"(groups.start_date <> '' AND groups.start_date < ? AND " +
+		"(coalesce(groups.end_date, '') = '' OR groups.end_date >= ?)) )"
+	if !strings.HasSuffix(stmt, expecting) {
+		t.Errorf("expecting %v, received %v", expecting, stmt)
+	}
+	values := []string{"1950", "1977-05", "1977-05~", "1977~", "1977"}
+	if len(terms) != len(values) {
+		t.Fatalf("expecting %v, received %v", len(values), len(terms))
+	}
+	for i, value := range values {
+		if terms[i] != value {
+			t.Errorf("expecting %v, received %v", value, terms[i])
+		}
+	}
+	if _, _, ok := parser.Parse("*~* *DE*<yesterday"); ok {
+		t.Errorf("expecting %v, received %v", false, ok)
+	}
+	if _, _, ok := parser.Parse("*~* *AC*<1977"); ok {
+		t.Errorf("expecting %v, received %v", false, ok)
+	}
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+)
+
+// DecodeAudio decodes at most the given number of seconds from the
+// beginning of the MP3 or FLAC stream in the reader, and returns them as
+// mono samples between -1 and 1, with their sample rate.
+func DecodeAudio(reader io.ReadSeeker, seconds int) ([]float64, int, error) {
+	var samples []float64
+	sampleRate, limit := 0, 0
+	err := streamAudio(reader, func(rate int, frame []float64) bool {
+		if samples == nil {
+			sampleRate, limit = rate, seconds*rate
+			samples = make([]float64, 0, limit)
+		}
+		sum := 0.0
+		for _, sample := range frame {
+			sum += sample
+		}
+		samples = append(samples, sum/float64(len(frame)))
+		return len(samples) < limit
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return samples, sampleRate, nil
+}
+
+// streamAudio decodes the MP3 or FLAC stream in the reader and passes
+// it to the sink one frame at a time, with its sample rate; a frame
+// holds a sample between -1 and 1 for each channel.   The decoding stops
+// at the end of the stream or when the sink returns false.
+func streamAudio(reader io.ReadSeeker, sink func(rate int, frame []float64) bool) error {
+	_, err := reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	start, err := skipID3v2(reader)
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(reader, magic)
+	if err != nil {
+		return err
+	}
+	_, err = reader.Seek(start, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	if string(magic) == "fLaC" {
+		return streamFLAC(reader, sink)
+	}
+	return streamMP3(reader, sink)
+}
+
+// streamMP3 decodes an MP3 stream, which the decoder always gives as
+// interleaved stereo samples of 16 bits in little endian.
+func streamMP3(reader io.Reader, sink func(rate int, frame []float64) bool) error {
+	decoder, err := mp3.NewDecoder(reader)
+	if err != nil {
+		return err
+	}
+	rate := decoder.SampleRate()
+	frame := make([]float64, 2)
+	buffer := make([]byte, 4*4096)
+	decoded := false
+	for {
+		n, err := io.ReadFull(decoder, buffer)
+		for i := 0; i+4 <= n; i += 4 {
+			frame[0] = float64(int16(uint16(buffer[i])|uint16(buffer[i+1])<<8)) / 32768
+			frame[1] = float64(int16(uint16(buffer[i+2])|uint16(buffer[i+3])<<8)) / 32768
+			decoded = true
+			if !sink(rate, frame) {
+				return nil
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !decoded {
+		return ErrUnknownFormat
+	}
+	return nil
+}
+
+// streamFLAC decodes a FLAC stream frame by frame.
+func streamFLAC(reader io.Reader, sink func(rate int, frame []float64) bool) error {
+	stream, err := flac.New(reader)
+	if err != nil {
+		return err
+	}
+	rate := int(stream.Info.SampleRate)
+	scale := float64(int64(1) << (stream.Info.BitsPerSample - 1))
+	samples := make([]float64, stream.Info.NChannels)
+	for {
+		frame, err := stream.ParseNext()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < frame.Subframes[0].NSamples; i++ {
+			for channel, subframe := range frame.Subframes {
+				samples[channel] = float64(subframe.Samples[i]) / scale
+			}
+			if !sink(rate, samples) {
+				return nil
+			}
+		}
+	}
+}
+
+// resample converts samples from one sample rate to a lower one,
+// averaging the samples that fall in each new sample, which keeps most
+// of the frequencies above the new Nyquist frequency out.
+func resample(samples []float64, from, to int) []float64 {
+	if from <= to {
+		return samples
+	}
+	count := int(int64(len(samples)) * int64(to) / int64(from))
+	resampled := make([]float64, count)
+	for i := range resampled {
+		start := int(int64(i) * int64(from) / int64(to))
+		end := int(int64(i+1) * int64(from) / int64(to))
+		if end > len(samples) {
+			end = len(samples)
+		}
+		sum := 0.0
+		for _, sample := range samples[start:end] {
+			sum += sample
+		}
+		if end > start {
+			resampled[i] = sum / float64(end-start)
+		}
+	}
+	return resampled
+}
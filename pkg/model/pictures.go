@@ -0,0 +1,455 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// PictureTypes are the names of the types of pictures defined by ID3v2,
+// indexed by their code.   FLAC uses the same codes.
+var PictureTypes = []string{
+	"Other",
+	"File icon",
+	"Other file icon",
+	"Front cover",
+	"Back cover",
+	"Leaflet page",
+	"Media",
+	"Lead artist",
+	"Artist",
+	"Conductor",
+	"Band",
+	"Composer",
+	"Lyricist",
+	"Recording location",
+	"During recording",
+	"During performance",
+	"Screen capture",
+	"Bright coloured fish",
+	"Illustration",
+	"Band logotype",
+	"Publisher logotype",
+}
+
+// FrontCover is the code of the picture type of front covers.
+const FrontCover = 3
+
+// A Picture is a picture embedded in the tag of a file, with its type
+// (one of the codes of PictureTypes), its MIME type, its description
+// and the contents of the image file.
+type Picture struct {
+	Type        byte
+	MIMEType    string
+	Description string
+	Data        []byte
+}
+
+// NewPicture returns a Picture of the given type with the contents of
+// an image file, guessing its MIME type from the contents.
+func NewPicture(data []byte, pictureType byte) *Picture {
+	return &Picture{
+		Type:     pictureType,
+		MIMEType: http.DetectContentType(data),
+		Data:     data,
+	}
+}
+
+// TypeName returns the name of the type of the picture.
+func (picture *Picture) TypeName() string {
+	if int(picture.Type) < len(PictureTypes) {
+		return PictureTypes[picture.Type]
+	}
+	return PictureTypes[0]
+}
+
+// Extension returns the usual extension, with the dot, of the image
+// file of the picture.
+func (picture *Picture) Extension() string {
+	switch picture.MIMEType {
+	case "image/png", "PNG":
+		return ".png"
+	case "image/gif", "GIF":
+		return ".gif"
+	}
+	return ".jpg"
+}
+
+// ReadPictures returns all the pictures embedded in the ID3v2 tag of an
+// MP3 file, or in the PICTURE blocks of a FLAC file, in the order they
+// are stored.
+func ReadPictures(path string) ([]*Picture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pictures := make([]*Picture, 0)
+	if isFLAC(file) {
+		blocks, _, err := readFLACBlocks(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if block.kind != flacPicture {
+				continue
+			}
+			picture, err := decodeFLACPicture(block.data)
+			if err == nil {
+				pictures = append(pictures, picture)
+			}
+		}
+		return pictures, nil
+	}
+
+	tag, err := readID3v2(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range tag.frames {
+		if frame.id != "APIC" && frame.id != "PIC" {
+			continue
+		}
+		data, err := frame.contents(tag.version)
+		if err != nil {
+			continue
+		}
+		picture, err := decodeAPIC(data, tag.version)
+		if err == nil {
+			pictures = append(pictures, picture)
+		}
+	}
+	return pictures, nil
+}
+
+// WritePictures replaces all the pictures embedded in the tag of an MP3
+// or FLAC file by the given ones, leaving the rest of the tag as it
+// was.   ID3v2.2 tags can not be written, and ErrUnsupportedTag is
+// returned for them.
+func WritePictures(path string, pictures []*Picture) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if isFLAC(file) {
+		blocks, size, err := readFLACBlocks(file)
+		if err != nil {
+			return err
+		}
+		kept := make([]*flacBlock, 0, len(blocks)+len(pictures))
+		for _, block := range blocks {
+			if block.kind != flacPicture && block.kind != flacPadding {
+				kept = append(kept, block)
+			}
+		}
+		for _, picture := range pictures {
+			kept = append(kept, &flacBlock{kind: flacPicture, data: encodeFLACPicture(picture)})
+		}
+		file.Close()
+		return writeFLACBlocks(path, kept, size)
+	}
+
+	tag, err := readID3v2(file)
+	if err != nil {
+		return err
+	}
+	if tag.version != 3 && tag.version != 4 {
+		return ErrUnsupportedTag
+	}
+	frames := make([]*id3Frame, 0, len(tag.frames)+len(pictures))
+	for _, frame := range tag.frames {
+		if frame.id != "APIC" {
+			frames = append(frames, frame)
+		}
+	}
+	for _, picture := range pictures {
+		frames = append(frames, &id3Frame{id: "APIC", data: encodeAPIC(picture, tag.version)})
+	}
+	tag.frames = frames
+	file.Close()
+	return writeID3v2(path, tag)
+}
+
+// ReplacePicture replaces the pictures of the same type as the given
+// picture in the tag of a file, keeping the other pictures.
+func ReplacePicture(path string, picture *Picture) error {
+	pictures, err := ReadPictures(path)
+	if err != nil {
+		return err
+	}
+	result := make([]*Picture, 0, len(pictures)+1)
+	for _, old := range pictures {
+		if old.Type != picture.Type {
+			result = append(result, old)
+		}
+	}
+	result = append(result, picture)
+	return WritePictures(path, result)
+}
+
+// decodeAPIC decodes the contents of an APIC frame, or of a PIC frame
+// for ID3v2.2 tags.
+func decodeAPIC(data []byte, version byte) (*Picture, error) {
+	invalid := errors.New("invalid picture frame")
+	if len(data) < 2 {
+		return nil, invalid
+	}
+	encoding := data[0]
+	picture := &Picture{}
+	data = data[1:]
+	if version == 2 {
+		if len(data) < 4 {
+			return nil, invalid
+		}
+		picture.MIMEType = string(data[:3])
+		data = data[3:]
+	} else {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 || end+1 >= len(data) {
+			return nil, invalid
+		}
+		picture.MIMEType = string(data[:end])
+		data = data[end+1:]
+	}
+	picture.Type = data[0]
+	data = data[1:]
+
+	if encoding == 1 || encoding == 2 {
+		end := 0
+		for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+			end += 2
+		}
+		if end+1 >= len(data) {
+			return nil, invalid
+		}
+		picture.Description = decodeUTF16(data[:end], encoding == 2)
+		data = data[end+2:]
+	} else {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, invalid
+		}
+		picture.Description = string(data[:end])
+		if encoding == 0 {
+			picture.Description = decodeLatin1(data[:end])
+		}
+		data = data[end+1:]
+	}
+	picture.Data = data
+	return picture, nil
+}
+
+// encodeAPIC encodes a picture as the contents of an APIC frame.   The
+// description is written in ISO-8859-1 when possible, and otherwise in
+// UTF-8 for ID3v2.4 and UTF-16 for ID3v2.3.
+func encodeAPIC(picture *Picture, version byte) []byte {
+	var buffer bytes.Buffer
+	ascii := true
+	for _, r := range picture.Description {
+		if r > 0x7f {
+			ascii = false
+		}
+	}
+	switch {
+	case ascii:
+		buffer.WriteByte(0)
+	case version == 4:
+		buffer.WriteByte(3)
+	default:
+		buffer.WriteByte(1)
+	}
+	buffer.WriteString(picture.MIMEType)
+	buffer.WriteByte(0)
+	buffer.WriteByte(picture.Type)
+	if ascii || version == 4 {
+		buffer.WriteString(picture.Description)
+		buffer.WriteByte(0)
+	} else {
+		buffer.Write([]byte{0xff, 0xfe})
+		for _, unit := range utf16.Encode([]rune(picture.Description)) {
+			buffer.WriteByte(byte(unit))
+			buffer.WriteByte(byte(unit >> 8))
+		}
+		buffer.Write([]byte{0, 0})
+	}
+	buffer.Write(picture.Data)
+	return buffer.Bytes()
+}
+
+// decodeLatin1 converts ISO-8859-1 text to UTF-8.
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// decodeUTF16 converts UTF-16 text to UTF-8, honoring the byte order
+// mark if there is one.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xff && data[1] == 0xfe:
+			bigEndian = false
+			data = data[2:]
+		case data[0] == 0xfe && data[1] == 0xff:
+			bigEndian = true
+			data = data[2:]
+		}
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(data[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// The types of the FLAC metadata blocks handled when writing pictures.
+const (
+	flacPadding = 1
+	flacPicture = 6
+)
+
+// A flacBlock is a metadata block of a FLAC file, with its type and its
+// contents.
+type flacBlock struct {
+	kind byte
+	data []byte
+}
+
+// isFLAC tells whether the file starts like a FLAC stream.
+func isFLAC(reader io.ReadSeeker) bool {
+	magic := make([]byte, 4)
+	_, err := reader.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = io.ReadFull(reader, magic)
+	}
+	return err == nil && string(magic) == "fLaC"
+}
+
+// readFLACBlocks reads the metadata blocks of a FLAC file, and returns
+// them together with the number of bytes from the start of the file to
+// the first audio frame.
+func readFLACBlocks(reader io.ReadSeeker) ([]*flacBlock, int64, error) {
+	_, err := reader.Seek(4, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+	blocks := make([]*flacBlock, 0)
+	size := int64(4)
+	header := make([]byte, 4)
+	for last := false; !last; {
+		_, err = io.ReadFull(reader, header)
+		if err != nil {
+			return nil, 0, err
+		}
+		last = header[0]&0x80 != 0
+		block := &flacBlock{
+			kind: header[0] & 0x7f,
+			data: make([]byte, int(header[1])<<16|int(header[2])<<8|int(header[3])),
+		}
+		_, err = io.ReadFull(reader, block.data)
+		if err != nil {
+			return nil, 0, err
+		}
+		blocks = append(blocks, block)
+		size += 4 + int64(len(block.data))
+	}
+	return blocks, size, nil
+}
+
+// writeFLACBlocks replaces the first size bytes of the FLAC file in the
+// given path by the given metadata blocks, followed by a padding block.
+func writeFLACBlocks(path string, blocks []*flacBlock, size int64) error {
+	blocks = append(blocks, &flacBlock{kind: flacPadding, data: make([]byte, id3Padding)})
+	var buffer bytes.Buffer
+	buffer.WriteString("fLaC")
+	for i, block := range blocks {
+		kind := block.kind
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+		length := len(block.data)
+		buffer.Write([]byte{kind, byte(length >> 16), byte(length >> 8), byte(length)})
+		buffer.Write(block.data)
+	}
+	return replaceHead(path, size, buffer.Bytes())
+}
+
+// decodeFLACPicture decodes the contents of a PICTURE metadata block.
+func decodeFLACPicture(data []byte) (*Picture, error) {
+	invalid := errors.New("invalid picture block")
+	field := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint32(data))
+		if length > len(data)-4 {
+			return nil, false
+		}
+		value := data[4 : 4+length]
+		data = data[4+length:]
+		return value, true
+	}
+	if len(data) < 4 {
+		return nil, invalid
+	}
+	picture := &Picture{Type: byte(binary.BigEndian.Uint32(data))}
+	data = data[4:]
+	mime, ok := field()
+	if !ok {
+		return nil, invalid
+	}
+	description, ok := field()
+	if !ok || len(data) < 16 {
+		return nil, invalid
+	}
+	data = data[16:]
+	contents, ok := field()
+	if !ok {
+		return nil, invalid
+	}
+	picture.MIMEType = string(mime)
+	picture.Description = string(description)
+	picture.Data = contents
+	return picture, nil
+}
+
+// encodeFLACPicture encodes a picture as the contents of a PICTURE
+// metadata block, filling its dimensions when the image can be
+// decoded.
+func encodeFLACPicture(picture *Picture) []byte {
+	var buffer bytes.Buffer
+	field := func(value []byte) {
+		binary.Write(&buffer, binary.BigEndian, uint32(len(value)))
+		buffer.Write(value)
+	}
+	binary.Write(&buffer, binary.BigEndian, uint32(picture.Type))
+	field([]byte(picture.MIMEType))
+	field([]byte(picture.Description))
+	var width, height uint32
+	if config, _, err := image.DecodeConfig(bytes.NewReader(picture.Data)); err == nil {
+		width, height = uint32(config.Width), uint32(config.Height)
+	}
+	depth := uint32(24)
+	if strings.HasSuffix(picture.MIMEType, "png") {
+		depth = 32
+	}
+	binary.Write(&buffer, binary.BigEndian, []uint32{width, height, depth, 0})
+	field(picture.Data)
+	return buffer.Bytes()
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempAudioFile(t *testing.T, name string, contents []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "rolas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, contents, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func flacStream() []byte {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|44100)
+	stream := []byte("fLaC\x80\x00\x00\x22")
+	stream = append(stream, info...)
+	return append(stream, make([]byte, 1000)...)
+}
+
+func TestWritePicturesMP3(t *testing.T) {
+	stream := cbrStream(10)
+	path, clean := tempAudioFile(t, "rola.mp3", stream)
+	defer clean()
+
+	pictures := []*Picture{
+		{Type: FrontCover, MIMEType: "image/jpeg", Data: []byte("front")},
+		{Type: 4, MIMEType: "image/png", Description: "Contraportada ñ", Data: []byte("back")},
+	}
+	err := WritePictures(path, pictures)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadPictures(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("expecting %v, received %v", 2, len(read))
+	}
+	for i, picture := range pictures {
+		if read[i].Type != picture.Type || read[i].MIMEType != picture.MIMEType ||
+			read[i].Description != picture.Description || !bytes.Equal(read[i].Data, picture.Data) {
+			t.Errorf("expecting %v, received %v", picture, read[i])
+		}
+	}
+
+	err = ReplacePicture(path, NewPicture([]byte("new front"), FrontCover))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, _ = ReadPictures(path)
+	if len(read) != 2 || read[0].Type != 4 || string(read[1].Data) != "new front" {
+		t.Errorf("unexpected pictures %v", read)
+	}
+	if read[0].TypeName() != "Back cover" {
+		t.Errorf("expecting %v, received %v", "Back cover", read[0].TypeName())
+	}
+
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.HasSuffix(contents, stream[20:]) {
+		t.Errorf("the audio frames were not preserved")
+	}
+	properties, err := ReadAudioProperties(bytes.NewReader(contents))
+	if err != nil || properties.Bitrate != 128 {
+		t.Errorf("unexpected properties %v, %v", properties, err)
+	}
+}
+
+func TestWritePicturesFLAC(t *testing.T) {
+	stream := flacStream()
+	path, clean := tempAudioFile(t, "rola.flac", stream)
+	defer clean()
+
+	err := WritePictures(path, []*Picture{NewPicture([]byte("front"), FrontCover)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadPictures(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(read) != 1 || read[0].Type != FrontCover || string(read[0].Data) != "front" {
+		t.Errorf("unexpected pictures %v", read)
+	}
+	contents, _ := ioutil.ReadFile(path)
+	if !bytes.HasSuffix(contents, stream[42:]) {
+		t.Errorf("the audio frames were not preserved")
+	}
+	properties, err := ReadAudioProperties(bytes.NewReader(contents))
+	if err != nil || properties.SampleRate != 44100 {
+		t.Errorf("unexpected properties %v, %v", properties, err)
+	}
+}
+
+func TestReadPicturesID3v22(t *testing.T) {
+	frame := append([]byte("\x00JPG\x03cover\x00"), []byte("data")...)
+	body := append([]byte{'P', 'I', 'C', 0, 0, byte(len(frame))}, frame...)
+	stream := []byte{'I', 'D', '3', 2, 0, 0, 0, 0, 0, byte(len(body))}
+	stream = append(append(stream, body...), cbrStream(1)[20:]...)
+	path, clean := tempAudioFile(t, "rola.mp3", stream)
+	defer clean()
+
+	read, err := ReadPictures(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(read) != 1 || read[0].Description != "cover" || string(read[0].Data) != "data" {
+		t.Errorf("unexpected pictures %v", read)
+	}
+	if WritePictures(path, read) != ErrUnsupportedTag {
+		t.Errorf("expecting %v", ErrUnsupportedTag)
+	}
+}
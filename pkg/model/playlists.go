@@ -0,0 +1,182 @@
+package model
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// A Playlist is a named list of Rolas kept in the database, with the
+// user who owns it, whether other users may see it, and the times it
+// was created and last changed.
+type Playlist struct {
+	ID      int64     `json:"id" db:"id_playlist"`
+	Name    string    `json:"name" db:"name"`
+	Comment string    `json:"comment" db:"comment"`
+	Owner   string    `json:"owner" db:"owner"`
+	Public  bool      `json:"public" db:"public"`
+	Created time.Time `json:"created" db:"created"`
+	Changed time.Time `json:"changed" db:"changed"`
+	Rolas   []int64   `json:"rolas" db:"-"`
+}
+
+// AddPlaylist adds a playlist with the given name, owner and Rolas to
+// the database, and returns the ID of the playlist.
+func (database *Database) AddPlaylist(name, owner string, rolaIDs []int64) int64 {
+	stmtStr := "INSERT INTO playlists (name, owner, created, changed) VALUES (?, ?, ?, ?)"
+
+	now := time.Now().Format(time.RFC3339)
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	id, err := stmt.Exec(name, owner, now, now)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+	lastId, err := id.LastInsertId()
+	if err != nil {
+		log.Fatal("could not retrieve the last insert ID:", err)
+	}
+	database.SetPlaylistRolas(lastId, rolaIDs)
+	return lastId
+}
+
+// AddPlay records that a Rola was played at the given time, adding one
+// to its play count.
+func (database *Database) AddPlay(rolaID int64, played time.Time) {
+	stmtStr := "UPDATE rolas " +
+		"SET play_count = play_count + 1, last_played = ? " +
+		"WHERE id_rola = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(played.Format(time.RFC3339), rolaID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// AllPlaylists returns all the playlists of the database, sorted by
+// name.
+func (database *Database) AllPlaylists() []*Playlist {
+	result := make([]*Playlist, 0)
+	stmtStr := "SELECT id_playlist FROM playlists ORDER BY name COLLATE NOCASE"
+
+	for _, id := range database.QueryCustom(stmtStr) {
+		result = append(result, database.QueryPlaylist(id))
+	}
+	return result
+}
+
+// DeletePlaylist removes the playlist with the given ID from the
+// database.
+func (database *Database) DeletePlaylist(playlistID int64) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	for _, stmtStr := range []string{
+		"DELETE FROM playlist_rolas WHERE id_playlist = ?",
+		"DELETE FROM playlists WHERE id_playlist = ?",
+	} {
+		_, err = tx.Exec(stmtStr, playlistID)
+		if err != nil {
+			log.Fatal("could not execute delete: ", err)
+		}
+	}
+	tx.Commit()
+}
+
+// QueryPlaylist receives a playlist's ID and returns the playlist with
+// its Rolas in order, or nil if there is no such playlist.
+func (database *Database) QueryPlaylist(playlistID int64) *Playlist {
+	stmtStr := "SELECT " +
+		" name, " +
+		" comment, " +
+		" owner, " +
+		" public, " +
+		" created, " +
+		" changed " +
+		"FROM playlists " +
+		"WHERE id_playlist = ?"
+
+	tx, stmt, rows := database.PreparedQuery(stmtStr, playlistID)
+	defer stmt.Close()
+	defer rows.Close()
+
+	var playlist *Playlist
+	for rows.Next() {
+		var name, comment, owner, created, changed sql.NullString
+		var public sql.NullInt64
+		err := rows.Scan(&name, &comment, &owner, &public, &created, &changed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		playlist = &Playlist{
+			ID:      playlistID,
+			Name:    name.String,
+			Comment: comment.String,
+			Owner:   owner.String,
+			Public:  public.Int64 != 0,
+		}
+		playlist.Created, _ = time.Parse(time.RFC3339, created.String)
+		playlist.Changed, _ = time.Parse(time.RFC3339, changed.String)
+	}
+	err := rows.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx.Commit()
+
+	if playlist != nil {
+		stmtStr = "SELECT id_rola FROM playlist_rolas WHERE id_playlist = ? ORDER BY position"
+		playlist.Rolas = database.QueryCustom(stmtStr, playlistID)
+	}
+	return playlist
+}
+
+// SetPlaylistRolas replaces the Rolas of the playlist with the given ID
+// by the given ones, in order.
+func (database *Database) SetPlaylistRolas(playlistID int64, rolaIDs []int64) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		log.Fatal("could not begin transaction: ", err)
+	}
+	_, err = tx.Exec("DELETE FROM playlist_rolas WHERE id_playlist = ?", playlistID)
+	if err != nil {
+		log.Fatal("could not execute delete: ", err)
+	}
+	for position, rolaID := range rolaIDs {
+		_, err = tx.Exec("INSERT INTO playlist_rolas (id_playlist, position, id_rola) VALUES (?, ?, ?)",
+			playlistID, position, rolaID)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	_, err = tx.Exec("UPDATE playlists SET changed = ? WHERE id_playlist = ?",
+		time.Now().Format(time.RFC3339), playlistID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
+
+// UpdatePlaylist changes the name, comment and visibility of the
+// playlist with the given ID.
+func (database *Database) UpdatePlaylist(playlistID int64, name, comment string, public bool) {
+	stmtStr := "UPDATE playlists " +
+		"SET name = ?, comment = ?, public = ?, changed = ? " +
+		"WHERE id_playlist = ?"
+
+	tx, stmt := database.PrepareStatement(stmtStr)
+	defer stmt.Close()
+
+	_, err := stmt.Exec(name, comment, public, time.Now().Format(time.RFC3339), playlistID)
+	if err != nil {
+		log.Fatal("could not execute update: ", err)
+	}
+	tx.Commit()
+}
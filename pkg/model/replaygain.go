@@ -0,0 +1,601 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+// ReplayGainReference is the loudness, in LUFS, that the gains of
+// ReplayGain 2.0 bring the Rolas to.
+const ReplayGainReference = -18.0
+
+// r128Reference is the loudness, in LUFS, of the R128 gain tags, which
+// hold the gain in dB as a fixed point number with 8 fractional bits.
+const r128Reference = -23.0
+
+// The gates of the integrated loudness of EBU R128: the blocks quieter
+// than loudnessAbsoluteGate LUFS, or than the loudness of the louder
+// blocks plus loudnessRelativeGate LU, are left out.
+const (
+	loudnessAbsoluteGate = -70.0
+	loudnessRelativeGate = -10.0
+)
+
+// The ReplayGain tags, as they are written in the ID3v2 TXXX frames and
+// the FLAC comments.
+const (
+	tagTrackGain = "REPLAYGAIN_TRACK_GAIN"
+	tagTrackPeak = "REPLAYGAIN_TRACK_PEAK"
+	tagAlbumGain = "REPLAYGAIN_ALBUM_GAIN"
+	tagAlbumPeak = "REPLAYGAIN_ALBUM_PEAK"
+)
+
+// flacComment is the type of the FLAC metadata block with the comments.
+const flacComment = 4
+
+// A ReplayGain holds the integrated loudness, in LUFS, and the peak, as
+// a fraction of the full scale, of a Rola and of its album.   A loudness
+// of 0 means that it is not known.
+type ReplayGain struct {
+	TrackLoudness float64 `json:"track_loudness"`
+	TrackPeak     float64 `json:"track_peak"`
+	AlbumLoudness float64 `json:"album_loudness"`
+	AlbumPeak     float64 `json:"album_peak"`
+}
+
+// TrackGain returns the gain, in dB, that brings the Rola to the
+// ReplayGainReference.
+func (gain *ReplayGain) TrackGain() float64 {
+	return ReplayGainReference - gain.TrackLoudness
+}
+
+// AlbumGain returns the gain, in dB, that brings the album of the Rola
+// to the ReplayGainReference.
+func (gain *ReplayGain) AlbumGain() float64 {
+	return ReplayGainReference - gain.AlbumLoudness
+}
+
+// A biquad is a second order filter, in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// filter passes a sample through the filter.
+func (filter *biquad) filter(x float64) float64 {
+	y := filter.b0*x + filter.b1*filter.x1 + filter.b2*filter.x2 - filter.a1*filter.y1 - filter.a2*filter.y2
+	filter.x2, filter.x1 = filter.x1, x
+	filter.y2, filter.y1 = filter.y1, y
+	return y
+}
+
+// kWeighting returns the two filters of the K-weighting of ITU-R
+// BS.1770, a high shelf and a high pass, for the given sample rate.
+// The coefficients are derived from the analog filters, so that at
+// 48 kHz they are the ones given by the recommendation.
+func kWeighting(rate int) []*biquad {
+	k := math.Tan(math.Pi * 1681.974450955533 / float64(rate))
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	k = math.Tan(math.Pi * 38.13547087602444 / float64(rate))
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	pass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return []*biquad{shelf, pass}
+}
+
+// A loudnessMeter measures audio as in EBU R128: the K-weighted mean
+// square of every channel is added up, with the surround channels of
+// 5.1 audio weighted by 1.41 and the LFE channel left out, in steps of
+// 100 ms; each block of four steps gives a momentary loudness, and the
+// blocks are gated to give the integrated loudness.   The peak is the
+// largest absolute sample.
+type loudnessMeter struct {
+	filters [][]*biquad
+	weights []float64
+	step    int
+	count   int
+	energy  float64
+	steps   []float64
+	peak    float64
+}
+
+// newLoudnessMeter returns a loudnessMeter for audio with the given
+// sample rate and number of channels.
+func newLoudnessMeter(rate, channels int) *loudnessMeter {
+	meter := &loudnessMeter{
+		filters: make([][]*biquad, channels),
+		weights: make([]float64, channels),
+		step:    rate / 10,
+		steps:   make([]float64, 0),
+	}
+	for channel := range meter.filters {
+		meter.filters[channel] = kWeighting(rate)
+		meter.weights[channel] = 1
+	}
+	if channels == 6 {
+		meter.weights[3] = 0
+		meter.weights[4] = 1.41
+		meter.weights[5] = 1.41
+	}
+	return meter
+}
+
+// add measures a frame, with a sample of each channel; the channels of
+// the frame beyond the ones of the meter are left out.
+func (meter *loudnessMeter) add(frame []float64) {
+	for channel, filters := range meter.filters {
+		if channel >= len(frame) {
+			break
+		}
+		sample := frame[channel]
+		if math.Abs(sample) > meter.peak {
+			meter.peak = math.Abs(sample)
+		}
+		for _, filter := range filters {
+			sample = filter.filter(sample)
+		}
+		meter.energy += meter.weights[channel] * sample * sample
+	}
+	meter.count++
+	if meter.count == meter.step {
+		meter.steps = append(meter.steps, meter.energy/float64(meter.step))
+		meter.count, meter.energy = 0, 0
+	}
+}
+
+// blocks returns the mean squares of the blocks of 400 ms measured, one
+// every 100 ms.
+func (meter *loudnessMeter) blocks() []float64 {
+	blocks := make([]float64, 0)
+	for i := 3; i < len(meter.steps); i++ {
+		blocks = append(blocks, (meter.steps[i-3]+meter.steps[i-2]+meter.steps[i-1]+meter.steps[i])/4)
+	}
+	return blocks
+}
+
+// energyLoudness returns the loudness, in LUFS, of a mean square.
+func energyLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// integratedLoudness gates the mean squares of the blocks of some audio
+// and returns its integrated loudness, in LUFS.   The audio without
+// blocks above the absolute gate, such as silence, gives the absolute
+// gate.
+func integratedLoudness(blocks []float64) float64 {
+	mean := func(threshold float64) (float64, bool) {
+		sum, count := 0.0, 0
+		for _, block := range blocks {
+			if block > threshold {
+				sum += block
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, false
+		}
+		return sum / float64(count), true
+	}
+	absolute := math.Pow(10, (loudnessAbsoluteGate+0.691)/10)
+	gated, ok := mean(absolute)
+	if !ok {
+		return loudnessAbsoluteGate
+	}
+	relative := math.Max(absolute, gated*math.Pow(10, loudnessRelativeGate/10))
+	gated, ok = mean(relative)
+	if !ok {
+		return loudnessAbsoluteGate
+	}
+	return energyLoudness(gated)
+}
+
+// measureLoudness decodes the audio file in the given path once and
+// measures the part of each Rola, all of them of the file: the whole
+// file, or the part of a track of a CUE sheet.   The audio of a Rola
+// with one channel is measured as such, as the MP3 decoder always gives
+// two.
+func measureLoudness(path string, rolas []*Rola) ([]*loudnessMeter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	meters := make([]*loudnessMeter, len(rolas))
+	starts := make([]int64, len(rolas))
+	ends := make([]int64, len(rolas))
+	var sample int64
+	err = streamAudio(file, func(rate int, frame []float64) bool {
+		if sample == 0 {
+			for i, rola := range rolas {
+				channels := len(frame)
+				if rola.Channels() > 0 && rola.Channels() < channels {
+					channels = rola.Channels()
+				}
+				meters[i] = newLoudnessMeter(rate, channels)
+				starts[i] = int64(rola.Start().Seconds() * float64(rate))
+				ends[i] = int64(rola.End().Seconds() * float64(rate))
+			}
+		}
+		more := false
+		for i, meter := range meters {
+			if sample >= starts[i] && (ends[i] == 0 || sample < ends[i]) {
+				meter.add(frame)
+			}
+			more = more || ends[i] == 0 || sample+1 < ends[i]
+		}
+		sample++
+		return more
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sample == 0 {
+		return nil, ErrUnknownFormat
+	}
+	return meters, nil
+}
+
+// ScanReplayGain measures the loudness and the peak of the Rolas with
+// the given IDs, or of the Rolas whose loudness is not known if ids is
+// nil, and of their albums, and keeps them in the database.   An album
+// is measured as a whole, so the other Rolas of the album are measured
+// again too; the hidden Rolas and the ones whose files are missing are
+// left out.   The files of the tracks of a CUE sheet are decoded once.
+// report, if not nil, is called after each file with the number of
+// Rolas done and their total.   The files that can not be decoded are
+// logged and skipped.   It returns the IDs of the Rolas measured.
+func (database *Database) ScanReplayGain(ids []int64, report func(done, total int)) []int64 {
+	var albums []int64
+	if ids == nil {
+		albums = database.QueryCustom("SELECT DISTINCT id_album FROM rolas " +
+			"WHERE loudness = 0 AND hidden = 0 AND missing = 0 ORDER BY id_album")
+	} else {
+		seen := make(map[int64]bool)
+		for _, id := range ids {
+			for _, album := range database.QueryCustom("SELECT id_album FROM rolas WHERE id_rola = ?", id) {
+				if !seen[album] {
+					seen[album] = true
+					albums = append(albums, album)
+				}
+			}
+		}
+	}
+	tracks := make([][]int64, len(albums))
+	total := 0
+	for i, album := range albums {
+		tracks[i] = database.QueryCustom("SELECT id_rola FROM rolas "+
+			"WHERE id_album = ? AND hidden = 0 AND missing = 0 ORDER BY container, start_offset, id_rola", album)
+		total += len(tracks[i])
+	}
+
+	measured := make([]int64, 0)
+	done := 0
+	for _, albumTracks := range tracks {
+		rolas := make([]*Rola, 0, len(albumTracks))
+		meters := make([]*loudnessMeter, 0, len(albumTracks))
+		for start := 0; start < len(albumTracks); {
+			first := database.QueryRola(albumTracks[start])
+			file := []*Rola{first}
+			for start+len(file) < len(albumTracks) && first.IsCueTrack() {
+				next := database.QueryRola(albumTracks[start+len(file)])
+				if next.File() != first.File() {
+					break
+				}
+				file = append(file, next)
+			}
+			start += len(file)
+			fileMeters, err := measureLoudness(first.File(), file)
+			if err != nil {
+				log.Println("could not measure the loudness of "+first.File()+":", err)
+			} else {
+				rolas = append(rolas, file...)
+				meters = append(meters, fileMeters...)
+			}
+			done += len(file)
+			if report != nil {
+				report(done, total)
+			}
+		}
+
+		albumBlocks := make([]float64, 0)
+		albumPeak := 0.0
+		for _, meter := range meters {
+			albumBlocks = append(albumBlocks, meter.blocks()...)
+			albumPeak = math.Max(albumPeak, meter.peak)
+		}
+		albumLoudness := integratedLoudness(albumBlocks)
+		for i, rola := range rolas {
+			rola.SetReplayGain(&ReplayGain{
+				TrackLoudness: integratedLoudness(meters[i].blocks()),
+				TrackPeak:     meters[i].peak,
+				AlbumLoudness: albumLoudness,
+				AlbumPeak:     albumPeak,
+			})
+			database.UpdateReplayGain(rola)
+			measured = append(measured, rola.ID())
+		}
+	}
+	return measured
+}
+
+// replayGainTags reads the ReplayGain of a Rola from the raw tags of its
+// file: the TXXX frames of ID3v2 and the comments of FLAC.
+func replayGainTags(metadata tag.Metadata) *ReplayGain {
+	values := make(map[string]string)
+	for name, value := range metadata.Raw() {
+		switch value := value.(type) {
+		case *tag.Comm:
+			if strings.HasPrefix(name, "TXX") {
+				values[strings.ToUpper(value.Description)] = value.Text
+			}
+		case string:
+			values[strings.ToUpper(name)] = value
+		}
+	}
+	return parseReplayGain(values)
+}
+
+// parseReplayGain reads the ReplayGain of a Rola from its tags, by
+// name in upper case.   The REPLAYGAIN_* tags give the gains relative to
+// the ReplayGainReference, and the R128_* tags, used instead by some
+// taggers, relative to -23 LUFS; the values that can not be read are
+// left as not known.
+func parseReplayGain(values map[string]string) *ReplayGain {
+	number := func(name string) (float64, bool) {
+		text := strings.TrimSpace(values[name])
+		if len(text) > 2 && strings.EqualFold(text[len(text)-2:], "dB") {
+			text = strings.TrimSpace(text[:len(text)-2])
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		return value, err == nil && !math.IsNaN(value) && !math.IsInf(value, 0)
+	}
+	loudness := func(gain, r128 string) float64 {
+		if value, ok := number(gain); ok {
+			return ReplayGainReference - value
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(values[r128])); err == nil {
+			return r128Reference - float64(value)/256
+		}
+		return 0
+	}
+	peak := func(name string) float64 {
+		if value, ok := number(name); ok && value > 0 {
+			return value
+		}
+		return 0
+	}
+	return &ReplayGain{
+		TrackLoudness: loudness(tagTrackGain, "R128_TRACK_GAIN"),
+		TrackPeak:     peak(tagTrackPeak),
+		AlbumLoudness: loudness(tagAlbumGain, "R128_ALBUM_GAIN"),
+		AlbumPeak:     peak(tagAlbumPeak),
+	}
+}
+
+// replayGainValues returns the ReplayGain tags of a Rola, in the order
+// they are written, as the gains in dB with two decimals and the peaks
+// with six.   The tags of the album are left out if its loudness is not
+// known.
+func replayGainValues(gain *ReplayGain) [][2]string {
+	values := [][2]string{
+		{tagTrackGain, fmt.Sprintf("%.2f dB", gain.TrackGain())},
+		{tagTrackPeak, fmt.Sprintf("%.6f", gain.TrackPeak)},
+	}
+	if gain.AlbumLoudness != 0 {
+		values = append(values,
+			[2]string{tagAlbumGain, fmt.Sprintf("%.2f dB", gain.AlbumGain())},
+			[2]string{tagAlbumPeak, fmt.Sprintf("%.6f", gain.AlbumPeak)})
+	}
+	return values
+}
+
+// isReplayGainTag tells whether a tag name is one of the ReplayGain or
+// R128 tags replaced by WriteReplayGain.
+func isReplayGainTag(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.HasPrefix(name, "REPLAYGAIN_") || strings.HasPrefix(name, "R128_")
+}
+
+// WriteReplayGain replaces the ReplayGain tags in the file in the given
+// path by the ones of the given ReplayGain: the TXXX frames of its
+// ID3v2 tag, or the comments of a FLAC file.   The R128 tags are
+// removed, so they do not contradict the new ones.
+func WriteReplayGain(path string, gain *ReplayGain) error {
+	if gain.TrackLoudness == 0 {
+		return errors.New("the loudness of " + path + " is not known")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if isFLAC(file) {
+		blocks, size, err := readFLACBlocks(file)
+		if err != nil {
+			return err
+		}
+		kept := make([]*flacBlock, 0, len(blocks)+1)
+		var comments *flacBlock
+		for _, block := range blocks {
+			switch block.kind {
+			case flacPadding:
+			case flacComment:
+				if comments == nil {
+					comments = block
+					kept = append(kept, block)
+				}
+			default:
+				kept = append(kept, block)
+			}
+		}
+		if comments == nil {
+			comments = &flacBlock{kind: flacComment}
+			kept = append(kept[:1], append([]*flacBlock{comments}, kept[1:]...)...)
+		}
+		comments.data, err = replaceFLACComments(comments.data, replayGainValues(gain))
+		if err != nil {
+			return err
+		}
+		file.Close()
+		return writeFLACBlocks(path, kept, size)
+	}
+
+	id3, err := readID3v2(file)
+	if err != nil {
+		return err
+	}
+	if id3.version != 3 && id3.version != 4 {
+		return ErrUnsupportedTag
+	}
+	frames := make([]*id3Frame, 0, len(id3.frames)+4)
+	for _, frame := range id3.frames {
+		if frame.id == "TXXX" {
+			data, err := frame.contents(id3.version)
+			if err == nil && isReplayGainTag(txxxDescription(data)) {
+				continue
+			}
+		}
+		frames = append(frames, frame)
+	}
+	for _, value := range replayGainValues(gain) {
+		data := append([]byte{0}, value[0]...)
+		data = append(data, 0)
+		data = append(data, value[1]...)
+		frames = append(frames, &id3Frame{id: "TXXX", data: data})
+	}
+	id3.frames = frames
+	file.Close()
+	return writeID3v2(path, id3)
+}
+
+// txxxDescription returns the description of a TXXX frame, which is the
+// name of the tag it holds.
+func txxxDescription(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+	encoding := data[0]
+	data = data[1:]
+	if encoding == 1 || encoding == 2 {
+		end := 0
+		for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+			end += 2
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		return decodeUTF16(data[:end], encoding == 2)
+	}
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	if encoding == 0 {
+		return decodeLatin1(data)
+	}
+	return string(data)
+}
+
+// replaceFLACComments replaces the ReplayGain tags among the comments
+// of a VORBIS_COMMENT block, keeping the others, and returns the new
+// contents of the block.   An empty block gets a vendor string.
+func replaceFLACComments(data []byte, values [][2]string) ([]byte, error) {
+	invalid := errors.New("invalid comment block")
+	vendor := []byte("rolas")
+	comments := make([][]byte, 0)
+	if len(data) > 0 {
+		field := func() ([]byte, bool) {
+			if len(data) < 4 {
+				return nil, false
+			}
+			length := int(binary.LittleEndian.Uint32(data))
+			if length > len(data)-4 {
+				return nil, false
+			}
+			value := data[4 : 4+length]
+			data = data[4+length:]
+			return value, true
+		}
+		var ok bool
+		vendor, ok = field()
+		if !ok || len(data) < 4 {
+			return nil, invalid
+		}
+		count := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		for i := 0; i < count; i++ {
+			comment, ok := field()
+			if !ok {
+				return nil, invalid
+			}
+			name := string(comment)
+			if equals := strings.IndexByte(name, '='); equals >= 0 {
+				name = name[:equals]
+			}
+			if !isReplayGainTag(name) {
+				comments = append(comments, comment)
+			}
+		}
+	}
+	for _, value := range values {
+		comments = append(comments, []byte(value[0]+"="+value[1]))
+	}
+
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, uint32(len(vendor)))
+	buffer.Write(vendor)
+	binary.Write(&buffer, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&buffer, binary.LittleEndian, uint32(len(comment)))
+		buffer.Write(comment)
+	}
+	return buffer.Bytes(), nil
+}
+
+// WriteReplayGainTags writes the ReplayGain of the Rolas with the given
+// IDs into the tags of their files (see WriteReplayGain).   The tracks of
+// CUE sheets, which share their file, and the Rolas whose loudness is
+// not known are skipped, and the files that can not be written are
+// logged.   It returns the number of files written.
+func (database *Database) WriteReplayGainTags(ids []int64) int {
+	written := 0
+	for _, id := range ids {
+		rola := database.QueryRola(id)
+		if rola.IsCueTrack() || rola.ReplayGain().TrackLoudness == 0 {
+			continue
+		}
+		err := WriteReplayGain(rola.Path(), rola.ReplayGain())
+		if err != nil {
+			log.Println("could not write the ReplayGain of "+rola.Path()+":", err)
+			continue
+		}
+		written++
+	}
+	return written
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+func sineMeter(rate, channels int, amplitude float64, seconds int) *loudnessMeter {
+	meter := newLoudnessMeter(rate, channels)
+	frame := make([]float64, channels)
+	for i := 0; i < seconds*rate; i++ {
+		for channel := range frame {
+			frame[channel] = amplitude * math.Sin(2*math.Pi*1000*float64(i)/float64(rate))
+		}
+		meter.add(frame)
+	}
+	return meter
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	amplitude := math.Pow(10, -23.0/20)
+	for _, rate := range []int{44100, 48000} {
+		meter := sineMeter(rate, 2, amplitude, 10)
+		loudness := integratedLoudness(meter.blocks())
+		if math.Abs(loudness+23) > 0.1 {
+			t.Errorf("expecting %v, received %v", -23.0, loudness)
+		}
+		if math.Abs(meter.peak-amplitude) > 0.001 {
+			t.Errorf("expecting %v, received %v", amplitude, meter.peak)
+		}
+	}
+	if loudness := integratedLoudness(sineMeter(48000, 2, 0, 2).blocks()); loudness != loudnessAbsoluteGate {
+		t.Errorf("expecting %v, received %v", loudnessAbsoluteGate, loudness)
+	}
+
+	quiet := sineMeter(48000, 2, amplitude/100, 10).blocks()
+	loud := sineMeter(48000, 2, amplitude, 10).blocks()
+	if loudness := integratedLoudness(append(quiet, loud...)); math.Abs(loudness+23) > 0.1 {
+		t.Errorf("expecting %v, received %v", -23.0, loudness)
+	}
+}
+
+func TestParseReplayGain(t *testing.T) {
+	gain := parseReplayGain(map[string]string{
+		"REPLAYGAIN_TRACK_GAIN": "-6.50 dB",
+		"REPLAYGAIN_TRACK_PEAK": "0.988831",
+		"R128_ALBUM_GAIN":       "-1280",
+		"REPLAYGAIN_ALBUM_PEAK": "peak",
+	})
+	expected := &ReplayGain{TrackLoudness: -11.5, TrackPeak: 0.988831, AlbumLoudness: -18}
+	if *gain != *expected {
+		t.Errorf("expecting %v, received %v", expected, gain)
+	}
+	if gain.TrackGain() != -6.5 || gain.AlbumGain() != 0 {
+		t.Errorf("expecting %v, received %v", "-6.5 and 0", []float64{gain.TrackGain(), gain.AlbumGain()})
+	}
+}
+
+func TestWriteReplayGainMP3(t *testing.T) {
+	stream := cbrStream(10)
+	path, clean := tempAudioFile(t, "rola.mp3", stream)
+	defer clean()
+
+	err := WriteReplayGain(path, &ReplayGain{TrackLoudness: -8, TrackPeak: 1, AlbumLoudness: -9, AlbumPeak: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &ReplayGain{TrackLoudness: -12.25, TrackPeak: 0.5}
+	err = WriteReplayGain(path, expected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contents, _ := ioutil.ReadFile(path)
+	tag, err := readID3v2(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	values := make(map[string]string)
+	for _, frame := range tag.frames {
+		parts := strings.SplitN(string(frame.data[1:]), "\x00", 2)
+		values[txxxDescription(frame.data)] = parts[1]
+	}
+	if len(values) != 2 || *parseReplayGain(values) != *expected {
+		t.Errorf("expecting %v, received %v", expected, values)
+	}
+	if !bytes.HasSuffix(contents, stream[20:]) {
+		t.Errorf("the audio frames were not preserved")
+	}
+}
+
+func TestWriteReplayGainFLAC(t *testing.T) {
+	stream := flacStream()
+	path, clean := tempAudioFile(t, "rola.flac", stream)
+	defer clean()
+
+	expected := &ReplayGain{TrackLoudness: -14, TrackPeak: 0.75, AlbumLoudness: -15, AlbumPeak: 0.8}
+	for i := 0; i < 2; i++ {
+		err := WriteReplayGain(path, expected)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	contents, _ := ioutil.ReadFile(path)
+	blocks, _, err := readFLACBlocks(bytes.NewReader(contents))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blocks) != 3 || blocks[1].kind != flacComment {
+		t.Fatalf("unexpected blocks %v", blocks)
+	}
+	data := blocks[1].data
+	data = data[4+binary.LittleEndian.Uint32(data):]
+	values := make(map[string]string)
+	for count := binary.LittleEndian.Uint32(data); count > 0; count-- {
+		length := binary.LittleEndian.Uint32(data[4:])
+		parts := strings.SplitN(string(data[8:8+length]), "=", 2)
+		values[parts[0]] = parts[1]
+		data = data[4+length:]
+	}
+	if len(values) != 4 || *parseReplayGain(values) != *expected {
+		t.Errorf("expecting %v, received %v", expected, values)
+	}
+	if !bytes.HasSuffix(contents, stream[42:]) {
+		t.Errorf("the audio frames were not preserved")
+	}
+}
@@ -1,37 +1,73 @@
 package model
 
 import (
+	"encoding/json"
 	"strings"
+	"time"
 )
 
 // A Rola represents a song, it contains the information present in
 // various frames from the id3v2 tag, namely, artist, title, album
-// track number, year, genre, and additionally, the path of the song
-// file, and the id assigned by the database to the song.
+// track number, year, genre, disc number, album artist, composer,
+// beats per minute, comment and lyrics, the properties of its audio
+// stream, the hash of its picture in the ArtworkCache, and additionally,
+// the path of the song file, and the id assigned by the database to the
+// song.   The Rola of a track of a CUE sheet is the part of its file
+// between its start and its end.   Its ReplayGain holds the loudness of
+// the song and of its album.
 type Rola struct {
-	artist string
-	title  string
-	album  string
-	track  int
-	year   int
-	genre  string
-	path   string
-	id     int64
-}
-
-// NewRola creates a Rola with default values; text fields are "Unknown"
-// and numeric fields are 0.
+	artist      string
+	title       string
+	album       string
+	track       int
+	year        int
+	genre       string
+	disc        int
+	albumArtist string
+	composer    string
+	bpm         int
+	comment     string
+	lyrics      string
+	duration    time.Duration
+	bitrate     int
+	sampleRate  int
+	channels    int
+	vbr         bool
+	artwork     string
+	path        string
+	file        string
+	start       time.Duration
+	end         time.Duration
+	replayGain  ReplayGain
+	id          int64
+}
+
+// NewRola creates a Rola with default values; the main text fields are
+// "Unknown", the extended ones (album artist, composer, comment and
+// lyrics) are empty, and numeric fields are 0.
 func NewRola() *Rola {
 	initial := "Unknown"
 	return &Rola{
-		artist: initial,
-		title:  initial,
-		album:  initial,
-		track:  0,
-		year:   2018,
-		genre:  initial,
-		path:   initial,
-		id:     0,
+		artist:      initial,
+		title:       initial,
+		album:       initial,
+		track:       0,
+		year:        2018,
+		genre:       initial,
+		disc:        0,
+		albumArtist: "",
+		composer:    "",
+		bpm:         0,
+		comment:     "",
+		lyrics:      "",
+		duration:    0,
+		bitrate:     0,
+		sampleRate:  0,
+		channels:    0,
+		vbr:         false,
+		artwork:     "",
+		path:        initial,
+		id:          0,
 	}
 }
 
@@ -65,11 +101,107 @@ func (rola *Rola) Genre() string {
 	return rola.genre
 }
 
+// Disc returns the disc number of the Rola as an int.
+func (rola *Rola) Disc() int {
+	return rola.disc
+}
+
+// AlbumArtist returns the artist credited for the whole album where
+// the Rola is included.
+func (rola *Rola) AlbumArtist() string {
+	return rola.albumArtist
+}
+
+// Composer returns the composer of the Rola.
+func (rola *Rola) Composer() string {
+	return rola.composer
+}
+
+// BPM returns the beats per minute of the Rola as an int.
+func (rola *Rola) BPM() int {
+	return rola.bpm
+}
+
+// Comment returns the comment attached to the Rola.
+func (rola *Rola) Comment() string {
+	return rola.comment
+}
+
+// Lyrics returns the lyrics of the Rola, possibly in several lines.
+func (rola *Rola) Lyrics() string {
+	return rola.lyrics
+}
+
+// Duration returns the length of the Rola.
+func (rola *Rola) Duration() time.Duration {
+	return rola.duration
+}
+
+// Bitrate returns the average bitrate of the Rola in kbps.
+func (rola *Rola) Bitrate() int {
+	return rola.bitrate
+}
+
+// SampleRate returns the sample rate of the Rola in Hz.
+func (rola *Rola) SampleRate() int {
+	return rola.sampleRate
+}
+
+// Channels returns the number of audio channels of the Rola.
+func (rola *Rola) Channels() int {
+	return rola.channels
+}
+
+// VBR returns true if the Rola was encoded with a variable bitrate.
+func (rola *Rola) VBR() bool {
+	return rola.vbr
+}
+
+// Artwork returns the hash of the picture of the Rola in the
+// ArtworkCache, or an empty string if it has no picture.
+func (rola *Rola) Artwork() string {
+	return rola.artwork
+}
+
 // Path returns the path of the song file where the Rola was mined.
 func (rola *Rola) Path() string {
 	return rola.path
 }
 
+// File returns the path of the audio file of the Rola: the file of its
+// CUE sheet if it is a track of one, or else its path.
+func (rola *Rola) File() string {
+	if rola.file != "" {
+		return rola.file
+	}
+	return rola.path
+}
+
+// IsCueTrack returns true if the Rola is a track of a CUE sheet, which
+// is only a part of its file.
+func (rola *Rola) IsCueTrack() bool {
+	return rola.file != ""
+}
+
+// Start returns the offset in its file where a track of a CUE sheet
+// begins, or 0.
+func (rola *Rola) Start() time.Duration {
+	return rola.start
+}
+
+// End returns the offset in its file where a track of a CUE sheet ends,
+// or 0 if it ends with the file.
+func (rola *Rola) End() time.Duration {
+	return rola.end
+}
+
+// ReplayGain returns the loudness and the peak of the Rola and of its
+// album.
+func (rola *Rola) ReplayGain() *ReplayGain {
+	gain := rola.replayGain
+	return &gain
+}
+
 // ID returns the ID assigned to the Rola by the database at insertion.
 func (rola *Rola) ID() int64 {
 	return rola.id
@@ -105,13 +237,140 @@ func (rola *Rola) SetGenre(genre string) {
 	rola.genre = strings.TrimSpace(genre)
 }
 
+// SetDisc sets the disc number of the Rola. Should be an int.
+func (rola *Rola) SetDisc(disc int) {
+	rola.disc = disc
+}
+
+// SetAlbumArtist sets the artist credited for the album of the Rola.
+func (rola *Rola) SetAlbumArtist(albumArtist string) {
+	rola.albumArtist = strings.TrimSpace(albumArtist)
+}
+
+// SetComposer sets the composer of the Rola.
+func (rola *Rola) SetComposer(composer string) {
+	rola.composer = strings.TrimSpace(composer)
+}
+
+// SetBPM sets the beats per minute of the Rola. Should be an int.
+func (rola *Rola) SetBPM(bpm int) {
+	rola.bpm = bpm
+}
+
+// SetComment sets the comment of the Rola.
+func (rola *Rola) SetComment(comment string) {
+	rola.comment = strings.TrimSpace(comment)
+}
+
+// SetLyrics sets the lyrics of the Rola.   Only the surrounding blank
+// space is removed, the line breaks inside the lyrics are kept.
+func (rola *Rola) SetLyrics(lyrics string) {
+	rola.lyrics = strings.TrimSpace(lyrics)
+}
+
+// SetAudioProperties sets the duration, bitrate, sample rate, number of
+// channels and bitrate mode of the Rola from the properties read from
+// its audio stream.
+func (rola *Rola) SetAudioProperties(properties *AudioProperties) {
+	rola.duration = properties.Duration
+	rola.bitrate = properties.Bitrate
+	rola.sampleRate = properties.SampleRate
+	rola.channels = properties.Channels
+	rola.vbr = properties.VBR
+}
+
+// SetArtwork sets the hash of the picture of the Rola in the
+// ArtworkCache.
+func (rola *Rola) SetArtwork(hash string) {
+	rola.artwork = hash
+}
+
 // SetPath sets the path of the file where the song represented by the Rola is.
 func (rola *Rola) SetPath(path string) {
 	rola.path = strings.TrimSpace(path)
 }
 
+// SetCueTrack makes the Rola a track of a CUE sheet, the part of the
+// given file between start and end, or the end of the file if end is 0.
+func (rola *Rola) SetCueTrack(file string, start, end time.Duration) {
+	rola.file = file
+	rola.start = start
+	rola.end = end
+}
+
+// SetReplayGain sets the loudness and the peak of the Rola and of its
+// album.
+func (rola *Rola) SetReplayGain(gain *ReplayGain) {
+	rola.replayGain = *gain
+}
+
 // SetID sets the ID of the Rola. This value should not be changed unless
 // the corresponding value changes in the Database.
 func (rola *Rola) SetID(id int64) {
 	rola.id = id
 }
+
+// rolaJSON holds the fields of a Rola as they are written in JSON.   The
+// duration, start and end are in seconds and the bitrate in kbps; the
+// file, start and end are only written for the tracks of CUE sheets,
+// and the ReplayGain only if the loudness of the Rola is known.
+type rolaJSON struct {
+	ID          int64       `json:"id"`
+	Title       string      `json:"title"`
+	Artist      string      `json:"artist"`
+	Album       string      `json:"album"`
+	Genre       string      `json:"genre"`
+	Track       int         `json:"track"`
+	Year        int         `json:"year"`
+	Disc        int         `json:"disc"`
+	AlbumArtist string      `json:"album_artist"`
+	Composer    string      `json:"composer"`
+	BPM         int         `json:"bpm"`
+	Comment     string      `json:"comment"`
+	Lyrics      string      `json:"lyrics"`
+	Duration    float64     `json:"duration"`
+	Bitrate     int         `json:"bitrate"`
+	SampleRate  int         `json:"sample_rate"`
+	Channels    int         `json:"channels"`
+	VBR         bool        `json:"vbr"`
+	Artwork     string      `json:"artwork"`
+	Path        string      `json:"path"`
+	File        string      `json:"file,omitempty"`
+	Start       float64     `json:"start,omitempty"`
+	End         float64     `json:"end,omitempty"`
+	ReplayGain  *ReplayGain `json:"replaygain,omitempty"`
+}
+
+// MarshalJSON encodes the Rola as a JSON object with all its fields.
+func (rola *Rola) MarshalJSON() ([]byte, error) {
+	var gain *ReplayGain
+	if rola.replayGain.TrackLoudness != 0 {
+		gain = rola.ReplayGain()
+	}
+	return json.Marshal(&rolaJSON{
+		ID:          rola.id,
+		Title:       rola.title,
+		Artist:      rola.artist,
+		Album:       rola.album,
+		Genre:       rola.genre,
+		Track:       rola.track,
+		Year:        rola.year,
+		Disc:        rola.disc,
+		AlbumArtist: rola.albumArtist,
+		Composer:    rola.composer,
+		BPM:         rola.bpm,
+		Comment:     rola.comment,
+		Lyrics:      rola.lyrics,
+		Duration:    rola.duration.Seconds(),
+		Bitrate:     rola.bitrate,
+		SampleRate:  rola.sampleRate,
+		Channels:    rola.channels,
+		VBR:         rola.vbr,
+		Artwork:     rola.artwork,
+		Path:        rola.path,
+		File:        rola.file,
+		Start:       rola.start.Seconds(),
+		End:         rola.end.Seconds(),
+		ReplayGain:  gain,
+	})
+}
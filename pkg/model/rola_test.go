@@ -1,7 +1,9 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestNewRola(t *testing.T) {
@@ -28,6 +30,15 @@ func TestNewRola(t *testing.T) {
 	if rola.artist != expecting {
 		t.Errorf("expecting %v, received %v", expecting, rola.path)
 	}
+	if rola.disc != 0 {
+		t.Errorf("expecting %v, received %v", 0, rola.disc)
+	}
+	if rola.albumArtist != "" {
+		t.Errorf("expecting %v, received %v", "", rola.albumArtist)
+	}
+	if rola.lyrics != "" {
+		t.Errorf("expecting %v, received %v", "", rola.lyrics)
+	}
 }
 
 func TestGetters(t *testing.T) {
@@ -65,6 +76,12 @@ func TestSetters(t *testing.T) {
 	year := 2010
 	genre := "Alternative"
 	path := "/Music/Mark Ronson/Record Collection/"
+	disc := 1
+	albumArtist := "Mark Ronson & The Business Intl."
+	composer := "Mark Ronson"
+	bpm := 96
+	comment := "Featuring Boy George"
+	lyrics := "Line one\nLine two\n"
 
 	rola.SetArtist(artist)
 	rola.SetTitle(title)
@@ -73,6 +90,12 @@ func TestSetters(t *testing.T) {
 	rola.SetYear(year)
 	rola.SetGenre(genre)
 	rola.SetPath(path)
+	rola.SetDisc(disc)
+	rola.SetAlbumArtist(albumArtist)
+	rola.SetComposer(composer)
+	rola.SetBPM(bpm)
+	rola.SetComment(comment)
+	rola.SetLyrics(lyrics)
 
 	if rola.Artist() != artist {
 		t.Errorf("expecting %v, received %v", artist, rola.Artist())
@@ -95,4 +118,47 @@ func TestSetters(t *testing.T) {
 	if rola.Path() != path {
 		t.Errorf("expecting %v, received %v", path, rola.Path())
 	}
+	if rola.Disc() != disc {
+		t.Errorf("expecting %v, received %v", disc, rola.Disc())
+	}
+	if rola.AlbumArtist() != albumArtist {
+		t.Errorf("expecting %v, received %v", albumArtist, rola.AlbumArtist())
+	}
+	if rola.Composer() != composer {
+		t.Errorf("expecting %v, received %v", composer, rola.Composer())
+	}
+	if rola.BPM() != bpm {
+		t.Errorf("expecting %v, received %v", bpm, rola.BPM())
+	}
+	if rola.Comment() != comment {
+		t.Errorf("expecting %v, received %v", comment, rola.Comment())
+	}
+	if rola.Lyrics() != "Line one\nLine two" {
+		t.Errorf("expecting %v, received %v", "Line one\nLine two", rola.Lyrics())
+	}
+}
+
+func TestRolaMarshalJSON(t *testing.T) {
+	rola := NewRola()
+	rola.SetID(7)
+	rola.SetTitle("Non Non Non")
+	rola.SetAudioProperties(&AudioProperties{Duration: 90 * time.Second, Bitrate: 320})
+	data, err := json.Marshal(rola)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if decoded["id"] != 7.0 {
+		t.Errorf("expecting %v, received %v", 7, decoded["id"])
+	}
+	if decoded["title"] != "Non Non Non" {
+		t.Errorf("expecting %v, received %v", "Non Non Non", decoded["title"])
+	}
+	if decoded["duration"] != 90.0 {
+		t.Errorf("expecting %v, received %v", 90, decoded["duration"])
+	}
+	if decoded["bitrate"] != 320.0 {
+		t.Errorf("expecting %v, received %v", 320, decoded["bitrate"])
+	}
 }
@@ -63,7 +63,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _rolasSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x54\xc1\x6e\xa3\x30\x10\xbd\xf3\x15\x73\x4b\x22\x11\xa9\xed\x71\x7b\x62\x2b\x37\x42\xdb\xa5\x95\x43\x57\xdb\x53\xe4\x84\xd9\x14\x95\x18\x64\x5c\xad\xfa\xf7\x95\x6d\x82\x81\x8c\x09\x37\xcf\xf3\xcc\xbc\xf7\xc6\xc3\x7a\x0d\x52\x9c\xf0\x07\x1c\x14\x0a\x8d\x6b\xfd\xd5\x60\xbb\xd6\x62\x5f\x61\xf4\xc0\x59\x92\x33\xc8\x93\x9f\x4f\x0c\x2c\x00\xcb\x08\x00\xa0\x2c\x76\xe6\x08\xee\x4b\xb3\x9c\x6d\x18\x87\x17\x9e\xfe\x4e\xf8\x1b\xfc\x62\x6f\xb1\xbd\x56\x60\x7b\x50\x65\xa3\xcb\x5a\x02\x40\xce\xfe\xe6\xd1\xea\x3e\x8a\xa8\x96\x37\x51\x9a\x6d\x19\xcf\x4d\xb1\xe7\xae\xd7\x9f\xe4\xe9\x95\x6d\x97\x37\xf1\xe2\x05\x55\x5b\xcb\xc5\xea\x9e\xcc\xbd\x0d\xe7\xde\xc6\x8b\x8d\xaa\x3f\x1b\x97\x7a\x91\x79\x17\xce\xbc\x8b\x17\xaf\xf2\x43\xd6\xff\x6d\xdb\x8b\xbe\x0d\xaa\x7f\xb5\x3a\xa1\x22\xbd\xf2\xa8\x37\xac\x8f\xcd\x18\x46\xfa\xea\x20\xd3\x1d\xfa\xcf\x78\xe9\xe2\x8f\xcf\x9c\xa5\x9b\xcc\xd4\x00\x80\x65\x57\x61\x05\x9c\x3d\x32\xce\xb2\x07\xb6\x75\xba\x7a\x84\x1c\x41\x63\x0d\x0e\x69\x31\xd0\x48\x48\x6b\x07\x3a\x23\xa4\xd5\xe2\x88\xbb\x33\x67\xcf\x56\xa1\xa8\xfa\xf0\x20\xbe\x2f\x95\x7e\xdf\x15\x42\x4f\xee\x17\x28\x26\x71\x92\xfe\xd1\xcc\x98\x64\xef\x10\x4f\xde\x9e\xaf\x3c\xdb\x90\xd5\xad\x16\x4a\x13\x24\x51\x16\x7d\x74\x86\xa4\xa8\xf6\x9f\x27\x92\xa4\x43\x3c\x49\x7b\xbe\x42\xb2\x11\xfa\x9d\x22\x19\x22\xff\x85\x42\xf9\x78\x57\x96\xe4\xa9\xea\x4a\x90\x34\x2d\xe0\x59\x9a\xe3\xb5\x3f\x00\xf9\xee\xe3\xb0\xce\x79\x6d\xba\xd4\x15\x52\x71\x25\x0e\x1f\x53\x6d\x33\xb2\x1d\x74\x44\xa9\xa8\x6a\x97\x1b\xd5\x4b\x18\xad\x95\x5f\xf2\xf1\x9d\x50\x15\xab\x76\x54\xc1\xcd\xdd\x63\xe4\x38\x4a\xe9\x1e\x2d\x35\x91\x33\x36\xb7\x9c\x71\xf8\xe9\x3b\x68\x30\x34\xaf\xb7\xad\x65\xdc\x27\x05\x25\xb9\x8b\x53\x57\xcc\xef\x62\x80\x86\x92\x5d\xe9\x61\x6e\xb7\xad\x1e\x34\x86\x7c\x07\x00\x00\xff\xff\x22\xcd\xd7\xda\xa1\x06\x00\x00")
+var _rolasSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xdd\x5a\x5b\x73\x22\xb7\x12\x7e\xe7\x57\xcc\x1b\x50\x11\x2e\xe3\xc7\xf8\xf8\x54\xb1\x66\x76\x43\x0e\xcb\xf8\x70\x49\xb2\x4f\xd4\x00\x32\x4c\x0c\x03\x35\x33\x64\xe3\x53\xf9\xf1\x47\x77\xb5\x6e\x33\xe0\x38\x49\x25\x7e\xd9\x1d\x49\xad\xfe\xfa\xa2\x56\x77\x8b\x5e\x2f\xca\xd3\x03\xfe\x36\x5a\x17\x38\xad\x70\xaf\x7a\x3d\xe1\xb2\x57\xa5\xab\x3d\x6e\x3d\x4e\xe3\xc1\x3c\x8e\xe6\x83\x0f\xe3\x38\x62\x13\x51\xa7\x15\x91\xbf\x6c\xb3\xa4\x9f\x11\xff\x1b\x4d\xe6\xf1\xa7\x78\x1a\x3d\x4d\x47\x9f\x07\xd3\x2f\xd1\x7f\xe2\x2f\x88\x2d\xdb\xe0\x72\x5d\x64\xa7\x2a\x3b\xe6\xe4\x6b\x1e\xff\x34\x6f\x75\xef\x5b\xad\x9e\x87\xe5\x6d\x6b\x34\x99\xc5\xd3\x39\xdd\x2c\x11\xbc\x7e\x18\x8c\x17\xf1\xac\x73\x8b\xda\x4f\xb8\x28\x8f\x79\x9b\x10\xfb\x68\xfb\x61\xda\x3e\x6a\x7f\x2a\x8e\xe7\x13\x27\x75\x28\xef\xc2\x94\x77\xa8\xbd\xc8\x5f\xf2\xe3\x57\xc6\xd6\xe1\x7b\xc2\xc5\xf3\xb1\x38\x10\x5c\x3e\x5d\xe9\x59\xad\x30\x35\x56\xa3\x30\xaf\x5e\xf9\x14\xe5\x1e\xa9\x3f\xaa\x4b\x3e\xfe\x31\x99\xc6\xa3\x4f\x13\xba\x07\xf9\xea\x88\x1d\xba\xd1\x34\xfe\x18\x4f\xe3\xc9\x63\x3c\xe3\x72\xa9\x99\x56\x40\x1c\xa2\xe0\x90\x2c\x74\xca\x10\xa4\x64\x06\xad\x11\xa4\xac\xd2\x2d\x5e\x4a\xcc\x1a\x2d\xe1\xb6\x57\xc3\x60\x7c\x95\x15\xd5\x6e\xb9\x21\x50\xcc\xf1\x0d\x41\x67\x8e\x7b\xe1\x6f\xa9\x8d\xbd\xe8\xf9\x8c\x06\xcf\xbe\x1b\xdc\x36\xa4\x6a\x22\x54\x51\x79\x40\xe2\x7c\xa3\x46\x6b\x40\xa6\xfb\xd5\xf9\xe0\x05\xc9\x67\x34\x48\xf6\xdd\x00\xf2\x44\x14\xe3\x03\x19\x02\xff\x8a\xd3\x42\x8f\x8b\x6d\xbd\x38\x8b\xe3\x3e\xf5\xc2\x64\x13\x1a\x25\xfd\x6c\x8a\x00\x5e\xbf\x47\x61\x39\xeb\x65\xab\xb2\x6a\x8f\x7d\xe3\x45\xba\x7e\xb1\x65\xab\x11\x9b\x4f\x6d\x71\x5e\xe0\x8b\x4e\x94\x12\xc1\x38\x56\xfa\x90\x9b\x6b\x42\xbb\x30\x69\x8d\x1d\xb8\xdd\xf5\x9c\xd7\x1c\x59\xce\x9d\xd6\x67\x11\x39\x57\x77\x38\x51\xd8\xf5\xf9\x14\x30\x9a\x96\x97\x6c\x82\x14\x51\xb7\x46\x31\x64\xa1\xad\x15\x1a\x2e\xc0\x6c\x88\x98\x6f\x0d\x69\xc5\x69\xd5\x93\xa6\x42\x0e\xd9\xb6\xa0\x1a\xc1\xbf\x56\xe4\xc8\xe1\x0d\xd1\xc8\xb6\x6c\x0d\xc6\x73\xe2\x79\xd0\x43\x07\xc3\x61\xf4\x98\x8c\x17\x9f\x27\xd1\x26\x2b\xd7\xca\x3b\x87\xf1\xc7\xc1\x62\x3c\x8f\x6e\xef\xeb\x89\x98\x31\x96\xe4\xac\x67\x65\xc5\xfc\x42\x51\xb6\xdb\x0d\xa4\xeb\xe3\xe1\x74\x2c\x89\xaf\x5f\x47\xb6\x3a\x1d\xae\x46\x49\x58\x1d\x70\x7e\x2d\xc0\xfd\x6b\x91\xad\x4b\x97\xc8\xd5\x72\x7a\xde\x64\xc7\xde\xa9\x38\x12\x33\x56\x19\x6e\x52\xf4\x99\x10\xd1\x7b\x9e\xb8\xe7\xf8\x62\x19\x56\x59\x45\x79\x5d\x2d\x7b\x99\x1e\x4e\x7b\xbc\x7c\x13\xed\x7a\x97\xe6\x39\xde\x97\x57\x13\xfe\xb2\x2a\x7c\x34\x1e\xcd\x15\xd5\xd7\x63\xf1\x62\xec\x26\x42\x3c\xf4\x32\xbe\xea\x4a\xfb\x05\xa9\x5c\x18\xa7\x7d\xfa\xba\x27\x2e\xdc\x60\x39\xba\x6c\xb9\x3e\x9e\x89\x2b\x5d\xab\x11\xf2\x59\x2d\x29\x3d\xde\xb8\x80\xcc\x24\x42\x62\x01\x91\x4a\x0c\xbd\xe5\x26\x96\xbe\xaf\xc7\x01\x6b\xbe\x84\x24\x6e\xb8\xf0\x90\x9e\xce\xab\x7d\xb6\xb6\x2e\x04\x2d\xb1\xd8\x9f\x85\xde\x8d\xcb\x97\xf8\xce\xd6\x18\xa7\x11\xca\x2b\xe9\xd2\xba\x2d\x5d\x71\x05\x9e\x63\x99\x89\x04\xd9\x13\xb3\xdd\x3b\x36\x18\xb3\xc5\xfe\x48\xed\x18\x8e\xda\x62\xa9\x19\xb7\xa5\x85\x8c\x15\xa1\x2d\x28\x2e\x83\x9c\x49\xab\x66\xfc\x71\x7b\x4d\xee\x05\x72\xde\xb3\x9c\xb8\xe4\xe2\x69\x48\x75\xc6\x95\x34\x8b\xe7\x66\xa6\xf0\x40\x2c\x9c\xee\x49\xf5\x80\x3b\x9d\x59\x3c\x8e\x1f\xe7\x64\x93\xbc\x73\xba\xbb\x31\x6f\xe3\x8f\xd3\xe4\x33\x4c\xb6\x4f\x7d\x86\xf7\xfb\x64\x34\x31\x86\xef\xa2\x84\x0c\xdc\xdd\x30\x7f\x7a\x20\xcb\xf8\xff\x7e\xfc\x8e\xc0\xa7\x5f\x16\x73\x86\xca\x64\x85\x0c\x80\x44\x3c\x9f\x00\x3c\x9f\x09\x80\x4f\x19\x78\x91\x04\x30\xe0\x22\x26\xa4\x00\xb4\x1c\x62\x80\x09\x05\xcb\x84\x1e\xc8\x12\xfe\xbf\xc1\x64\x48\x47\x85\x18\xa9\x21\x46\xda\xbf\x01\x10\x94\x08\x9c\x21\x52\xf0\x34\x74\xcb\x53\x85\x0c\xcc\xe3\x02\x22\x14\x4c\x04\x6e\x7b\x26\x01\xa7\x2c\x80\x00\x62\x84\xe1\x2f\x14\xfe\x42\xe0\xe7\x48\x8b\xfe\x8d\x66\x64\xa2\x50\xfb\x23\x89\x25\xa0\x6a\x68\xae\xc9\x62\x3c\x66\x08\xf8\xf6\xc6\xec\x24\xa1\xc5\x5d\x24\xa5\x30\xe6\x2c\xef\x69\x30\xaa\xcb\x85\xcf\xb8\x1c\xf8\x38\x30\x31\xd0\xb9\x76\x4a\xb1\x3d\xab\xf7\x1e\xa2\x3b\x73\x6b\x36\x3a\x9a\x31\xa6\x51\x32\x55\x43\x2e\x33\x36\xcc\x78\xb1\x32\x8f\x1e\xbc\x40\x29\xba\xcc\xf1\xd7\x77\x28\x47\x19\x06\x86\x4b\x06\xcd\xbb\xfa\x60\xbd\x98\x8c\xfe\xbb\x88\xe5\x78\x87\x2e\xeb\xbe\xb1\x76\xa5\x5e\x35\x24\xa2\x13\xe9\xa6\xf1\x6c\x3e\x1d\x3d\xb2\x00\x0c\x8b\x78\x5b\x5e\x28\x2a\x92\x12\x21\x06\xb6\xcb\x40\x78\x3c\xc3\x5a\x67\x3b\x0a\x30\x15\x9b\x36\xed\xa4\x15\x0b\x0c\x45\x4f\x4f\x7d\xe8\xfa\x34\x4d\x16\x4f\xd1\x87\x2f\x1c\x99\xc7\x8a\x34\xab\x76\x4c\xf8\x17\x16\xe2\x5e\xe3\x6a\x76\x5d\x8f\x61\xb4\x08\xa0\xc8\xd0\x24\x48\xa3\x42\x00\x08\x02\xcc\x3d\x26\xbb\x7e\x13\xa5\x7b\x8a\x07\x18\x13\xe8\xca\x31\x29\x55\xb4\xd7\x9e\xac\xfe\x81\x1b\x6a\x4b\x02\x65\xd8\xf6\xe4\x85\x8e\x69\xce\x3f\xa7\x35\x11\x3e\x93\xb6\xc1\x20\x46\x09\x8f\x1f\x08\x04\xf8\x21\xc5\xc3\xb6\x4d\x23\x01\x57\x1b\x67\x53\x7f\xa6\xb8\x6a\x5c\xfd\x8b\x12\x12\xec\xd3\x70\x8e\x78\x3c\x36\xf5\xfe\xa7\x75\x5b\xf8\x94\xcc\xe0\x6b\x72\x57\xcb\x3a\x94\xb9\x8c\x58\x96\x8d\xa0\x3c\x52\x14\x14\x69\x02\xc4\x90\x20\xc9\xd4\x36\x51\xd3\x7a\x78\x89\x01\x0b\x31\x6d\x00\x0b\x41\x8b\xb5\x04\x6a\x6e\x38\xae\x5b\xd7\x70\x9e\x34\x48\x19\x0e\x88\x6b\x9b\x8f\x5d\xcc\xa6\xf5\xfe\xa9\x5d\x28\xd6\xb9\x88\x1a\xea\x14\xa3\x53\xe1\xf7\x25\xd5\x91\x08\xbb\x1b\xed\x3e\x34\xb2\xba\xa0\xe4\x12\xbd\x85\xba\x25\xaa\x4d\xc0\xfe\xcc\x5e\x81\xbc\x80\x78\x4b\xa0\x16\x0c\xac\xff\xc3\x88\x65\xa1\x5f\xb7\x13\x2d\xea\x9b\xd5\xdc\x7c\x64\x41\x15\x1d\xde\x07\xd6\xcb\x97\x1f\xfd\xee\xfb\xb4\x25\x3d\xb9\xd3\xdb\x3b\x95\x17\x24\x62\xe0\xac\x8a\x63\x8a\xdc\x24\xcb\x08\x40\xec\x60\x21\x7e\x8e\x64\x20\x62\xc7\x03\xb5\x22\xff\x1f\x3d\x24\xc8\x38\x05\x48\x39\x3c\xa2\x7e\x8d\xa4\xdf\x22\xe1\x9d\x48\xb9\x20\x92\xae\x16\xdc\x1d\x78\x19\x52\xde\x84\xa8\xcb\xa8\x00\x89\x80\xe1\x11\xb4\xaf\x1d\x69\xdf\x4f\xfe\xf7\x97\xf9\x12\x39\xe1\x7a\xa7\x36\x2c\xcf\x87\x8e\xd6\x83\x59\x1b\xde\xc9\xa2\x4f\x17\x83\xac\xd0\xe3\x9e\x0d\xd4\xd7\xad\xe7\x71\x48\x7f\xed\x40\xfd\x5e\xc3\xc4\xb1\x8b\xa6\xad\xb9\xd5\xe4\xe5\xe2\xde\x5e\x4e\x05\x6c\xdc\x5d\xce\xad\x25\xbb\xf4\x4d\xd9\xfb\x5f\xde\xa9\x07\xa7\xfa\x71\x30\x7b\x1c\x0c\xe3\xcb\x7b\xf7\x76\xeb\xde\xdd\x0b\x04\x08\xa2\x5d\xb2\x1f\xd9\x95\x87\x0a\x53\x41\x3e\xb1\xe0\x69\xaa\x6f\x0d\xb9\xf9\xf8\xa9\xaf\x1a\x42\xfc\x5b\x76\x83\x40\xbe\xcf\x7a\x42\xfa\xdb\x1f\x11\xac\x76\x11\xb5\xde\x83\xc2\x0e\xd8\xa3\xc8\x7e\xfc\x08\x3a\x35\x41\xbe\x65\xc8\x3d\x99\xec\x56\xe0\x96\x9f\x0c\xf6\x56\x75\x7f\xb6\xbc\xfb\x53\x07\x75\xdb\x57\x5b\x5b\x48\x85\xb3\x58\x0a\x66\xdc\xe5\x32\xa7\xa3\x62\x95\x40\x7a\x10\x6a\xbb\x2b\xf0\xd0\x4e\x95\x2f\x71\x57\x63\x40\x52\xb7\xda\x95\xbd\x48\xeb\xc4\xfc\x63\x3a\xc6\x46\x5d\x6c\x0a\x6b\xb4\x72\x79\x4a\xae\xc2\x39\x43\x88\x04\x20\x24\x79\x22\xc9\xc4\x29\x8e\xdf\xb8\x8f\x30\xa8\xc4\x15\xb2\xce\xd2\x93\x8d\xff\x3d\xbb\xdc\x97\xc7\xbd\x86\xbe\x77\x6d\xd0\x33\xac\xbd\x34\xd3\x23\x57\x2e\xdd\x00\x0d\x5a\xd5\x59\x6a\x1a\x6e\x69\x5f\x70\xd0\x3e\xd6\x41\x96\xc3\xa6\xe5\x29\x3c\xeb\x40\xdb\x37\xa2\xc1\x59\xc9\x44\x4f\xf4\x70\x9a\x3c\x79\x3d\xe6\xde\x37\x65\x8e\xca\x18\x64\x0c\xba\xc4\x3c\x2b\x35\x86\x78\x44\x31\x59\xf0\xc8\x64\x8f\x89\x24\xd9\x7c\xe2\xb2\xba\x87\xc4\xc8\x83\xcf\xc4\xef\x93\x3a\x02\xd5\xd5\x32\x56\x73\x96\x70\x29\xe8\xa7\xe8\x95\x12\xaf\xfb\x56\x68\x2d\x94\xb2\x3a\x0f\x72\xd6\x3a\xa1\x25\xb8\xcc\xb8\x5e\xf5\x4a\xad\x64\x43\x20\x23\x1c\x01\x91\xb4\xa1\x7c\xcb\x97\x3e\x2c\xb6\xdd\x65\x1c\x19\x4d\x86\xf1\x4f\x02\xbd\x2e\xcc\x13\xf9\x7a\xd0\xb1\x9e\x5a\x3c\x54\xbc\x66\x37\x28\xe4\xeb\x86\x67\x35\xaf\xd8\xf5\x6a\xf6\x6d\x2f\x95\x5a\xa7\x17\x48\xa2\x5e\x62\x3a\xa2\x0f\x61\x2c\x95\x46\xd7\x4d\xd4\x44\xa7\x17\x1d\x35\x6a\x93\x29\x3b\xf0\x1b\x90\xd0\xe8\xdf\x6f\xa8\x8b\xd8\x66\x65\xea\x97\x9d\xb5\x64\xe2\xbc\x32\xea\xc7\x12\x2f\x75\xb9\xe4\x97\x1b\xa0\x24\x44\xe2\x1a\x90\x9d\x16\xf7\x01\x59\xda\xa0\xb7\xcf\xf2\x97\x32\x70\x50\xe0\x83\x30\x4c\x14\xf8\x35\x79\x69\xbe\x49\x5f\x43\x68\xe2\x7d\x7f\x19\x17\x99\x57\x38\x4c\x6a\xd2\x50\xcd\x22\xf8\x18\xa3\xb2\xba\xfa\x24\x47\x66\x82\xfc\xcb\xca\x23\xd5\xa6\x37\xfa\x8d\xc1\x7c\xd7\x79\xa0\x0f\xeb\x41\x0c\x32\x5d\xab\xcb\x98\x64\x7e\xc7\x3e\x6e\xae\xe1\xdc\x77\x5d\x59\x06\x3c\x21\x68\x62\x3c\x9d\x02\x6b\x85\x29\x95\x3f\x5b\x84\xd2\xa3\x5d\xd7\xa2\xed\xdf\x12\xe8\x80\x69\x95\x2a\x00\xbc\x3f\x3c\x90\x4a\x38\x3b\x74\x54\xd2\x0c\x9b\xf9\xed\x76\xb7\x6b\xb4\xf4\xed\xc5\x7a\x8a\x2f\x56\x0a\x17\x0a\xa4\xbc\x40\xd3\xdc\x26\x87\xed\x6a\xce\x4b\x35\xad\xed\xa5\x72\x42\xf2\xf1\x46\x0a\x20\x16\x0c\x15\x7a\xd8\x26\x14\x97\x05\x80\x98\xa8\x4a\x00\xa0\xf3\x2a\xf7\x80\x0f\x2b\xc2\x61\x97\x9d\xe8\x11\xce\x8e\x9b\xd2\x7b\x1d\xc0\x23\xf5\xf3\x31\xcb\xb1\x10\x90\x25\xc7\xce\x23\x9f\xfd\xcb\x14\xdf\x2e\x7b\xfc\x5c\xfd\xde\x3d\x48\x18\xc3\x65\x0d\xbd\x2b\x2e\x0d\x7c\xe0\xf7\xb8\x6e\xc7\x78\xe9\xfb\x39\xae\xa7\x6f\x2c\xf9\xd5\x34\x8d\xad\x35\x24\x73\xde\x64\x95\xd1\xf1\x74\x41\x1f\xd2\x2c\x6f\xd7\xa4\xc1\x2e\xc9\x6d\x28\xfd\xf5\x75\x91\x38\x86\xee\xbb\x65\xac\x7f\x58\xb7\xb1\xe5\xbb\x9c\x97\x66\x00\x32\x53\x81\xa5\x1b\x88\x54\x52\x60\xb7\x1a\xed\xb5\x61\x55\x81\xda\xe1\x82\x26\x9d\x30\x5f\x74\x0b\xfb\x3d\x9e\x1f\x1a\xd0\xc6\x51\x22\x2f\x98\x80\x93\xb2\x66\x9e\xcf\x41\xf9\xc4\x15\xce\xe9\xbe\x1a\x58\x0b\xde\xc7\xd3\x18\x9b\xee\xbb\x16\x43\xae\x07\x70\xe1\xf9\x3f\xca\xf2\x52\x23\x1c\xc1\x7d\xeb\xc7\xd1\xfc\x3b\xc2\xea\x71\x31\x9d\x8d\x7e\x88\xa3\xf2\xb4\x27\xe7\xce\x02\x4a\x9f\x7e\x8d\xd2\x30\x1a\xcc\x84\x4e\x6d\x1b\x93\x9a\x5f\x77\x63\x04\x31\x09\xdf\xd1\x6f\xbf\x45\xed\x7b\x32\xd7\xeb\xdb\x3d\xc2\xc5\x64\x44\xb0\x0d\xc4\x03\x97\xb5\x1f\x6c\xc2\xb0\xdb\xa1\x3c\xaf\xca\xaa\xe8\x70\x3c\x7d\xe2\x53\xb9\xfe\x24\x0c\xba\x51\x2f\xea\x77\xcd\xe6\x90\x41\xe2\xac\xff\x86\xac\x37\x96\x2b\x03\x93\x19\xdd\xbc\xe1\x7a\x11\xcd\x50\x42\x1d\xfd\xeb\xdf\x44\xae\x56\x37\xd0\x78\x33\x54\x6d\x2b\xb3\xfe\x94\x58\x8b\x5c\xf6\xdc\x9e\x8c\x3f\x61\x3b\x24\xde\xf7\xe1\x8b\x26\x97\x74\xbe\xb3\xc2\x28\x7b\xbb\x0c\x17\x69\xb1\xde\xbd\x5a\x2f\xe7\xd6\x51\x01\x27\xe1\x8a\xa6\x90\x3e\x09\xe2\xc1\x85\xdc\x3e\x63\xca\x64\x92\x10\x5f\x8d\xf5\x0d\x90\x16\xb2\x6b\x64\x74\x26\x3c\xe1\x91\xad\x34\x5b\xa2\x0c\x6a\x47\x82\xf4\xe5\xa2\xce\x89\x10\x87\x41\xf0\xa5\x77\xbe\xb6\x8e\x60\x71\xef\x51\x08\xa9\x7e\xb2\xb4\x54\x7a\x61\x5f\x01\x81\x03\x92\x7a\xf4\x68\x46\x14\x2b\x46\x30\x16\x48\x51\x86\x7f\xe5\xcd\x45\xbf\x4c\x2d\x8d\x9d\x62\xa9\x0d\xe7\xe7\x3b\x1c\xbe\x3a\xb3\xd2\xa9\xaf\x73\xbc\xcd\x99\xf8\xef\x9a\xa5\xa6\xf5\x3f\xc2\xa5\x3f\x8d\x5e\xee\xd2\x72\x77\x61\xa2\xe3\xec\xb0\xcb\x36\x1b\x9c\xd7\xc4\x65\x7f\xd5\xab\xf9\xea\x62\x56\x0f\x7a\x93\xc1\xe7\x2c\xdf\xe2\xe2\x54\xb0\x5f\x5d\xd6\x62\x02\x2b\xaf\xca\xbf\x0e\x59\x59\x12\xd2\xfa\xcd\xc5\xa2\x5a\x89\xeb\x7f\xf2\x9d\xfd\x0f\xd7\x52\x7b\x7e\x6f\x7a\xc6\xbd\x72\x87\x71\x93\xe0\xeb\x63\x5e\x91\x4b\x5e\xfe\x6a\xff\x7a\x6b\xf2\x7c\xfc\xf8\xfc\x5c\xe2\x8a\x3f\x6a\x5f\x2d\x1e\xad\x22\x9a\x36\xf0\x64\x15\x98\x96\xf3\x5b\x02\xbe\xe1\x87\xda\xc7\xf3\x26\xc7\x65\xf9\x46\x70\x27\x9c\xbe\xbc\x91\x94\xbf\x53\xfe\x4e\xfe\x7c\x93\x7a\x14\xff\x07\x5a\xcc\xea\x89\xc8\x38\x00\x00")
 
 func rolasSqlBytes() ([]byte, error) {
 	return bindataRead(
@@ -78,7 +78,7 @@ func rolasSql() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "rolas.sql", size: 1697, mode: os.FileMode(420), modTime: time.Unix(1539836583, 0)}
+	info := bindataFileInfo{name: "rolas.sql", size: 14536, mode: os.FileMode(420), modTime: time.Unix(1792421213, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -0,0 +1,315 @@
+package mpd
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A filter tells whether a song passes it.
+type filter func(*song) bool
+
+// match returns the songs that pass the filter in the arguments of find
+// or search, sorted by the tag after a "sort" argument and restricted
+// to the range after a "window" argument.   The comparisons of search
+// ignore case.
+func (server *Server) match(args []string, fold bool) ([]*song, error) {
+	sortTag, window := "", ""
+	for len(args) >= 2 {
+		name := strings.ToLower(args[len(args)-2])
+		if name == "sort" {
+			sortTag = strings.ToLower(strings.TrimPrefix(args[len(args)-1], "-"))
+		} else if name == "window" {
+			window = args[len(args)-1]
+		} else {
+			break
+		}
+		args = args[:len(args)-2]
+	}
+	if len(args) == 0 {
+		return nil, errArgs()
+	}
+	accept, err := server.parseFilter(args, fold)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*song, 0)
+	for _, current := range server.songs() {
+		if accept(current) {
+			found = append(found, current)
+		}
+	}
+	if sortTag != "" {
+		sortSongs(found, sortTag)
+	}
+	if window != "" {
+		start, end, err := parseRange(window, len(found))
+		if err != nil {
+			return nil, err
+		}
+		found = found[start:end]
+	}
+	return found, nil
+}
+
+// sortSongs sorts songs by the value of a tag, keeping the order of the
+// songs with the same value.
+func sortSongs(songs []*song, tag string) {
+	sort.SliceStable(songs, func(i, j int) bool {
+		return strings.ToLower(tagValue(songs[i], tag)) < strings.ToLower(tagValue(songs[j], tag))
+	})
+}
+
+// parseFilter parses a filter expression, or a list of pairs of a tag
+// and a value that must all match; the values of find must be equal to
+// the tags, and the values of search must be contained in them.   An
+// empty list of arguments accepts every song.
+func (server *Server) parseFilter(args []string, fold bool) (filter, error) {
+	if len(args) == 1 && strings.HasPrefix(args[0], "(") {
+		parser := &expressionParser{server: server, text: args[0], fold: fold}
+		accept, err := parser.parse()
+		if err == nil && strings.TrimSpace(parser.text[parser.position:]) != "" {
+			err = parser.fail("unexpected text after the expression")
+		}
+		return accept, err
+	}
+	if len(args)%2 != 0 {
+		return nil, errArgs()
+	}
+	operator := "=="
+	if fold {
+		operator = "contains"
+	}
+	filters := make([]filter, 0)
+	for i := 0; i < len(args); i += 2 {
+		var accept filter
+		var err error
+		if strings.ToLower(args[i]) == "base" {
+			accept = baseFilter(args[i+1])
+		} else {
+			accept, err = server.tagFilter(args[i], operator, args[i+1], fold)
+		}
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, accept)
+	}
+	return allOf(filters), nil
+}
+
+// tagFilter returns the filter comparing a tag of the songs with a value
+// with the given operator; the tag "any" compares every tag.   A search
+// of the tag "any" whose value starts with "*~*" is made by the
+// library, in the language of the Parser.
+func (server *Server) tagFilter(tag, operator, value string, fold bool) (filter, error) {
+	tag = strings.ToLower(tag)
+	if tag == "any" && strings.HasPrefix(value, "*~*") && (operator == "==" || operator == "contains") {
+		ids := make(map[int64]bool)
+		for _, id := range server.library.Search(value) {
+			ids[id] = true
+		}
+		return func(current *song) bool {
+			return ids[current.rola.ID()]
+		}, nil
+	}
+	if tag != "any" && tagName(tag) == "" {
+		return nil, &ackError{ackArg, "Unknown tag type: " + tag}
+	}
+
+	var compare func(string) bool
+	folded := value
+	if fold {
+		folded = strings.ToLower(value)
+	}
+	lower := func(text string) string {
+		if fold {
+			return strings.ToLower(text)
+		}
+		return text
+	}
+	switch operator {
+	case "==", "!=":
+		compare = func(text string) bool { return lower(text) == folded }
+	case "contains":
+		compare = func(text string) bool { return strings.Contains(lower(text), folded) }
+	case "starts_with":
+		compare = func(text string) bool { return strings.HasPrefix(lower(text), folded) }
+	case "=~", "!~":
+		if fold {
+			value = "(?i)" + value
+		}
+		expression, err := regexp.Compile(value)
+		if err != nil {
+			return nil, &ackError{ackArg, "invalid regular expression: " + err.Error()}
+		}
+		compare = expression.MatchString
+	default:
+		return nil, &ackError{ackArg, "unknown operator " + operator}
+	}
+
+	accept := func(current *song) bool {
+		if tag != "any" {
+			return compare(tagValue(current, tag))
+		}
+		for _, name := range tagNames {
+			if compare(tagValue(current, strings.ToLower(name))) {
+				return true
+			}
+		}
+		return compare(current.uri)
+	}
+	if operator == "!=" || operator == "!~" {
+		return not(accept), nil
+	}
+	return accept, nil
+}
+
+// baseFilter returns the filter of the songs inside the directory with
+// the given URI.
+func baseFilter(uri string) filter {
+	uri = strings.Trim(uri, "/")
+	return func(current *song) bool {
+		return inDirectory(current.uri, uri)
+	}
+}
+
+func allOf(filters []filter) filter {
+	return func(current *song) bool {
+		for _, accept := range filters {
+			if !accept(current) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func not(accept filter) filter {
+	return func(current *song) bool {
+		return !accept(current)
+	}
+}
+
+// An expressionParser parses the filter expressions of the protocol:
+//
+//	(TAG OPERATOR 'VALUE')
+//	(base 'VALUE')
+//	(!EXPRESSION)
+//	(EXPRESSION AND EXPRESSION ...)
+//
+// where OPERATOR is one of ==, !=, contains, starts_with, =~ and !~.
+type expressionParser struct {
+	server   *Server
+	text     string
+	position int
+	fold     bool
+}
+
+func (parser *expressionParser) parse() (filter, error) {
+	if !parser.consume("(") {
+		return nil, parser.fail("'(' expected")
+	}
+	var accept filter
+	var err error
+	switch {
+	case parser.consume("!"):
+		accept, err = parser.parse()
+		if err == nil {
+			accept = not(accept)
+		}
+	case parser.peek() == '(':
+		filters := make([]filter, 0)
+		for err == nil {
+			accept, err = parser.parse()
+			filters = append(filters, accept)
+			if !parser.consume("AND") {
+				break
+			}
+		}
+		accept = allOf(filters)
+	default:
+		tag := parser.word()
+		if strings.ToLower(tag) == "base" {
+			var value string
+			value, err = parser.quoted()
+			accept = baseFilter(value)
+			break
+		}
+		operator := parser.word()
+		var value string
+		value, err = parser.quoted()
+		if err == nil {
+			accept, err = parser.server.tagFilter(tag, operator, value, parser.fold)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !parser.consume(")") {
+		return nil, parser.fail("')' expected")
+	}
+	return accept, nil
+}
+
+// skip skips the spaces at the position of the parser.
+func (parser *expressionParser) skip() {
+	for parser.position < len(parser.text) && parser.text[parser.position] == ' ' {
+		parser.position++
+	}
+}
+
+// peek returns the next byte after the spaces, or 0 at the end.
+func (parser *expressionParser) peek() byte {
+	parser.skip()
+	if parser.position == len(parser.text) {
+		return 0
+	}
+	return parser.text[parser.position]
+}
+
+// consume skips the given token if it is next, and tells whether it was.
+func (parser *expressionParser) consume(token string) bool {
+	parser.skip()
+	if strings.HasPrefix(parser.text[parser.position:], token) {
+		parser.position += len(token)
+		return true
+	}
+	return false
+}
+
+// word returns the text up to the next space or parenthesis.
+func (parser *expressionParser) word() string {
+	parser.skip()
+	start := parser.position
+	for parser.position < len(parser.text) && !strings.ContainsRune(" ()'\"", rune(parser.text[parser.position])) {
+		parser.position++
+	}
+	return parser.text[start:parser.position]
+}
+
+// quoted returns the text of a value quoted with ' or ", where '\'
+// escapes the next character.
+func (parser *expressionParser) quoted() (string, error) {
+	quote := parser.peek()
+	if quote != '\'' && quote != '"' {
+		return "", parser.fail("quoted value expected")
+	}
+	var value []byte
+	for parser.position++; parser.position < len(parser.text); parser.position++ {
+		c := parser.text[parser.position]
+		if c == quote {
+			parser.position++
+			return string(value), nil
+		}
+		if c == '\\' && parser.position+1 < len(parser.text) {
+			parser.position++
+			c = parser.text[parser.position]
+		}
+		value = append(value, c)
+	}
+	return "", parser.fail("unterminated value")
+}
+
+func (parser *expressionParser) fail(message string) error {
+	return &ackError{ackArg, "invalid filter expression: " + message}
+}
@@ -0,0 +1,387 @@
+package mpd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// tagNames are the tags of the songs the server knows, as they are
+// written in the responses.
+var tagNames = []string{"Artist", "AlbumArtist", "Title", "Album", "Track", "Date", "Genre", "Composer", "Disc", "Comment"}
+
+// A song is a Rola of the library with its URI, the path of its file
+// relative to the music directory.   The track of a CUE sheet is named
+// as MPD names the tracks of a sheet embedded in a file: the URI of the
+// file followed by /track and the number of the track in four digits.
+type song struct {
+	rola *model.Rola
+	uri  string
+}
+
+// songs returns the songs of the library inside the music directory,
+// sorted by URI.
+func (server *Server) songs() []*song {
+	result := make([]*song, 0)
+	for _, rola := range server.library.AllRolas() {
+		uri, err := filepath.Rel(server.root, rola.File())
+		if err != nil || strings.HasPrefix(uri, "..") {
+			continue
+		}
+		uri = filepath.ToSlash(uri)
+		if rola.IsCueTrack() {
+			number, err := strconv.Atoi(rola.Path()[strings.LastIndex(rola.Path(), "#")+1:])
+			if err != nil {
+				continue
+			}
+			uri += fmt.Sprintf("/track%04d", number)
+		}
+		result = append(result, &song{rola, uri})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].uri < result[j].uri
+	})
+	return result
+}
+
+// resolve returns the song with the given URI, or the songs in the
+// directory with the given URI; the empty URI is the music directory.
+func (server *Server) resolve(uri string) ([]*song, error) {
+	uri = strings.Trim(uri, "/")
+	result := make([]*song, 0)
+	for _, current := range server.songs() {
+		if current.uri == uri || inDirectory(current.uri, uri) {
+			result = append(result, current)
+		}
+	}
+	if len(result) == 0 {
+		return nil, &ackError{ackNoExist, "No such song or directory"}
+	}
+	return result, nil
+}
+
+// inDirectory tells whether a URI is inside the directory with the given
+// URI.
+func inDirectory(uri, directory string) bool {
+	return directory == "" || strings.HasPrefix(uri, directory+"/")
+}
+
+// tagValue returns the value of a tag of a song, given its name in lower
+// case, or an empty string if the song has no value for it.
+func tagValue(current *song, tag string) string {
+	rola := current.rola
+	number := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	switch tag {
+	case "artist":
+		return rola.Artist()
+	case "albumartist":
+		return rola.AlbumArtist()
+	case "title":
+		return rola.Title()
+	case "album":
+		return rola.Album()
+	case "track":
+		return number(rola.Track())
+	case "date":
+		return number(rola.Year())
+	case "genre":
+		return rola.Genre()
+	case "composer":
+		return rola.Composer()
+	case "disc":
+		return number(rola.Disc())
+	case "comment":
+		return rola.Comment()
+	case "file":
+		return current.uri
+	}
+	return ""
+}
+
+// tagName returns the name of a tag as it is written in the responses,
+// given its name in any case, or an empty string if the tag is not
+// known.
+func tagName(tag string) string {
+	for _, name := range tagNames {
+		if strings.EqualFold(name, tag) {
+			return name
+		}
+	}
+	if strings.EqualFold(tag, "file") {
+		return "file"
+	}
+	return ""
+}
+
+// writeSong writes the URI, the tags and the duration of a song.
+func writeSong(w io.Writer, current *song) {
+	fmt.Fprintf(w, "file: %s\n", current.uri)
+	for _, name := range tagNames {
+		if value := tagValue(current, strings.ToLower(name)); value != "" {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+	if duration := current.rola.Duration(); duration > 0 {
+		fmt.Fprintf(w, "Time: %d\nduration: %.3f\n", int(duration.Seconds()), duration.Seconds())
+	}
+}
+
+// walk calls directory for every directory under the directory with the
+// given URI and file for every song under it, in the order of their
+// URIs.
+func (server *Server) walk(uri string, directory func(string), file func(*song)) error {
+	uri = strings.Trim(uri, "/")
+	found := uri == ""
+	seen := make(map[string]bool)
+	for _, current := range server.songs() {
+		if !inDirectory(current.uri, uri) {
+			continue
+		}
+		found = true
+		parts := strings.Split(current.uri, "/")
+		for i := range parts[:len(parts)-1] {
+			parent := strings.Join(parts[:i+1], "/")
+			if len(parent) > len(uri) && !seen[parent] {
+				seen[parent] = true
+				directory(parent)
+			}
+		}
+		file(current)
+	}
+	if !found {
+		return &ackError{ackNoExist, "No such directory"}
+	}
+	return nil
+}
+
+// lsinfo lists the directories and songs directly inside a directory,
+// and the stored playlists if the directory is the music directory.
+func (server *Server) lsinfo(session *session, args []string, w io.Writer) error {
+	uri := ""
+	if len(args) > 0 {
+		uri = strings.Trim(args[0], "/")
+	}
+	directories := make([]string, 0)
+	files := make([]*song, 0)
+	for _, current := range server.songs() {
+		if current.uri == uri {
+			writeSong(w, current)
+			return nil
+		}
+		if !inDirectory(current.uri, uri) {
+			continue
+		}
+		rest := strings.TrimPrefix(current.uri[len(uri):], "/")
+		if i := strings.Index(rest, "/"); i >= 0 {
+			directory := strings.TrimPrefix(uri+"/"+rest[:i], "/")
+			if len(directories) == 0 || directories[len(directories)-1] != directory {
+				directories = append(directories, directory)
+			}
+		} else {
+			files = append(files, current)
+		}
+	}
+	if uri != "" && len(directories) == 0 && len(files) == 0 {
+		return &ackError{ackNoExist, "No such directory"}
+	}
+	for _, directory := range directories {
+		fmt.Fprintf(w, "directory: %s\n", directory)
+	}
+	for _, current := range files {
+		writeSong(w, current)
+	}
+	if uri == "" {
+		return server.listplaylists(session, nil, w)
+	}
+	return nil
+}
+
+func (server *Server) listall(session *session, args []string, w io.Writer) error {
+	uri := ""
+	if len(args) > 0 {
+		uri = args[0]
+	}
+	return server.walk(uri, func(directory string) {
+		fmt.Fprintf(w, "directory: %s\n", directory)
+	}, func(current *song) {
+		fmt.Fprintf(w, "file: %s\n", current.uri)
+	})
+}
+
+func (server *Server) listallinfo(session *session, args []string, w io.Writer) error {
+	uri := ""
+	if len(args) > 0 {
+		uri = args[0]
+	}
+	return server.walk(uri, func(directory string) {
+		fmt.Fprintf(w, "directory: %s\n", directory)
+	}, func(current *song) {
+		writeSong(w, current)
+	})
+}
+
+func (server *Server) find(session *session, args []string, w io.Writer) error {
+	found, err := server.match(args, false)
+	for _, current := range found {
+		writeSong(w, current)
+	}
+	return err
+}
+
+func (server *Server) search(session *session, args []string, w io.Writer) error {
+	found, err := server.match(args, true)
+	for _, current := range found {
+		writeSong(w, current)
+	}
+	return err
+}
+
+func (server *Server) findadd(session *session, args []string, w io.Writer) error {
+	found, err := server.match(args, false)
+	if err == nil {
+		server.enqueue(found, -1)
+	}
+	return err
+}
+
+func (server *Server) searchadd(session *session, args []string, w io.Writer) error {
+	found, err := server.match(args, true)
+	if err == nil {
+		server.enqueue(found, -1)
+	}
+	return err
+}
+
+// list lists the distinct values of a tag in the songs that pass a
+// filter, grouped by the values of the tags after "group" arguments.
+// The old form "list album ARTIST" is also accepted.
+func (server *Server) list(session *session, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errArgs()
+	}
+	tag := strings.ToLower(args[0])
+	if tagName(tag) == "" {
+		return &ackError{ackArg, "Unknown tag type: " + args[0]}
+	}
+	rest, groups := splitGroups(args[1:])
+	if tag == "album" && len(rest) == 1 {
+		rest = []string{"artist", rest[0]}
+	}
+	accept, err := server.parseFilter(rest, false)
+	if err != nil {
+		return err
+	}
+
+	keys := make([][]string, 0)
+	seen := make(map[string]bool)
+	for _, current := range server.songs() {
+		if !accept(current) {
+			continue
+		}
+		key := make([]string, 0, len(groups)+1)
+		for _, group := range groups {
+			key = append(key, tagValue(current, group))
+		}
+		key = append(key, tagValue(current, tag))
+		joined := strings.Join(key, "\x00")
+		if key[len(key)-1] == "" || seen[joined] {
+			continue
+		}
+		seen[joined] = true
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Join(keys[i], "\x00") < strings.Join(keys[j], "\x00")
+	})
+
+	var previous []string
+	for _, key := range keys {
+		for i, group := range groups {
+			if previous == nil || previous[i] != key[i] {
+				fmt.Fprintf(w, "%s: %s\n", tagName(group), key[i])
+			}
+		}
+		fmt.Fprintf(w, "%s: %s\n", tagName(tag), key[len(key)-1])
+		previous = key
+	}
+	return nil
+}
+
+// count counts the songs that pass a filter and their duration, grouped
+// by the values of a tag after a "group" argument.
+func (server *Server) count(session *session, args []string, w io.Writer) error {
+	rest, groups := splitGroups(args)
+	accept, err := server.parseFilter(rest, false)
+	if err != nil {
+		return err
+	}
+	type total struct {
+		songs    int
+		playtime time.Duration
+	}
+	totals := make(map[string]*total)
+	values := make([]string, 0)
+	for _, current := range server.songs() {
+		if !accept(current) {
+			continue
+		}
+		value := ""
+		if len(groups) > 0 {
+			value = tagValue(current, groups[0])
+		}
+		if totals[value] == nil {
+			totals[value] = &total{}
+			values = append(values, value)
+		}
+		totals[value].songs++
+		totals[value].playtime += current.rola.Duration()
+	}
+	if len(groups) == 0 && len(values) == 0 {
+		values, totals[""] = append(values, ""), &total{}
+	}
+	for _, value := range sortedStrings(values) {
+		if len(groups) > 0 {
+			fmt.Fprintf(w, "%s: %s\n", tagName(groups[0]), value)
+		}
+		fmt.Fprintf(w, "songs: %d\nplaytime: %d\n", totals[value].songs, int(totals[value].playtime.Seconds()))
+	}
+	return nil
+}
+
+// splitGroups splits the "group TAG" arguments at the end of a command
+// from the rest, and returns the tags in lower case.
+func splitGroups(args []string) ([]string, []string) {
+	groups := make([]string, 0)
+	for len(args) >= 2 && strings.EqualFold(args[len(args)-2], "group") {
+		groups = append([]string{strings.ToLower(args[len(args)-1])}, groups...)
+		args = args[:len(args)-2]
+	}
+	return args, groups
+}
+
+func (server *Server) stats(session *session, args []string, w io.Writer) error {
+	artists := make(map[string]bool)
+	albums := make(map[string]bool)
+	var playtime time.Duration
+	songs := server.songs()
+	for _, current := range songs {
+		artists[current.rola.Artist()] = true
+		albums[current.rola.Album()] = true
+		playtime += current.rola.Duration()
+	}
+	fmt.Fprintf(w, "artists: %d\nalbums: %d\nsongs: %d\n", len(artists), len(albums), len(songs))
+	fmt.Fprintf(w, "uptime: %d\nplaytime: 0\n", int(time.Since(server.started).Seconds()))
+	fmt.Fprintf(w, "db_playtime: %d\ndb_update: %d\n", int(playtime.Seconds()), server.started.Unix())
+	return nil
+}
@@ -0,0 +1,359 @@
+// Package mpd serves the library of rolas over the protocol of the Music
+// Player Daemon, so MPD clients such as mpc or ncmpcpp can browse and
+// search the library, and keep a queue and stored playlists.   The
+// files of the library are named by their paths relative to the music
+// directory of the server.
+//
+// The filters of find, search, list and count are either pairs of a tag
+// and a value, or filter expressions such as (artist == 'Queen'); a
+// search of the tag "any" whose value starts with "*~*" is made in the
+// language of the Parser.   The server has no audio output: the player
+// is always stopped, and the playback commands fail.
+package mpd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// The version of the protocol the server speaks.
+const protocolVersion = "0.21.0"
+
+// A Library is the part of the database the server needs; a
+// model.Database is a Library.
+type Library interface {
+	AllRolas() []*model.Rola
+	Search(text string) []int64
+	AllPlaylists() []*model.Playlist
+	QueryPlaylist(playlistID int64) *model.Playlist
+	AddPlaylist(name, owner string, rolaIDs []int64) int64
+	SetPlaylistRolas(playlistID int64, rolaIDs []int64)
+	UpdatePlaylist(playlistID int64, name, comment string, public bool)
+	DeletePlaylist(playlistID int64)
+}
+
+// A Server answers the connections of MPD clients; the queue and its
+// options are shared by all of them.
+type Server struct {
+	library Library
+	root    string
+	started time.Time
+
+	mutex    sync.Mutex
+	queue    *queue
+	options  map[string]bool
+	sessions map[*session]bool
+}
+
+// A session is the connection of a client, with the subsystems that
+// changed since its last idle command.
+type session struct {
+	changed map[string]bool
+	wake    chan struct{}
+}
+
+// A command answers a command of the protocol, writing its response to
+// the given writer.
+type command func(server *Server, session *session, args []string, w io.Writer) error
+
+// commands maps the names of the commands of the protocol to their
+// handlers; idle, noidle, close and the command lists are handled by
+// the connection itself.   It is filled in init, since the commands
+// command lists it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":               (*Server).ping,
+		"commands":           (*Server).listCommands,
+		"notcommands":        (*Server).notCommands,
+		"tagtypes":           (*Server).tagTypes,
+		"urlhandlers":        (*Server).ping,
+		"outputs":            (*Server).ping,
+		"replay_gain_status": (*Server).replayGainStatus,
+		"status":             (*Server).status,
+		"stats":              (*Server).stats,
+		"currentsong":        (*Server).ping,
+		"lsinfo":             (*Server).lsinfo,
+		"listall":            (*Server).listall,
+		"listallinfo":        (*Server).listallinfo,
+		"find":               (*Server).find,
+		"search":             (*Server).search,
+		"findadd":            (*Server).findadd,
+		"searchadd":          (*Server).searchadd,
+		"list":               (*Server).list,
+		"count":              (*Server).count,
+		"add":                (*Server).add,
+		"addid":              (*Server).addid,
+		"clear":              (*Server).clear,
+		"delete":             (*Server).delete,
+		"deleteid":           (*Server).deleteid,
+		"move":               (*Server).move,
+		"moveid":             (*Server).moveid,
+		"shuffle":            (*Server).shuffle,
+		"playlistinfo":       (*Server).playlistinfo,
+		"playlistid":         (*Server).playlistid,
+		"plchanges":          (*Server).plchanges,
+		"plchangesposid":     (*Server).plchangesposid,
+		"repeat":             option("repeat"),
+		"random":             option("random"),
+		"single":             option("single"),
+		"consume":            option("consume"),
+		"play":               (*Server).playback,
+		"playid":             (*Server).playback,
+		"pause":              (*Server).playback,
+		"stop":               (*Server).playback,
+		"next":               (*Server).playback,
+		"previous":           (*Server).playback,
+		"seek":               (*Server).playback,
+		"seekid":             (*Server).playback,
+		"seekcur":            (*Server).playback,
+		"setvol":             (*Server).playback,
+		"listplaylists":      (*Server).listplaylists,
+		"listplaylist":       (*Server).listplaylist,
+		"listplaylistinfo":   (*Server).listplaylistinfo,
+		"load":               (*Server).load,
+		"save":               (*Server).save,
+		"rm":                 (*Server).rm,
+		"rename":             (*Server).rename,
+		"playlistadd":        (*Server).playlistadd,
+		"playlistclear":      (*Server).playlistclear,
+		"playlistdelete":     (*Server).playlistdelete,
+	}
+}
+
+// NewServer returns a Server over the given library, whose files are
+// named relative to the given music directory.
+func NewServer(library Library, root string) *Server {
+	return &Server{
+		library:  library,
+		root:     strings.TrimSuffix(root, "/"),
+		started:  time.Now(),
+		queue:    newQueue(),
+		options:  map[string]bool{"repeat": false, "random": false, "single": false, "consume": false},
+		sessions: make(map[*session]bool),
+	}
+}
+
+// ListenAndServe listens on the given TCP address and serves the
+// connections of the clients.
+func (server *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Serve serves the connections accepted by the listener, each one in
+// its own goroutine.
+func (server *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.serve(conn)
+	}
+}
+
+// notify tells the sessions that the given subsystem changed, waking up
+// the ones waiting in an idle command.   The mutex of the server must
+// be held.
+func (server *Server) notify(subsystem string) {
+	for current := range server.sessions {
+		current.changed[subsystem] = true
+		select {
+		case current.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// serve reads the commands of a connection and writes their responses,
+// until the client closes it.
+func (server *Server) serve(conn net.Conn) {
+	current := &session{
+		changed: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	server.mutex.Lock()
+	server.sessions[current] = true
+	server.mutex.Unlock()
+	defer func() {
+		server.mutex.Lock()
+		delete(server.sessions, current)
+		server.mutex.Unlock()
+		conn.Close()
+	}()
+
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "OK MPD %s\n", protocolVersion)
+	writer.Flush()
+
+	var list []string
+	inList, listOK := false, false
+	for line := range lines {
+		args, err := splitArgs(line)
+		if err != nil {
+			writeAck(writer, err, 0, "")
+			writer.Flush()
+			continue
+		}
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		switch {
+		case inList && name == "command_list_end":
+			server.execList(current, list, listOK, writer)
+			inList, list = false, nil
+		case inList:
+			list = append(list, line)
+			continue
+		case name == "command_list_begin" || name == "command_list_ok_begin":
+			inList, listOK = true, name == "command_list_ok_begin"
+			continue
+		case name == "close":
+			return
+		case name == "noidle":
+			continue
+		case name == "idle":
+			if !server.idle(current, args[1:], lines, writer) {
+				return
+			}
+		default:
+			server.execList(current, []string{line}, false, writer)
+		}
+		writer.Flush()
+	}
+}
+
+// execList executes a list of commands, writing list_OK after each one
+// if listOK is true, and OK at the end; the first command that fails
+// stops the list with its error.
+func (server *Server) execList(current *session, list []string, listOK bool, w io.Writer) {
+	for i, line := range list {
+		args, err := splitArgs(line)
+		name := ""
+		if err == nil && len(args) == 0 {
+			err = &ackError{ackUnknown, "No command given"}
+		}
+		if err == nil {
+			name = args[0]
+			handler, ok := commands[name]
+			if !ok {
+				err = &ackError{ackUnknown, "unknown command \"" + name + "\""}
+			} else {
+				response := &bytes.Buffer{}
+				err = handler(server, current, args[1:], response)
+				if err == nil {
+					w.Write(response.Bytes())
+				}
+			}
+		}
+		if err != nil {
+			writeAck(w, err, i, name)
+			return
+		}
+		if listOK {
+			io.WriteString(w, "list_OK\n")
+		}
+	}
+	io.WriteString(w, "OK\n")
+}
+
+// idle waits until one of the given subsystems, or any of them if none
+// is given, changes, or until the client sends noidle; it returns false
+// if the connection was closed while waiting.
+func (server *Server) idle(current *session, subsystems []string, lines <-chan string, w *bufio.Writer) bool {
+	for {
+		server.mutex.Lock()
+		changed := make([]string, 0)
+		for subsystem := range current.changed {
+			if len(subsystems) == 0 || contains(subsystems, subsystem) {
+				changed = append(changed, subsystem)
+				delete(current.changed, subsystem)
+			}
+		}
+		server.mutex.Unlock()
+		if len(changed) > 0 {
+			for _, subsystem := range changed {
+				fmt.Fprintf(w, "changed: %s\n", subsystem)
+			}
+			io.WriteString(w, "OK\n")
+			return true
+		}
+
+		w.Flush()
+		select {
+		case <-current.wake:
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			if strings.TrimSpace(line) != "noidle" {
+				log.Println("mpd: command sent while idle, closing the connection:", line)
+				return false
+			}
+			io.WriteString(w, "OK\n")
+			return true
+		}
+	}
+}
+
+func (server *Server) ping(session *session, args []string, w io.Writer) error {
+	return nil
+}
+
+func (server *Server) listCommands(session *session, args []string, w io.Writer) error {
+	names := []string{"close", "command_list_begin", "command_list_end", "command_list_ok_begin", "idle", "noidle"}
+	for name := range commands {
+		names = append(names, name)
+	}
+	for _, name := range sortedStrings(names) {
+		fmt.Fprintf(w, "command: %s\n", name)
+	}
+	return nil
+}
+
+func (server *Server) notCommands(session *session, args []string, w io.Writer) error {
+	return nil
+}
+
+func (server *Server) tagTypes(session *session, args []string, w io.Writer) error {
+	for _, tag := range tagNames {
+		fmt.Fprintf(w, "tagtype: %s\n", tag)
+	}
+	return nil
+}
+
+func (server *Server) replayGainStatus(session *session, args []string, w io.Writer) error {
+	io.WriteString(w, "replay_gain_mode: off\n")
+	return nil
+}
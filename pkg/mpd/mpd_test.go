@@ -0,0 +1,341 @@
+package mpd
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// A library is a Library kept in memory.
+type library struct {
+	rolas     []*model.Rola
+	playlists []*model.Playlist
+}
+
+func newLibrary() *library {
+	rolas := []struct {
+		artist, album, title, path string
+		track                      int
+	}{
+		{"Queen", "A Night at the Opera", "Love of My Life", "Queen/Opera/09.mp3", 9},
+		{"Queen", "A Night at the Opera", "Bohemian Rhapsody", "Queen/Opera/11.mp3", 11},
+		{"Queen", "Innuendo", "Innuendo", "Queen/Innuendo/01.mp3", 1},
+		{"Café Tacvba", "Re", "El Aparato", "Cafe Tacvba/Re/02.flac", 2},
+		{"Elsewhere", "Nowhere", "Outside", "/elsewhere/01.mp3", 1},
+	}
+	result := &library{playlists: make([]*model.Playlist, 0)}
+	for i, fields := range rolas {
+		rola := model.NewRola()
+		rola.SetID(int64(i + 1))
+		rola.SetArtist(fields.artist)
+		rola.SetAlbum(fields.album)
+		rola.SetTitle(fields.title)
+		rola.SetTrack(fields.track)
+		if !strings.HasPrefix(fields.path, "/") {
+			fields.path = "/music/" + fields.path
+		}
+		rola.SetPath(fields.path)
+		rola.SetAudioProperties(&model.AudioProperties{Duration: 200 * time.Second})
+		result.rolas = append(result.rolas, rola)
+	}
+	return result
+}
+
+func (library *library) AllRolas() []*model.Rola {
+	return library.rolas
+}
+
+// Search only understands searches of titles in the language of the
+// Parser: '*~* *TI*title'.
+func (library *library) Search(text string) []int64 {
+	ids := make([]int64, 0)
+	title := strings.TrimPrefix(text, "*~* *TI*")
+	for _, rola := range library.rolas {
+		if rola.Title() == title {
+			ids = append(ids, rola.ID())
+		}
+	}
+	return ids
+}
+
+func (library *library) AllPlaylists() []*model.Playlist {
+	return library.playlists
+}
+
+func (library *library) QueryPlaylist(playlistID int64) *model.Playlist {
+	return library.playlists[playlistID-1]
+}
+
+func (library *library) AddPlaylist(name, owner string, rolaIDs []int64) int64 {
+	id := int64(len(library.playlists) + 1)
+	library.playlists = append(library.playlists, &model.Playlist{ID: id, Name: name, Owner: owner, Rolas: rolaIDs})
+	return id
+}
+
+func (library *library) SetPlaylistRolas(playlistID int64, rolaIDs []int64) {
+	library.playlists[playlistID-1].Rolas = rolaIDs
+}
+
+func (library *library) UpdatePlaylist(playlistID int64, name, comment string, public bool) {
+	playlist := library.playlists[playlistID-1]
+	playlist.Name, playlist.Comment, playlist.Public = name, comment, public
+}
+
+func (library *library) DeletePlaylist(playlistID int64) {
+	library.playlists[playlistID-1].Name = ""
+}
+
+// A client is a connection to a server over loopback.
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// listen starts a server over a new library, and returns a function to
+// connect clients to it.
+func listen(t *testing.T) (func() *client, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewServer(newLibrary(), "/music").Serve(listener)
+	connect := func() *client {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		current := &client{t, conn, bufio.NewReader(conn)}
+		if greeting := current.line(); !strings.HasPrefix(greeting, "OK MPD ") {
+			t.Fatalf("expecting %v, received %v", "OK MPD", greeting)
+		}
+		return current
+	}
+	return connect, func() { listener.Close() }
+}
+
+func (current *client) line() string {
+	line, err := current.reader.ReadString('\n')
+	if err != nil {
+		current.t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\n")
+}
+
+// send sends a command and reads its response.
+func (current *client) send(command string) ([]string, string) {
+	current.write(command)
+	return current.read()
+}
+
+func (current *client) write(commands string) {
+	_, err := current.conn.Write([]byte(commands + "\n"))
+	if err != nil {
+		current.t.Fatal(err)
+	}
+}
+
+// read returns the lines of a response, up to the final OK or ACK
+// line, which is returned apart.
+func (current *client) read() ([]string, string) {
+	lines := make([]string, 0)
+	for {
+		line := current.line()
+		if line == "OK" || strings.HasPrefix(line, "ACK ") {
+			return lines, line
+		}
+		lines = append(lines, line)
+	}
+}
+
+// values returns the values of the lines of a response with the given
+// key.
+func values(lines []string, key string) []string {
+	result := make([]string, 0)
+	for _, line := range lines {
+		if strings.HasPrefix(line, key+": ") {
+			result = append(result, line[len(key)+2:])
+		}
+	}
+	return result
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`find  artist "Guns N' Roses" "quoted \"title\""`)
+	expected := []string{"find", "artist", "Guns N' Roses", `quoted "title"`}
+	if err != nil || !reflect.DeepEqual(args, expected) {
+		t.Errorf("expecting %v, received %v", expected, args)
+	}
+	if _, err := splitArgs(`find artist "open`); err == nil {
+		t.Errorf("expecting an error for an unterminated argument")
+	}
+}
+
+func TestDatabaseCommands(t *testing.T) {
+	connect, stop := listen(t)
+	defer stop()
+	current := connect()
+	defer current.conn.Close()
+
+	requests := []struct {
+		command  string
+		key      string
+		expected []string
+	}{
+		{"lsinfo", "directory", []string{"Cafe Tacvba", "Queen"}},
+		{`lsinfo "Queen/"`, "directory", []string{"Queen/Innuendo", "Queen/Opera"}},
+		{"listall Queen/Opera", "file", []string{"Queen/Opera/09.mp3", "Queen/Opera/11.mp3"}},
+		{"listallinfo", "directory", []string{"Cafe Tacvba", "Cafe Tacvba/Re", "Queen", "Queen/Innuendo", "Queen/Opera"}},
+		{`find album "A Night at the Opera"`, "Title", []string{"Love of My Life", "Bohemian Rhapsody"}},
+		{`find album "a night at the opera"`, "Title", []string{}},
+		{`search title "RHAPSODY"`, "file", []string{"Queen/Opera/11.mp3"}},
+		{`search any "café"`, "Title", []string{"El Aparato"}},
+		{`find "((artist == 'Queen') AND (!(album == 'Innuendo')))" sort title`, "Title", []string{"Bohemian Rhapsody", "Love of My Life"}},
+		{`search "(title contains 'life')"`, "Track", []string{"9"}},
+		{`find "(title =~ '^[BL]')" window 1:2`, "Title", []string{"Bohemian Rhapsody"}},
+		{`find base Queen/Innuendo`, "Title", []string{"Innuendo"}},
+		{`search any "*~* *TI*Innuendo"`, "file", []string{"Queen/Innuendo/01.mp3"}},
+		{"list album artist Queen", "Album", []string{"A Night at the Opera", "Innuendo"}},
+		{"list album Queen", "Album", []string{"A Night at the Opera", "Innuendo"}},
+		{"list album group artist", "Artist", []string{"Café Tacvba", "Queen"}},
+		{"count artist Queen", "playtime", []string{"600"}},
+		{"stats", "songs", []string{"4"}},
+	}
+	for _, request := range requests {
+		lines, end := current.send(request.command)
+		if end != "OK" {
+			t.Errorf("%v: expecting OK, received %v", request.command, end)
+		}
+		if received := values(lines, request.key); !reflect.DeepEqual(received, request.expected) {
+			t.Errorf("%v: expecting %v, received %v", request.command, request.expected, received)
+		}
+	}
+
+	for _, command := range []string{"lsinfo Nothing", "find artist", "find mood happy", `find "(artist === 'x')"`, "play", "nothing"} {
+		if _, end := current.send(command); !strings.HasPrefix(end, "ACK ") {
+			t.Errorf("%v: expecting ACK, received %v", command, end)
+		}
+	}
+}
+
+func TestCueTracks(t *testing.T) {
+	library := newLibrary()
+	rola := model.NewRola()
+	rola.SetID(int64(len(library.rolas) + 1))
+	rola.SetTitle("Somebody to Love")
+	rola.SetPath("/music/Queen/Live/live.flac#02")
+	rola.SetCueTrack("/music/Queen/Live/live.flac", time.Minute, 0)
+	library.rolas = append(library.rolas, rola)
+	server := NewServer(library, "/music")
+
+	songs, err := server.resolve("Queen/Live/live.flac/track0002")
+	if err != nil || len(songs) != 1 || songs[0].rola != rola {
+		t.Errorf("expecting %v, received %v", rola, songs)
+	}
+	songs, err = server.resolve("Queen/Live")
+	if err != nil || len(songs) != 1 || songs[0].uri != "Queen/Live/live.flac/track0002" {
+		t.Errorf("expecting %v, received %v", "Queen/Live/live.flac/track0002", songs)
+	}
+}
+
+func TestQueueCommands(t *testing.T) {
+	connect, stop := listen(t)
+	defer stop()
+	current := connect()
+	defer current.conn.Close()
+
+	requests := []struct {
+		command  string
+		key      string
+		expected []string
+	}{
+		{"add Queen/Opera", "", []string{}},
+		{`addid "Queen/Innuendo/01.mp3" 0`, "Id", []string{"3"}},
+		{"playlistinfo", "file", []string{"Queen/Innuendo/01.mp3", "Queen/Opera/09.mp3", "Queen/Opera/11.mp3"}},
+		{"move 0 2", "", []string{}},
+		{"playlistid", "Id", []string{"1", "2", "3"}},
+		{"plchangesposid 3", "cpos", []string{"0", "1", "2"}},
+		{"deleteid 2", "", []string{}},
+		{"plchanges 4", "Pos", []string{"1"}},
+		{"random 1", "", []string{}},
+		{"status", "random", []string{"1"}},
+		{"status", "playlistlength", []string{"2"}},
+		{"status", "state", []string{"stop"}},
+		{"save mix", "", []string{}},
+		{"listplaylists", "playlist", []string{"mix"}},
+		{`playlistadd mix "Cafe Tacvba/Re/02.flac"`, "", []string{}},
+		{"listplaylist mix", "file", []string{"Queen/Opera/09.mp3", "Queen/Innuendo/01.mp3", "Cafe Tacvba/Re/02.flac"}},
+		{"playlistdelete mix 0", "", []string{}},
+		{"clear", "", []string{}},
+		{"load mix 1:", "file", []string{}},
+		{"playlistinfo", "Title", []string{"El Aparato"}},
+	}
+	for _, request := range requests {
+		lines, end := current.send(request.command)
+		if end != "OK" {
+			t.Errorf("%v: expecting OK, received %v", request.command, end)
+		}
+		if received := values(lines, request.key); request.key != "" && !reflect.DeepEqual(received, request.expected) {
+			t.Errorf("%v: expecting %v, received %v", request.command, request.expected, received)
+		}
+	}
+
+	if _, end := current.send("save mix"); !strings.HasPrefix(end, "ACK [56@0]") {
+		t.Errorf("expecting %v, received %v", "ACK [56@0]", end)
+	}
+}
+
+func TestCommandList(t *testing.T) {
+	connect, stop := listen(t)
+	defer stop()
+	current := connect()
+	defer current.conn.Close()
+
+	current.write("command_list_ok_begin\nping\nadd Queen\nstatus\ncommand_list_end")
+	lines, end := current.read()
+	expected := []string{"list_OK", "list_OK", "repeat: 0", "random: 0", "single: 0", "consume: 0",
+		"playlist: 2", "playlistlength: 3", "state: stop", "list_OK"}
+	if end != "OK" || !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expecting %v, received %v %v", expected, lines, end)
+	}
+
+	current.write("command_list_begin\nclear\nmove 5 0\nadd Queen\ncommand_list_end")
+	if _, end := current.read(); !strings.HasPrefix(end, "ACK [2@1] {move}") {
+		t.Errorf("expecting %v, received %v", "ACK [2@1] {move}", end)
+	}
+	if lines, _ := current.send("status"); !reflect.DeepEqual(values(lines, "playlistlength"), []string{"0"}) {
+		t.Errorf("the list was not stopped at the failed command: %v", lines)
+	}
+}
+
+func TestIdle(t *testing.T) {
+	connect, stop := listen(t)
+	defer stop()
+	waiting := connect()
+	defer waiting.conn.Close()
+	other := connect()
+	defer other.conn.Close()
+
+	waiting.write("idle playlist options")
+	other.send("add Queen/Innuendo")
+	lines, end := waiting.read()
+	if end != "OK" || !reflect.DeepEqual(values(lines, "changed"), []string{"playlist"}) {
+		t.Errorf("expecting %v, received %v", "changed: playlist", lines)
+	}
+
+	other.send("single 1")
+	if lines, _ := waiting.send("idle"); !reflect.DeepEqual(values(lines, "changed"), []string{"options"}) {
+		t.Errorf("expecting %v, received %v", "changed: options", lines)
+	}
+
+	waiting.write("idle")
+	if lines, end := waiting.send("noidle"); end != "OK" || len(lines) != 0 {
+		t.Errorf("expecting %v, received %v %v", "OK", lines, end)
+	}
+}
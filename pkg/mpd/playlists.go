@@ -0,0 +1,228 @@
+package mpd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// playlistOwner is the owner of the playlists saved by MPD clients,
+// which are public so that every user of the library can see them.
+const playlistOwner = "mpd"
+
+// storedPlaylist returns the playlist of the library with the given
+// name, or an error if there is none.
+func (server *Server) storedPlaylist(name string) (*model.Playlist, error) {
+	for _, playlist := range server.library.AllPlaylists() {
+		if playlist.Name == name {
+			return playlist, nil
+		}
+	}
+	return nil, &ackError{ackNoExist, "No such playlist"}
+}
+
+// playlistSongs returns the songs of a playlist that are in the music
+// directory, in order.
+func (server *Server) playlistSongs(playlist *model.Playlist) []*song {
+	byID := songsByID(server.songs())
+	result := make([]*song, 0, len(playlist.Rolas))
+	for _, id := range playlist.Rolas {
+		if current, ok := byID[id]; ok {
+			result = append(result, current)
+		}
+	}
+	return result
+}
+
+// changePlaylist calls change with the playlist with the given name,
+// and notifies the clients that the stored playlists changed.
+func (server *Server) changePlaylist(name string, change func(*model.Playlist) error) error {
+	playlist, err := server.storedPlaylist(name)
+	if err != nil {
+		return err
+	}
+	err = change(playlist)
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	server.notify("stored_playlist")
+	server.mutex.Unlock()
+	return nil
+}
+
+// addPlaylist adds a public playlist with the given name and Rolas.
+func (server *Server) addPlaylist(name string, rolas []int64) {
+	id := server.library.AddPlaylist(name, playlistOwner, rolas)
+	server.library.UpdatePlaylist(id, name, "", true)
+	server.mutex.Lock()
+	server.notify("stored_playlist")
+	server.mutex.Unlock()
+}
+
+func (server *Server) listplaylists(session *session, args []string, w io.Writer) error {
+	for _, playlist := range server.library.AllPlaylists() {
+		fmt.Fprintf(w, "playlist: %s\nLast-Modified: %s\n", playlist.Name,
+			playlist.Changed.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	return nil
+}
+
+func (server *Server) listplaylist(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	playlist, err := server.storedPlaylist(args[0])
+	if err != nil {
+		return err
+	}
+	for _, current := range server.playlistSongs(playlist) {
+		fmt.Fprintf(w, "file: %s\n", current.uri)
+	}
+	return nil
+}
+
+func (server *Server) listplaylistinfo(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	playlist, err := server.storedPlaylist(args[0])
+	if err != nil {
+		return err
+	}
+	for _, current := range server.playlistSongs(playlist) {
+		writeSong(w, current)
+	}
+	return nil
+}
+
+// load adds the songs of a stored playlist, or the ones in a range, to
+// the end of the queue.
+func (server *Server) load(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errArgs()
+	}
+	playlist, err := server.storedPlaylist(args[0])
+	if err != nil {
+		return err
+	}
+	songs := server.playlistSongs(playlist)
+	if len(args) == 2 {
+		start, end, err := parseRange(args[1], len(songs))
+		if err != nil {
+			return err
+		}
+		songs = songs[start:end]
+	}
+	server.enqueue(songs, -1)
+	return nil
+}
+
+// save saves the queue as a stored playlist.
+func (server *Server) save(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	if _, err := server.storedPlaylist(args[0]); err == nil {
+		return &ackError{ackExist, "Playlist already exists"}
+	}
+	server.mutex.Lock()
+	songs := make([]*song, 0, len(server.queue.entries))
+	for _, current := range server.queue.entries {
+		songs = append(songs, current.song)
+	}
+	server.mutex.Unlock()
+	server.addPlaylist(args[0], rolaIDs(songs))
+	return nil
+}
+
+func (server *Server) rm(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	return server.changePlaylist(args[0], func(playlist *model.Playlist) error {
+		server.library.DeletePlaylist(playlist.ID)
+		return nil
+	})
+}
+
+func (server *Server) rename(session *session, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errArgs()
+	}
+	if _, err := server.storedPlaylist(args[1]); err == nil {
+		return &ackError{ackExist, "Playlist already exists"}
+	}
+	return server.changePlaylist(args[0], func(playlist *model.Playlist) error {
+		server.library.UpdatePlaylist(playlist.ID, args[1], playlist.Comment, playlist.Public)
+		return nil
+	})
+}
+
+// playlistadd adds a song, or the songs in a directory, to the end of a
+// stored playlist, which is created if it does not exist.
+func (server *Server) playlistadd(session *session, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errArgs()
+	}
+	songs, err := server.resolve(args[1])
+	if err != nil {
+		return err
+	}
+	if _, err := server.storedPlaylist(args[0]); err != nil {
+		server.addPlaylist(args[0], rolaIDs(songs))
+		return nil
+	}
+	return server.changePlaylist(args[0], func(playlist *model.Playlist) error {
+		server.library.SetPlaylistRolas(playlist.ID, append(playlist.Rolas, rolaIDs(songs)...))
+		return nil
+	})
+}
+
+func (server *Server) playlistclear(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	return server.changePlaylist(args[0], func(playlist *model.Playlist) error {
+		server.library.SetPlaylistRolas(playlist.ID, []int64{})
+		return nil
+	})
+}
+
+// playlistdelete removes the song at a position of a stored playlist.
+func (server *Server) playlistdelete(session *session, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errArgs()
+	}
+	position, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	return server.changePlaylist(args[0], func(playlist *model.Playlist) error {
+		if position < 0 || position >= len(playlist.Rolas) {
+			return &ackError{ackArg, "Bad song index"}
+		}
+		rolas := append(playlist.Rolas[:position:position], playlist.Rolas[position+1:]...)
+		server.library.SetPlaylistRolas(playlist.ID, rolas)
+		return nil
+	})
+}
+
+// rolaIDs returns the IDs of the Rolas of some songs.
+func rolaIDs(songs []*song) []int64 {
+	ids := make([]int64, 0, len(songs))
+	for _, current := range songs {
+		ids = append(ids, current.rola.ID())
+	}
+	return ids
+}
+
+// songsByID maps the IDs of the Rolas of some songs to the songs.
+func songsByID(songs []*song) map[int64]*song {
+	result := make(map[int64]*song)
+	for _, current := range songs {
+		result[current.rola.ID()] = current
+	}
+	return result
+}
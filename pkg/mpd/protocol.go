@@ -0,0 +1,128 @@
+package mpd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The codes of the errors of the protocol.
+const (
+	ackArg     = 2
+	ackUnknown = 5
+	ackNoExist = 50
+	ackSystem  = 52
+	ackExist   = 56
+)
+
+// An ackError is an error of a command, written to the client as an ACK
+// line with its code.
+type ackError struct {
+	code    int
+	message string
+}
+
+func (err *ackError) Error() string {
+	return err.message
+}
+
+// errArgs returns the error of a command called with the wrong number of
+// arguments.
+func errArgs() error {
+	return &ackError{ackArg, "wrong number of arguments"}
+}
+
+// writeAck writes the ACK line of an error of the command with the
+// given name, at the given position of a command list.
+func writeAck(w io.Writer, err error, position int, name string) {
+	code := ackUnknown
+	if ack, ok := err.(*ackError); ok {
+		code = ack.code
+	}
+	fmt.Fprintf(w, "ACK [%d@%d] {%s} %s\n", code, position, name, err.Error())
+}
+
+// splitArgs splits a line of the protocol in its arguments, which are
+// separated by spaces, or quoted with '"' and escaped with '\'.
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	line = strings.TrimSpace(line)
+	for line != "" {
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			args = append(args, line[:end])
+			line = strings.TrimSpace(line[end:])
+			continue
+		}
+		var arg []byte
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+			}
+			arg = append(arg, line[i])
+		}
+		if i == len(line) {
+			return nil, &ackError{ackArg, "missing closing '\"'"}
+		}
+		args = append(args, string(arg))
+		line = strings.TrimSpace(line[i+1:])
+	}
+	return args, nil
+}
+
+// parseRange parses a position or a START:END range of the queue, and
+// returns the range; an END missing means the end of a list of the
+// given length.
+func parseRange(text string, length int) (int, int, error) {
+	invalid := &ackError{ackArg, "bad range \"" + text + "\""}
+	parts := strings.SplitN(text, ":", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil || start < 0 {
+		return 0, 0, invalid
+	}
+	end := start + 1
+	if len(parts) == 2 {
+		end = length
+		if parts[1] != "" {
+			end, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return 0, 0, invalid
+			}
+		}
+	}
+	if end < start || end > length {
+		return 0, 0, invalid
+	}
+	return start, end, nil
+}
+
+// parseInt parses an integer argument.
+func parseInt(text string) (int, error) {
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, &ackError{ackArg, "need an integer: \"" + text + "\""}
+	}
+	return number, nil
+}
+
+// contains tells whether a slice of strings contains a string.
+func contains(list []string, text string) bool {
+	for _, element := range list {
+		if element == text {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedStrings sorts a slice of strings and returns it.
+func sortedStrings(list []string) []string {
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,369 @@
+package mpd
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+// An entry is a song in the queue, with its ID, which does not change
+// while the song is in the queue, and the version of the queue when the
+// song was added or last moved.
+type entry struct {
+	id      int
+	song    *song
+	version int
+}
+
+// A queue is the list of songs to play, with a version that changes
+// every time the list changes.
+type queue struct {
+	entries []*entry
+	version int
+	nextID  int
+}
+
+func newQueue() *queue {
+	return &queue{
+		entries: make([]*entry, 0),
+		version: 1,
+		nextID:  1,
+	}
+}
+
+// changed increases the version of the queue and marks the songs from
+// the given position on as changed.
+func (queue *queue) changed(from int) {
+	queue.version++
+	for _, current := range queue.entries[from:] {
+		current.version = queue.version
+	}
+}
+
+// insert inserts songs at a position of the queue, or at its end if the
+// position is negative, and returns the IDs of the new entries.
+func (queue *queue) insert(songs []*song, position int) []int {
+	if position < 0 || position > len(queue.entries) {
+		position = len(queue.entries)
+	}
+	added := make([]*entry, 0, len(songs))
+	ids := make([]int, 0, len(songs))
+	for _, current := range songs {
+		added = append(added, &entry{id: queue.nextID, song: current})
+		ids = append(ids, queue.nextID)
+		queue.nextID++
+	}
+	rest := append(added, queue.entries[position:]...)
+	queue.entries = append(queue.entries[:position], rest...)
+	queue.changed(position)
+	return ids
+}
+
+// remove removes the songs in a range of positions of the queue.
+func (queue *queue) remove(start, end int) {
+	queue.entries = append(queue.entries[:start], queue.entries[end:]...)
+	queue.changed(start)
+}
+
+// move moves the songs in a range of positions of the queue to the
+// given position.
+func (queue *queue) move(start, end, to int) {
+	moved := append([]*entry{}, queue.entries[start:end]...)
+	rest := append(append([]*entry{}, queue.entries[:start]...), queue.entries[end:]...)
+	if to > len(rest) {
+		to = len(rest)
+	}
+	queue.entries = append(append(append([]*entry{}, rest[:to]...), moved...), rest[to:]...)
+	if to < start {
+		start = to
+	}
+	queue.changed(start)
+}
+
+// position returns the position of the song with the given ID, or -1
+// if it is not in the queue.
+func (queue *queue) position(id int) int {
+	for i, current := range queue.entries {
+		if current.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// enqueue adds songs to the queue at the given position, or at its end
+// if the position is negative, and returns their IDs.
+func (server *Server) enqueue(songs []*song, position int) []int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	ids := server.queue.insert(songs, position)
+	server.notify("playlist")
+	return ids
+}
+
+// writeEntry writes a song of the queue with its position and ID.
+func writeEntry(w io.Writer, current *entry, position int) {
+	writeSong(w, current.song)
+	fmt.Fprintf(w, "Pos: %d\nId: %d\n", position, current.id)
+}
+
+func (server *Server) status(session *session, args []string, w io.Writer) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, option := range []string{"repeat", "random", "single", "consume"} {
+		value := 0
+		if server.options[option] {
+			value = 1
+		}
+		fmt.Fprintf(w, "%s: %d\n", option, value)
+	}
+	fmt.Fprintf(w, "playlist: %d\nplaylistlength: %d\n", server.queue.version, len(server.queue.entries))
+	io.WriteString(w, "state: stop\n")
+	return nil
+}
+
+// option returns the command that sets the option of the queue with
+// the given name: repeat, random, single or consume.
+func option(name string) command {
+	return func(server *Server, session *session, args []string, w io.Writer) error {
+		if len(args) != 1 || (args[0] != "0" && args[0] != "1") {
+			return &ackError{ackArg, "Boolean (0/1) expected"}
+		}
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.options[name] = args[0] == "1"
+		server.notify("options")
+		return nil
+	}
+}
+
+// playback answers the commands of the player, which the server does
+// not have.
+func (server *Server) playback(session *session, args []string, w io.Writer) error {
+	return &ackError{ackSystem, "playback is not available"}
+}
+
+func (server *Server) add(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	songs, err := server.resolve(args[0])
+	if err != nil {
+		return err
+	}
+	server.enqueue(songs, -1)
+	return nil
+}
+
+// addid adds a song to the queue, at the given position or at the end,
+// and writes its ID.
+func (server *Server) addid(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errArgs()
+	}
+	songs, err := server.resolve(args[0])
+	if err != nil {
+		return err
+	}
+	if len(songs) != 1 || songs[0].uri != strings.Trim(args[0], "/") {
+		return &ackError{ackNoExist, "No such song"}
+	}
+	position := -1
+	if len(args) == 2 {
+		position, err = parseInt(args[1])
+		if err != nil {
+			return err
+		}
+	}
+	ids := server.enqueue(songs, position)
+	fmt.Fprintf(w, "Id: %d\n", ids[0])
+	return nil
+}
+
+func (server *Server) clear(session *session, args []string, w io.Writer) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.queue.remove(0, len(server.queue.entries))
+	server.notify("playlist")
+	return nil
+}
+
+func (server *Server) delete(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	start, end, err := parseRange(args[0], len(server.queue.entries))
+	if err != nil {
+		return err
+	}
+	server.queue.remove(start, end)
+	server.notify("playlist")
+	return nil
+}
+
+func (server *Server) deleteid(session *session, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errArgs()
+	}
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	position := server.queue.position(id)
+	if position < 0 {
+		return &ackError{ackNoExist, "No such song"}
+	}
+	server.queue.remove(position, position+1)
+	server.notify("playlist")
+	return nil
+}
+
+func (server *Server) move(session *session, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errArgs()
+	}
+	to, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	start, end, err := parseRange(args[0], len(server.queue.entries))
+	if err != nil {
+		return err
+	}
+	if to < 0 || to+end-start > len(server.queue.entries) {
+		return &ackError{ackArg, "Bad song index"}
+	}
+	server.queue.move(start, end, to)
+	server.notify("playlist")
+	return nil
+}
+
+func (server *Server) moveid(session *session, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errArgs()
+	}
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := parseInt(args[1])
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	position := server.queue.position(id)
+	if position < 0 {
+		return &ackError{ackNoExist, "No such song"}
+	}
+	if to < 0 || to >= len(server.queue.entries) {
+		return &ackError{ackArg, "Bad song index"}
+	}
+	server.queue.move(position, position+1, to)
+	server.notify("playlist")
+	return nil
+}
+
+// shuffle shuffles the songs of the queue, or the ones in a range.
+func (server *Server) shuffle(session *session, args []string, w io.Writer) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	start, end := 0, len(server.queue.entries)
+	if len(args) > 0 {
+		var err error
+		start, end, err = parseRange(args[0], len(server.queue.entries))
+		if err != nil {
+			return err
+		}
+	}
+	entries := server.queue.entries[start:end]
+	rand.Shuffle(len(entries), func(i, j int) {
+		entries[i], entries[j] = entries[j], entries[i]
+	})
+	server.queue.changed(start)
+	server.notify("playlist")
+	return nil
+}
+
+// playlistinfo writes the songs of the queue, or the ones at a position
+// or in a range.
+func (server *Server) playlistinfo(session *session, args []string, w io.Writer) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	start, end := 0, len(server.queue.entries)
+	if len(args) > 0 && args[0] != "-1" {
+		var err error
+		start, end, err = parseRange(args[0], len(server.queue.entries))
+		if err != nil {
+			return err
+		}
+	}
+	for i := start; i < end; i++ {
+		writeEntry(w, server.queue.entries[i], i)
+	}
+	return nil
+}
+
+// playlistid writes the songs of the queue, or the one with the given
+// ID.
+func (server *Server) playlistid(session *session, args []string, w io.Writer) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(args) == 0 {
+		for i, current := range server.queue.entries {
+			writeEntry(w, current, i)
+		}
+		return nil
+	}
+	id, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	position := server.queue.position(id)
+	if position < 0 {
+		return &ackError{ackNoExist, "No such song"}
+	}
+	writeEntry(w, server.queue.entries[position], position)
+	return nil
+}
+
+// plchanges writes the songs of the queue added or moved after the
+// given version of the queue.
+func (server *Server) plchanges(session *session, args []string, w io.Writer) error {
+	return server.changes(args, func(current *entry, position int) {
+		writeEntry(w, current, position)
+	})
+}
+
+// plchangesposid works like plchanges, but only writes the positions and
+// the IDs of the songs.
+func (server *Server) plchangesposid(session *session, args []string, w io.Writer) error {
+	return server.changes(args, func(current *entry, position int) {
+		fmt.Fprintf(w, "cpos: %d\nId: %d\n", position, current.id)
+	})
+}
+
+func (server *Server) changes(args []string, write func(*entry, int)) error {
+	if len(args) == 0 {
+		return errArgs()
+	}
+	version, err := parseInt(args[0])
+	if err != nil {
+		return err
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for i, current := range server.queue.entries {
+		if current.version > version {
+			write(current, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package mpris exports the audio player of the application on the
+// D-Bus session bus as an MPRIS2 media player, so the media keys and the
+// media widgets of the desktop can show the rola being played and
+// control the playback.
+//
+// The player implements Player and tells the Server whenever the rola,
+// its status or its position change by calling Update and Seeked.   The
+// Server implements the interfaces org.mpris.MediaPlayer2 and
+// org.mpris.MediaPlayer2.Player, without a track list; the loop status,
+// shuffle, rate and volume properties are fixed.
+package mpris
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+const (
+	objectPath      = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	rootInterface   = "org.mpris.MediaPlayer2"
+	playerInterface = "org.mpris.MediaPlayer2.Player"
+	propsInterface  = "org.freedesktop.DBus.Properties"
+	busNamePrefix   = "org.mpris.MediaPlayer2."
+	noTrack         = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+	trackPrefix     = "/com/github/Japodrilo/rolas/Rola/"
+)
+
+// artworkSize is the size of the thumbnails given as the art of the
+// rolas.
+const artworkSize = 500
+
+// A Status is the playback status of a Player.
+type Status string
+
+// The playback statuses of MPRIS2.
+const (
+	Playing Status = "Playing"
+	Paused  Status = "Paused"
+	Stopped Status = "Stopped"
+)
+
+// A Player is the audio player controlled through the Server.   Its
+// methods are called from the goroutines of the D-Bus connection.
+type Player interface {
+	// Current returns the Rola being played or paused, or nil if the
+	// player is stopped with nothing to play.
+	Current() *model.Rola
+	Status() Status
+	Position() time.Duration
+	CanGoNext() bool
+	CanGoPrevious() bool
+	Play()
+	Pause()
+	Stop()
+	Next()
+	Previous()
+	// SetPosition moves the playback of the current Rola to the given
+	// position, which is inside the Rola.
+	SetPosition(position time.Duration)
+}
+
+// A Server exports a Player on a D-Bus connection.
+type Server struct {
+	conn    *dbus.Conn
+	name    string
+	player  Player
+	artwork func(rola *model.Rola) string
+
+	mutex   sync.Mutex
+	emitted map[string]dbus.Variant
+}
+
+// NewServer exports the player on the connection, under the bus name
+// "org.mpris.MediaPlayer2." followed by the given name, which must not
+// be owned by another program.
+func NewServer(conn *dbus.Conn, name string, player Player) (*Server, error) {
+	server := &Server{
+		conn:    conn,
+		name:    busNamePrefix + name,
+		player:  player,
+		artwork: artURL,
+	}
+	server.emitted = server.playerProperties()
+
+	exports := []struct {
+		object  interface{}
+		iface   string
+		mapping map[string]string
+	}{
+		{&root{}, rootInterface, nil},
+		{&controls{server}, playerInterface, map[string]string{"SeekBy": "Seek"}},
+		{&properties{server}, propsInterface, nil},
+		{introspect.Introspectable(introspection), "org.freedesktop.DBus.Introspectable", nil},
+	}
+	for _, export := range exports {
+		err := conn.ExportWithMap(export.object, export.mapping, objectPath, export.iface)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reply, err := conn.RequestName(server.name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("the name %s is already taken", server.name)
+	}
+	return server, nil
+}
+
+// Close releases the bus name of the server.
+func (server *Server) Close() error {
+	_, err := server.conn.ReleaseName(server.name)
+	return err
+}
+
+// Update tells the server that the current rola or the status of the
+// player changed, so the properties that changed are signaled to the
+// desktop.
+func (server *Server) Update() error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	current := server.playerProperties()
+	changed := make(map[string]dbus.Variant)
+	for name, value := range current {
+		if name == "Position" {
+			continue
+		}
+		if last, ok := server.emitted[name]; !ok || !reflect.DeepEqual(last.Value(), value.Value()) {
+			changed[name] = value
+		}
+	}
+	server.emitted = current
+	if len(changed) == 0 {
+		return nil
+	}
+	return server.conn.Emit(objectPath, propsInterface+".PropertiesChanged", playerInterface, changed, []string{})
+}
+
+// Seeked tells the server that the position of the player jumped, other
+// than by playing, to the given position.
+func (server *Server) Seeked(position time.Duration) error {
+	return server.conn.Emit(objectPath, playerInterface+".Seeked", microseconds(position))
+}
+
+// rootProperties returns the properties of org.mpris.MediaPlayer2.
+func (server *Server) rootProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"CanQuit":             dbus.MakeVariant(false),
+		"CanRaise":            dbus.MakeVariant(false),
+		"HasTrackList":        dbus.MakeVariant(false),
+		"Identity":            dbus.MakeVariant("Rolas"),
+		"DesktopEntry":        dbus.MakeVariant("rolas"),
+		"SupportedUriSchemes": dbus.MakeVariant([]string{}),
+		"SupportedMimeTypes":  dbus.MakeVariant([]string{}),
+	}
+}
+
+// playerProperties returns the properties of org.mpris.MediaPlayer2.Player,
+// as the player has them now.
+func (server *Server) playerProperties() map[string]dbus.Variant {
+	rola := server.player.Current()
+	return map[string]dbus.Variant{
+		"PlaybackStatus": dbus.MakeVariant(string(server.player.Status())),
+		"LoopStatus":     dbus.MakeVariant("None"),
+		"Rate":           dbus.MakeVariant(1.0),
+		"Shuffle":        dbus.MakeVariant(false),
+		"Metadata":       dbus.MakeVariant(server.metadata(rola)),
+		"Volume":         dbus.MakeVariant(1.0),
+		"Position":       dbus.MakeVariant(microseconds(server.player.Position())),
+		"MinimumRate":    dbus.MakeVariant(1.0),
+		"MaximumRate":    dbus.MakeVariant(1.0),
+		"CanGoNext":      dbus.MakeVariant(server.player.CanGoNext()),
+		"CanGoPrevious":  dbus.MakeVariant(server.player.CanGoPrevious()),
+		"CanPlay":        dbus.MakeVariant(rola != nil),
+		"CanPause":       dbus.MakeVariant(rola != nil),
+		"CanSeek":        dbus.MakeVariant(rola != nil && rola.Duration() > 0),
+		"CanControl":     dbus.MakeVariant(true),
+	}
+}
+
+// metadata returns the metadata of a Rola, in the xesam vocabulary used
+// by MPRIS2.   Only the values the Rola has are given.
+func (server *Server) metadata(rola *model.Rola) map[string]dbus.Variant {
+	if rola == nil {
+		return map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(noTrack)}
+	}
+	metadata := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(trackID(rola)),
+		"xesam:url":     dbus.MakeVariant(fileURL(rola.File())),
+	}
+	texts := map[string]string{
+		"xesam:title": rola.Title(),
+		"xesam:album": rola.Album(),
+	}
+	lists := map[string]string{
+		"xesam:artist":      rola.Artist(),
+		"xesam:albumArtist": rola.AlbumArtist(),
+		"xesam:genre":       rola.Genre(),
+		"xesam:composer":    rola.Composer(),
+		"xesam:comment":     rola.Comment(),
+	}
+	numbers := map[string]int{
+		"xesam:trackNumber": rola.Track(),
+		"xesam:discNumber":  rola.Disc(),
+	}
+	for key, value := range texts {
+		if value != "" {
+			metadata[key] = dbus.MakeVariant(value)
+		}
+	}
+	for key, value := range lists {
+		if value != "" {
+			metadata[key] = dbus.MakeVariant([]string{value})
+		}
+	}
+	for key, value := range numbers {
+		if value > 0 {
+			metadata[key] = dbus.MakeVariant(int32(value))
+		}
+	}
+	if rola.Duration() > 0 {
+		metadata["mpris:length"] = dbus.MakeVariant(microseconds(rola.Duration()))
+	}
+	if art := server.artwork(rola); art != "" {
+		metadata["mpris:artUrl"] = dbus.MakeVariant(art)
+	}
+	return metadata
+}
+
+// artURL returns the URL of the thumbnail of the picture of a Rola in
+// the ArtworkCache, or an empty string if it has none.
+func artURL(rola *model.Rola) string {
+	path, err := model.GetArtworkCache().ThumbnailPath(rola.Artwork(), artworkSize)
+	if err != nil {
+		return ""
+	}
+	return fileURL(path)
+}
+
+func fileURL(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// trackID returns the object path that identifies a Rola in MPRIS2.
+func trackID(rola *model.Rola) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s%d", trackPrefix, rola.ID()))
+}
+
+// microseconds converts a duration to the unit of time of MPRIS2.
+func microseconds(duration time.Duration) int64 {
+	return int64(duration / time.Microsecond)
+}
@@ -0,0 +1,278 @@
+package mpris
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`
+
+// A player is a Player that only remembers what it was told.
+type player struct {
+	mutex    sync.Mutex
+	rolas    []*model.Rola
+	current  int
+	status   Status
+	position time.Duration
+}
+
+func newPlayer() *player {
+	result := &player{status: Stopped}
+	for i, title := range []string{"Love of My Life", "Bohemian Rhapsody"} {
+		rola := model.NewRola()
+		rola.SetID(int64(i + 1))
+		rola.SetTitle(title)
+		rola.SetArtist("Queen")
+		rola.SetAlbum("A Night at the Opera")
+		rola.SetTrack(9 + 2*i)
+		rola.SetArtwork("cafe")
+		rola.SetPath("/music/Queen/Opera/" + title + ".mp3")
+		rola.SetAudioProperties(&model.AudioProperties{Duration: time.Minute})
+		result.rolas = append(result.rolas, rola)
+	}
+	return result
+}
+
+func (player *player) Current() *model.Rola {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	return player.rolas[player.current]
+}
+
+func (player *player) Status() Status {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	return player.status
+}
+
+func (player *player) Position() time.Duration {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	return player.position
+}
+
+func (player *player) CanGoNext() bool {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	return player.current < len(player.rolas)-1
+}
+
+func (player *player) CanGoPrevious() bool {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	return player.current > 0
+}
+
+func (player *player) set(status Status) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.status = status
+}
+
+func (player *player) Play()  { player.set(Playing) }
+func (player *player) Pause() { player.set(Paused) }
+func (player *player) Stop()  { player.set(Stopped) }
+
+func (player *player) Next() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.current++
+	player.position = 0
+}
+
+func (player *player) Previous() {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.current--
+	player.position = 0
+}
+
+func (player *player) SetPosition(position time.Duration) {
+	player.mutex.Lock()
+	defer player.mutex.Unlock()
+	player.position = position
+}
+
+// startBus starts a private session bus, and returns its address and a
+// function to stop it.   The test is skipped if there is no dbus-daemon.
+func startBus(t *testing.T) (string, func()) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	dir, err := ioutil.TempDir("", "mpris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "bus.conf")
+	contents := fmt.Sprintf(busConfig, filepath.Join(dir, "bus"))
+	err = ioutil.WriteFile(config, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = daemon.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address), func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+		os.RemoveAll(dir)
+	}
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Auth(nil)
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// waitSignal returns the first signal with the given name, or fails the
+// test if none arrives in a second.
+func waitSignal(t *testing.T, signals chan *dbus.Signal, name string) *dbus.Signal {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Name == name {
+				return signal
+			}
+		case <-timeout:
+			t.Fatalf("expecting the signal %v", name)
+			return nil
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	address, stop := startBus(t)
+	defer stop()
+	conn := connect(t, address)
+	defer conn.Close()
+	client := connect(t, address)
+	defer client.Close()
+
+	current := newPlayer()
+	server, err := NewServer(conn, "rolas", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.artwork = func(rola *model.Rola) string {
+		return "file:///artwork/" + rola.Artwork() + ".jpg"
+	}
+	server.Update()
+	if _, err := NewServer(client, "rolas", current); err == nil {
+		t.Errorf("expecting an error for a name already taken")
+	}
+
+	object := client.Object(busNamePrefix+"rolas", objectPath)
+	identity, err := object.GetProperty(rootInterface + ".Identity")
+	if err != nil || identity.Value() != "Rolas" {
+		t.Errorf("expecting %v, received %v %v", "Rolas", identity, err)
+	}
+	value, err := object.GetProperty(playerInterface + ".Metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := value.Value().(map[string]dbus.Variant)
+	expected := map[string]interface{}{
+		"mpris:trackid": dbus.ObjectPath(trackPrefix + "1"),
+		"mpris:length":  int64(60000000),
+		"mpris:artUrl":  "file:///artwork/cafe.jpg",
+		"xesam:title":   "Love of My Life",
+		"xesam:url":     "file:///music/Queen/Opera/Love%20of%20My%20Life.mp3",
+	}
+	for key, value := range expected {
+		if received := metadata[key].Value(); received != value {
+			t.Errorf("%v: expecting %v, received %v", key, value, received)
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	client.Signal(signals)
+	call := client.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, "type='signal',path='"+string(objectPath)+"'")
+	if call.Err != nil {
+		t.Fatal(call.Err)
+	}
+
+	if call := object.Call(playerInterface+".PlayPause", 0); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+	signal := waitSignal(t, signals, propsInterface+".PropertiesChanged")
+	changed := signal.Body[1].(map[string]dbus.Variant)
+	if status := changed["PlaybackStatus"].Value(); status != "Playing" || len(changed) != 1 {
+		t.Errorf("expecting %v, received %v", "Playing", changed)
+	}
+
+	if call := object.Call(playerInterface+".Seek", 0, int64(30000000)); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+	signal = waitSignal(t, signals, playerInterface+".Seeked")
+	if position := signal.Body[0]; position != int64(30000000) {
+		t.Errorf("expecting %v, received %v", 30000000, position)
+	}
+	object.Call(playerInterface+".SetPosition", 0, dbus.ObjectPath(trackPrefix+"2"), int64(0))
+	if position, _ := object.GetProperty(playerInterface + ".Position"); position.Value() != int64(30000000) {
+		t.Errorf("expecting %v, received %v", 30000000, position)
+	}
+
+	if call := object.Call(playerInterface+".Seek", 0, int64(60000000)); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+	signal = waitSignal(t, signals, propsInterface+".PropertiesChanged")
+	changed = signal.Body[1].(map[string]dbus.Variant)
+	metadata = changed["Metadata"].Value().(map[string]dbus.Variant)
+	if title := metadata["xesam:title"].Value(); title != "Bohemian Rhapsody" {
+		t.Errorf("expecting %v, received %v", "Bohemian Rhapsody", title)
+	}
+	if next := changed["CanGoNext"].Value(); next != false {
+		t.Errorf("expecting %v, received %v", false, next)
+	}
+
+	call = object.Call(propsInterface+".Set", 0, playerInterface, "Volume", dbus.MakeVariant(0.5))
+	if call.Err == nil {
+		t.Errorf("expecting an error setting a read-only property")
+	}
+}
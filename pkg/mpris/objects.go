@@ -0,0 +1,237 @@
+package mpris
+
+import (
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+)
+
+// root implements org.mpris.MediaPlayer2; the application can neither
+// be raised nor quit by the desktop.
+type root struct{}
+
+func (object *root) Raise() *dbus.Error {
+	return nil
+}
+
+func (object *root) Quit() *dbus.Error {
+	return nil
+}
+
+// controls implements org.mpris.MediaPlayer2.Player over the Player of
+// a Server.
+type controls struct {
+	server *Server
+}
+
+// done signals the properties changed by a control.
+func (object *controls) done() *dbus.Error {
+	if err := object.server.Update(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (object *controls) Next() *dbus.Error {
+	if object.server.player.CanGoNext() {
+		object.server.player.Next()
+	}
+	return object.done()
+}
+
+func (object *controls) Previous() *dbus.Error {
+	if object.server.player.CanGoPrevious() {
+		object.server.player.Previous()
+	}
+	return object.done()
+}
+
+func (object *controls) Pause() *dbus.Error {
+	if object.server.player.Status() == Playing {
+		object.server.player.Pause()
+	}
+	return object.done()
+}
+
+func (object *controls) PlayPause() *dbus.Error {
+	if object.server.player.Status() == Playing {
+		object.server.player.Pause()
+	} else if object.server.player.Current() != nil {
+		object.server.player.Play()
+	}
+	return object.done()
+}
+
+func (object *controls) Stop() *dbus.Error {
+	object.server.player.Stop()
+	return object.done()
+}
+
+func (object *controls) Play() *dbus.Error {
+	if object.server.player.Current() != nil {
+		object.server.player.Play()
+	}
+	return object.done()
+}
+
+// SeekBy implements the method Seek, which moves the position of the
+// player by the given offset, in microseconds; seeking past the end of
+// the rola goes to the next one.   It is renamed so it is not mistaken
+// for io.Seeker.
+func (object *controls) SeekBy(offset int64) *dbus.Error {
+	player := object.server.player
+	rola := player.Current()
+	if rola == nil || rola.Duration() <= 0 {
+		return nil
+	}
+	position := player.Position() + time.Duration(offset)*time.Microsecond
+	if position < 0 {
+		position = 0
+	}
+	if position > rola.Duration() {
+		return object.Next()
+	}
+	player.SetPosition(position)
+	if err := object.server.Seeked(position); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// SetPosition moves the playback of the rola with the given track ID to
+// the given position, in microseconds.   It is ignored if the rola is
+// not the current one or the position is outside of it.
+func (object *controls) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	player := object.server.player
+	rola := player.Current()
+	if rola == nil || track != trackID(rola) {
+		return nil
+	}
+	target := time.Duration(position) * time.Microsecond
+	if target < 0 || target > rola.Duration() {
+		return nil
+	}
+	player.SetPosition(target)
+	if err := object.server.Seeked(target); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (object *controls) OpenUri(uri string) *dbus.Error {
+	return dbus.NewError("org.freedesktop.DBus.Error.NotSupported", []interface{}{"opening URIs is not supported"})
+}
+
+// properties implements org.freedesktop.DBus.Properties for the
+// interfaces of a Server; none of the properties can be set.
+type properties struct {
+	server *Server
+}
+
+func (object *properties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	all, err := object.GetAll(iface)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+	value, ok := all[name]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{"unknown property " + name})
+	}
+	return value, nil
+}
+
+func (object *properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case rootInterface:
+		return object.server.rootProperties(), nil
+	case playerInterface:
+		return object.server.playerProperties(), nil
+	}
+	return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{"unknown interface " + iface})
+}
+
+func (object *properties) Set(iface, name string, value dbus.Variant) *dbus.Error {
+	if _, err := object.Get(iface, name); err != nil {
+		return err
+	}
+	return dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly", []interface{}{"property " + name + " is read-only"})
+}
+
+// introspection describes the object exported by a Server.
+const introspection = introspect.IntrospectDeclarationString + `
+<node>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="DesktopEntry" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek">
+      <arg name="Offset" type="x" direction="in"/>
+    </method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri">
+      <arg name="Uri" type="s" direction="in"/>
+    </method>
+    <signal name="Seeked">
+      <arg name="Position" type="x"/>
+    </signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="LoopStatus" type="s" access="read"/>
+    <property name="Rate" type="d" access="read"/>
+    <property name="Shuffle" type="b" access="read"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="read"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed" type="a{sv}"/>
+      <arg name="invalidated" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="data" type="s" direction="out"/>
+    </method>
+  </interface>
+</node>`
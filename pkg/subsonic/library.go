@@ -0,0 +1,466 @@
+package subsonic
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// ignoredArticles are the articles ignored when performers are sorted
+// and indexed by name.
+var ignoredArticles = []string{"The", "El", "La", "Los", "Las", "Le", "Les"}
+
+// The most artists, albums or songs a search answers with.
+const maxCount = 500
+
+// contentTypes maps the suffixes of the files the Miner mines to their
+// content types.
+var contentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"flac": "audio/flac",
+}
+
+// A catalog holds the performers, the albums of a music folder and the
+// credits of the performers in those albums, to answer the methods
+// that browse the library.
+type catalog struct {
+	performers  []*model.Performer
+	albums      []*model.Album
+	performerID map[int64]*model.Performer
+	albumID     map[int64]*model.Album
+	byAlbum     map[int64][]*model.Credit
+	byPerformer map[int64][]*model.Credit
+}
+
+// loadCatalog loads the catalog of the albums in the given folder, or of
+// all the albums if the folder is empty.
+func (server *Server) loadCatalog(folder string) *catalog {
+	result := &catalog{
+		performers:  server.database.AllPerformers(),
+		albums:      make([]*model.Album, 0),
+		performerID: make(map[int64]*model.Performer),
+		albumID:     make(map[int64]*model.Album),
+		byAlbum:     make(map[int64][]*model.Credit),
+		byPerformer: make(map[int64][]*model.Credit),
+	}
+	for _, performer := range result.performers {
+		result.performerID[performer.ID] = performer
+	}
+	for _, album := range server.database.AllAlbums() {
+		if inFolder(album.Path, folder) {
+			result.albums = append(result.albums, album)
+			result.albumID[album.ID] = album
+		}
+	}
+	for _, credit := range server.database.AllCredits() {
+		if result.albumID[credit.AlbumID] == nil || result.performerID[credit.PerformerID] == nil {
+			continue
+		}
+		result.byAlbum[credit.AlbumID] = append(result.byAlbum[credit.AlbumID], credit)
+		result.byPerformer[credit.PerformerID] = append(result.byPerformer[credit.PerformerID], credit)
+	}
+	return result
+}
+
+// albumArtist returns the performer of the most Rolas of an album, which
+// is the artist of the album in the API.
+func (catalog *catalog) albumArtist(albumID int64) *model.Performer {
+	var best *model.Credit
+	for _, credit := range catalog.byAlbum[albumID] {
+		if best == nil || credit.Rolas > best.Rolas {
+			best = credit
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return catalog.performerID[best.PerformerID]
+}
+
+// artistAlbums returns the albums of a performer, sorted by year.
+func (catalog *catalog) artistAlbums(performerID int64) []*model.Album {
+	albums := make([]*model.Album, 0)
+	for _, credit := range catalog.byPerformer[performerID] {
+		albums = append(albums, catalog.albumID[credit.AlbumID])
+	}
+	sort.Slice(albums, func(i, j int) bool {
+		if albums[i].Year != albums[j].Year {
+			return albums[i].Year < albums[j].Year
+		}
+		return strings.ToLower(albums[i].Name) < strings.ToLower(albums[j].Name)
+	})
+	return albums
+}
+
+func (catalog *catalog) artist(performer *model.Performer) *artist {
+	result := &artist{
+		ID:         newID(artistPrefix, performer.ID),
+		Name:       performer.Name,
+		AlbumCount: len(catalog.byPerformer[performer.ID]),
+	}
+	for _, album := range catalog.artistAlbums(performer.ID) {
+		if album.Artwork != "" {
+			result.CoverArt = result.ID
+			break
+		}
+	}
+	return result
+}
+
+func (catalog *catalog) album(source *model.Album) *album {
+	result := &album{
+		ID:   newID(albumPrefix, source.ID),
+		Name: source.Name,
+		Year: source.Year,
+	}
+	if performer := catalog.albumArtist(source.ID); performer != nil {
+		result.Artist = performer.Name
+		result.ArtistID = newID(artistPrefix, performer.ID)
+	}
+	if source.Artwork != "" {
+		result.CoverArt = result.ID
+	}
+	var duration time.Duration
+	for _, credit := range catalog.byAlbum[source.ID] {
+		result.SongCount += credit.Rolas
+		duration += credit.Duration
+	}
+	result.Duration = int(duration.Seconds())
+	if info, err := os.Stat(source.Path); err == nil {
+		result.Created = timestamp(info.ModTime())
+	}
+	return result
+}
+
+// artists returns the performers with albums in the catalog.
+func (catalog *catalog) artists() []*artist {
+	result := make([]*artist, 0)
+	for _, performer := range catalog.performers {
+		if len(catalog.byPerformer[performer.ID]) > 0 {
+			result = append(result, catalog.artist(performer))
+		}
+	}
+	return result
+}
+
+// song returns the song of the API of the Rola with the given ID.
+func (server *Server) song(id int64) *child {
+	rola := server.database.QueryRola(id)
+	performerID, albumID := server.database.QueryRolaForeign(id)
+	suffix := strings.TrimPrefix(strings.ToLower(filepath.Ext(rola.File())), ".")
+	song := &child{
+		ID:          newID(songPrefix, id),
+		Parent:      newID(albumPrefix, albumID),
+		Title:       rola.Title(),
+		Album:       rola.Album(),
+		Artist:      rola.Artist(),
+		Track:       rola.Track(),
+		Year:        rola.Year(),
+		Genre:       rola.Genre(),
+		ContentType: contentTypes[suffix],
+		Suffix:      suffix,
+		Duration:    int(rola.Duration().Seconds()),
+		BitRate:     rola.Bitrate(),
+		Path:        server.relativePath(rola.Path()),
+		DiscNumber:  rola.Disc(),
+		AlbumID:     newID(albumPrefix, albumID),
+		ArtistID:    newID(artistPrefix, performerID),
+		Type:        "music",
+	}
+	if rola.Artwork() != "" {
+		song.CoverArt = song.ID
+	}
+	if info, err := os.Stat(rola.File()); err == nil && !rola.IsCueTrack() {
+		song.Size = info.Size()
+	}
+	return song
+}
+
+// albumSongs returns the songs of an album, sorted by disc and track.
+func (server *Server) albumSongs(albumID int64) []*child {
+	songs := make([]*child, 0)
+	for _, id := range server.database.QueryAlbumRolas(albumID) {
+		songs = append(songs, server.song(id))
+	}
+	sort.Slice(songs, func(i, j int) bool {
+		if songs[i].DiscNumber != songs[j].DiscNumber {
+			return songs[i].DiscNumber < songs[j].DiscNumber
+		}
+		if songs[i].Track != songs[j].Track {
+			return songs[i].Track < songs[j].Track
+		}
+		return songs[i].Title < songs[j].Title
+	})
+	return songs
+}
+
+// folder returns the music folder in the musicFolderId parameter of the
+// request, or an empty string if there is none.
+func (server *Server) folder(r *http.Request) (string, bool) {
+	if r.Form.Get("musicFolderId") == "" {
+		return "", true
+	}
+	id := formInt(r, "musicFolderId", 0)
+	if id < 1 || id > len(server.folders) {
+		return "", false
+	}
+	return server.folders[id-1], true
+}
+
+// relativePath returns a path relative to the music folder that holds
+// it, or the path itself if no music folder holds it.
+func (server *Server) relativePath(path string) string {
+	for _, folder := range server.folders {
+		if inFolder(path, folder) {
+			relative, err := filepath.Rel(folder, path)
+			if err == nil {
+				return relative
+			}
+		}
+	}
+	return path
+}
+
+// inFolder tells whether a path is inside a folder; every path is
+// inside the empty folder.
+func inFolder(path, folder string) bool {
+	return folder == "" || path == folder || strings.HasPrefix(path, folder+string(filepath.Separator))
+}
+
+// sortName returns the name of a performer without its leading
+// article, which is the name performers are sorted and indexed by.
+func sortName(name string) string {
+	for _, article := range ignoredArticles {
+		if len(name) > len(article)+1 && strings.EqualFold(name[:len(article)+1], article+" ") {
+			return strings.TrimSpace(name[len(article)+1:])
+		}
+	}
+	return name
+}
+
+// buildIndexes groups artists by the first letter of their sort names;
+// the artists whose names do not start with a letter go to the "#"
+// index.
+func buildIndexes(artists []*artist) []*index {
+	sort.SliceStable(artists, func(i, j int) bool {
+		return strings.ToLower(sortName(artists[i].Name)) < strings.ToLower(sortName(artists[j].Name))
+	})
+	result := make([]*index, 0)
+	byName := make(map[string]*index)
+	for _, entry := range artists {
+		name := "#"
+		for _, letter := range sortName(entry.Name) {
+			if unicode.IsLetter(letter) {
+				name = string(unicode.ToUpper(letter))
+			}
+			break
+		}
+		current, ok := byName[name]
+		if !ok {
+			current = &index{Name: name, Artists: make([]*artist, 0)}
+			byName[name] = current
+			result = append(result, current)
+		}
+		current.Artists = append(current.Artists, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Name != "#" && (result[j].Name == "#" || result[i].Name < result[j].Name)
+	})
+	return result
+}
+
+// paginate returns the bounds of the page of a list of the given length
+// asked for in the count and offset parameters with the given prefix.
+func paginate(r *http.Request, prefix string, length int) (int, int) {
+	count := formInt(r, prefix+"Count", 20)
+	offset := formInt(r, prefix+"Offset", 0)
+	if count < 0 || count > maxCount {
+		count = maxCount
+	}
+	if offset < 0 || offset > length {
+		offset = length
+	}
+	if offset+count > length {
+		return offset, length
+	}
+	return offset, offset + count
+}
+
+func (server *Server) getMusicFolders(w http.ResponseWriter, r *http.Request) {
+	folders := make([]*musicFolder, 0, len(server.folders))
+	for i, folder := range server.folders {
+		folders = append(folders, &musicFolder{i + 1, filepath.Base(folder)})
+	}
+	answer := newResponse()
+	answer.MusicFolders = &musicFolders{folders}
+	write(w, r, answer)
+}
+
+func (server *Server) getIndexes(w http.ResponseWriter, r *http.Request) {
+	folder, ok := server.folder(r)
+	if !ok {
+		writeError(w, r, errNotFound, "music folder not found")
+		return
+	}
+	answer := newResponse()
+	answer.Indexes = &indexes{
+		LastModified:    time.Now().UnixNano() / int64(time.Millisecond),
+		IgnoredArticles: strings.Join(ignoredArticles, " "),
+		Indexes:         buildIndexes(server.loadCatalog(folder).artists()),
+	}
+	write(w, r, answer)
+}
+
+func (server *Server) getArtists(w http.ResponseWriter, r *http.Request) {
+	folder, ok := server.folder(r)
+	if !ok {
+		writeError(w, r, errNotFound, "music folder not found")
+		return
+	}
+	answer := newResponse()
+	answer.Artists = &artists{
+		IgnoredArticles: strings.Join(ignoredArticles, " "),
+		Indexes:         buildIndexes(server.loadCatalog(folder).artists()),
+	}
+	write(w, r, answer)
+}
+
+// getMusicDirectory answers with the albums of a performer as
+// directories, or with the songs of an album.
+func (server *Server) getMusicDirectory(w http.ResponseWriter, r *http.Request) {
+	id := r.Form.Get("id")
+	catalog := server.loadCatalog("")
+	if performerID, ok := parseID(artistPrefix, id); ok && catalog.performerID[performerID] != nil {
+		result := &directory{
+			ID:       id,
+			Name:     catalog.performerID[performerID].Name,
+			Children: make([]*child, 0),
+		}
+		for _, source := range catalog.artistAlbums(performerID) {
+			album := catalog.album(source)
+			result.Children = append(result.Children, &child{
+				ID:       album.ID,
+				Parent:   id,
+				IsDir:    true,
+				Title:    album.Name,
+				Album:    album.Name,
+				Artist:   album.Artist,
+				Year:     album.Year,
+				CoverArt: album.CoverArt,
+			})
+		}
+		answer := newResponse()
+		answer.Directory = result
+		write(w, r, answer)
+		return
+	}
+	if albumID, ok := parseID(albumPrefix, id); ok && catalog.albumID[albumID] != nil {
+		album := catalog.album(catalog.albumID[albumID])
+		answer := newResponse()
+		answer.Directory = &directory{
+			ID:       id,
+			Parent:   album.ArtistID,
+			Name:     album.Name,
+			Children: server.albumSongs(albumID),
+		}
+		write(w, r, answer)
+		return
+	}
+	writeError(w, r, errNotFound, "directory not found")
+}
+
+func (server *Server) getArtist(w http.ResponseWriter, r *http.Request) {
+	catalog := server.loadCatalog("")
+	performerID, ok := parseID(artistPrefix, r.Form.Get("id"))
+	if !ok || catalog.performerID[performerID] == nil {
+		writeError(w, r, errNotFound, "artist not found")
+		return
+	}
+	result := catalog.artist(catalog.performerID[performerID])
+	result.Albums = make([]*album, 0)
+	for _, source := range catalog.artistAlbums(performerID) {
+		result.Albums = append(result.Albums, catalog.album(source))
+	}
+	answer := newResponse()
+	answer.Artist = result
+	write(w, r, answer)
+}
+
+func (server *Server) getAlbum(w http.ResponseWriter, r *http.Request) {
+	catalog := server.loadCatalog("")
+	albumID, ok := parseID(albumPrefix, r.Form.Get("id"))
+	if !ok || catalog.albumID[albumID] == nil {
+		writeError(w, r, errNotFound, "album not found")
+		return
+	}
+	result := catalog.album(catalog.albumID[albumID])
+	result.Songs = server.albumSongs(albumID)
+	if len(result.Songs) > 0 {
+		result.Genre = result.Songs[0].Genre
+	}
+	answer := newResponse()
+	answer.Album = result
+	write(w, r, answer)
+}
+
+// search3 answers with the performers, albums and Rolas containing the
+// text of the query parameter; an empty query finds everything.
+func (server *Server) search3(w http.ResponseWriter, r *http.Request) {
+	folder, ok := server.folder(r)
+	if !ok {
+		writeError(w, r, errNotFound, "music folder not found")
+		return
+	}
+	query := strings.TrimSpace(strings.Trim(r.Form.Get("query"), `"`))
+	text := strings.ToLower(query)
+	catalog := server.loadCatalog(folder)
+	result := &searchResult{
+		Artists: make([]*artist, 0),
+		Albums:  make([]*album, 0),
+		Songs:   make([]*child, 0),
+	}
+
+	found := make([]*artist, 0)
+	for _, artist := range catalog.artists() {
+		if strings.Contains(strings.ToLower(artist.Name), text) {
+			found = append(found, artist)
+		}
+	}
+	start, end := paginate(r, "artist", len(found))
+	result.Artists = append(result.Artists, found[start:end]...)
+
+	albums := make([]*model.Album, 0)
+	for _, album := range catalog.albums {
+		if strings.Contains(strings.ToLower(album.Name), text) {
+			albums = append(albums, album)
+		}
+	}
+	start, end = paginate(r, "album", len(albums))
+	for _, album := range albums[start:end] {
+		result.Albums = append(result.Albums, catalog.album(album))
+	}
+
+	options := &model.ListOptions{Sort: "title"}
+	if query != "" {
+		options.IDs = server.database.QuerySimple(query)
+	}
+	_, total := server.database.ListRolas(options)
+	options.Offset, end = paginate(r, "song", total)
+	options.Limit = end - options.Offset
+	if options.Limit > 0 {
+		ids, _ := server.database.ListRolas(options)
+		for _, id := range ids {
+			result.Songs = append(result.Songs, server.song(id))
+		}
+	}
+
+	answer := newResponse()
+	answer.SearchResult3 = result
+	write(w, r, answer)
+}
@@ -0,0 +1,136 @@
+package subsonic
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// stream serves the audio of a Rola as it is, or only its part of the
+// file for the track of a CUE sheet; the server does not transcode, so
+// the maxBitRate and format parameters are ignored.
+func (server *Server) stream(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(songPrefix, r.Form.Get("id"))
+	if !ok || server.database.QueryPath(id) == "" {
+		writeError(w, r, errNotFound, "song not found")
+		return
+	}
+	audio, err := model.OpenAudio(server.database.QueryRola(id))
+	if os.IsNotExist(err) {
+		writeError(w, r, errNotFound, "song not found")
+		return
+	}
+	if err != nil {
+		writeError(w, r, errGeneric, err.Error())
+		return
+	}
+	defer audio.Close()
+	suffix := strings.TrimPrefix(strings.ToLower(filepath.Ext(audio.Name)), ".")
+	if contentType, ok := contentTypes[suffix]; ok {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, audio.Name, audio.ModTime, audio)
+}
+
+// getCoverArt serves the picture of a Rola, of an album or of the first
+// album of a performer with one, scaled to the smallest thumbnail at
+// least as large as the size parameter.
+func (server *Server) getCoverArt(w http.ResponseWriter, r *http.Request) {
+	id := r.Form.Get("id")
+	var hash string
+	if rolaID, ok := parseID(songPrefix, id); ok {
+		hash = server.database.QueryArtwork(rolaID)
+	} else if albumID, ok := parseID(albumPrefix, id); ok {
+		if album := server.loadCatalog("").albumID[albumID]; album != nil {
+			hash = album.Artwork
+		}
+	} else if performerID, ok := parseID(artistPrefix, id); ok {
+		catalog := server.loadCatalog("")
+		for _, album := range catalog.artistAlbums(performerID) {
+			if album.Artwork != "" {
+				hash = album.Artwork
+				break
+			}
+		}
+	}
+	if hash == "" {
+		writeError(w, r, errNotFound, "cover art not found")
+		return
+	}
+
+	cache := model.GetArtworkCache()
+	var data []byte
+	var err error
+	if size := coverSize(formInt(r, "size", 0)); size > 0 {
+		data, err = cache.Thumbnail(hash, size)
+	} else {
+		data, err = cache.Original(hash)
+	}
+	if err == model.ErrNoArtwork {
+		writeError(w, r, errNotFound, "cover art not found")
+		return
+	}
+	if err != nil {
+		writeError(w, r, errGeneric, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", "max-age=86400")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// coverSize returns the size of the smallest of the ArtworkSizes at
+// least as large as the given size, or 0 if the original picture must
+// be served.
+func coverSize(size int) int {
+	if size <= 0 {
+		return 0
+	}
+	for _, thumbnail := range model.ArtworkSizes {
+		if thumbnail >= size {
+			return thumbnail
+		}
+	}
+	return 0
+}
+
+// scrobble records the plays of the Rolas in the id parameters, at the
+// times in milliseconds of the time parameters, or now if they are
+// missing.   Notifications of the Rolas being played, with submission
+// false, are accepted and ignored.
+func (server *Server) scrobble(w http.ResponseWriter, r *http.Request) {
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		writeError(w, r, errMissing, "required parameter id is missing")
+		return
+	}
+	rolas := make([]int64, 0, len(ids))
+	for _, text := range ids {
+		id, ok := parseID(songPrefix, text)
+		if !ok || server.database.QueryPath(id) == "" {
+			writeError(w, r, errNotFound, "song not found")
+			return
+		}
+		rolas = append(rolas, id)
+	}
+	if r.Form.Get("submission") != "false" {
+		times := r.Form["time"]
+		for i, id := range rolas {
+			played := time.Now()
+			if i < len(times) {
+				milliseconds, err := strconv.ParseInt(times[i], 10, 64)
+				if err == nil {
+					played = time.Unix(0, milliseconds*int64(time.Millisecond))
+				}
+			}
+			server.database.AddPlay(id, played)
+		}
+	}
+	write(w, r, newResponse())
+}
@@ -0,0 +1,181 @@
+package subsonic
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// playlist returns the playlist of the API of a playlist of the
+// database, with its songs if entries is true.
+func (server *Server) playlist(source *model.Playlist, entries bool) *playlist {
+	result := &playlist{
+		ID:        newID(playlistPrefix, source.ID),
+		Name:      source.Name,
+		Comment:   source.Comment,
+		Owner:     source.Owner,
+		Public:    source.Public,
+		SongCount: len(source.Rolas),
+		Created:   timestamp(source.Created),
+		Changed:   timestamp(source.Changed),
+	}
+	songs := make([]*child, 0, len(source.Rolas))
+	for _, id := range source.Rolas {
+		song := server.song(id)
+		result.Duration += song.Duration
+		songs = append(songs, song)
+	}
+	if entries {
+		result.Entries = songs
+	}
+	return result
+}
+
+// ownPlaylist returns the playlist in the given parameter of the
+// request, if it belongs to the user of the request; otherwise it
+// writes the error and returns nil.
+func (server *Server) ownPlaylist(w http.ResponseWriter, r *http.Request, name string) *model.Playlist {
+	id, ok := parseID(playlistPrefix, r.Form.Get(name))
+	var source *model.Playlist
+	if ok {
+		source = server.database.QueryPlaylist(id)
+	}
+	if source == nil {
+		writeError(w, r, errNotFound, "playlist not found")
+		return nil
+	}
+	if source.Owner != r.Form.Get("u") {
+		writeError(w, r, errUnauthorized, "the playlist belongs to another user")
+		return nil
+	}
+	return source
+}
+
+// songIDs returns the IDs of the Rolas in the given parameters of the
+// request, and whether they were all valid.
+func (server *Server) songIDs(r *http.Request, name string) ([]int64, bool) {
+	result := make([]int64, 0)
+	for _, text := range r.Form[name] {
+		id, ok := parseID(songPrefix, text)
+		if !ok || server.database.QueryPath(id) == "" {
+			return nil, false
+		}
+		result = append(result, id)
+	}
+	return result, true
+}
+
+// getPlaylists answers with the playlists of the user of the request
+// and the public playlists of other users.
+func (server *Server) getPlaylists(w http.ResponseWriter, r *http.Request) {
+	result := make([]*playlist, 0)
+	for _, source := range server.database.AllPlaylists() {
+		if source.Public || source.Owner == r.Form.Get("u") {
+			result = append(result, server.playlist(source, false))
+		}
+	}
+	answer := newResponse()
+	answer.Playlists = &playlists{result}
+	write(w, r, answer)
+}
+
+func (server *Server) getPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(playlistPrefix, r.Form.Get("id"))
+	var source *model.Playlist
+	if ok {
+		source = server.database.QueryPlaylist(id)
+	}
+	if source == nil || (!source.Public && source.Owner != r.Form.Get("u")) {
+		writeError(w, r, errNotFound, "playlist not found")
+		return
+	}
+	answer := newResponse()
+	answer.Playlist = server.playlist(source, true)
+	write(w, r, answer)
+}
+
+// createPlaylist creates a playlist with the name and the songs of the
+// request, or replaces the songs of the playlist in the playlistId
+// parameter.
+func (server *Server) createPlaylist(w http.ResponseWriter, r *http.Request) {
+	rolas, ok := server.songIDs(r, "songId")
+	if !ok {
+		writeError(w, r, errNotFound, "song not found")
+		return
+	}
+	var id int64
+	if r.Form.Get("playlistId") != "" {
+		source := server.ownPlaylist(w, r, "playlistId")
+		if source == nil {
+			return
+		}
+		id = source.ID
+		if name := r.Form.Get("name"); name != "" {
+			server.database.UpdatePlaylist(id, name, source.Comment, source.Public)
+		}
+		server.database.SetPlaylistRolas(id, rolas)
+	} else if name := r.Form.Get("name"); name != "" {
+		id = server.database.AddPlaylist(name, r.Form.Get("u"), rolas)
+	} else {
+		writeError(w, r, errMissing, "required parameter name is missing")
+		return
+	}
+	answer := newResponse()
+	answer.Playlist = server.playlist(server.database.QueryPlaylist(id), true)
+	write(w, r, answer)
+}
+
+// updatePlaylist changes the name, comment and visibility of a
+// playlist, removes the songs at the songIndexToRemove positions and
+// appends the songs in the songIdToAdd parameters.
+func (server *Server) updatePlaylist(w http.ResponseWriter, r *http.Request) {
+	source := server.ownPlaylist(w, r, "playlistId")
+	if source == nil {
+		return
+	}
+	added, ok := server.songIDs(r, "songIdToAdd")
+	if !ok {
+		writeError(w, r, errNotFound, "song not found")
+		return
+	}
+
+	name, comment, public := source.Name, source.Comment, source.Public
+	if _, ok := r.Form["name"]; ok {
+		name = r.Form.Get("name")
+	}
+	if _, ok := r.Form["comment"]; ok {
+		comment = r.Form.Get("comment")
+	}
+	if _, ok := r.Form["public"]; ok {
+		public = r.Form.Get("public") == "true"
+	}
+	server.database.UpdatePlaylist(source.ID, name, comment, public)
+
+	removed := make(map[int]bool)
+	for _, text := range r.Form["songIndexToRemove"] {
+		index, err := strconv.Atoi(text)
+		if err == nil {
+			removed[index] = true
+		}
+	}
+	if len(removed) > 0 || len(added) > 0 {
+		rolas := make([]int64, 0, len(source.Rolas)+len(added))
+		for i, id := range source.Rolas {
+			if !removed[i] {
+				rolas = append(rolas, id)
+			}
+		}
+		server.database.SetPlaylistRolas(source.ID, append(rolas, added...))
+	}
+	write(w, r, newResponse())
+}
+
+func (server *Server) deletePlaylist(w http.ResponseWriter, r *http.Request) {
+	source := server.ownPlaylist(w, r, "id")
+	if source == nil {
+		return
+	}
+	server.database.DeletePlaylist(source.ID)
+	write(w, r, newResponse())
+}
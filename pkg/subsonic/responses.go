@@ -0,0 +1,192 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+)
+
+// The version of the Subsonic API implemented, and the name of the
+// server sent to OpenSubsonic clients.
+const (
+	apiVersion = "1.16.1"
+	serverType = "rolas"
+)
+
+// The codes of the errors of the Subsonic API.
+const (
+	errGeneric      = 0
+	errMissing      = 10
+	errCredentials  = 40
+	errUnauthorized = 50
+	errNotFound     = 70
+)
+
+// A response is the subsonic-response element every method answers
+// with; only the element of the method that was called is set.   The
+// same structure is written as XML, where the fields are attributes
+// and elements, and as JSON, where they are all fields.
+type response struct {
+	XMLName       xml.Name      `xml:"http://subsonic.org/restapi subsonic-response" json:"-"`
+	Status        string        `xml:"status,attr" json:"status"`
+	Version       string        `xml:"version,attr" json:"version"`
+	Type          string        `xml:"type,attr" json:"type"`
+	OpenSubsonic  bool          `xml:"openSubsonic,attr" json:"openSubsonic"`
+	Error         *apiError     `xml:"error,omitempty" json:"error,omitempty"`
+	License       *license      `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders  *musicFolders `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes       *indexes      `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	Directory     *directory    `xml:"directory,omitempty" json:"directory,omitempty"`
+	Artists       *artists      `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist        *artist       `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album         *album        `xml:"album,omitempty" json:"album,omitempty"`
+	SearchResult3 *searchResult `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Playlists     *playlists    `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist      *playlist     `xml:"playlist,omitempty" json:"playlist,omitempty"`
+}
+
+type apiError struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type license struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type musicFolders struct {
+	MusicFolders []*musicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+type musicFolder struct {
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+// indexes are the performers grouped by the first letter of their
+// names, for browsing by folders; artists are the same for browsing
+// by tags.
+type indexes struct {
+	LastModified    int64    `xml:"lastModified,attr" json:"lastModified"`
+	IgnoredArticles string   `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Indexes         []*index `xml:"index" json:"index"`
+}
+
+type artists struct {
+	IgnoredArticles string   `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Indexes         []*index `xml:"index" json:"index"`
+}
+
+type index struct {
+	Name    string    `xml:"name,attr" json:"name"`
+	Artists []*artist `xml:"artist" json:"artist"`
+}
+
+type artist struct {
+	ID         string   `xml:"id,attr" json:"id"`
+	Name       string   `xml:"name,attr" json:"name"`
+	CoverArt   string   `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AlbumCount int      `xml:"albumCount,attr" json:"albumCount"`
+	Albums     []*album `xml:"album,omitempty" json:"album,omitempty"`
+}
+
+type album struct {
+	ID        string   `xml:"id,attr" json:"id"`
+	Name      string   `xml:"name,attr" json:"name"`
+	Artist    string   `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	ArtistID  string   `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	CoverArt  string   `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount int      `xml:"songCount,attr" json:"songCount"`
+	Duration  int      `xml:"duration,attr" json:"duration"`
+	Created   string   `xml:"created,attr" json:"created"`
+	Year      int      `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre     string   `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	Songs     []*child `xml:"song,omitempty" json:"song,omitempty"`
+}
+
+// A child is an entry of a directory, either an album or a song; songs
+// are also the entries of albums, playlists and searches.
+type child struct {
+	ID          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist      string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track       int    `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Year        int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre       string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	CoverArt    string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size        int64  `xml:"size,attr,omitempty" json:"size,omitempty"`
+	ContentType string `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix      string `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	Duration    int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate     int    `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	Path        string `xml:"path,attr,omitempty" json:"path,omitempty"`
+	DiscNumber  int    `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+	AlbumID     string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistID    string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	Type        string `xml:"type,attr,omitempty" json:"type,omitempty"`
+}
+
+type directory struct {
+	ID       string   `xml:"id,attr" json:"id"`
+	Parent   string   `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	Name     string   `xml:"name,attr" json:"name"`
+	Children []*child `xml:"child" json:"child"`
+}
+
+type searchResult struct {
+	Artists []*artist `xml:"artist" json:"artist"`
+	Albums  []*album  `xml:"album" json:"album"`
+	Songs   []*child  `xml:"song" json:"song"`
+}
+
+type playlists struct {
+	Playlists []*playlist `xml:"playlist" json:"playlist"`
+}
+
+type playlist struct {
+	ID        string   `xml:"id,attr" json:"id"`
+	Name      string   `xml:"name,attr" json:"name"`
+	Comment   string   `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner     string   `xml:"owner,attr" json:"owner"`
+	Public    bool     `xml:"public,attr" json:"public"`
+	SongCount int      `xml:"songCount,attr" json:"songCount"`
+	Duration  int      `xml:"duration,attr" json:"duration"`
+	Created   string   `xml:"created,attr" json:"created"`
+	Changed   string   `xml:"changed,attr" json:"changed"`
+	Entries   []*child `xml:"entry,omitempty" json:"entry,omitempty"`
+}
+
+// newResponse returns an empty successful response.
+func newResponse() *response {
+	return &response{
+		Status:       "ok",
+		Version:      apiVersion,
+		Type:         serverType,
+		OpenSubsonic: true,
+	}
+}
+
+// write writes a response in the format asked for in the f parameter
+// of the request: XML by default, or JSON.   Subsonic clients expect
+// errors in the body, so the status is always 200 OK.
+func write(w http.ResponseWriter, r *http.Request, answer *response) {
+	if r.Form.Get("f") == "json" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]*response{"subsonic-response": answer})
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(answer)
+}
+
+// writeError writes a failed response with the given code and message.
+func writeError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	answer := newResponse()
+	answer.Status = "failed"
+	answer.Error = &apiError{code, message}
+	write(w, r, answer)
+}
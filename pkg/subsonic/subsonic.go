@@ -0,0 +1,176 @@
+// Package subsonic serves the library of rolas over the core of the
+// Subsonic API, so the many clients that speak it can browse and play
+// the library.   Performers are the artists of the API, and the
+// directories the Miner mines are its music folders.   The methods
+// are answered under any prefix, with or without the ".view" suffix:
+//
+//	ping, getLicense
+//	getMusicFolders, getIndexes, getMusicDirectory
+//	getArtists, getArtist, getAlbum, search3
+//	stream, download, getCoverArt, scrobble
+//	getPlaylists, getPlaylist, createPlaylist, updatePlaylist,
+//	deletePlaylist
+//
+// Every request must authenticate one of the users of the server with
+// the u parameter and either the t and s parameters, where the token t
+// is the MD5 hash of the password followed by the salt s, or the p
+// parameter with the password, in clear or hex encoded after "enc:".
+// The answers are XML, or JSON if the f parameter is "json".
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Japodrilo/MyP-Proyecto2/pkg/model"
+)
+
+// The prefixes of the IDs of the API, which tell performers, albums,
+// Rolas and playlists apart.
+const (
+	artistPrefix   = "ar-"
+	albumPrefix    = "al-"
+	songPrefix     = "tr-"
+	playlistPrefix = "pl-"
+)
+
+// A Server answers the requests of the Subsonic API over a database,
+// for the given users and music folders.
+type Server struct {
+	database *model.Database
+	users    map[string]string
+	folders  []string
+}
+
+// methods maps the names of the methods of the API to their handlers.
+var methods = map[string]func(*Server, http.ResponseWriter, *http.Request){
+	"ping":              (*Server).ping,
+	"getLicense":        (*Server).getLicense,
+	"getMusicFolders":   (*Server).getMusicFolders,
+	"getIndexes":        (*Server).getIndexes,
+	"getMusicDirectory": (*Server).getMusicDirectory,
+	"getArtists":        (*Server).getArtists,
+	"getArtist":         (*Server).getArtist,
+	"getAlbum":          (*Server).getAlbum,
+	"search3":           (*Server).search3,
+	"stream":            (*Server).stream,
+	"download":          (*Server).stream,
+	"getCoverArt":       (*Server).getCoverArt,
+	"scrobble":          (*Server).scrobble,
+	"getPlaylists":      (*Server).getPlaylists,
+	"getPlaylist":       (*Server).getPlaylist,
+	"createPlaylist":    (*Server).createPlaylist,
+	"updatePlaylist":    (*Server).updatePlaylist,
+	"deletePlaylist":    (*Server).deletePlaylist,
+}
+
+// NewServer returns a Server over the given database, for the users
+// mapped to their passwords, with the given directories as its music
+// folders.
+func NewServer(database *model.Database, users map[string]string, folders []string) *Server {
+	cleaned := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		cleaned = append(cleaned, filepath.Clean(folder))
+	}
+	return &Server{
+		database: database,
+		users:    users,
+		folders:  cleaned,
+	}
+}
+
+// ServeHTTP authenticates the request and calls the method named by the
+// last element of its path.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeError(w, r, errGeneric, "invalid request: "+err.Error())
+		return
+	}
+	handler, ok := methods[strings.TrimSuffix(path.Base(r.URL.Path), ".view")]
+	if !ok {
+		writeError(w, r, errGeneric, "unknown method")
+		return
+	}
+	code, message := server.authenticate(r.Form)
+	if code != 0 {
+		writeError(w, r, code, message)
+		return
+	}
+	handler(server, w, r)
+}
+
+// authenticate checks the credentials of a request, and returns 0 if
+// they are valid, or the code and the message of the error otherwise.
+func (server *Server) authenticate(form url.Values) (int, string) {
+	user := form.Get("u")
+	token, salt, password := form.Get("t"), form.Get("s"), form.Get("p")
+	if user == "" || (password == "" && (token == "" || salt == "")) {
+		return errMissing, "missing credentials"
+	}
+	expected, ok := server.users[user]
+	if ok && token != "" {
+		sum := md5.Sum([]byte(expected + salt))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(token))) == 1
+	} else if ok {
+		if strings.HasPrefix(password, "enc:") {
+			decoded, err := hex.DecodeString(password[len("enc:"):])
+			password = string(decoded)
+			ok = err == nil
+		}
+		ok = ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+	}
+	if !ok {
+		return errCredentials, "wrong username or password"
+	}
+	return 0, ""
+}
+
+func (server *Server) ping(w http.ResponseWriter, r *http.Request) {
+	write(w, r, newResponse())
+}
+
+func (server *Server) getLicense(w http.ResponseWriter, r *http.Request) {
+	answer := newResponse()
+	answer.License = &license{Valid: true}
+	write(w, r, answer)
+}
+
+// newID returns the ID in the API of the element of the database with
+// the given prefix and ID.
+func newID(prefix string, id int64) string {
+	return prefix + strconv.FormatInt(id, 10)
+}
+
+// parseID returns the ID in the database of an ID of the API with the
+// given prefix, and whether it was valid.
+func parseID(prefix, text string) (int64, bool) {
+	if !strings.HasPrefix(text, prefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(text[len(prefix):], 10, 64)
+	return id, err == nil && id > 0
+}
+
+// formInt returns the integer in a parameter of the request, or the
+// given default if the parameter is missing or is not a number.
+func formInt(r *http.Request, name string, value int) int {
+	number, err := strconv.Atoi(r.Form.Get(name))
+	if err != nil {
+		return value
+	}
+	return number
+}
+
+// timestamp formats a time as the API expects it.
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,124 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	server := NewServer(nil, map[string]string{"ana": "sesame"}, nil)
+	requests := []struct {
+		query string
+		code  int
+	}{
+		// md5("sesame" + "c19b2d") = 26719a1196d2a940705a59634eb18eab
+		{"u=ana&t=26719a1196d2a940705a59634eb18eab&s=c19b2d", 0},
+		{"u=ana&t=26719A1196D2A940705A59634EB18EAB&s=c19b2d", 0},
+		{"u=ana&t=26719a1196d2a940705a59634eb18eab&s=other", errCredentials},
+		{"u=ana&p=sesame", 0},
+		{"u=ana&p=enc:736573616d65", 0},
+		{"u=ana&p=enc:zz", errCredentials},
+		{"u=ana&p=wrong", errCredentials},
+		{"u=bob&p=sesame", errCredentials},
+		{"u=ana&t=26719a1196d2a940705a59634eb18eab", errMissing},
+		{"p=sesame", errMissing},
+	}
+	for _, request := range requests {
+		form, _ := url.ParseQuery(request.query)
+		if code, _ := server.authenticate(form); code != request.code {
+			t.Errorf("%v: expecting %v, received %v", request.query, request.code, code)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := NewServer(nil, map[string]string{"ana": "sesame"}, nil)
+	requests := []struct {
+		target string
+		status string
+		code   int
+	}{
+		{"/rest/ping.view?u=ana&p=sesame", "ok", 0},
+		{"/rest/ping?u=ana&p=sesame&f=json", "ok", 0},
+		{"/rest/ping.view?u=ana&p=wrong", "failed", errCredentials},
+		{"/rest/getNowPlaying.view?u=ana&p=sesame&f=json", "failed", errGeneric},
+	}
+	for _, request := range requests {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", request.target, nil))
+		answer := &response{}
+		if strings.Contains(request.target, "f=json") {
+			wrapper := struct {
+				Response *response `json:"subsonic-response"`
+			}{answer}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &wrapper); err != nil {
+				t.Fatalf("%v: %v", request.target, err)
+			}
+		} else if err := xml.Unmarshal(recorder.Body.Bytes(), answer); err != nil {
+			t.Fatalf("%v: %v", request.target, err)
+		}
+		if answer.Status != request.status || answer.Version != apiVersion {
+			t.Errorf("%v: expecting %v, received %v", request.target, request.status, answer.Status)
+		}
+		if request.code != 0 && (answer.Error == nil || answer.Error.Code != request.code) {
+			t.Errorf("%v: expecting error %v, received %v", request.target, request.code, answer.Error)
+		}
+	}
+}
+
+func TestBuildIndexes(t *testing.T) {
+	artists := []*artist{
+		{Name: "The Beatles"},
+		{Name: "Babasónicos"},
+		{Name: "2 Minutos"},
+		{Name: "Los Ángeles Azules"},
+		{Name: "Café Tacvba"},
+	}
+	indexes := buildIndexes(artists)
+	names := make([]string, 0)
+	for _, index := range indexes {
+		for _, artist := range index.Artists {
+			names = append(names, index.Name+":"+artist.Name)
+		}
+	}
+	expected := "B:Babasónicos B:The Beatles C:Café Tacvba Á:Los Ángeles Azules #:2 Minutos"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("expecting %v, received %v", expected, strings.Join(names, " "))
+	}
+}
+
+func TestCoverSize(t *testing.T) {
+	sizes := map[int]int{0: 0, 50: 64, 64: 64, 100: 128, 300: 500, 1000: 0}
+	for size, expected := range sizes {
+		if received := coverSize(size); received != expected {
+			t.Errorf("expecting %v, received %v", expected, received)
+		}
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subsonic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "users.json")
+	ioutil.WriteFile(path, []byte(`[{"username": "ana", "password": "sesame"}]`), 0600)
+	users, err := LoadUsers(path)
+	if err != nil || users["ana"] != "sesame" {
+		t.Errorf("expecting %v, received %v (%v)", "sesame", users["ana"], err)
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"username": "ana"}]`), 0600)
+	if _, err := LoadUsers(path); err == nil {
+		t.Errorf("expecting an error for a user without a password")
+	}
+}
@@ -0,0 +1,38 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+)
+
+// A user is a user of the server as it is written in the users file.
+type user struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoadUsers reads the users of the server from a JSON file with a list
+// of objects with their usernames and passwords, and returns them
+// mapped to their passwords.   The token authentication of the API
+// needs the passwords themselves, so the file should only be readable
+// by the user running the server.
+func LoadUsers(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*user, 0)
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, errors.New("invalid users file " + path + ": " + err.Error())
+	}
+	users := make(map[string]string)
+	for _, user := range list {
+		if user.Username == "" || user.Password == "" {
+			return nil, errors.New("invalid users file " + path + ": every user needs a username and a password")
+		}
+		users[user.Username] = user.Password
+	}
+	return users, nil
+}
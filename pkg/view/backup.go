@@ -0,0 +1,98 @@
+package view
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A BackupManager represents the window used by the 'Backups' button in
+// the main application window.   It lists the backups of the database,
+// and has buttons to make a backup, restore the selected one, and check
+// the library for problems.
+type BackupManager struct {
+	BackupB   *gtk.ToolButton
+	BackupsLB *gtk.ListBox
+	CheckB    *gtk.ToolButton
+	RestoreB  *gtk.ToolButton
+	Win       *gtk.Window
+}
+
+// BackupManagerWindow creates a BackupManager and draws the
+// corresponding window.
+func BackupManagerWindow() *BackupManager {
+	win := SetupPopupWindow("Backups", 420, 360)
+	box := SetupBox()
+	scrwin := SetupScrolledWindow()
+	backups := SetupListBox()
+	tb := SetupToolbar()
+	backup := SetupToolButtonLabel("Back up now")
+	restore := SetupToolButtonLabel("Restore")
+	check := SetupToolButtonLabel("Check library")
+
+	scrwin.SetVExpand(true)
+	scrwin.Add(backups)
+
+	tb.Add(backup)
+	tb.Add(restore)
+	tb.Add(check)
+	tb.SetHExpand(true)
+
+	box.Add(scrwin)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+
+	return &BackupManager{
+		BackupB:   backup,
+		BackupsLB: backups,
+		CheckB:    check,
+		RestoreB:  restore,
+		Win:       win,
+	}
+}
+
+// SetBackups replaces the list of backups with the given descriptions.
+func (manager *BackupManager) SetBackups(descriptions []string) {
+	for row := manager.BackupsLB.GetRowAtIndex(0); row != nil; row = manager.BackupsLB.GetRowAtIndex(0) {
+		row.Destroy()
+	}
+	for _, description := range descriptions {
+		manager.BackupsLB.Add(SetupListBoxRowLabel(description))
+	}
+	manager.Win.ShowAll()
+}
+
+// Selected returns the index of the selected backup in the list, or -1
+// if there is no backup selected.
+func (manager *BackupManager) Selected() int {
+	row := manager.BackupsLB.GetSelectedRow()
+	if row == nil {
+		return -1
+	}
+	return row.GetIndex()
+}
+
+// ProblemsWindow draws a window listing the problems found in the
+// library, or telling that there are none.
+func ProblemsWindow(problems []string) {
+	win := SetupPopupWindow("Library check", 560, 360)
+	scrwin := SetupScrolledWindow()
+	list := SetupListBox()
+	if len(problems) == 0 {
+		list.Add(SetupListBoxRowLabel("No problems found"))
+	}
+	for _, problem := range problems {
+		list.Add(SetupListBoxRowLabel(problem))
+	}
+	scrwin.Add(list)
+	win.Add(scrwin)
+	win.ShowAll()
+}
+
+// Confirm runs a dialog asking the user a question, and tells whether
+// the answer was yes.
+func Confirm(parent *gtk.Window, question string) bool {
+	dialog := gtk.MessageDialogNew(parent, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, "%s", question)
+	defer dialog.Destroy()
+	return gtk.ResponseType(dialog.Run()) == gtk.RESPONSE_YES
+}
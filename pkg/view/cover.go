@@ -0,0 +1,138 @@
+package view
+
+import (
+	"log"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A CoverManager represents the window used by the 'Artwork' menu in
+// the main application window.   It lists the pictures of a Rola, each
+// with its type, and has buttons to view a picture in its full size,
+// save it to a file, replace it from a file, and apply it to all the
+// Rolas of the album.
+type CoverManager struct {
+	ApplyB      *gtk.ToolButton
+	PicturesLB  *gtk.ListBox
+	ReplaceB    *gtk.ToolButton
+	SaveAsB     *gtk.ToolButton
+	ViewB       *gtk.ToolButton
+	Win         *gtk.Window
+	WriteTagsCB *gtk.CheckButton
+}
+
+// CoverManagerWindow creates a CoverManager and draws the corresponding
+// window.
+func CoverManagerWindow() *CoverManager {
+	win := SetupPopupWindow("Artwork", 420, 520)
+	box := SetupBox()
+	scrwin := SetupScrolledWindow()
+	pictures := SetupListBox()
+	writeTags := SetupCheckButton("Write the changes to the tags")
+	tb := SetupToolbar()
+	view := SetupToolButtonLabel("View")
+	saveAs := SetupToolButtonLabel("Save as")
+	replace := SetupToolButtonLabel("Replace")
+	apply := SetupToolButtonLabel("Apply to album")
+
+	scrwin.SetVExpand(true)
+	scrwin.Add(pictures)
+
+	tb.Add(view)
+	tb.Add(saveAs)
+	tb.Add(replace)
+	tb.Add(apply)
+	tb.SetHExpand(true)
+
+	box.Add(scrwin)
+	box.Add(writeTags)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+
+	return &CoverManager{
+		ApplyB:      apply,
+		PicturesLB:  pictures,
+		ReplaceB:    replace,
+		SaveAsB:     saveAs,
+		ViewB:       view,
+		Win:         win,
+		WriteTagsCB: writeTags,
+	}
+}
+
+// AddPicture adds a row to the list of pictures, with a thumbnail of
+// the picture and its description.
+func (manager *CoverManager) AddPicture(pix *gdk.Pixbuf, description string) {
+	manager.PicturesLB.Add(SetupListBoxRowPicture(pix, description))
+	manager.Win.ShowAll()
+}
+
+// Clear removes all the pictures from the list.
+func (manager *CoverManager) Clear() {
+	for row := manager.PicturesLB.GetRowAtIndex(0); row != nil; row = manager.PicturesLB.GetRowAtIndex(0) {
+		row.Destroy()
+	}
+}
+
+// Selected returns the index of the selected picture in the list, or
+// -1 if there is no picture selected.
+func (manager *CoverManager) Selected() int {
+	row := manager.PicturesLB.GetSelectedRow()
+	if row == nil {
+		return -1
+	}
+	return row.GetIndex()
+}
+
+// PictureWindow draws a window showing a picture in its full size,
+// scrolling it when it does not fit in the window.
+func PictureWindow(title string, pix *gdk.Pixbuf) {
+	win := SetupPopupWindow(title, 600, 600)
+	scrwin := SetupScrolledWindow()
+	image, err := gtk.ImageNewFromPixbuf(pix)
+	if err != nil {
+		log.Fatal("Unable to create image:", err)
+	}
+	scrwin.Add(image)
+	win.Add(scrwin)
+	win.ShowAll()
+}
+
+// ChooseOpenFile runs a dialog to choose a file to open, and returns its
+// path, or an empty string if the dialog is cancelled.
+func ChooseOpenFile(parent *gtk.Window, title string) string {
+	return chooseFile(parent, title, gtk.FILE_CHOOSER_ACTION_OPEN, "Open", "")
+}
+
+// ChooseSaveFile runs a dialog to choose the path of a file to save,
+// suggesting the given name, and returns the path, or an empty string
+// if the dialog is cancelled.
+func ChooseSaveFile(parent *gtk.Window, title, name string) string {
+	return chooseFile(parent, title, gtk.FILE_CHOOSER_ACTION_SAVE, "Save", name)
+}
+
+// ChooseFolder runs a dialog to choose a directory, and returns its
+// path, or an empty string if the dialog is cancelled.
+func ChooseFolder(parent *gtk.Window, title string) string {
+	return chooseFile(parent, title, gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER, "Select", "")
+}
+
+func chooseFile(parent *gtk.Window, title string, action gtk.FileChooserAction, accept, name string) string {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons(title, parent, action,
+		"Cancel", gtk.RESPONSE_CANCEL, accept, gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		log.Fatal("Unable to create file chooser:", err)
+	}
+	defer dialog.Destroy()
+	if action == gtk.FILE_CHOOSER_ACTION_SAVE {
+		dialog.SetDoOverwriteConfirmation(true)
+		dialog.SetCurrentName(name)
+	}
+	if gtk.ResponseType(dialog.Run()) != gtk.RESPONSE_ACCEPT {
+		return ""
+	}
+	return dialog.GetFilename()
+}
@@ -0,0 +1,78 @@
+package view
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// SetupDateEntry creates a new gtk.Entry for a partial date, which may
+// be typed as YYYY, YYYY-MM or YYYY-MM-DD, or picked from a calendar
+// with the icon at the end of the entry, and returns it.
+func SetupDateEntry() *gtk.Entry {
+	entry := SetupEntry()
+	entry.SetPlaceholderText("YYYY-MM-DD")
+	entry.SetIconFromIconName(gtk.ENTRY_ICON_SECONDARY, "x-office-calendar")
+	entry.SetIconTooltipText(gtk.ENTRY_ICON_SECONDARY, "Pick a date")
+	entry.Connect("icon-press", func() {
+		DatePickerWindow(entry)
+	})
+	return entry
+}
+
+// DatePickerWindow draws a window with a calendar, showing the date in
+// the given entry, if there is one.   The buttons of the window write
+// the year, the month or the day picked in the entry, according to how
+// much of the date is known, and close the window.
+func DatePickerWindow(entry *gtk.Entry) {
+	win := SetupPopupWindow("Pick a date", 280, 240)
+	box := SetupBox()
+	tb := SetupToolbar()
+	year := SetupToolButtonLabel("Year")
+	month := SetupToolButtonLabel("Month")
+	day := SetupToolButtonLabel("Day")
+
+	calendar, err := gtk.CalendarNew()
+	if err != nil {
+		log.Fatal("Unable to create Calendar:", err)
+	}
+	var y, m, d uint
+	n, _ := fmt.Sscanf(GetTextEntry(entry), "%d-%d-%d", &y, &m, &d)
+	if n > 0 && y > 0 {
+		if m < 1 || m > 12 {
+			m = 1
+		}
+		calendar.SelectMonth(m-1, y)
+	}
+	if n == 3 && d > 0 {
+		calendar.SelectDay(d)
+	}
+
+	year.Connect("clicked", func() {
+		y, _, _ := calendar.GetDate()
+		entry.SetText(fmt.Sprintf("%04d", y))
+		win.Close()
+	})
+	month.Connect("clicked", func() {
+		y, m, _ := calendar.GetDate()
+		entry.SetText(fmt.Sprintf("%04d-%02d", y, m+1))
+		win.Close()
+	})
+	day.Connect("clicked", func() {
+		y, m, d := calendar.GetDate()
+		entry.SetText(fmt.Sprintf("%04d-%02d-%02d", y, m+1, d))
+		win.Close()
+	})
+
+	tb.Add(year)
+	tb.Add(month)
+	tb.Add(day)
+	tb.SetHExpand(true)
+
+	box.Add(calendar)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+}
@@ -0,0 +1,120 @@
+package view
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A DuplicateFinder represents the window used by the 'Duplicates'
+// button in the main application window.   It lists the groups of rolas
+// taken for copies of the same song and, side by side, the copies of the
+// selected group; the buttons keep the selected copy and delete, hide or
+// move the others.
+type DuplicateFinder struct {
+	CandidatesLB *gtk.ListBox
+	DeleteB      *gtk.ToolButton
+	GroupsLB     *gtk.ListBox
+	HideB        *gtk.ToolButton
+	MoveB        *gtk.ToolButton
+	Win          *gtk.Window
+}
+
+// DuplicateFinderWindow creates a DuplicateFinder and draws the
+// corresponding window.
+func DuplicateFinderWindow() *DuplicateFinder {
+	win := SetupPopupWindow("Duplicates", 720, 420)
+	box := SetupBox()
+	grid := SetupGrid(gtk.ORIENTATION_HORIZONTAL)
+	groupsScroll := SetupScrolledWindow()
+	candidatesScroll := SetupScrolledWindow()
+	groups := SetupListBox()
+	candidates := SetupListBox()
+	tb := SetupToolbar()
+	remove := SetupToolButtonLabel("Delete others")
+	hide := SetupToolButtonLabel("Hide others")
+	move := SetupToolButtonLabel("Move others…")
+
+	groupsScroll.SetVExpand(true)
+	groupsScroll.SetHExpand(true)
+	groupsScroll.Add(groups)
+	candidatesScroll.SetVExpand(true)
+	candidatesScroll.SetHExpand(true)
+	candidatesScroll.Add(candidates)
+
+	grid.Add(groupsScroll)
+	grid.Add(candidatesScroll)
+	grid.SetColumnHomogeneous(true)
+
+	tb.Add(remove)
+	tb.Add(hide)
+	tb.Add(move)
+	tb.SetHExpand(true)
+
+	box.Add(grid)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+
+	return &DuplicateFinder{
+		CandidatesLB: candidates,
+		DeleteB:      remove,
+		GroupsLB:     groups,
+		HideB:        hide,
+		MoveB:        move,
+		Win:          win,
+	}
+}
+
+// SetGroups replaces the list of groups with the given descriptions, and
+// empties the list of copies.
+func (finder *DuplicateFinder) SetGroups(descriptions []string) {
+	clearListBox(finder.GroupsLB)
+	clearListBox(finder.CandidatesLB)
+	if len(descriptions) == 0 {
+		finder.GroupsLB.Add(SetupListBoxRowLabel("No duplicates found"))
+	}
+	for _, description := range descriptions {
+		finder.GroupsLB.Add(SetupListBoxRowLabel(description))
+	}
+	finder.Win.ShowAll()
+}
+
+// SetCandidates replaces the list of copies with the given descriptions,
+// and selects the first one, which is the one kept by default.
+func (finder *DuplicateFinder) SetCandidates(descriptions []string) {
+	clearListBox(finder.CandidatesLB)
+	for _, description := range descriptions {
+		finder.CandidatesLB.Add(SetupListBoxRowLabel(description))
+	}
+	finder.CandidatesLB.SelectRow(finder.CandidatesLB.GetRowAtIndex(0))
+	finder.Win.ShowAll()
+}
+
+// SelectedGroup returns the index of the selected group, or -1 if there
+// is no group selected.
+func (finder *DuplicateFinder) SelectedGroup() int {
+	return selectedIndex(finder.GroupsLB)
+}
+
+// SelectedCandidate returns the index of the selected copy, or -1 if
+// there is no copy selected.
+func (finder *DuplicateFinder) SelectedCandidate() int {
+	return selectedIndex(finder.CandidatesLB)
+}
+
+// clearListBox removes every row of a list box.
+func clearListBox(list *gtk.ListBox) {
+	for row := list.GetRowAtIndex(0); row != nil; row = list.GetRowAtIndex(0) {
+		row.Destroy()
+	}
+}
+
+// selectedIndex returns the index of the selected row of a list box, or
+// -1 if there is no row selected.
+func selectedIndex(list *gtk.ListBox) int {
+	row := list.GetSelectedRow()
+	if row == nil {
+		return -1
+	}
+	return row.GetIndex()
+}
@@ -23,9 +23,9 @@ func NewGroupContent() *GroupContent {
 	groupNameL := SetupLabel("Name:")
 	groupNameE := SetupEntry()
 	startL := SetupLabel("Start date:")
-	startE := SetupEntry()
+	startE := SetupDateEntry()
 	endL := SetupLabel("End date:")
-	endE := SetupEntry()
+	endE := SetupDateEntry()
 	cornerSE := SetupLabel("    ")
 
 	groupNameE.SetHExpand(true)
@@ -49,14 +49,18 @@ func NewGroupContent() *GroupContent {
 	}
 }
 
-// AddMember contains a gtk.ListBox, a gtk.ComboBoxText and the grid
-// holding them.   These are used to create the dialog where a member
-// is added to a group in the application.   It is intended to be used
-// inside a gtk.Container.
+// AddMember contains a gtk.ListBox, a gtk.ComboBoxText, the entries for
+// the period and the roles of a member, and the grid holding them.
+// These are used to create the dialog where a member is added to a
+// group in the application.   It is intended to be used inside a
+// gtk.Container.
 type AddMember struct {
 	CurrentMemberLB *gtk.ListBox
 	grid            *gtk.Grid
+	JoinedE         *gtk.Entry
+	LeftE           *gtk.Entry
 	NewMemberCBT    *gtk.ComboBoxText
+	RolesE          *gtk.Entry
 }
 
 // NewAddMember creates a new AddMember object and returs it.
@@ -66,35 +70,57 @@ func NewAddMember() *AddMember {
 	cornerNW := SetupLabel("    ")
 	currentMemberL := SetupLabel("Current Members:")
 	currentMemberLB := SetupListBox()
-	newMemberL := SetupLabel("New Member:")
+	newMemberL := SetupLabel("Member:")
 	newMemberCBT := SetupComboBoxText()
+	joinedL := SetupLabel("Joined:")
+	joinedE := SetupDateEntry()
+	leftL := SetupLabel("Left:")
+	leftE := SetupDateEntry()
+	rolesL := SetupLabel("Roles:")
+	rolesE := SetupEntry()
 	cornerSE := SetupLabel("    ")
 
 	newMemberCBT.SetHExpand(true)
 	currentMemberLB.SetVExpand(true)
+	rolesE.SetPlaceholderText("vocals, guitar, ...")
 
 	grid.Add(cornerNW)
-	grid.Attach(currentMemberL, 1, 2, 1, 1)
-	grid.Attach(currentMemberLB, 2, 2, 1, 1)
 	grid.Attach(newMemberL, 1, 1, 1, 1)
+	grid.Attach(joinedL, 1, 2, 1, 1)
+	grid.Attach(leftL, 1, 3, 1, 1)
+	grid.Attach(rolesL, 1, 4, 1, 1)
+	grid.Attach(currentMemberL, 1, 5, 1, 1)
 	grid.Attach(newMemberCBT, 2, 1, 1, 1)
-	grid.Attach(cornerSE, 3, 3, 1, 1)
+	grid.Attach(joinedE, 2, 2, 1, 1)
+	grid.Attach(leftE, 2, 3, 1, 1)
+	grid.Attach(rolesE, 2, 4, 1, 1)
+	grid.Attach(currentMemberLB, 2, 5, 1, 1)
+	grid.Attach(cornerSE, 3, 6, 1, 1)
 
 	return &AddMember{
 		CurrentMemberLB: currentMemberLB,
 		grid:            grid,
+		JoinedE:         joinedE,
+		LeftE:           leftE,
 		NewMemberCBT:    newMemberCBT,
+		RolesE:          rolesE,
 	}
 }
 
 // EditGroup represents the window from the 'Edit Group' dialog in the
 // main application.   It contains the information of both a GroupContent
-// and an AddMember objects.
+// and an AddMember objects, and the buttons to remove a member and to
+// show the lineups of the group.
 type EditGroup struct {
 	CurrentMemberLB *gtk.ListBox
 	GroupContent    *GroupContent
+	JoinedE         *gtk.Entry
+	LeftE           *gtk.Entry
+	LineupB         *gtk.ToolButton
 	NewMemberCBT    *gtk.ComboBoxText
 	Notebook        *gtk.Notebook
+	RemoveB         *gtk.ToolButton
+	RolesE          *gtk.Entry
 	SaveB           *gtk.ToolButton
 	Win             *gtk.Window
 }
@@ -107,18 +133,23 @@ func EditGroupWindow() *EditGroup {
 	nb := SetupNotebook()
 	tb := SetupToolbar()
 	save := SetupToolButtonLabel("Save")
+	remove := SetupToolButtonLabel("Remove")
+	lineup := SetupToolButtonLabel("Lineup")
 
 	addMember := NewAddMember()
 	groupContent := NewGroupContent()
 
 	save.SetExpand(true)
 	save.SetVExpand(true)
+	remove.SetSensitive(false)
 
 	tb.Add(save)
+	tb.Add(remove)
+	tb.Add(lineup)
 	tb.SetHExpand(true)
 
 	nb.AppendPage(groupContent.grid, SetupLabel("Edit Group"))
-	nb.AppendPage(addMember.grid, SetupLabel("Add Member"))
+	nb.AppendPage(addMember.grid, SetupLabel("Members"))
 
 	box.Add(nb)
 	box.Add(tb)
@@ -129,9 +160,45 @@ func EditGroupWindow() *EditGroup {
 	return &EditGroup{
 		CurrentMemberLB: addMember.CurrentMemberLB,
 		GroupContent:    groupContent,
+		JoinedE:         addMember.JoinedE,
+		LeftE:           addMember.LeftE,
+		LineupB:         lineup,
 		NewMemberCBT:    addMember.NewMemberCBT,
 		Notebook:        nb,
+		RemoveB:         remove,
+		RolesE:          addMember.RolesE,
 		SaveB:           save,
 		Win:             win,
 	}
 }
+
+// LineupWindow draws the timeline of the lineups of a group: a column
+// for every period in which the members did not change, and a row for
+// every member, marked in the periods the member was in the group.
+// active[i][j] tells whether the i-th member was in the j-th period.
+func LineupWindow(group string, periods, members []string, active [][]bool) {
+	win := SetupPopupWindow("Lineup of "+group, 560, 300)
+	scrwin := SetupScrolledWindow()
+	grid := SetupGrid(gtk.ORIENTATION_VERTICAL)
+
+	grid.SetColumnSpacing(12)
+	grid.SetRowSpacing(6)
+
+	for j, period := range periods {
+		grid.Attach(SetupLabel(period), j+1, 0, 1, 1)
+	}
+	for i, member := range members {
+		name := SetupLabel(member)
+		name.SetXAlign(0)
+		grid.Attach(name, 0, i+1, 1, 1)
+		for j := range periods {
+			if active[i][j] {
+				grid.Attach(SetupLabel("■"), j+1, i+1, 1, 1)
+			}
+		}
+	}
+
+	scrwin.Add(grid)
+	win.Add(scrwin)
+	win.ShowAll()
+}
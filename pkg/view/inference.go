@@ -0,0 +1,84 @@
+package view
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A TagInferrer represents the window used by the 'Infer tags' button in
+// the main application window.   It previews the tags inferred from the
+// paths of the rolas, each with a check button to choose whether to
+// apply it, and has a button to apply the chosen ones.
+type TagInferrer struct {
+	ApplyB     *gtk.ToolButton
+	Checks     []*gtk.CheckButton
+	InferredLB *gtk.ListBox
+	NoneB      *gtk.ToolButton
+	Win        *gtk.Window
+}
+
+// TagInferrerWindow creates a TagInferrer and draws the corresponding
+// window.
+func TagInferrerWindow() *TagInferrer {
+	win := SetupPopupWindow("Infer tags", 640, 420)
+	box := SetupBox()
+	scrwin := SetupScrolledWindow()
+	inferred := SetupListBox()
+	tb := SetupToolbar()
+	apply := SetupToolButtonLabel("Apply")
+	none := SetupToolButtonLabel("Choose none")
+
+	scrwin.SetVExpand(true)
+	scrwin.Add(inferred)
+
+	tb.Add(apply)
+	tb.Add(none)
+	tb.SetHExpand(true)
+
+	box.Add(scrwin)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+
+	return &TagInferrer{
+		ApplyB:     apply,
+		InferredLB: inferred,
+		NoneB:      none,
+		Win:        win,
+	}
+}
+
+// SetInferences replaces the previewed inferences with the given
+// descriptions, all of them chosen.
+func (inferrer *TagInferrer) SetInferences(descriptions []string) {
+	clearListBox(inferrer.InferredLB)
+	inferrer.Checks = make([]*gtk.CheckButton, len(descriptions))
+	if len(descriptions) == 0 {
+		inferrer.InferredLB.Add(SetupListBoxRowLabel("No tags to infer"))
+	}
+	for i, description := range descriptions {
+		check := SetupCheckButton(description)
+		check.SetActive(true)
+		inferrer.Checks[i] = check
+		inferrer.InferredLB.Add(check)
+	}
+	inferrer.Win.ShowAll()
+}
+
+// Chosen returns the indices of the chosen inferences.
+func (inferrer *TagInferrer) Chosen() []int {
+	chosen := make([]int, 0)
+	for i, check := range inferrer.Checks {
+		if check.GetActive() {
+			chosen = append(chosen, i)
+		}
+	}
+	return chosen
+}
+
+// ChooseNone unchecks every inference.
+func (inferrer *TagInferrer) ChooseNone() {
+	for _, check := range inferrer.Checks {
+		check.SetActive(false)
+	}
+}
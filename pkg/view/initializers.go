@@ -3,6 +3,7 @@ package view
 import (
 	"log"
 
+	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -59,6 +60,32 @@ func GetTextSearchEntry(entry *gtk.SearchEntry) string {
 	return text
 }
 
+// GetTextView receives a gtk.TextView as a parameter and returns
+// the text in its buffer. It includes error handling.
+func GetTextView(textView *gtk.TextView) string {
+	buffer, err := textView.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to get buffer:", err)
+	}
+	start, end := buffer.GetBounds()
+	text, err := buffer.GetText(start, end, true)
+	if err != nil {
+		log.Fatal("Unable to get text from buffer:", err)
+	}
+	return text
+}
+
+// SetTextView receives a gtk.TextView and a string as parameters, and
+// replaces the text in the buffer of the text view by the string.
+// It includes error handling.
+func SetTextView(textView *gtk.TextView, text string) {
+	buffer, err := textView.GetBuffer()
+	if err != nil {
+		log.Fatal("Unable to get buffer:", err)
+	}
+	buffer.SetText(text)
+}
+
 // SetupBox creates a new gtk.Box object, sets its 'Homogeneous' property
 // to false, and returns it. It includes error handling.
 func SetupBox() *gtk.Box {
@@ -70,6 +97,16 @@ func SetupBox() *gtk.Box {
 	return box
 }
 
+// SetupCheckButton creates a new gtk.CheckButton object with the label
+// given as an argument, and returns it. It includes error handling.
+func SetupCheckButton(label string) *gtk.CheckButton {
+	cb, err := gtk.CheckButtonNewWithLabel(label)
+	if err != nil {
+		log.Fatal("Unable to create check button:", err)
+	}
+	return cb
+}
+
 // SetupComboBoxText creates a new gtk.ComboBoxTex object
 // and returns it. It includes error handling.
 func SetupComboBoxText() *gtk.ComboBoxText {
@@ -138,6 +175,29 @@ func SetupListBoxRowLabel(text string) *gtk.ListBoxRow {
 	return lbr
 }
 
+// SetupListBoxRowPicture creates a new gtk.ListBoxRow object holding
+// an image with the pixbuf given as an argument, next to a label with
+// the text given as an argument, and returns it.
+// It includes error handling.
+func SetupListBoxRowPicture(pix *gdk.Pixbuf, text string) *gtk.ListBoxRow {
+	lbr, err := gtk.ListBoxRowNew()
+	if err != nil {
+		log.Fatal("Unable to create List Box Row:", err)
+	}
+	box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 12)
+	if err != nil {
+		log.Fatal("Unable to create box:", err)
+	}
+	image, err := gtk.ImageNewFromPixbuf(pix)
+	if err != nil {
+		log.Fatal("Unable to create image:", err)
+	}
+	box.Add(image)
+	box.Add(SetupLabel(text))
+	lbr.Add(box)
+	return lbr
+}
+
 // SetupNotebook creates a new gtk.Notebook object and returns it.
 // It includes error handling.
 func SetupNotebook() *gtk.Notebook {
@@ -199,6 +259,27 @@ func SetupSearchEntry() *gtk.SearchEntry {
 	return se
 }
 
+// SetupStatusbar creates a new gtk.Statusbar object and returns it.
+// It includes error handling.
+func SetupStatusbar() *gtk.Statusbar {
+	sb, err := gtk.StatusbarNew()
+	if err != nil {
+		log.Fatal("unable to create statusbar:", err)
+	}
+	return sb
+}
+
+// SetupTextView creates a new gtk.TextView object, sets its wrap mode
+// to wrap words, and returns it. It includes error handling.
+func SetupTextView() *gtk.TextView {
+	tv, err := gtk.TextViewNew()
+	if err != nil {
+		log.Fatal("unable to create text view:", err)
+	}
+	tv.SetWrapMode(gtk.WRAP_WORD)
+	return tv
+}
+
 // SetupToolbar creates a new gtk.Toolbar object and returns it.
 // It includes error handling.
 func SetupToolbar() *gtk.Toolbar {
@@ -1,11 +1,6 @@
 package view
 
 import (
-	"log"
-	"os"
-	"os/user"
-
-	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -14,9 +9,11 @@ import (
 type MainWindow struct {
 	Buttons        map[string]*gtk.ToolButton
 	Grid           *gtk.Grid
+	Lyrics         *gtk.TextView
 	ScrolledWindow *gtk.ScrolledWindow
 	SearchEntry    *gtk.SearchEntry
 	SongInfo       []*gtk.Label
+	Status         *gtk.Statusbar
 	TreeView       *TreeView
 	Win            *gtk.Window
 }
@@ -32,34 +29,33 @@ func SetupMainWindow() *MainWindow {
 	albumLabel := SetupLabel("\tAlbum")
 	artistLabel := SetupLabel("\tArtist\n\n\n")
 	titleLabel := SetupLabel("\n\n\n\tTitle\n\n\n")
+	lyrics := SetupTextView()
+	lyricsScroll := SetupScrolledWindow()
 	tb := SetupToolbar()
 	tb2 := SetupToolbar()
 	se := SetupSearchEntry()
 	edit := SetupToolButtonIcon("gtk-edit")
 	performers := SetupToolButtonIcon("gtk-open")
+	artwork := SetupToolButtonIcon("image-x-generic")
+	export := SetupToolButtonIcon("document-save")
+	importB := SetupToolButtonIcon("document-open")
+	backups := SetupToolButtonIcon("document-revert")
+	duplicates := SetupToolButtonIcon("edit-copy")
+	infer := SetupToolButtonIcon("edit-find-replace")
+	missing := SetupToolButtonIcon("dialog-warning")
+	replayGain := SetupToolButtonIcon("audio-volume-high")
 	new := SetupToolButtonIcon("gtk-new")
 	populate := SetupToolButtonIcon("gtk-refresh")
 	about := SetupToolButtonIcon("gtk-info")
 	treeview := NewTreeView()
 	scrwin := SetupScrolledWindow()
+	status := SetupStatusbar()
 	grid := SetupGrid(gtk.ORIENTATION_HORIZONTAL)
 	space1 := SetupLabel("                       ")
 	space2 := SetupLabel("                       ")
 	space3 := SetupLabel("                       ")
 
-	home, err := user.Current()
-	if err != nil {
-		log.Fatal("could not retrieve the current user:", err)
-	}
-	cache := home.HomeDir + "/.cache/rolas"
-	fileExists := true
-	if _, err := os.Stat(cache + "/noimage.png"); os.IsNotExist(err) {
-		fileExists = false
-	}
-	if !fileExists {
-		RestoreAsset(cache, "noimage.png")
-	}
-	pix, _ := gdk.PixbufNewFromFileAtScale(cache+"/noimage.png", 250, 250, true)
+	pix := NoImagePixbuf(250)
 	defaultImage, _ := gtk.ImageNewFromPixbuf(pix)
 
 	se.SetHExpand(true)
@@ -78,7 +74,15 @@ func SetupMainWindow() *MainWindow {
 	tb.Add(populate)
 	tb.Add(edit)
 	tb.Add(performers)
+	tb.Add(artwork)
 	tb.Add(new)
+	tb.Add(export)
+	tb.Add(importB)
+	tb.Add(backups)
+	tb.Add(duplicates)
+	tb.Add(infer)
+	tb.Add(missing)
+	tb.Add(replayGain)
 	tb.SetStyle(gtk.TOOLBAR_ICONS)
 
 	tb2.Add(about)
@@ -86,15 +90,29 @@ func SetupMainWindow() *MainWindow {
 	buttons["populate"] = populate
 	buttons["edit"] = edit
 	buttons["performers"] = performers
+	buttons["artwork"] = artwork
 	buttons["new"] = new
+	buttons["export"] = export
+	buttons["import"] = importB
+	buttons["backups"] = backups
+	buttons["duplicates"] = duplicates
+	buttons["infer"] = infer
+	buttons["missing"] = missing
+	buttons["replaygain"] = replayGain
 	buttons["about"] = about
 
 	box.Add(gridtop)
 	box.Add(scrwin)
 	box.Add(grid)
+	box.Add(status)
 
 	grid.Attach(defaultImage, 0, 0, 1, 1)
 	grid.Attach(boxinfo, 2, 0, 1, 1)
+	grid.Attach(lyricsScroll, 3, 0, 1, 1)
+
+	lyrics.SetEditable(false)
+	lyrics.SetCursorVisible(false)
+	lyricsScroll.Add(lyrics)
 
 	scrwin.SetVExpand(true)
 	scrwin.Add(treeview.TreeView)
@@ -108,9 +126,11 @@ func SetupMainWindow() *MainWindow {
 	return &MainWindow{
 		Buttons:        buttons,
 		Grid:           grid,
+		Lyrics:         lyrics,
 		ScrolledWindow: scrwin,
 		SearchEntry:    se,
 		SongInfo:       songInfo,
+		Status:         status,
 		TreeView:       treeview,
 		Win:            win,
 	}
@@ -0,0 +1,115 @@
+package view
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A MissingFiles represents the window used by the 'Missing files' button
+// in the main application window.   It lists the rolas whose files are
+// missing and, side by side, the files that may be the file of the
+// selected rola; the buttons look for them in another folder, relink the
+// selected rola to the selected file, relink every rola to its likeliest
+// file, and remove the selected rola or all of them from the library.
+type MissingFiles struct {
+	CandidatesLB *gtk.ListBox
+	RolasLB      *gtk.ListBox
+	RelinkB      *gtk.ToolButton
+	RelinkAllB   *gtk.ToolButton
+	RemoveB      *gtk.ToolButton
+	RemoveAllB   *gtk.ToolButton
+	SearchB      *gtk.ToolButton
+	Win          *gtk.Window
+}
+
+// MissingFilesWindow creates a MissingFiles and draws the corresponding
+// window.
+func MissingFilesWindow() *MissingFiles {
+	win := SetupPopupWindow("Missing files", 720, 420)
+	box := SetupBox()
+	grid := SetupGrid(gtk.ORIENTATION_HORIZONTAL)
+	rolasScroll := SetupScrolledWindow()
+	candidatesScroll := SetupScrolledWindow()
+	rolas := SetupListBox()
+	candidates := SetupListBox()
+	tb := SetupToolbar()
+	search := SetupToolButtonLabel("Search folder…")
+	relink := SetupToolButtonLabel("Relink")
+	relinkAll := SetupToolButtonLabel("Relink all")
+	remove := SetupToolButtonLabel("Remove")
+	removeAll := SetupToolButtonLabel("Remove all")
+
+	rolasScroll.SetVExpand(true)
+	rolasScroll.SetHExpand(true)
+	rolasScroll.Add(rolas)
+	candidatesScroll.SetVExpand(true)
+	candidatesScroll.SetHExpand(true)
+	candidatesScroll.Add(candidates)
+
+	grid.Add(rolasScroll)
+	grid.Add(candidatesScroll)
+	grid.SetColumnHomogeneous(true)
+
+	tb.Add(search)
+	tb.Add(relink)
+	tb.Add(relinkAll)
+	tb.Add(remove)
+	tb.Add(removeAll)
+	tb.SetHExpand(true)
+
+	box.Add(grid)
+	box.Add(tb)
+
+	win.Add(box)
+	win.ShowAll()
+
+	return &MissingFiles{
+		CandidatesLB: candidates,
+		RolasLB:      rolas,
+		RelinkB:      relink,
+		RelinkAllB:   relinkAll,
+		RemoveB:      remove,
+		RemoveAllB:   removeAll,
+		SearchB:      search,
+		Win:          win,
+	}
+}
+
+// SetRolas replaces the list of rolas with the given descriptions, and
+// empties the list of files.
+func (missing *MissingFiles) SetRolas(descriptions []string) {
+	clearListBox(missing.RolasLB)
+	clearListBox(missing.CandidatesLB)
+	if len(descriptions) == 0 {
+		missing.RolasLB.Add(SetupListBoxRowLabel("No missing files"))
+	}
+	for _, description := range descriptions {
+		missing.RolasLB.Add(SetupListBoxRowLabel(description))
+	}
+	missing.Win.ShowAll()
+}
+
+// SetCandidates replaces the list of files with the given descriptions,
+// and selects the first one, which is the likeliest.
+func (missing *MissingFiles) SetCandidates(descriptions []string) {
+	clearListBox(missing.CandidatesLB)
+	if len(descriptions) == 0 {
+		missing.CandidatesLB.Add(SetupListBoxRowLabel("No files found"))
+	}
+	for _, description := range descriptions {
+		missing.CandidatesLB.Add(SetupListBoxRowLabel(description))
+	}
+	missing.CandidatesLB.SelectRow(missing.CandidatesLB.GetRowAtIndex(0))
+	missing.Win.ShowAll()
+}
+
+// SelectedRola returns the index of the selected rola, or -1 if there is
+// no rola selected.
+func (missing *MissingFiles) SelectedRola() int {
+	return selectedIndex(missing.RolasLB)
+}
+
+// SelectedCandidate returns the index of the selected file, or -1 if
+// there is no file selected.
+func (missing *MissingFiles) SelectedCandidate() int {
+	return selectedIndex(missing.CandidatesLB)
+}
@@ -26,9 +26,9 @@ func NewPersonContent() *PersonContent {
 	realNameL := SetupLabel("Real name:")
 	realNameE := SetupEntry()
 	birthL := SetupLabel("Date of birth:")
-	birthE := SetupEntry()
+	birthE := SetupDateEntry()
 	deathL := SetupLabel("Date of death:")
-	deathE := SetupEntry()
+	deathE := SetupDateEntry()
 	cornerSE := SetupLabel("    ")
 
 	stageNameE.SetHExpand(true)
@@ -58,13 +58,17 @@ func NewPersonContent() *PersonContent {
 
 // AddToGroup represent the contents of the tab in the 'edit person'
 // window where a person can be added to a group (without the window).
-// It contains the combo box that shows the list of existing persons,
-// the list box to show the current members of the group and the grid
+// It contains the combo box that shows the list of existing groups,
+// the entries for the period and the roles of the person in the group,
+// the list box to show the current groups of the person and the grid
 // that holds them.
 type AddToGroup struct {
 	CurrentGroupLB *gtk.ListBox
 	grid           *gtk.Grid
+	JoinedE        *gtk.Entry
+	LeftE          *gtk.Entry
 	NewGroupCBT    *gtk.ComboBoxText
+	RolesE         *gtk.Entry
 }
 
 // NewAddToGroup creates an AddToGroup object, which is intended to be
@@ -75,24 +79,40 @@ func NewAddToGroup() *AddToGroup {
 	cornerNW := SetupLabel("    ")
 	currentGroupL := SetupLabel("Current Groups:")
 	currentGroupLB := SetupListBox()
-	newGroupL := SetupLabel("New Group:")
+	newGroupL := SetupLabel("Group:")
 	newGroupCBT := SetupComboBoxText()
+	joinedL := SetupLabel("Joined:")
+	joinedE := SetupDateEntry()
+	leftL := SetupLabel("Left:")
+	leftE := SetupDateEntry()
+	rolesL := SetupLabel("Roles:")
+	rolesE := SetupEntry()
 	cornerSE := SetupLabel("    ")
 
 	newGroupCBT.SetHExpand(true)
 	currentGroupL.SetVExpand(true)
+	rolesE.SetPlaceholderText("vocals, guitar, ...")
 
 	grid.Add(cornerNW)
-	grid.Attach(currentGroupL, 1, 2, 1, 1)
-	grid.Attach(currentGroupLB, 2, 2, 1, 1)
 	grid.Attach(newGroupL, 1, 1, 1, 1)
+	grid.Attach(joinedL, 1, 2, 1, 1)
+	grid.Attach(leftL, 1, 3, 1, 1)
+	grid.Attach(rolesL, 1, 4, 1, 1)
+	grid.Attach(currentGroupL, 1, 5, 1, 1)
 	grid.Attach(newGroupCBT, 2, 1, 1, 1)
-	grid.Attach(cornerSE, 3, 3, 1, 1)
+	grid.Attach(joinedE, 2, 2, 1, 1)
+	grid.Attach(leftE, 2, 3, 1, 1)
+	grid.Attach(rolesE, 2, 4, 1, 1)
+	grid.Attach(currentGroupLB, 2, 5, 1, 1)
+	grid.Attach(cornerSE, 3, 6, 1, 1)
 
 	return &AddToGroup{
 		CurrentGroupLB: currentGroupLB,
 		grid:           grid,
+		JoinedE:        joinedE,
+		LeftE:          leftE,
 		NewGroupCBT:    newGroupCBT,
+		RolesE:         rolesE,
 	}
 }
 
@@ -101,9 +121,13 @@ func NewAddToGroup() *AddToGroup {
 // relevant fields from an AddToGroup.
 type EditPerson struct {
 	CurrentGroupLB *gtk.ListBox
+	JoinedE        *gtk.Entry
+	LeftE          *gtk.Entry
 	NewGroupCBT    *gtk.ComboBoxText
 	Notebook       *gtk.Notebook
 	PersonContent  *PersonContent
+	RemoveB        *gtk.ToolButton
+	RolesE         *gtk.Entry
 	SaveB          *gtk.ToolButton
 	Win            *gtk.Window
 }
@@ -115,18 +139,21 @@ func EditPersonWindow() *EditPerson {
 	nb := SetupNotebook()
 	tb := SetupToolbar()
 	save := SetupToolButtonLabel("Save")
+	remove := SetupToolButtonLabel("Remove")
 
 	personContent := NewPersonContent()
 	addToGroup := NewAddToGroup()
 
 	save.SetExpand(true)
 	save.SetVExpand(true)
+	remove.SetSensitive(false)
 
 	tb.Add(save)
+	tb.Add(remove)
 	tb.SetHExpand(true)
 
 	nb.AppendPage(personContent.grid, SetupLabel("Edit Person"))
-	nb.AppendPage(addToGroup.grid, SetupLabel("Add to Group"))
+	nb.AppendPage(addToGroup.grid, SetupLabel("Groups"))
 
 	box.Add(nb)
 	box.Add(tb)
@@ -136,9 +163,13 @@ func EditPersonWindow() *EditPerson {
 
 	return &EditPerson{
 		CurrentGroupLB: addToGroup.CurrentGroupLB,
+		JoinedE:        addToGroup.JoinedE,
+		LeftE:          addToGroup.LeftE,
 		NewGroupCBT:    addToGroup.NewGroupCBT,
 		Notebook:       nb,
 		PersonContent:  personContent,
+		RemoveB:        remove,
+		RolesE:         addToGroup.RolesE,
 		SaveB:          save,
 		Win:            win,
 	}
@@ -0,0 +1,41 @@
+package view
+
+import (
+	"log"
+
+	"github.com/gotk3/gotk3/gdk"
+)
+
+// PixbufFromBytes creates a new gdk.Pixbuf from the contents of an image
+// file held in memory, without writing it to disk.
+func PixbufFromBytes(data []byte) (*gdk.Pixbuf, error) {
+	loader, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return nil, err
+	}
+	_, err = loader.Write(data)
+	if err != nil {
+		loader.Close()
+		return nil, err
+	}
+	err = loader.Close()
+	if err != nil {
+		return nil, err
+	}
+	return loader.GetPixbuf()
+}
+
+// NoImagePixbuf creates a new gdk.Pixbuf with the image shown for the
+// Rolas without a picture, scaled to a square of the given size.
+// It includes error handling.
+func NoImagePixbuf(size int) *gdk.Pixbuf {
+	pix, err := PixbufFromBytes(MustAsset("noimage.png"))
+	if err != nil {
+		log.Fatal("Unable to load the default image:", err)
+	}
+	scaled, err := pix.ScaleSimple(size, size, gdk.INTERP_BILINEAR)
+	if err != nil {
+		log.Fatal("Unable to scale the default image:", err)
+	}
+	return scaled
+}
@@ -13,16 +13,23 @@ type EditRola struct {
 }
 
 // RolaContent contains all the entries (gtk.Entry) used in the 'Edit Rola'
-// menu in the main application window, as well as the grid holding them
-// together.   It is meant to be used inside a gtk.Container.
+// menu in the main application window, the text view for the lyrics, as
+// well as the grid holding them together.   It is meant to be used inside
+// a gtk.Container.
 type RolaContent struct {
-	grid    *gtk.Grid
-	TitleE  *gtk.Entry
-	ArtistE *gtk.Entry
-	AlbumE  *gtk.Entry
-	GenreE  *gtk.Entry
-	TrackE  *gtk.Entry
-	YearE   *gtk.Entry
+	grid         *gtk.Grid
+	TitleE       *gtk.Entry
+	ArtistE      *gtk.Entry
+	AlbumE       *gtk.Entry
+	GenreE       *gtk.Entry
+	TrackE       *gtk.Entry
+	YearE        *gtk.Entry
+	DiscE        *gtk.Entry
+	AlbumArtistE *gtk.Entry
+	ComposerE    *gtk.Entry
+	BPME         *gtk.Entry
+	CommentE     *gtk.Entry
+	LyricsTV     *gtk.TextView
 }
 
 // NewRolaContent creates and returns a new RolaContent.
@@ -42,6 +49,19 @@ func NewRolaContent() *RolaContent {
 	trackE := SetupEntry()
 	yearL := SetupLabel("Year:")
 	yearE := SetupEntry()
+	discL := SetupLabel("Disc:")
+	discE := SetupEntry()
+	albumArtistL := SetupLabel("Album artist:")
+	albumArtistE := SetupEntry()
+	composerL := SetupLabel("Composer:")
+	composerE := SetupEntry()
+	bpmL := SetupLabel("BPM:")
+	bpmE := SetupEntry()
+	commentL := SetupLabel("Comment:")
+	commentE := SetupEntry()
+	lyricsL := SetupLabel("Lyrics:")
+	lyricsTV := SetupTextView()
+	lyricsSW := SetupScrolledWindow()
 	cornerSE := SetupLabel("    ")
 
 	titleE.SetHExpand(true)
@@ -50,6 +70,13 @@ func NewRolaContent() *RolaContent {
 	genreE.SetHExpand(true)
 	trackE.SetHExpand(true)
 	yearE.SetHExpand(true)
+	discE.SetHExpand(true)
+	albumArtistE.SetHExpand(true)
+	composerE.SetHExpand(true)
+	bpmE.SetHExpand(true)
+	commentE.SetHExpand(true)
+	lyricsSW.SetVExpand(true)
+	lyricsSW.Add(lyricsTV)
 
 	grid.Add(cornerNW)
 	grid.Attach(titleL, 1, 1, 1, 1)
@@ -64,24 +91,42 @@ func NewRolaContent() *RolaContent {
 	grid.Attach(genreE, 2, 4, 1, 1)
 	grid.Attach(trackE, 2, 5, 1, 1)
 	grid.Attach(yearE, 2, 6, 1, 1)
-
-	grid.Attach(cornerSE, 3, 7, 1, 1)
+	grid.Attach(discL, 1, 7, 1, 1)
+	grid.Attach(albumArtistL, 1, 8, 1, 1)
+	grid.Attach(composerL, 1, 9, 1, 1)
+	grid.Attach(bpmL, 1, 10, 1, 1)
+	grid.Attach(commentL, 1, 11, 1, 1)
+	grid.Attach(lyricsL, 1, 12, 1, 1)
+	grid.Attach(discE, 2, 7, 1, 1)
+	grid.Attach(albumArtistE, 2, 8, 1, 1)
+	grid.Attach(composerE, 2, 9, 1, 1)
+	grid.Attach(bpmE, 2, 10, 1, 1)
+	grid.Attach(commentE, 2, 11, 1, 1)
+	grid.Attach(lyricsSW, 2, 12, 1, 1)
+
+	grid.Attach(cornerSE, 3, 13, 1, 1)
 
 	return &RolaContent{
-		grid:    grid,
-		TitleE:  titleE,
-		ArtistE: artistE,
-		AlbumE:  albumE,
-		GenreE:  genreE,
-		TrackE:  trackE,
-		YearE:   yearE,
+		grid:         grid,
+		TitleE:       titleE,
+		ArtistE:      artistE,
+		AlbumE:       albumE,
+		GenreE:       genreE,
+		TrackE:       trackE,
+		YearE:        yearE,
+		DiscE:        discE,
+		AlbumArtistE: albumArtistE,
+		ComposerE:    composerE,
+		BPME:         bpmE,
+		CommentE:     commentE,
+		LyricsTV:     lyricsTV,
 	}
 }
 
 // EditRolaWindow creates an EditRola and draws the corresponding
 // window.
 func EditRolaWindow() *EditRola {
-	win := SetupPopupWindow("Edit Rola", 350, 216)
+	win := SetupPopupWindow("Edit Rola", 400, 520)
 	box := SetupBox()
 	tb := SetupToolbar()
 	save := SetupToolButtonLabel("Save")
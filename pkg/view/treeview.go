@@ -35,9 +35,18 @@ const (
 	COLUMN_PATH
 	COLUMN_VISIBLE
 	COLUMN_ID
+	COLUMN_DURATION
+	COLUMN_BITRATE
+	COLUMN_SAMPLE_RATE
+	COLUMN_CHANNELS
+	COLUMN_VBR
+	COLUMN_AVAILABLE
+	COLUMN_TRACK_GAIN
+	COLUMN_ALBUM_GAIN
 )
 
-// Add a column to the tree view (during the initialization of the tree view)
+// Add a column to the tree view (during the initialization of the tree view);
+// its cells are greyed out in the rows of the rolas whose files are missing
 func createColumn(title string, id int) *gtk.TreeViewColumn {
 	cellRenderer, err := gtk.CellRendererTextNew()
 	if err != nil {
@@ -48,6 +57,7 @@ func createColumn(title string, id int) *gtk.TreeViewColumn {
 	if err != nil {
 		log.Fatal("Unable to create cell column:", err)
 	}
+	column.AddAttribute(cellRenderer, "sensitive", COLUMN_AVAILABLE)
 
 	return column
 }
@@ -72,11 +82,20 @@ func setupTreeView() (*gtk.TreeView, *gtk.ListStore, *gtk.TreeModelFilter) {
 	treeView.AppendColumn(createColumn("Artist", COLUMN_ARTIST))
 	treeView.AppendColumn(createColumn("Album", COLUMN_ALBUM))
 	treeView.AppendColumn(createColumn("Genre", COLUMN_GENRE))
+	treeView.AppendColumn(createColumn("Duration", COLUMN_DURATION))
+	treeView.AppendColumn(createColumn("Bitrate", COLUMN_BITRATE))
+	treeView.AppendColumn(createColumn("Sample rate", COLUMN_SAMPLE_RATE))
+	treeView.AppendColumn(createColumn("Channels", COLUMN_CHANNELS))
+	treeView.AppendColumn(createColumn("Mode", COLUMN_VBR))
+	treeView.AppendColumn(createColumn("Track gain", COLUMN_TRACK_GAIN))
+	treeView.AppendColumn(createColumn("Album gain", COLUMN_ALBUM_GAIN))
 	treeView.AppendColumn(createInvisibleColumn("Path", COLUMN_PATH))
 	treeView.AppendColumn(createInvisibleColumn("Visible", COLUMN_VISIBLE))
 	treeView.AppendColumn(createInvisibleColumn("ID", COLUMN_ID))
 
-	listStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN, glib.TYPE_INT)
+	listStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN, glib.TYPE_INT,
+		glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN,
+		glib.TYPE_STRING, glib.TYPE_STRING)
 	if err != nil {
 		log.Fatal("Unable to create list store:", err)
 	}